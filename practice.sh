@@ -0,0 +1,17 @@
+#!/bin/bash
+
+# Runs the local practice commands of the web UI:
+#   ./practice.sh init <challenge>      copy a challenge's template into your submissions directory
+#   ./practice.sh test [challenge]      run the tests against your submission (-race, -run Pattern)
+#   ./practice.sh status                list your submissions and scoreboard rows
+#   ./practice.sh submit [challenge]    grade your submission as the scoreboards will and commit it (-commit)
+# Challenges are named 1, challenge-1 or gin/challenge-1-basic-routing, and
+# default to the challenge of the current directory. Your GitHub username is
+# read from git; pass -user to set it. Add -h to a command for all its flags.
+
+ROOT="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
+BIN="$ROOT/web-ui/.bin/web-ui"
+
+# Rebuilding is a no-op when the web UI has not changed
+go -C "$ROOT/web-ui" build -o "$BIN" . || exit 1
+exec "$BIN" "$@"
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"web-ui/internal/config"
+	"web-ui/internal/modproxy"
+	"web-ui/internal/sandbox"
+	"web-ui/internal/services"
+)
+
+// runCommand runs a subcommand given as the first argument, e.g.
+// `go run . import-modules`. It reports false when args hold no subcommand.
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "import-modules":
+		if err := importModulesCommand(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "import-modules: %v\n", err)
+			os.Exit(1)
+		}
+		return true
+	case "grade":
+		if err := gradeCommand(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "grade: %v\n", err)
+			os.Exit(1)
+		}
+		return true
+	case "grade-diff":
+		if err := gradeDiffCommand(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "grade-diff: %v\n", err)
+			os.Exit(1)
+		}
+		return true
+	case "init":
+		if err := initCommand(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "init: %v\n", err)
+			os.Exit(1)
+		}
+		return true
+	case "test":
+		if err := testCommand(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "test: %v\n", err)
+			os.Exit(1)
+		}
+		return true
+	case "status":
+		if err := statusCommand(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "status: %v\n", err)
+			os.Exit(1)
+		}
+		return true
+	case "submit":
+		if err := submitCommand(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "submit: %v\n", err)
+			os.Exit(1)
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// importModulesCommand seeds the offline module proxy from the go.mod and
+// go.sum files of the challenges
+func importModulesCommand(args []string) error {
+	fs := flag.NewFlagSet("import-modules", flag.ExitOnError)
+	cfg := config.Default()
+	cfg.RegisterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: web-ui import-modules [flags] [module dir ...]\n\n")
+		fmt.Fprintf(fs.Output(), "Downloads the dependencies of every challenge (or of the given directories)\n")
+		fmt.Fprintf(fs.Output(), "into the module store. Needs internet access; runs afterwards do not.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := cfg.Parse(fs, args); err != nil {
+		return err
+	}
+
+	moduleDirs := fs.Args()
+	if len(moduleDirs) == 0 {
+		var err error
+		moduleDirs, err = modproxy.FindModuleDirs(cfg.RepoRoot)
+		if err != nil {
+			return err
+		}
+		if len(moduleDirs) == 0 {
+			return fmt.Errorf("no challenge go.mod files found under %s", cfg.RepoRoot)
+		}
+	}
+
+	result, err := modproxy.Import(context.Background(), cfg.ModulesDir, moduleDirs, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d module versions into %s\n", result.Downloaded, cfg.ModulesDir)
+	if len(result.Failed) > 0 {
+		fmt.Printf("%d module versions could not be downloaded:\n", len(result.Failed))
+		for _, failure := range result.Failed {
+			fmt.Printf("  %s\n", failure)
+		}
+	}
+	return nil
+}
+
+// newExecutionService creates an execution service with the configured
+// isolation mode and limits
+func newExecutionService(cfg *config.Config) (*services.ExecutionService, error) {
+	isolation, err := sandbox.ParseIsolation(cfg.Isolation)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -isolation: %v", err)
+	}
+
+	executionService := services.NewExecutionService()
+	executionService.SetIsolation(isolation)
+	executionService.SetLimits(sandbox.Limits{
+		WallTime:       time.Duration(cfg.Limits.TestTimeout),
+		CPUTime:        time.Duration(cfg.Limits.CPUTime),
+		MemoryBytes:    uint64(cfg.Limits.MemoryMB) << 20,
+		MaxProcesses:   uint64(cfg.Limits.MaxProcesses),
+		MaxOutputBytes: int64(cfg.Limits.MaxOutputKB) << 10,
+	}, time.Duration(cfg.Limits.BuildTimeout))
+	return executionService, nil
+}
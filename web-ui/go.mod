@@ -1,3 +1,5 @@
 module web-ui
 
 go 1.21
+
+require golang.org/x/crypto v0.33.0
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"web-ui/internal/config"
+	"web-ui/internal/leaderboard"
+	"web-ui/internal/modproxy"
+	"web-ui/internal/scoreboard"
+	"web-ui/internal/services"
+)
+
+// gradeCommand grades the committed submissions with the execution service
+// and regenerates the challenge scoreboards and the README leaderboards
+func gradeCommand(args []string) error {
+	fs := flag.NewFlagSet("grade", flag.ExitOnError)
+	cfg := config.Default()
+	cfg.RegisterFlags(fs)
+	check := fs.Bool("check", false, "only report scoreboards and leaderboards that are stale; exit with an error if any are")
+	readmePath := fs.String("readme", "", "README holding the classic and package leaderboards (default: README.md in the repository root)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: web-ui grade [flags] [challenge ...]\n\n")
+		fmt.Fprintf(fs.Output(), "Runs every submission in challenge-*/submissions and packages/*/*/submissions,\n")
+		fmt.Fprintf(fs.Output(), "rewrites each SCOREBOARD.md from the results and regenerates the README\n")
+		fmt.Fprintf(fs.Output(), "leaderboards. Challenges may be limited by name, e.g. challenge-1 or gin or\n")
+		fmt.Fprintf(fs.Output(), "gin/challenge-1-basic-routing; the others keep their scoreboards.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := cfg.Parse(fs, args); err != nil {
+		return err
+	}
+	if *readmePath == "" {
+		*readmePath = filepath.Join(cfg.RepoRoot, "README.md")
+	}
+
+	grader, err := newGrader(cfg)
+	if err != nil {
+		return err
+	}
+	challenges, err := grader.Challenges()
+	if err != nil {
+		return err
+	}
+	selected, err := selectChallenges(challenges, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	var targets []services.GradeTarget
+	for _, challenge := range challenges {
+		if !selected[challenge.String()] {
+			continue
+		}
+		submissions, err := grader.Submissions(challenge)
+		if err != nil {
+			return fmt.Errorf("%s: %v", challenge, err)
+		}
+		targets = append(targets, submissions...)
+	}
+
+	fmt.Printf("Grading %d submissions with %d workers\n", len(targets), cfg.Workers)
+	done := 0
+	results := grader.Grade(context.Background(), targets, cfg.Workers, func(result services.GradeResult) {
+		done++
+		fmt.Printf("[%d/%d] %-4s %s %d/%d (%s)\n", done, len(targets), gradeLabel(result.Entry), result.GradeTarget,
+			result.Entry.Passed, result.Entry.Total, result.Result.Status)
+	})
+
+	// Every scoreboard is regenerated from its graded submissions; the
+	// scoreboards of challenges that were not selected are read as they are
+	byChallenge := make(map[string][]scoreboard.Entry)
+	for _, result := range results {
+		key := result.ChallengeRef.String()
+		byChallenge[key] = append(byChallenge[key], result.Entry)
+	}
+	boards := make(map[string]*scoreboard.Scoreboard)
+	var stale []string
+	for _, challenge := range challenges {
+		current, readErr := ioutil.ReadFile(challenge.ScoreboardPath())
+		if !selected[challenge.String()] {
+			if readErr == nil {
+				boards[challenge.String()], err = scoreboard.Parse(current)
+				if err != nil {
+					return fmt.Errorf("%s: %v", challenge.ScoreboardPath(), err)
+				}
+			}
+			continue
+		}
+
+		if readErr != nil {
+			current = nil
+		}
+		board := gradedScoreboard(challenge, byChallenge[challenge.String()], current)
+		boards[challenge.String()] = board
+
+		formatted := scoreboard.Format(board)
+		if readErr == nil && bytes.Equal(formatted, current) {
+			continue
+		}
+		stale = append(stale, challenge.ScoreboardPath())
+		if !*check {
+			if err := ioutil.WriteFile(challenge.ScoreboardPath(), formatted, 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The README leaderboards aggregate every scoreboard
+	readme, err := ioutil.ReadFile(*readmePath)
+	if err != nil {
+		return err
+	}
+	updated, err := updateLeaderboards(string(readme), challenges, boards)
+	if err != nil {
+		return err
+	}
+	if updated != string(readme) {
+		stale = append(stale, *readmePath)
+		if !*check {
+			if err := ioutil.WriteFile(*readmePath, []byte(updated), 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	if *check {
+		if len(stale) > 0 {
+			fmt.Println("Out of date:")
+			for _, path := range stale {
+				fmt.Printf("  %s\n", path)
+			}
+			return fmt.Errorf("%d files are out of date; run `go run . grade` to regenerate them", len(stale))
+		}
+		fmt.Println("All scoreboards are up to date")
+		return nil
+	}
+	fmt.Printf("Updated %d files\n", len(stale))
+	return nil
+}
+
+// gradedScoreboard builds the scoreboard of a challenge from the entries of
+// its graded submissions. The title of the current scoreboard, if there is
+// one, is kept but none of its rows: users without a graded submission are
+// left out. Usernames are told apart without case, as on GitHub; of a user's
+// submissions the best one is kept.
+func gradedScoreboard(challenge services.ChallengeRef, entries []scoreboard.Entry, current []byte) *scoreboard.Scoreboard {
+	board := &scoreboard.Scoreboard{Title: challenge.ScoreboardTitle(), Entries: []scoreboard.Entry{}}
+	if existing, err := scoreboard.Parse(current); err == nil && existing.Title != "" {
+		board.Title = existing.Title
+	}
+	for _, entry := range entries {
+		kept := false
+		for i, other := range board.Entries {
+			if strings.EqualFold(other.Username, entry.Username) {
+				if entry.Beats(other) {
+					board.Entries[i] = entry
+				}
+				kept = true
+				break
+			}
+		}
+		if !kept {
+			board.Entries = append(board.Entries, entry)
+		}
+	}
+	board.Sort()
+	return board
+}
+
+// updateLeaderboards regenerates the classic and package leaderboards of a
+// README from the scoreboards of the challenges by name; a challenge without
+// a scoreboard has none in boards
+func updateLeaderboards(readme string, challenges []services.ChallengeRef, boards map[string]*scoreboard.Scoreboard) (string, error) {
+	classic := make(map[int]*scoreboard.Scoreboard)
+	packages := make(map[string]map[string]*scoreboard.Scoreboard)
+	for _, challenge := range challenges {
+		board := boards[challenge.String()]
+		if challenge.PackageName == "" {
+			classic[challenge.ChallengeID] = board
+			continue
+		}
+		if packages[challenge.PackageName] == nil {
+			packages[challenge.PackageName] = make(map[string]*scoreboard.Scoreboard)
+		}
+		packages[challenge.PackageName][challenge.PackageChallengeID] = board
+	}
+	updated, err := leaderboard.ReplaceSection(readme, leaderboard.ClassicHeading, leaderboard.ClassicEnd, leaderboard.Classic(classic))
+	if err != nil {
+		return "", err
+	}
+	return leaderboard.ReplaceSection(updated, leaderboard.PackageHeading, leaderboard.PackageEnd, leaderboard.Packages(packages))
+}
+
+// newGrader loads the challenges and sets up the execution service for
+// grading, serving the offline module store if it has modules
+func newGrader(cfg *config.Config) (*services.GradingService, error) {
+	challengeService := services.NewChallengeService(cfg.RepoRoot)
+	if err := challengeService.LoadChallenges(); err != nil {
+		return nil, err
+	}
+	executionService, err := newExecutionService(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := executionService.Isolation(); err != nil {
+		log.Printf("Warning: namespace isolation unavailable: %v", err)
+	}
+
+	moduleProxy := modproxy.NewProxy(cfg.ModulesDir)
+	if modules := moduleProxy.Modules(); cfg.Features.ModuleProxy && len(modules) > 0 {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, fmt.Errorf("failed to serve the module proxy: %v", err)
+		}
+		go http.Serve(listener, http.StripPrefix("/goproxy", moduleProxy))
+		executionService.SetModuleProxy(fmt.Sprintf("http://%s/goproxy", listener.Addr()), modules)
+	}
+
+	return services.NewGradingService(challengeService, services.NewPackageService(cfg.PackagesDir), executionService), nil
+}
+
+// selectChallenges returns the names of the challenges matching the given
+// names: a challenge name, or a package for all of its challenges. No names
+// select every challenge.
+func selectChallenges(challenges []services.ChallengeRef, names []string) (map[string]bool, error) {
+	selected := make(map[string]bool)
+	for _, challenge := range challenges {
+		if len(names) == 0 {
+			selected[challenge.String()] = true
+		}
+	}
+	for _, name := range names {
+		name = strings.Trim(name, "/")
+		found := false
+		for _, challenge := range challenges {
+			if challenge.String() == name || challenge.PackageName == name {
+				selected[challenge.String()] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown challenge %q", name)
+		}
+	}
+	return selected, nil
+}
+
+// gradeLabel labels a graded submission in the progress output
+func gradeLabel(entry scoreboard.Entry) string {
+	if entry.Completed() {
+		return "PASS"
+	}
+	return "FAIL"
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"web-ui/internal/scoreboard"
+	"web-ui/internal/services"
+)
+
+func TestGradedScoreboard(t *testing.T) {
+	classic := services.ChallengeRef{ChallengeID: 3}
+	gin := services.ChallengeRef{PackageName: "gin", PackageChallengeID: "challenge-1-basic-routing"}
+	existing := "# Scoreboard for Challenge 3: Employees\n" +
+		"| Username   | Passed Tests | Total Tests |\n" +
+		"|------------|--------------|-------------|\n" +
+		"| dave | 6 | 6 |\n" +
+		"| bob | 2 | 6 |\n"
+	tests := []struct {
+		name      string
+		challenge services.ChallengeRef
+		entries   []scoreboard.Entry
+		current   string
+		want      string
+	}{
+		{
+			name:      "new scoreboard",
+			challenge: classic,
+			entries:   []scoreboard.Entry{{Username: "bob", Passed: 4, Total: 6}, {Username: "alice", Passed: 6, Total: 6}, {Username: "carol", Passed: 4, Total: 6}},
+			want: "# Scoreboard for challenge-3\n" +
+				"| Username   | Passed Tests | Total Tests |\n" +
+				"|------------|--------------|-------------|\n" +
+				"| alice | 6 | 6 |\n" +
+				"| bob | 4 | 6 |\n" +
+				"| carol | 4 | 6 |\n",
+		},
+		{
+			// The title stays, the rows are replaced and dave, who has no
+			// submission anymore, leaves
+			name:      "existing scoreboard",
+			challenge: classic,
+			entries:   []scoreboard.Entry{{Username: "bob", Passed: 5, Total: 6}},
+			current:   existing,
+			want: "# Scoreboard for Challenge 3: Employees\n" +
+				"| Username   | Passed Tests | Total Tests |\n" +
+				"|------------|--------------|-------------|\n" +
+				"| bob | 5 | 6 |\n",
+		},
+		{
+			// The submission that completed the challenge counts, not the
+			// one passing more tests
+			name:      "one user in two directories",
+			challenge: gin,
+			entries:   []scoreboard.Entry{{Username: "Alice", Passed: 9, Total: 10}, {Username: "alice", Passed: 3, Total: 3}, {Username: "ALICE", Passed: 1, Total: 3}},
+			want: "# Scoreboard for gin challenge-1-basic-routing\n" +
+				"| Username   | Passed Tests | Total Tests |\n" +
+				"|------------|--------------|-------------|\n" +
+				"| alice | 3 | 3 |\n",
+		},
+		{
+			name:      "no submissions",
+			challenge: classic,
+			current:   existing,
+			want: "# Scoreboard for Challenge 3: Employees\n" +
+				"| Username   | Passed Tests | Total Tests |\n" +
+				"|------------|--------------|-------------|\n",
+		},
+		{
+			name:      "unreadable scoreboard",
+			challenge: classic,
+			entries:   []scoreboard.Entry{{Username: "bob", Passed: 5, Total: 6}},
+			current:   "# Old scoreboard\n| Rank | Score |\n|---|---|\n",
+			want: "# Scoreboard for challenge-3\n" +
+				"| Username   | Passed Tests | Total Tests |\n" +
+				"|------------|--------------|-------------|\n" +
+				"| bob | 5 | 6 |\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var current []byte
+			if tt.current != "" {
+				current = []byte(tt.current)
+			}
+			board := gradedScoreboard(tt.challenge, tt.entries, current)
+			if got := string(scoreboard.Format(board)); got != tt.want {
+				t.Errorf("scoreboard:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateLeaderboards(t *testing.T) {
+	ref := func(id int) services.ChallengeRef { return services.ChallengeRef{ChallengeID: id} }
+	pkg := func(id string) services.ChallengeRef {
+		return services.ChallengeRef{PackageName: "go-redis", PackageChallengeID: id}
+	}
+	board := func(entries ...scoreboard.Entry) *scoreboard.Scoreboard {
+		return &scoreboard.Scoreboard{Entries: entries}
+	}
+	challenges := []services.ChallengeRef{ref(1), ref(2), ref(3), pkg("challenge-1"), pkg("challenge-2")}
+	boards := map[string]*scoreboard.Scoreboard{
+		"challenge-1": board(scoreboard.Entry{Username: "alice", Passed: 3, Total: 3}, scoreboard.Entry{Username: "bob", Passed: 2, Total: 3}),
+		"challenge-2": board(scoreboard.Entry{Username: "alice", Passed: 4, Total: 4}),
+		// challenge-3 has no scoreboard
+		"go-redis/challenge-1": board(scoreboard.Entry{Username: "alice", Passed: 5, Total: 5}, scoreboard.Entry{Username: "carol", Passed: 5, Total: 5}),
+		"go-redis/challenge-2": board(scoreboard.Entry{Username: "carol", Passed: 2, Total: 2}),
+	}
+	readme := "# Challenges\n\nIntro\n\n" +
+		"## 🏆 Top 10 Leaderboard\n\nstale\n<!-- END_CLASSIC_LEADERBOARD -->\n" +
+		"## 🚀 Package Challenges Leaderboard\n\nstale\n<!-- END_PACKAGE_LEADERBOARD -->\n\n## Contributing\n"
+
+	updated, err := updateLeaderboards(readme, challenges, boards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := func(username string) string {
+		return `<img src="https://github.com/` + username + `.png" width="24" height="24" style="border-radius: 50%;"><br/>**[` + username + `](https://github.com/` + username + `)**`
+	}
+	// bob completed nothing and is not ranked; the progress shows two rows
+	// of challenges
+	for _, want := range []string{
+		"# Challenges\n\nIntro\n\n## 🏆 Top 10 Leaderboard\n",
+		"| 🥇 | " + profile("alice") + " | **2**/3 | **66.7%** | Beginner | ✅✅<br/>⬜ |\n\n",
+		"- **Total Challenges Available**: 3\n- **Active Developers**: 1\n- **Most Challenges Solved**: 2 by alice\n\n<!-- END_CLASSIC_LEADERBOARD -->\n## 🚀 Package Challenges Leaderboard\n",
+		"| 🥇 | " + profile("carol") + " | **2** | **1** pkg | 🌱 Package Beginner | **go-redis**: 2 |\n" +
+			"| 🥈 | " + profile("alice") + " | **1** | **1** pkg | 🌱 Package Beginner | **go-redis**: 1 |\n",
+		"#### Go-Redis Package\n",
+		"| 🥇 | **[carol](https://github.com/carol)** | 2/2 | 🟩🟩🟩🟩🟩🟩🟩🟩🟩🟩 100% |\n" +
+			"| 🥈 | **[alice](https://github.com/alice)** | 1/2 | 🟩🟩🟩🟩🟩⬜⬜⬜⬜⬜ 50% |\n",
+		"- **Available Packages**: 1 (go-redis)\n",
+		"<!-- END_PACKAGE_LEADERBOARD -->\n\n## Contributing\n",
+	} {
+		if !strings.Contains(updated, want) {
+			t.Errorf("leaderboards do not contain\n%s\ngot:\n%s", want, updated)
+		}
+	}
+	if strings.Contains(updated, "stale") || strings.Contains(updated, "[bob]") {
+		t.Errorf("leaderboards keep stale content or rank bob:\n%s", updated)
+	}
+
+	again, err := updateLeaderboards(updated, challenges, boards)
+	if err != nil || again != updated {
+		t.Errorf("regenerating the leaderboards changed them: %v", err)
+	}
+	if _, err := updateLeaderboards("# No leaderboards\n", challenges, boards); err == nil {
+		t.Errorf("a README without leaderboard sections was accepted")
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"web-ui/internal/config"
+	"web-ui/internal/scoreboard"
+	"web-ui/internal/services"
+)
+
+// gradeDiffCommand grades the submissions changed between two git refs,
+// updates their scoreboard rows and prints a Markdown summary for a pull
+// request. Changes to another user's submissions fail the command.
+func gradeDiffCommand(args []string) error {
+	fs := flag.NewFlagSet("grade-diff", flag.ExitOnError)
+	base := fs.String("base", "", "git ref the changes are compared against, e.g. origin/main (required)")
+	head := fs.String("head", "HEAD", "git ref holding the changes")
+	author := fs.String("author", "", "GitHub username of the author; only their submissions may change (default: the changes may touch one user's submissions)")
+	cfg := config.Default()
+	cfg.RegisterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: web-ui grade-diff -base ref [-head ref] [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Grades the submissions changed between base and head (as in git diff base...head),\n")
+		fmt.Fprintf(fs.Output(), "updates their rows in the SCOREBOARD.md files and prints a Markdown summary.\n")
+		fmt.Fprintf(fs.Output(), "Fails if the changes touch the submissions of another user than the author.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := cfg.Parse(fs, args); err != nil {
+		return err
+	}
+	if *base == "" {
+		fs.Usage()
+		return fmt.Errorf("-base is required")
+	}
+
+	root, err := gitRoot(cfg.RepoRoot)
+	if err != nil {
+		return err
+	}
+	changed, err := gitOutput(root, "diff", "--name-only", "--no-renames", "-z", *base+"..."+*head)
+	if err != nil {
+		return err
+	}
+
+	grader, err := newGrader(cfg)
+	if err != nil {
+		return err
+	}
+	challenges, err := grader.Challenges()
+	if err != nil {
+		return err
+	}
+
+	// Ownership is checked before anything is graded or written
+	targets, violations := changedSubmissions(challenges, root, strings.Split(strings.TrimRight(changed, "\x00"), "\x00"), *author)
+	if len(violations) > 0 {
+		fmt.Printf("### ⚠️ Path ownership violations in `%s...%s`\n\n", *base, *head)
+		for _, violation := range violations {
+			fmt.Printf("- %s\n", violation)
+		}
+		return fmt.Errorf("%d path ownership violations", len(violations))
+	}
+
+	// Submissions are graded as they are in head; a removed one leaves the scoreboard
+	var graded []services.GradeTarget
+	removed := make(map[string]bool)
+	for _, target := range targets {
+		path, err := services.RepoPath(root, target.Path)
+		if err != nil {
+			return err
+		}
+		code, err := gitOutput(root, "show", *head+":"+path)
+		if err != nil {
+			removed[target.String()] = true
+			continue
+		}
+		target.Code = code
+		graded = append(graded, target)
+	}
+
+	fmt.Fprintf(os.Stderr, "Grading %d changed submissions with %d workers\n", len(graded), cfg.Workers)
+	results := grader.Grade(context.Background(), graded, cfg.Workers, func(result services.GradeResult) {
+		fmt.Fprintf(os.Stderr, "%-4s %s %d/%d (%s)\n", gradeLabel(result.Entry), result.GradeTarget,
+			result.Entry.Passed, result.Entry.Total, result.Result.Status)
+	})
+	byTarget := make(map[string]services.GradeResult)
+	for _, result := range results {
+		byTarget[result.GradeTarget.String()] = result
+	}
+
+	// Only the rows of the changed submissions are updated
+	var rows []string
+	boards := make(map[string]*scoreboard.Scoreboard)
+	updated := make(map[string]bool)
+	for _, target := range targets {
+		challenge := target.ChallengeRef
+		board, ok := boards[challenge.String()]
+		if !ok {
+			board, err = scoreboard.ReadFile(challenge.ScoreboardPath())
+			if os.IsNotExist(err) {
+				board, err = &scoreboard.Scoreboard{Title: challenge.ScoreboardTitle()}, nil
+			}
+			if err != nil {
+				return err
+			}
+			boards[challenge.String()] = board
+		}
+
+		previous, existed := board.Find(target.Username)
+		before := "new"
+		if existed {
+			before = fmt.Sprintf("was %d/%d", previous.Passed, previous.Total)
+		}
+		if removed[target.String()] {
+			if board.Remove(target.Username) {
+				updated[challenge.String()] = true
+			} else {
+				before = "not listed"
+			}
+			rows = append(rows, fmt.Sprintf("| %s | %s | 🗑️ Removed | - | %s |", challenge, target.Username, before))
+			continue
+		}
+		result := byTarget[target.String()]
+		if !existed || previous != result.Entry {
+			board.Upsert(result.Entry)
+			updated[challenge.String()] = true
+		}
+		outcome := "✅ Passed"
+		if !result.Entry.Completed() {
+			outcome = fmt.Sprintf("❌ Failed (%s)", result.Result.Status)
+		}
+		if existed && previous.Passed == result.Entry.Passed && previous.Total == result.Entry.Total {
+			before = "unchanged"
+		}
+		rows = append(rows, fmt.Sprintf("| %s | %s | %s | %d/%d | %s |", challenge, target.Username, outcome, result.Entry.Passed, result.Entry.Total, before))
+	}
+	for _, challenge := range challenges {
+		if !updated[challenge.String()] {
+			continue
+		}
+		board := boards[challenge.String()]
+		board.Sort()
+		if err := ioutil.WriteFile(challenge.ScoreboardPath(), scoreboard.Format(board), 0644); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("### Grading results for `%s...%s`\n\n", *base, *head)
+	if len(rows) == 0 {
+		fmt.Println("No submissions changed.")
+	} else {
+		fmt.Println("| Challenge | User | Result | Tests | Scoreboard |")
+		fmt.Println("|-----------|------|--------|-------|------------|")
+		for _, row := range rows {
+			fmt.Println(row)
+		}
+	}
+	return nil
+}
+
+// changedSubmissions maps changed paths, relative to the repository root, to
+// the submissions holding them. It also returns the path ownership
+// violations among them: a change to the submissions of another user than
+// author, or without an author, changes to the submissions of more than one
+// user.
+func changedSubmissions(challenges []services.ChallengeRef, root string, paths []string, author string) ([]services.GradeTarget, []string) {
+	var targets []services.GradeTarget
+	seen := make(map[string]bool)
+	var violations []string
+	users := make(map[string]bool)
+	for _, path := range paths {
+		challenge, username, ok := services.SubmissionOwner(challenges, root, path)
+		if !ok {
+			continue
+		}
+		users[username] = true
+		if author != "" && !strings.EqualFold(username, author) {
+			violations = append(violations, fmt.Sprintf("`%s` is in the submissions of %s, not of %s", path, username, author))
+		}
+		target := services.SubmissionTarget(challenge, username)
+		if !seen[target.String()] {
+			seen[target.String()] = true
+			targets = append(targets, target)
+		}
+	}
+	if author == "" && len(users) > 1 {
+		var names []string
+		for username := range users {
+			names = append(names, username)
+		}
+		sort.Strings(names)
+		violations = append(violations, fmt.Sprintf("the changes touch the submissions of %d users: %s", len(names), strings.Join(names, ", ")))
+	}
+	return targets, violations
+}
+
+// gitRoot returns the top-level directory of the git repository holding dir
+func gitRoot(dir string) (string, error) {
+	toplevel, err := gitOutput(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(toplevel), nil
+}
+
+// gitOutput runs a git command in dir and returns its output
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return string(output), nil
+}
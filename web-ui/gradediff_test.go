@@ -0,0 +1,84 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"web-ui/internal/services"
+)
+
+func TestChangedSubmissions(t *testing.T) {
+	root := t.TempDir()
+	challenges := []services.ChallengeRef{
+		{ChallengeID: 1, Dir: filepath.Join(root, "challenge-1")},
+		{ChallengeID: 2, Dir: filepath.Join(root, "challenge-2")},
+		{PackageName: "gin", PackageChallengeID: "challenge-1-basic-routing", Dir: filepath.Join(root, "packages", "gin", "challenge-1-basic-routing")},
+	}
+	tests := []struct {
+		name       string
+		paths      []string
+		author     string
+		targets    []string
+		violations []string
+	}{
+		{
+			name:    "own classic and package submissions",
+			paths:   []string{"challenge-1/submissions/alice/solution-template.go", "packages/gin/challenge-1-basic-routing/submissions/alice/solution.go", "README.md"},
+			author:  "alice",
+			targets: []string{"challenge-1/alice", "gin/challenge-1-basic-routing/alice"},
+		},
+		{
+			name:   "no submissions",
+			paths:  []string{"challenge-1/README.md", "web-ui/main.go", ""},
+			author: "alice",
+		},
+		{
+			// Without rename detection a renamed file is a removed and an added path
+			name:    "renamed within own submissions",
+			paths:   []string{"challenge-1/submissions/alice/solution-template.go", "challenge-2/submissions/alice/solution-template.go"},
+			author:  "Alice",
+			targets: []string{"challenge-1/alice", "challenge-2/alice"},
+		},
+		{
+			name:       "renamed from another user",
+			paths:      []string{"challenge-1/submissions/bob/solution-template.go", "challenge-1/submissions/alice/solution-template.go"},
+			author:     "alice",
+			targets:    []string{"challenge-1/bob", "challenge-1/alice"},
+			violations: []string{"`challenge-1/submissions/bob/solution-template.go` is in the submissions of bob, not of alice"},
+		},
+		{
+			name:       "another user's file",
+			paths:      []string{"packages/gin/challenge-1-basic-routing/submissions/bob/solution.go"},
+			author:     "alice",
+			targets:    []string{"gin/challenge-1-basic-routing/bob"},
+			violations: []string{"`packages/gin/challenge-1-basic-routing/submissions/bob/solution.go` is in the submissions of bob, not of alice"},
+		},
+		{
+			name:    "one user without an author",
+			paths:   []string{"challenge-1/submissions/bob/solution-template.go", "challenge-1/submissions/bob/notes.md"},
+			targets: []string{"challenge-1/bob"},
+		},
+		{
+			name:       "several users without an author",
+			paths:      []string{"challenge-1/submissions/bob/solution-template.go", "challenge-2/submissions/alice/solution-template.go"},
+			targets:    []string{"challenge-1/bob", "challenge-2/alice"},
+			violations: []string{"the changes touch the submissions of 2 users: alice, bob"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, violations := changedSubmissions(challenges, root, tt.paths, tt.author)
+			var names []string
+			for _, target := range targets {
+				names = append(names, target.String())
+			}
+			if !reflect.DeepEqual(names, tt.targets) {
+				t.Errorf("targets = %q, want %q", names, tt.targets)
+			}
+			if !reflect.DeepEqual(violations, tt.violations) {
+				t.Errorf("violations = %q, want %q", violations, tt.violations)
+			}
+		})
+	}
+}
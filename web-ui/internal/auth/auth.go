@@ -0,0 +1,288 @@
+// Package auth identifies the users of the web UI: local accounts with
+// bcrypt passwords, OAuth2 login providers such as GitHub, and the server-side
+// sessions both sign users in with.
+//
+// Accounts and sessions are kept in files in the data directory, so they
+// survive restarts. A session cookie holds a random token; only its SHA-256
+// hash is stored.
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Files in the data directory
+const (
+	usersFileName    = "users.json"
+	sessionsFileName = "sessions.json"
+)
+
+// DefaultSessionTTL is how long a session lasts unless SetSessionTTL changes it
+const DefaultSessionTTL = 7 * 24 * time.Hour
+
+// Errors of signing in and creating accounts
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUsernameTaken      = errors.New("username is already taken")
+	ErrInvalidUsername    = errors.New("usernames are up to 39 letters, digits and hyphens, not starting with a hyphen, like GitHub's")
+	ErrSignupDisabled     = errors.New("creating accounts is turned off on this server")
+	ErrUsernameReserved   = errors.New("usernames belong to GitHub accounts on this server; sign in with GitHub")
+	ErrPasswordLength     = fmt.Errorf("passwords need %d to %d characters", MinPasswordLength, MaxPasswordLength)
+)
+
+// usernamePattern matches the usernames GitHub allows. Usernames name the
+// submission directories, so nothing else is accepted.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,38})$`)
+
+// ValidUsername reports whether name is a username GitHub allows
+func ValidUsername(name string) bool {
+	return usernamePattern.MatchString(name)
+}
+
+// User is an account of the web UI
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"passwordHash,omitempty"` // bcrypt; empty for provider accounts
+	Provider     string    `json:"provider,omitempty"`     // The login provider of the account, e.g. "github"
+	ProviderID   string    `json:"providerId,omitempty"`   // The user's ID at the provider, stable across renames
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Service keeps the accounts and sessions and signs users in
+type Service struct {
+	dir        string
+	required   bool
+	signup     bool
+	sessionTTL time.Duration
+	publicURL  string
+	adminToken string // SHA-256 hash of the admin token; empty without one
+	providers  map[string]Provider
+
+	mu       sync.Mutex
+	users    map[string]*User      // By username
+	sessions map[string]session    // By token hash
+	states   map[string]oauthState // Pending provider logins, by state
+}
+
+// NewService creates an auth service keeping its accounts and sessions in dir
+func NewService(dir string) (*Service, error) {
+	s := &Service{
+		dir:        dir,
+		signup:     true,
+		sessionTTL: DefaultSessionTTL,
+		providers:  make(map[string]Provider),
+		users:      make(map[string]*User),
+		sessions:   make(map[string]session),
+		states:     make(map[string]oauthState),
+	}
+	if err := readJSON(filepath.Join(dir, usersFileName), &s.users); err != nil {
+		return nil, fmt.Errorf("failed to read accounts: %v", err)
+	}
+	if err := readJSON(filepath.Join(dir, sessionsFileName), &s.sessions); err != nil {
+		return nil, fmt.Errorf("failed to read sessions: %v", err)
+	}
+	return s, nil
+}
+
+// SetRequired sets whether acting as a user needs signing in. Without it the
+// web UI trusts the username a request names, as on a single-user machine.
+func (s *Service) SetRequired(required bool) {
+	s.required = required
+}
+
+// Required reports whether acting as a user needs signing in
+func (s *Service) Required() bool {
+	return s.required
+}
+
+// SetSignup sets whether anyone can create a local account
+func (s *Service) SetSignup(signup bool) {
+	s.signup = signup
+}
+
+// Signup reports whether anyone can create a local account. Once users can
+// sign in with GitHub, whose usernames local accounts share, a GitHub
+// username belongs to the GitHub account only, so no local accounts are
+// created.
+func (s *Service) Signup() bool {
+	_, github := s.providers["github"]
+	return s.signup && !github
+}
+
+// SetSessionTTL sets how long new sessions last
+func (s *Service) SetSessionTTL(ttl time.Duration) {
+	if ttl > 0 {
+		s.sessionTTL = ttl
+	}
+}
+
+// SetPublicURL sets the URL the server is reached at, e.g.
+// https://practice.example.com, which provider callback URLs start with.
+// Without it they start with the scheme and host of the login request.
+func (s *Service) SetPublicURL(publicURL string) {
+	s.publicURL = publicURL
+}
+
+// SetAdminToken sets the token that authorizes admin requests. Without one
+// no request is an admin's.
+func (s *Service) SetAdminToken(token string) {
+	s.adminToken = ""
+	if token != "" {
+		s.adminToken = hashToken(token)
+	}
+}
+
+// AddProvider makes a login provider available under its name
+func (s *Service) AddProvider(provider Provider) {
+	s.providers[provider.Name()] = provider
+}
+
+// Providers returns the login providers, by name
+func (s *Service) Providers() []Provider {
+	var providers []Provider
+	for _, provider := range s.providers {
+		providers = append(providers, provider)
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name() < providers[j].Name() })
+	return providers
+}
+
+// Register creates a local account
+func (s *Service) Register(username, password string) (*User, error) {
+	if !s.signup {
+		return nil, ErrSignupDisabled
+	}
+	if !s.Signup() {
+		return nil, ErrUsernameReserved
+	}
+	if !ValidUsername(username) {
+		return nil, ErrInvalidUsername
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lookup(username) != nil {
+		return nil, ErrUsernameTaken
+	}
+	user := &User{Username: username, PasswordHash: hash, CreatedAt: time.Now()}
+	s.users[username] = user
+	if err := s.saveUsers(); err != nil {
+		delete(s.users, username)
+		return nil, err
+	}
+	copied := *user
+	return &copied, nil
+}
+
+// Authenticate checks the password of a local account
+func (s *Service) Authenticate(username, password string) (*User, error) {
+	s.mu.Lock()
+	user := s.lookup(username)
+	s.mu.Unlock()
+
+	if user == nil || user.PasswordHash == "" {
+		// Take as long as a wrong password, so accounts cannot be probed
+		checkPassword(dummyHash, password)
+		return nil, ErrInvalidCredentials
+	}
+	if !checkPassword(user.PasswordHash, password) {
+		return nil, ErrInvalidCredentials
+	}
+	copied := *user
+	return &copied, nil
+}
+
+// providerUser returns the account of a provider's user, creating it under
+// their provider username the first time they sign in. A username taken by
+// another account is not handed over.
+func (s *Service) providerUser(provider string, external ExternalUser) (*User, error) {
+	if !ValidUsername(external.Username) {
+		return nil, ErrInvalidUsername
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.Provider == provider && user.ProviderID == external.ID {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	if s.lookup(external.Username) != nil {
+		return nil, fmt.Errorf("%w by another account", ErrUsernameTaken)
+	}
+	user := &User{Username: external.Username, Provider: provider, ProviderID: external.ID, CreatedAt: time.Now()}
+	s.users[user.Username] = user
+	if err := s.saveUsers(); err != nil {
+		delete(s.users, user.Username)
+		return nil, err
+	}
+	copied := *user
+	return &copied, nil
+}
+
+// lookup finds an account by username, ignoring case as GitHub does. s.mu
+// must be held.
+func (s *Service) lookup(username string) *User {
+	if user, ok := s.users[username]; ok {
+		return user
+	}
+	for name, user := range s.users {
+		if strings.EqualFold(name, username) {
+			return user
+		}
+	}
+	return nil
+}
+
+// saveUsers writes the accounts file. s.mu must be held.
+func (s *Service) saveUsers() error {
+	return writeJSON(filepath.Join(s.dir, usersFileName), s.users)
+}
+
+// saveSessions writes the sessions file. s.mu must be held.
+func (s *Service) saveSessions() error {
+	return writeJSON(filepath.Join(s.dir, sessionsFileName), s.sessions)
+}
+
+// readJSON reads a JSON file into v; a missing file leaves v as it is
+func readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSON replaces a file with v as JSON, readable by its owner only
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,323 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisterAndAuthenticate(t *testing.T) {
+	s, err := NewService(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Register("alice", "correct horse"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	for _, tc := range []struct {
+		username, password string
+		want               error
+	}{
+		{"Alice", "battery staple", ErrUsernameTaken},
+		{"-alice", "correct horse", ErrInvalidUsername},
+		{"../alice", "correct horse", ErrInvalidUsername},
+		{"bob", "short", ErrPasswordLength},
+	} {
+		if _, err := s.Register(tc.username, tc.password); !errors.Is(err, tc.want) {
+			t.Errorf("register %q: %v, want %v", tc.username, err, tc.want)
+		}
+	}
+
+	if user, err := s.Authenticate("alice", "correct horse"); err != nil || user.Username != "alice" {
+		t.Errorf("authenticate with the right password: %v, %v", user, err)
+	}
+	if user, err := s.Authenticate("ALICE", "correct horse"); err != nil || user.Username != "alice" {
+		t.Errorf("authenticate ignoring case: %v, %v", user, err)
+	}
+	if _, err := s.Authenticate("alice", "wrong horse"); err != ErrInvalidCredentials {
+		t.Errorf("authenticate with a wrong password: %v, want %v", err, ErrInvalidCredentials)
+	}
+	if _, err := s.Authenticate("nobody", "correct horse"); err != ErrInvalidCredentials {
+		t.Errorf("authenticate an unknown user: %v, want %v", err, ErrInvalidCredentials)
+	}
+
+	// With GitHub sign-in, usernames are GitHub's
+	s.AddProvider(NewGitHubProvider("id", "secret"))
+	if _, err := s.Register("torvalds", "correct horse"); err != ErrUsernameReserved || s.Signup() {
+		t.Errorf("register with GitHub sign-in: %v, want %v", err, ErrUsernameReserved)
+	}
+	if _, err := s.Authenticate("alice", "correct horse"); err != nil {
+		t.Errorf("authenticate an earlier local account with GitHub sign-in: %v", err)
+	}
+
+	s.SetSignup(false)
+	if _, err := s.Register("carol", "correct horse"); err != ErrSignupDisabled {
+		t.Errorf("register with signup off: %v, want %v", err, ErrSignupDisabled)
+	}
+}
+
+func TestSessionsSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewService(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Register("alice", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := s.CreateSession("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ended, _, err := s.CreateSession("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EndSession(ended); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := NewService(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, ok := restarted.SessionUser(token); !ok || user != "alice" {
+		t.Errorf("session after restart: %q, %v, want alice", user, ok)
+	}
+	if _, ok := restarted.SessionUser(ended); ok {
+		t.Errorf("ended session is still signed in")
+	}
+	if _, err := restarted.Authenticate("alice", "correct horse"); err != nil {
+		t.Errorf("account after restart: %v", err)
+	}
+}
+
+func TestSessionExpires(t *testing.T) {
+	s, err := NewService(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetSessionTTL(time.Millisecond)
+	token, _, err := s.CreateSession("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := s.SessionUser(token); ok {
+		t.Errorf("expired session is still signed in")
+	}
+}
+
+func TestSessionCookie(t *testing.T) {
+	s, err := NewService(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	if err := s.StartSession(rec, httptest.NewRequest("POST", "/api/auth/login", nil), "alice"); err != nil {
+		t.Fatal(err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != SessionCookieName || !cookies[0].HttpOnly {
+		t.Fatalf("session cookies = %v, want one HttpOnly %s cookie", cookies, SessionCookieName)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookies[0])
+	if user, ok := s.RequestUser(req); !ok || user != "alice" {
+		t.Errorf("request user = %q, %v, want alice", user, ok)
+	}
+	if err := s.StopSession(httptest.NewRecorder(), req); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.RequestUser(req); ok {
+		t.Errorf("request is still signed in after signing out")
+	}
+}
+
+// fakeGitHub is a stand-in for GitHub's OAuth endpoints and user API
+type fakeGitHub struct {
+	*httptest.Server
+	clientSecret string
+	user         string // The user who signs in on the authorize page
+	id           int64
+}
+
+func newFakeGitHub(t *testing.T, clientSecret string) *fakeGitHub {
+	f := &fakeGitHub{clientSecret: clientSecret, user: "octocat", id: 583231}
+	const code, token = "the-code", "the-token"
+	mux := http.NewServeMux()
+
+	// The user signs in and GitHub sends them back with a code
+	mux.HandleFunc("/login/oauth/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("client_id") != "client" || query.Get("response_type") != "code" {
+			http.Error(w, "bad authorize request", http.StatusBadRequest)
+			return
+		}
+		back, err := url.Parse(query.Get("redirect_uri"))
+		if err != nil {
+			http.Error(w, "bad redirect_uri", http.StatusBadRequest)
+			return
+		}
+		params := url.Values{"code": {code}, "state": {query.Get("state")}}
+		back.RawQuery = params.Encode()
+		http.Redirect(w, r, back.String(), http.StatusFound)
+	})
+
+	// The server trades the code for a token; GitHub answers errors with 200
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != "POST" || r.FormValue("client_secret") != f.clientSecret || r.FormValue("code") != code {
+			json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": token, "token_type": "bearer"})
+	})
+
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "Bad credentials", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": f.id, "login": f.user})
+	})
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+// provider returns a GitHub provider using the stand-in
+func (f *fakeGitHub) provider(clientSecret string) *GitHubProvider {
+	p := NewGitHubProvider("client", clientSecret)
+	p.AuthURL = f.URL + "/login/oauth/authorize"
+	p.TokenURL = f.URL + "/login/oauth/access_token"
+	p.UserURL = f.URL + "/user"
+	p.HTTPClient = f.Client()
+	return p
+}
+
+// login signs in through the stand-in the way a browser does and returns the
+// code and state of the callback
+func (f *fakeGitHub) login(t *testing.T, authURL, redirectURL string) (code, state string) {
+	t.Helper()
+	client := *f.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location := resp.Header.Get("Location")
+	if resp.StatusCode != http.StatusFound || !strings.HasPrefix(location, redirectURL+"?") {
+		t.Fatalf("authorize answered %s to %q, want a redirect to %s", resp.Status, location, redirectURL)
+	}
+	back, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return back.Query().Get("code"), back.Query().Get("state")
+}
+
+func TestGitHubLogin(t *testing.T) {
+	github := newFakeGitHub(t, "secret")
+	s, err := NewService(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AddProvider(github.provider("secret"))
+	const redirectURL = "http://practice.test/auth/github/callback"
+
+	authURL, state, err := s.BeginLogin("github", redirectURL, "/challenge/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, returned := github.login(t, authURL, redirectURL)
+	if returned != state {
+		t.Fatalf("callback state %q, want %q", returned, state)
+	}
+	user, next, err := s.FinishLogin(context.Background(), "github", returned, code, redirectURL)
+	if err != nil {
+		t.Fatalf("finish login: %v", err)
+	}
+	if user.Username != "octocat" || user.Provider != "github" || user.ProviderID != "583231" {
+		t.Errorf("user = %+v, want octocat from github", user)
+	}
+	if next != "/challenge/1" {
+		t.Errorf("next = %q, want /challenge/1", next)
+	}
+
+	// A state is used once
+	if _, _, err := s.FinishLogin(context.Background(), "github", returned, code, redirectURL); err == nil {
+		t.Errorf("finishing a login twice succeeded")
+	}
+
+	// Renamed users keep their account, found by their GitHub ID
+	github.user = "octocat-renamed"
+	authURL, _, err = s.BeginLogin("github", redirectURL, "https://evil.test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state = github.login(t, authURL, redirectURL)
+	user, next, err = s.FinishLogin(context.Background(), "github", state, code, redirectURL)
+	if err != nil {
+		t.Fatalf("finish login after rename: %v", err)
+	}
+	if user.Username != "octocat" {
+		t.Errorf("renamed user signed in as %q, want octocat", user.Username)
+	}
+	if next != "/" {
+		t.Errorf("next = %q, want / instead of another site", next)
+	}
+}
+
+func TestGitHubLoginFailures(t *testing.T) {
+	github := newFakeGitHub(t, "secret")
+	s, err := NewService(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A local account created before GitHub sign-in was set up
+	if _, err := s.Register("octocat", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	s.AddProvider(github.provider("wrong"))
+	const redirectURL = "http://practice.test/auth/github/callback"
+
+	if _, _, err := s.BeginLogin("gitlab", redirectURL, "/"); err != ErrUnknownProvider {
+		t.Errorf("begin login with an unknown provider: %v, want %v", err, ErrUnknownProvider)
+	}
+	if _, _, err := s.FinishLogin(context.Background(), "github", "forged", "the-code", redirectURL); err == nil {
+		t.Errorf("finishing a login that was not started succeeded")
+	}
+
+	// The token endpoint rejects the client secret
+	authURL, _, err := s.BeginLogin("github", redirectURL, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := github.login(t, authURL, redirectURL)
+	if _, _, err := s.FinishLogin(context.Background(), "github", state, code, redirectURL); err == nil || !strings.Contains(err.Error(), "bad_verification_code") {
+		t.Errorf("finish login with a wrong client secret: %v, want bad_verification_code", err)
+	}
+
+	// A GitHub user cannot take over a local account with the same name
+	s.AddProvider(github.provider("secret"))
+	authURL, _, err = s.BeginLogin("github", redirectURL, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state = github.login(t, authURL, redirectURL)
+	if _, _, err := s.FinishLogin(context.Background(), "github", state, code, redirectURL); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("finish login as a taken username: %v, want %v", err, ErrUsernameTaken)
+	}
+}
@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loginTimeout is how long a user has to come back from a provider
+const loginTimeout = 10 * time.Minute
+
+// ErrUnknownProvider is returned for a login provider that is not configured
+var ErrUnknownProvider = errors.New("unknown login provider")
+
+// Provider is an OAuth2 login provider. GitHubProvider is the first one;
+// others implement the same authorization code flow.
+type Provider interface {
+	Name() string  // In the login URLs, e.g. "github"
+	Title() string // On the sign-in page, e.g. "GitHub"
+
+	// AuthCodeURL returns the provider page that asks the user to sign in
+	// and sends them to redirectURL with a code and the state
+	AuthCodeURL(state, redirectURL string) string
+
+	// Exchange trades the code of a callback for the user who signed in
+	Exchange(ctx context.Context, code, redirectURL string) (ExternalUser, error)
+}
+
+// ExternalUser is a user as a login provider knows them
+type ExternalUser struct {
+	ID       string // Stable across renames
+	Username string
+}
+
+// OAuth2Config is a client of an OAuth2 authorization server
+type OAuth2Config struct {
+	ClientID     string
+	ClientSecret string
+	AuthURL      string // Authorization endpoint
+	TokenURL     string // Token endpoint
+	Scopes       []string
+}
+
+// AuthCodeURL returns the authorization endpoint URL of a login
+func (c OAuth2Config) AuthCodeURL(state, redirectURL string) string {
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {c.ClientID},
+		"redirect_uri":  {redirectURL},
+		"state":         {state},
+	}
+	if len(c.Scopes) > 0 {
+		params.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	separator := "?"
+	if strings.Contains(c.AuthURL, "?") {
+		separator = "&"
+	}
+	return c.AuthURL + separator + params.Encode()
+}
+
+// ExchangeCode trades an authorization code for an access token
+func (c OAuth2Config) ExchangeCode(ctx context.Context, client *http.Client, code, redirectURL string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {c.ClientID},
+		"client_secret": {c.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return "", fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+	if token.Error != "" {
+		return "", fmt.Errorf("token endpoint: %s %s", token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("token endpoint returned status %d without a token", resp.StatusCode)
+	}
+	return token.AccessToken, nil
+}
+
+// GitHubProvider signs users in with their GitHub account, as an OAuth app
+type GitHubProvider struct {
+	OAuth2Config
+	UserURL    string // The API endpoint of the signed-in user
+	HTTPClient *http.Client
+}
+
+// NewGitHubProvider creates the GitHub login provider of an OAuth app. The
+// app's callback URL is /auth/github/callback on the server.
+func NewGitHubProvider(clientID, clientSecret string) *GitHubProvider {
+	return &GitHubProvider{
+		OAuth2Config: OAuth2Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			AuthURL:      "https://github.com/login/oauth/authorize",
+			TokenURL:     "https://github.com/login/oauth/access_token",
+		},
+		UserURL:    "https://api.github.com/user",
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name is used in the login URLs
+func (p *GitHubProvider) Name() string {
+	return "github"
+}
+
+// Title is shown on the sign-in page
+func (p *GitHubProvider) Title() string {
+	return "GitHub"
+}
+
+// Exchange trades the code for an access token and reads the user's GitHub
+// login and ID with it. The token is not kept.
+func (p *GitHubProvider) Exchange(ctx context.Context, code, redirectURL string) (ExternalUser, error) {
+	token, err := p.ExchangeCode(ctx, p.HTTPClient, code, redirectURL)
+	if err != nil {
+		return ExternalUser{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.UserURL, nil)
+	if err != nil {
+		return ExternalUser{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return ExternalUser{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return ExternalUser{}, fmt.Errorf("GitHub user API returned status %d", resp.StatusCode)
+	}
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&user); err != nil {
+		return ExternalUser{}, err
+	}
+	if user.ID == 0 || user.Login == "" {
+		return ExternalUser{}, fmt.Errorf("GitHub user API returned no user")
+	}
+	return ExternalUser{ID: strconv.FormatInt(user.ID, 10), Username: user.Login}, nil
+}
+
+// oauthState is a provider login waiting for its callback
+type oauthState struct {
+	provider string
+	next     string // Local page to go to after signing in
+	expires  time.Time
+}
+
+// BeginLogin starts signing in with a provider. It returns the provider URL
+// to send the user to and the state the callback has to bring back, which
+// the caller also keeps in the user's browser.
+func (s *Service) BeginLogin(providerName, redirectURL, next string) (authURL, state string, err error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+	state, err = randomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	s.mu.Lock()
+	s.dropExpired()
+	s.states[state] = oauthState{provider: providerName, next: LocalPath(next), expires: time.Now().Add(loginTimeout)}
+	s.mu.Unlock()
+	return provider.AuthCodeURL(state, redirectURL), state, nil
+}
+
+// FinishLogin completes a provider login from its callback. It checks the
+// state, trades the code for the provider's user and returns their account
+// and the page to go to. Each state is used once.
+func (s *Service) FinishLogin(ctx context.Context, providerName, state, code, redirectURL string) (*User, string, error) {
+	s.mu.Lock()
+	pending, ok := s.states[state]
+	delete(s.states, state)
+	s.mu.Unlock()
+	if !ok || pending.provider != providerName || time.Now().After(pending.expires) {
+		return nil, "", fmt.Errorf("the login expired or was not started here; please sign in again")
+	}
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, "", ErrUnknownProvider
+	}
+	if code == "" {
+		return nil, "", fmt.Errorf("%s did not return an authorization code", provider.Title())
+	}
+
+	external, err := provider.Exchange(ctx, code, redirectURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("signing in with %s failed: %v", provider.Title(), err)
+	}
+	user, err := s.providerUser(providerName, external)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, pending.next, nil
+}
+
+// CallbackURL returns the URL a provider sends users back to after a login
+// request r
+func (s *Service) CallbackURL(r *http.Request, providerName string) string {
+	return s.URL(r, "/auth/"+providerName+"/callback")
+}
+
+// URL returns the absolute URL of a path on this server: the public URL, if
+// set, or else the scheme and host of request r, followed by path
+func (s *Service) URL(r *http.Request, path string) string {
+	base := strings.TrimSuffix(s.publicURL, "/")
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + path
+}
+
+// SecureCookies reports whether cookies set in answer to r should only be
+// sent over HTTPS
+func (s *Service) SecureCookies(r *http.Request) bool {
+	return r.TLS != nil || strings.HasPrefix(s.publicURL, "https://")
+}
+
+// LocalPath returns next if it is a path on this server, or "/", so that
+// nobody is sent elsewhere after signing in
+func LocalPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.Contains(next, "\\") {
+		return "/"
+	}
+	return next
+}
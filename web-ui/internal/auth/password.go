@@ -0,0 +1,30 @@
+package auth
+
+import "golang.org/x/crypto/bcrypt"
+
+// Password lengths; bcrypt ignores everything after 72 bytes
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+// dummyHash is checked against when an account does not exist, so that
+// takes as long as a wrong password
+var dummyHash, _ = hashPassword("not a password of anyone")
+
+// hashPassword hashes a password with bcrypt
+func hashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return "", ErrPasswordLength
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword reports whether password matches a bcrypt hash
+func checkPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
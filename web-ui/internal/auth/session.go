@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SessionCookieName is the cookie holding the session token
+const SessionCookieName = "session"
+
+// session is a signed-in user, by the hash of the session token
+type session struct {
+	Username string    `json:"username"`
+	Expires  time.Time `json:"expires"`
+}
+
+// CreateSession signs a user in and returns the session token
+func (s *Service) CreateSession(username string) (string, time.Time, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expires := time.Now().Add(s.sessionTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[hashToken(token)] = session{Username: username, Expires: expires}
+	s.dropExpired()
+	if err := s.saveSessions(); err != nil {
+		delete(s.sessions, hashToken(token))
+		return "", time.Time{}, err
+	}
+	return token, expires, nil
+}
+
+// SessionUser returns the user signed in with a session token
+func (s *Service) SessionUser(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[hashToken(token)]
+	if !ok || time.Now().After(session.Expires) {
+		return "", false
+	}
+	return session.Username, true
+}
+
+// EndSession signs out the session of a token
+func (s *Service) EndSession(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := hashToken(token)
+	if _, ok := s.sessions[key]; !ok {
+		return nil
+	}
+	delete(s.sessions, key)
+	return s.saveSessions()
+}
+
+// dropExpired forgets expired sessions and provider logins. s.mu must be held.
+func (s *Service) dropExpired() {
+	now := time.Now()
+	for key, session := range s.sessions {
+		if now.After(session.Expires) {
+			delete(s.sessions, key)
+		}
+	}
+	for state, pending := range s.states {
+		if now.After(pending.expires) {
+			delete(s.states, state)
+		}
+	}
+}
+
+// StartSession signs a user in and sets the session cookie on w
+func (s *Service) StartSession(w http.ResponseWriter, r *http.Request, username string) error {
+	token, expires, err := s.CreateSession(username)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   s.SecureCookies(r),
+		SameSite: http.SameSiteLaxMode, // Other sites cannot post as the user
+	})
+	return nil
+}
+
+// RequestUser returns the user signed in with the session cookie of r
+func (s *Service) RequestUser(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return "", false
+	}
+	return s.SessionUser(cookie.Value)
+}
+
+// IsAdmin reports whether r carries the admin token as a bearer token.
+// Browsers never add the header on their own, so other sites cannot make
+// admin requests the way they could with a cookie.
+func (s *Service) IsAdmin(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if s.adminToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(s.adminToken)) == 1
+}
+
+// StopSession signs out the session of r and clears the session cookie
+func (s *Service) StopSession(w http.ResponseWriter, r *http.Request) error {
+	http.SetCookie(w, &http.Cookie{Name: SessionCookieName, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return nil
+	}
+	return s.EndSession(cookie.Value)
+}
+
+// randomToken returns 32 random bytes, hex encoded
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// hashToken returns the key a session token is stored under
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
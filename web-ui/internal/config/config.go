@@ -0,0 +1,333 @@
+// Package config holds the settings of the server and the commands: where
+// the challenges, packages and data live, how code runs and which optional
+// features are on.
+//
+// Settings come from, in increasing order of precedence: the defaults, a
+// JSON config file given with -config or WEBUI_CONFIG, environment
+// variables named after the flags (WEBUI_LISTEN for -listen,
+// WEBUI_TEST_TIMEOUT for -test-timeout) and the command-line flags.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// envPrefix prefixes the environment variable of every flag
+const envPrefix = "WEBUI_"
+
+// Config is the configuration of the server and the commands. Paths are
+// absolute once Parse returns.
+type Config struct {
+	Listen        string   `json:"listen"`   // Address the server listens on
+	RepoRoot      string   `json:"repo"`     // Holds challenge-N and packages/; found by walking up if empty
+	PackagesDir   string   `json:"packages"` // Default: packages/ in the repository root
+	DataDir       string   `json:"data"`     // Submission history; default: web-ui/.data in the repository root
+	ModulesDir    string   `json:"modules"`  // Offline module store; default: web-ui/.modules in the repository root
+	Workers       int      `json:"workers"`  // Code runs executed concurrently
+	Isolation     string   `json:"isolation"`
+	WatchInterval Duration `json:"watch"`         // 0 turns reloading off
+	StarsInterval Duration `json:"starsInterval"` // Refresh of the GitHub star counts; 0 refreshes them once
+	Limits        Limits   `json:"limits"`
+	Features      Features `json:"features"`
+	Auth          Auth     `json:"auth"`
+
+	configFile string
+	flags      []string // Names of the flags bound to the settings
+}
+
+// Limits are the server-wide limits of code runs. Zero keeps the execution
+// service's default; a challenge's metadata.json can still override them.
+type Limits struct {
+	TestTimeout  Duration `json:"testTimeout"`
+	CPUTime      Duration `json:"cpuTime"`
+	MemoryMB     int      `json:"memoryMB"`
+	MaxProcesses int      `json:"maxProcesses"`
+	MaxOutputKB  int      `json:"maxOutputKB"`
+	BuildTimeout Duration `json:"buildTimeout"` // Compiling test binaries and fetching modules
+}
+
+// Features turns optional features on and off
+type Features struct {
+	GitHubStars      bool `json:"githubStars"`      // Fetch the star counts of the packages from GitHub
+	SaveToFilesystem bool `json:"saveToFilesystem"` // Let the web UI write submissions into the repository
+	ModuleProxy      bool `json:"moduleProxy"`      // Serve the offline module store to code runs
+}
+
+// Auth configures how users sign in. Without Required, requests act as the
+// username they name, as on a single-user machine.
+type Auth struct {
+	Required   bool        `json:"required"`   // Writes need a signed-in user
+	Signup     bool        `json:"signup"`     // Anyone can create a local account, unless GitHub login is set up
+	SessionTTL Duration    `json:"sessionTTL"` // How long a sign-in lasts
+	PublicURL  string      `json:"publicURL"`  // Base of the login callback URLs; default: the requested host
+	AdminToken string      `json:"adminToken"` // Bearer token of admin requests such as reloading; none without it
+	GitHub     OAuthClient `json:"github"`     // GitHub login, if the client ID is set
+}
+
+// OAuthClient is the registration of the server with an OAuth2 login provider
+type OAuthClient struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+}
+
+// Default returns the configuration used when nothing is set
+func Default() *Config {
+	return &Config{
+		Listen:        ":8080",
+		Workers:       runtime.NumCPU(),
+		Isolation:     "none",
+		WatchInterval: Duration(2 * time.Second),
+		StarsInterval: Duration(6 * time.Hour),
+		Features: Features{
+			GitHubStars:      true,
+			SaveToFilesystem: true,
+			ModuleProxy:      true,
+		},
+		Auth: Auth{
+			Required:   true,
+			Signup:     true,
+			SessionTTL: Duration(7 * 24 * time.Hour),
+		},
+	}
+}
+
+// RegisterFlags defines a flag for every setting on fs, bound to c
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.configFile, "config", "", "JSON config file; flags and WEBUI_* environment variables override it")
+	fs.StringVar(&c.Listen, "listen", c.Listen, "address the server listens on")
+	fs.StringVar(&c.RepoRoot, "repo", c.RepoRoot, "repository root holding challenge-N and packages/ (default: found above the working directory)")
+	fs.StringVar(&c.PackagesDir, "packages", c.PackagesDir, "directory holding the package challenges (default: packages/ in the repository root)")
+	fs.StringVar(&c.DataDir, "data", c.DataDir, "directory the submission history is kept in (default: web-ui/.data in the repository root)")
+	fs.StringVar(&c.ModulesDir, "modules", c.ModulesDir, "module store of the offline Go module proxy, see import-modules (default: web-ui/.modules in the repository root)")
+	fs.IntVar(&c.Workers, "workers", c.Workers, "number of code runs executed concurrently")
+	fs.StringVar(&c.Isolation, "isolation", c.Isolation, "run submitted code in Linux namespaces: none, auto or strict")
+	fs.Var(&c.WatchInterval, "watch", "how often challenge and package files are checked for changes; 0 turns reloading off")
+	fs.Var(&c.StarsInterval, "stars-interval", "how often the GitHub star counts of the packages are refreshed in the background; 0 refreshes them once")
+	fs.Var(&c.Limits.TestTimeout, "test-timeout", "wall-clock limit of a test run (default: 30s)")
+	fs.Var(&c.Limits.CPUTime, "cpu-time", "CPU time limit of a test run (default: 1m0s)")
+	fs.IntVar(&c.Limits.MemoryMB, "memory-mb", c.Limits.MemoryMB, "address space limit of a test run in MB (default: 1024)")
+	fs.IntVar(&c.Limits.MaxProcesses, "max-processes", c.Limits.MaxProcesses, "process and thread limit of a test run (default: 4096)")
+	fs.IntVar(&c.Limits.MaxOutputKB, "max-output-kb", c.Limits.MaxOutputKB, "output limit of a run in KB (default: 1024)")
+	fs.Var(&c.Limits.BuildTimeout, "build-timeout", "time limit of compiling tests and fetching modules (default: 5m0s)")
+	fs.BoolVar(&c.Features.GitHubStars, "github-stars", c.Features.GitHubStars, "fetch the star counts of the packages from GitHub; false shows the last known counts")
+	fs.BoolVar(&c.Features.SaveToFilesystem, "save-to-filesystem", c.Features.SaveToFilesystem, "let the web UI save submissions into the repository")
+	fs.BoolVar(&c.Features.ModuleProxy, "module-proxy", c.Features.ModuleProxy, "serve the offline module store to code runs when it has modules")
+	fs.BoolVar(&c.Auth.Required, "auth", c.Auth.Required, "require signing in to submit and save; false trusts the username a request names, for one user on their own machine")
+	fs.BoolVar(&c.Auth.Signup, "signup", c.Auth.Signup, "let anyone create a local account; off once GitHub login is set up, as usernames are GitHub's")
+	fs.Var(&c.Auth.SessionTTL, "session-ttl", "how long a sign-in lasts")
+	fs.StringVar(&c.Auth.PublicURL, "public-url", c.Auth.PublicURL, "URL the server is reached at, for login callbacks behind a proxy (default: the requested host)")
+	fs.StringVar(&c.Auth.AdminToken, "admin-token", c.Auth.AdminToken, "bearer token authorizing admin requests such as reloading; prefer "+EnvVar("admin-token"))
+	fs.StringVar(&c.Auth.GitHub.ClientID, "github-client-id", c.Auth.GitHub.ClientID, "client ID of the GitHub OAuth app to sign in with")
+	fs.StringVar(&c.Auth.GitHub.ClientSecret, "github-client-secret", c.Auth.GitHub.ClientSecret, "client secret of the GitHub OAuth app; prefer "+EnvVar("github-client-secret"))
+
+	c.flags = nil
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name != "config" {
+			c.flags = append(c.flags, f.Name)
+		}
+	})
+}
+
+// Parse parses args with fs, whose flags RegisterFlags bound to c, applies
+// the config file and the environment under them and resolves the paths
+func (c *Config) Parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// The flags given on the command line are set again last, so they win
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	configFile := c.configFile
+	if configFile == "" {
+		configFile = os.Getenv(envPrefix + "CONFIG")
+	}
+	if configFile != "" {
+		if err := c.readFile(configFile); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range c.flags {
+		if _, ok := explicit[name]; ok {
+			continue
+		}
+		value, ok := os.LookupEnv(EnvVar(name))
+		if !ok {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvVar(name), err)
+		}
+	}
+	for name, value := range explicit {
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid -%s: %v", name, err)
+		}
+	}
+
+	if err := c.resolve(); err != nil {
+		return err
+	}
+	return c.validate()
+}
+
+// EnvVar returns the environment variable setting a flag, e.g. WEBUI_TEST_TIMEOUT
+// for -test-timeout
+func EnvVar(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// readFile applies a JSON config file over c. Relative paths in the file are
+// relative to the file's directory.
+func (c *Config) readFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	before := *c
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for _, field := range []struct{ value, previous *string }{
+		{&c.RepoRoot, &before.RepoRoot},
+		{&c.PackagesDir, &before.PackagesDir},
+		{&c.DataDir, &before.DataDir},
+		{&c.ModulesDir, &before.ModulesDir},
+	} {
+		if *field.value != *field.previous && *field.value != "" && !filepath.IsAbs(*field.value) {
+			*field.value = filepath.Join(dir, *field.value)
+		}
+	}
+	return nil
+}
+
+// resolve finds the repository root if it is not set, derives the default
+// directories from it and makes every path absolute
+func (c *Config) resolve() error {
+	if c.RepoRoot == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		if c.RepoRoot, err = FindRepoRoot(wd); err != nil {
+			return err
+		}
+	}
+	if c.PackagesDir == "" {
+		c.PackagesDir = filepath.Join(c.RepoRoot, "packages")
+	}
+	if c.DataDir == "" {
+		c.DataDir = filepath.Join(c.RepoRoot, "web-ui", ".data")
+	}
+	if c.ModulesDir == "" {
+		c.ModulesDir = filepath.Join(c.RepoRoot, "web-ui", ".modules")
+	}
+
+	for _, path := range []*string{&c.RepoRoot, &c.PackagesDir, &c.DataDir, &c.ModulesDir} {
+		abs, err := filepath.Abs(*path)
+		if err != nil {
+			return err
+		}
+		*path = abs
+	}
+	return nil
+}
+
+// validate rejects settings no server or command can work with
+func (c *Config) validate() error {
+	switch {
+	case c.Listen == "":
+		return fmt.Errorf("the listen address is empty")
+	case c.Workers < 1:
+		return fmt.Errorf("workers must be at least 1, not %d", c.Workers)
+	case c.WatchInterval < 0 || c.StarsInterval < 0:
+		return fmt.Errorf("intervals cannot be negative")
+	case c.Auth.SessionTTL <= 0:
+		return fmt.Errorf("the session TTL must be positive")
+	case c.Auth.GitHub.ClientID != "" && c.Auth.GitHub.ClientSecret == "":
+		return fmt.Errorf("the GitHub login needs a client secret; set %s", EnvVar("github-client-secret"))
+	case c.Auth.PublicURL != "" && !strings.HasPrefix(c.Auth.PublicURL, "http://") && !strings.HasPrefix(c.Auth.PublicURL, "https://"):
+		return fmt.Errorf("the public URL must start with http:// or https://")
+	case c.Limits.TestTimeout < 0 || c.Limits.CPUTime < 0 || c.Limits.BuildTimeout < 0 ||
+		c.Limits.MemoryMB < 0 || c.Limits.MaxProcesses < 0 || c.Limits.MaxOutputKB < 0:
+		return fmt.Errorf("limits cannot be negative")
+	}
+	if info, err := os.Stat(c.RepoRoot); err != nil || !info.IsDir() {
+		return fmt.Errorf("repository root %s is not a directory", c.RepoRoot)
+	}
+	return nil
+}
+
+// FindRepoRoot walks up from dir to the repository root: the first directory
+// holding both challenge-1 and packages
+func FindRepoRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for current := dir; ; current = filepath.Dir(current) {
+		if isDir(filepath.Join(current, "challenge-1")) && isDir(filepath.Join(current, "packages")) {
+			return current, nil
+		}
+		if filepath.Dir(current) == current {
+			return "", fmt.Errorf("no repository root (a directory holding challenge-1 and packages) above %s; pass -repo or set %s", dir, EnvVar("repo"))
+		}
+	}
+}
+
+// isDir reports whether path is a directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// Duration is a time.Duration written as in flags, e.g. "30s", in config
+// files
+type Duration time.Duration
+
+// String formats the duration, e.g. 1m30s
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set parses a duration, e.g. 30s; it makes Duration a flag.Value
+func (d *Duration) Set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string, e.g. "30s"
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a duration string, e.g. "30s"
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("durations are strings like \"30s\": %v", err)
+	}
+	return d.Set(value)
+}
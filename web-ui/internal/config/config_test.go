@@ -0,0 +1,197 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRepo creates a directory tree with the layout of the repository and
+// returns its root
+func newRepo(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, dir := range []string{"challenge-1", "challenge-2/submissions/alice", "packages/gin/challenge-1-basic-routing", "web-ui/internal"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// parse parses args into a new configuration, as a command does
+func parse(args ...string) (*Config, error) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg := Default()
+	cfg.RegisterFlags(fs)
+	return cfg, cfg.Parse(fs, args)
+}
+
+func TestParsePrecedence(t *testing.T) {
+	root := newRepo(t)
+	configFile := filepath.Join(root, "web-ui", "config.json")
+	file := `{"listen": ":9000", "workers": 3, "limits": {"testTimeout": "45s", "memoryMB": 512}, "features": {"githubStars": false}, "data": "state"}`
+	if err := os.WriteFile(configFile, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	type settings struct {
+		listen      string
+		workers     int
+		testTimeout time.Duration
+		memoryMB    int
+		githubStars bool
+		dataDir     string
+	}
+	defaults := Default()
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want settings
+	}{
+		{
+			name: "defaults",
+			args: []string{"-repo", root},
+			want: settings{defaults.Listen, defaults.Workers, 0, 0, true, filepath.Join(root, "web-ui", ".data")},
+		},
+		{
+			// Relative paths in the file are relative to the file
+			name: "file over defaults",
+			args: []string{"-repo", root, "-config", configFile},
+			want: settings{":9000", 3, 45 * time.Second, 512, false, filepath.Join(root, "web-ui", "state")},
+		},
+		{
+			name: "file named in the environment",
+			env:  map[string]string{"WEBUI_CONFIG": configFile},
+			args: []string{"-repo", root},
+			want: settings{":9000", 3, 45 * time.Second, 512, false, filepath.Join(root, "web-ui", "state")},
+		},
+		{
+			name: "environment over file",
+			env:  map[string]string{"WEBUI_LISTEN": ":9100", "WEBUI_WORKERS": "5", "WEBUI_GITHUB_STARS": "true"},
+			args: []string{"-repo", root, "-config", configFile},
+			want: settings{":9100", 5, 45 * time.Second, 512, true, filepath.Join(root, "web-ui", "state")},
+		},
+		{
+			name: "flags over environment",
+			env:  map[string]string{"WEBUI_LISTEN": ":9100", "WEBUI_WORKERS": "5", "WEBUI_TEST_TIMEOUT": "1m"},
+			args: []string{"-repo", root, "-config", configFile, "-listen", ":9200", "-memory-mb", "256", "-data", "here"},
+			want: settings{":9200", 5, time.Minute, 256, false, filepath.Join(mustGetwd(t), "here")},
+		},
+		{
+			name: "environment without file",
+			env:  map[string]string{"WEBUI_REPO": root, "WEBUI_TEST_TIMEOUT": "1m", "WEBUI_GITHUB_STARS": "false"},
+			want: settings{defaults.Listen, defaults.Workers, time.Minute, 0, false, filepath.Join(root, "web-ui", ".data")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, err := parse(tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := settings{cfg.Listen, cfg.Workers, time.Duration(cfg.Limits.TestTimeout), cfg.Limits.MemoryMB, cfg.Features.GitHubStars, cfg.DataDir}
+			if got != tt.want {
+				t.Errorf("settings = %+v\nwant %+v", got, tt.want)
+			}
+			if cfg.RepoRoot != root || cfg.PackagesDir != filepath.Join(root, "packages") {
+				t.Errorf("repository root %s with packages %s, want %s", cfg.RepoRoot, cfg.PackagesDir, root)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	root := newRepo(t)
+	badFile := filepath.Join(root, "bad.json")
+	if err := os.WriteFile(badFile, []byte(`{"listen": ":9000", "port": 9000}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"invalid environment value", map[string]string{"WEBUI_WORKERS": "many"}, []string{"-repo", root}, "invalid WEBUI_WORKERS"},
+		{"unknown field in the file", nil, []string{"-repo", root, "-config", badFile}, `unknown field "port"`},
+		{"missing file", nil, []string{"-repo", root, "-config", filepath.Join(root, "missing.json")}, "failed to read config file"},
+		{"invalid setting", nil, []string{"-repo", root, "-workers", "0"}, "workers must be at least 1"},
+		{"missing repository", nil, []string{"-repo", filepath.Join(root, "missing")}, "is not a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if _, err := parse(tt.args...); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindRepoRoot(t *testing.T) {
+	root := newRepo(t)
+	for _, dir := range []string{"", "challenge-1", "challenge-2/submissions/alice", "packages/gin/challenge-1-basic-routing", "web-ui/internal"} {
+		got, err := FindRepoRoot(filepath.Join(root, filepath.FromSlash(dir)))
+		if err != nil || got != root {
+			t.Errorf("FindRepoRoot(%s) = %s, %v, want %s", dir, got, err, root)
+		}
+	}
+
+	// A challenge-1 without packages next to it is not the root
+	nested := filepath.Join(root, "web-ui", "internal", "challenge-1")
+	if err := os.Mkdir(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := FindRepoRoot(nested); err != nil || got != root {
+		t.Errorf("FindRepoRoot from a nested challenge-1 = %s, %v, want %s", got, err, root)
+	}
+
+	if got, err := FindRepoRoot(t.TempDir()); err == nil {
+		t.Errorf("FindRepoRoot outside a repository = %s, want an error", got)
+	}
+}
+
+func TestParseFindsRepoRoot(t *testing.T) {
+	root := newRepo(t)
+	wd := mustGetwd(t)
+	t.Cleanup(func() { os.Chdir(wd) })
+	if err := os.Chdir(filepath.Join(root, "challenge-2", "submissions", "alice")); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RepoRoot != root || cfg.ModulesDir != filepath.Join(root, "web-ui", ".modules") {
+		t.Errorf("repository root %s with modules %s, want %s", cfg.RepoRoot, cfg.ModulesDir, root)
+	}
+
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse(); err == nil || !strings.Contains(err.Error(), "no repository root") {
+		t.Errorf("error outside a repository = %v, want no repository root", err)
+	}
+}
+
+// mustGetwd returns the working directory relative flag paths resolve against
+func mustGetwd(t *testing.T) string {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wd
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ReloadContent rereads the challenges, scoreboards and packages from disk
+// and reports the files that changed since the last reload. Only requests
+// with the admin token are served.
+func (h *APIHandler) ReloadContent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authService.IsAdmin(r) {
+		http.Error(w, "Reloading needs the admin token", http.StatusForbidden)
+		return
+	}
+
+	result := h.contentWatcher.Reload()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
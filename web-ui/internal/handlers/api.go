@@ -1,16 +1,18 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"web-ui/internal/auth"
 	"web-ui/internal/models"
 	"web-ui/internal/services"
 	"web-ui/internal/utils"
@@ -23,7 +25,13 @@ type APIHandler struct {
 	userService       *services.UserService
 	executionService  *services.ExecutionService
 	packageService    *services.PackageService
-	submissions       []models.Submission
+	jobService        *services.JobService
+	submissionStore   services.SubmissionStore
+	contentWatcher    *services.ContentWatcher
+	authService       *auth.Service
+	teamService       *services.TeamService
+	interviewService  *services.InterviewService
+	recorderService   *services.RecorderService
 }
 
 // NewAPIHandler creates a new API handler
@@ -33,6 +41,13 @@ func NewAPIHandler(
 	userService *services.UserService,
 	executionService *services.ExecutionService,
 	packageService *services.PackageService,
+	jobService *services.JobService,
+	submissionStore services.SubmissionStore,
+	contentWatcher *services.ContentWatcher,
+	authService *auth.Service,
+	teamService *services.TeamService,
+	interviewService *services.InterviewService,
+	recorderService *services.RecorderService,
 ) *APIHandler {
 	return &APIHandler{
 		challengeService:  challengeService,
@@ -40,7 +55,13 @@ func NewAPIHandler(
 		userService:       userService,
 		executionService:  executionService,
 		packageService:    packageService,
-		submissions:       make([]models.Submission, 0),
+		jobService:        jobService,
+		submissionStore:   submissionStore,
+		contentWatcher:    contentWatcher,
+		authService:       authService,
+		teamService:       teamService,
+		interviewService:  interviewService,
+		recorderService:   recorderService,
 	}
 }
 
@@ -102,12 +123,27 @@ func (h *APIHandler) HandleSubmissions(w http.ResponseWriter, r *http.Request) {
 
 // createSubmission creates a new submission
 func (h *APIHandler) createSubmission(w http.ResponseWriter, r *http.Request) {
-	var submission models.Submission
-	err := json.NewDecoder(r.Body).Decode(&submission)
+	var request struct {
+		models.Submission
+		Async    bool `json:"async"`    // Return a job ID instead of waiting for the run
+		Coverage bool `json:"coverage"` // Measure the coverage of the solution, which runs the tests on a copy of the workspace
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		http.Error(w, "Invalid request data", http.StatusBadRequest)
 		return
 	}
+	submission := request.Submission
+	if codeTooLarge(w, submission.Code) {
+		return
+	}
+
+	// Submissions count for the signed-in user, whoever the request names
+	username, ok := h.actingUser(w, r, submission.Username)
+	if !ok {
+		return
+	}
+	submission.Username = username
 
 	// Set submission timestamp
 	submission.SubmittedAt = time.Now()
@@ -119,31 +155,202 @@ func (h *APIHandler) createSubmission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Run the code
-	result := h.executionService.RunCode(submission.Code, challenge)
-	submission.Passed = result.Passed
-	submission.TestOutput = result.Output
-	submission.ExecutionMs = result.ExecutionMs
+	// Benchmark thresholds declared by the challenge count toward passing, and
+	// the coverage of the solution, when asked for, is kept with the submission
+	opts := services.RunOptions{Benchmark: challenge.Benchmark != nil, Coverage: request.Coverage}
+
+	owner := h.jobOwner(r, submission.Username)
+	h.startJob(w, r, owner, "submission", request.Async, func(ctx context.Context, onOutput services.OutputFunc) interface{} {
+		result := h.executionService.RunCodeOptions(ctx, submission.Code, challenge, opts, onOutput)
+		if ctx.Err() != nil {
+			// Canceled runs are not submissions
+			return result
+		}
+		return h.recordSubmission(submission, result)
+	})
+}
+
+// codeTooLarge answers a request with code over services.MaxCodeBytes and
+// reports whether it did
+func codeTooLarge(w http.ResponseWriter, code string) bool {
+	if len(code) <= services.MaxCodeBytes {
+		return false
+	}
+	http.Error(w, fmt.Sprintf("Code is too large, the limit is %d KB", services.MaxCodeBytes>>10), http.StatusRequestEntityTooLarge)
+	return true
+}
 
-	// Store submission
-	h.submissions = append(h.submissions, submission)
+// recordSubmission stores a submission with the result of its run
+func (h *APIHandler) recordSubmission(submission models.Submission, result services.ExecutionResult) services.SubmissionRecord {
+	record := h.storeSubmission(services.SubmissionRecord{Submission: submission}, result)
+	submission = record.Submission
+	if result.Report != nil {
+		// Score the attempt from the exact test counts
+		h.userService.RecordScore(submission.Username, submission.ChallengeID, result.Report.Score())
+	}
 
 	// Add to scoreboard if passed
 	if submission.Passed {
 		h.scoreboardService.AddSubmission(submission)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(submission)
+	return record
+}
+
+// storeSubmission fills in a submission from the result of its run and adds
+// it to the submission store. A store failure is logged; the run's result is
+// still returned to the user.
+func (h *APIHandler) storeSubmission(record services.SubmissionRecord, result services.ExecutionResult) services.SubmissionRecord {
+	record.Passed = result.Passed
+	record.TestOutput = result.Output
+	record.ExecutionMs = result.ExecutionMs
+	record.Status = string(result.Status)
+	record.Message = result.Message
+	if result.Coverage != nil {
+		coverage := result.Coverage.Percent
+		record.Coverage = &coverage
+	}
+	if result.Report != nil {
+		record.TestsPassed = result.Report.Passed
+		record.TestsTotal = result.Report.Total
+	}
+	record.Result = &result
+
+	if err := h.submissionStore.Add(&record); err != nil {
+		log.Printf("Failed to store submission of %s: %v", record.Username, err)
+	}
+	return record
 }
 
-// getSubmissions returns all submissions
+// getSubmissions lists stored submissions, newest first. Query parameters
+// filter them: username, challengeId, package, packageChallengeId, passed,
+// since and until (RFC 3339), and offset and limit page through them. The
+// list leaves out code and results; GET /api/submissions/{id} has them.
 func (h *APIHandler) getSubmissions(w http.ResponseWriter, r *http.Request) {
+	query, err := parseSubmissionQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.submissionStore.Query(query)
+	if err != nil {
+		http.Error(w, "Failed to read submissions", http.StatusInternalServerError)
+		return
+	}
+	for i := range page.Submissions {
+		page.Submissions[i].Code = ""
+		page.Submissions[i].TestOutput = ""
+		page.Submissions[i].Result = nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// Page sizes of the submission list
+const (
+	defaultSubmissionLimit = 50
+	maxSubmissionLimit     = 500
+)
+
+// parseSubmissionQuery reads the filters and page of the submission list
+func parseSubmissionQuery(values url.Values) (services.SubmissionQuery, error) {
+	query := services.SubmissionQuery{
+		Username:           values.Get("username"),
+		PackageName:        values.Get("package"),
+		PackageChallengeID: values.Get("packageChallengeId"),
+		Limit:              defaultSubmissionLimit,
+	}
+
+	var err error
+	if v := values.Get("challengeId"); v != "" {
+		if query.ChallengeID, err = strconv.Atoi(v); err != nil {
+			return query, fmt.Errorf("Invalid challengeId")
+		}
+	}
+	if v := values.Get("passed"); v != "" {
+		passed, err := strconv.ParseBool(v)
+		if err != nil {
+			return query, fmt.Errorf("Invalid passed, expected true or false")
+		}
+		query.Passed = &passed
+	}
+	if v := values.Get("since"); v != "" {
+		if query.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return query, fmt.Errorf("Invalid since, expected an RFC 3339 time")
+		}
+	}
+	if v := values.Get("until"); v != "" {
+		if query.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return query, fmt.Errorf("Invalid until, expected an RFC 3339 time")
+		}
+	}
+	if v := values.Get("offset"); v != "" {
+		if query.Offset, err = strconv.Atoi(v); err != nil || query.Offset < 0 {
+			return query, fmt.Errorf("Invalid offset")
+		}
+	}
+	if v := values.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit < 1 || query.Limit > maxSubmissionLimit {
+			return query, fmt.Errorf("Invalid limit, expected 1 to %d", maxSubmissionLimit)
+		}
+	}
+	return query, nil
+}
+
+// GetSubmission returns a stored submission with its code and full result
+func (h *APIHandler) GetSubmission(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract submission ID from URL
+	path := strings.TrimPrefix(r.URL.Path, "/api/submissions/")
+	id, err := strconv.ParseInt(path, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid submission ID", http.StatusBadRequest)
+		return
+	}
+
+	// Submissions the user may not see are not found, so their IDs cannot be
+	// told apart from unused ones
+	record, err := h.submissionStore.Get(id)
+	if err == services.ErrSubmissionNotFound || (err == nil && !h.canViewSubmissions(r, record.Username)) {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read submission", http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(h.submissions)
+	json.NewEncoder(w).Encode(record)
+}
+
+// canViewSubmissions reports whether a request may see the code and results
+// of owner's submissions: the viewing user's own, and, once signed in, those
+// of the members of teams they own or mentor
+func (h *APIHandler) canViewSubmissions(r *http.Request, owner string) bool {
+	if viewer := viewingUser(h.authService, r); viewer != "" && strings.EqualFold(viewer, owner) {
+		return true
+	}
+	mentor := sessionUser(h.authService, r)
+	if mentor == "" {
+		return false
+	}
+	for _, team := range h.teamService.UserTeams(mentor) {
+		if _, member := team.Member(owner); member && team.RoleOf(mentor) != models.RoleMember {
+			return true
+		}
+	}
+	return false
 }
 
-// GetScoreboard returns the scoreboard for a challenge
+// GetScoreboard returns the scoreboard for a challenge, or with ?team={id}
+// the members of a team on it
 func (h *APIHandler) GetScoreboard(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -158,10 +365,16 @@ func (h *APIHandler) GetScoreboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	team, ok := teamScope(h.teamService, h.authService, w, r)
+	if !ok {
+		return
+	}
+
 	scoreboard, exists := h.scoreboardService.GetScoreboard(id)
 	if !exists {
 		scoreboard = []models.ScoreboardEntry{}
 	}
+	scoreboard = teamScoreboard(scoreboard, team)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(scoreboard)
@@ -177,6 +390,9 @@ func (h *APIHandler) RunCode(w http.ResponseWriter, r *http.Request) {
 	var request struct {
 		ChallengeID int    `json:"challengeId"`
 		Code        string `json:"code"`
+		Async       bool   `json:"async"`     // Return a job ID instead of waiting for the run
+		Benchmark   bool   `json:"benchmark"` // Also run the benchmarks once the tests pass
+		Coverage    bool   `json:"coverage"`  // Report which lines of the solution the tests ran
 	}
 
 	err := json.NewDecoder(r.Body).Decode(&request)
@@ -184,6 +400,9 @@ func (h *APIHandler) RunCode(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request data", http.StatusBadRequest)
 		return
 	}
+	if codeTooLarge(w, request.Code) {
+		return
+	}
 
 	challenge, exists := h.challengeService.GetChallenge(request.ChallengeID)
 	if !exists {
@@ -191,10 +410,10 @@ func (h *APIHandler) RunCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result := h.executionService.RunCode(request.Code, challenge)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	opts := services.RunOptions{Benchmark: request.Benchmark, Coverage: request.Coverage}
+	h.startJob(w, r, h.jobOwner(r, ""), "run", request.Async, func(ctx context.Context, onOutput services.OutputFunc) interface{} {
+		return h.executionService.RunCodeOptions(ctx, request.Code, challenge, opts, onOutput)
+	})
 }
 
 // SaveSubmissionToFilesystem saves a submission to the filesystem
@@ -211,22 +430,29 @@ func (h *APIHandler) SaveSubmissionToFilesystem(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	if request.Username == "" {
+	username, ok := h.actingUser(w, r, request.Username)
+	if !ok {
+		return
+	}
+	if username == "" {
 		http.Error(w, "Username is required", http.StatusBadRequest)
 		return
 	}
+	request.Username = username
 
 	// Set username cookie
 	h.setUsernameCookie(w, request.Username)
 
 	// Validate challenge exists
-	_, exists := h.challengeService.GetChallenge(request.ChallengeID)
+	challenge, exists := h.challengeService.GetChallenge(request.ChallengeID)
 	if !exists {
 		http.Error(w, "Challenge not found", http.StatusNotFound)
 		return
 	}
 
-	response := h.executionService.SaveSubmissionToFilesystem(request)
+	target := services.SubmissionTarget(services.ChallengeRef{ChallengeID: challenge.ID, Dir: challenge.Dir}, request.Username)
+	response := services.SaveSubmission(h.challengeService.RepoRoot(), target, request.Code,
+		fmt.Sprintf("Add solution for Challenge %d", request.ChallengeID))
 
 	// Clear user attempts cache
 	h.userService.RefreshUserAttempts(request.Username, h.challengeService.GetChallenges())
@@ -282,7 +508,11 @@ func (h *APIHandler) GetGitUsername(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	gitInfo := utils.GetGitUsername()
+	// With signing in required, the server's git user is nobody's identity
+	gitInfo := &utils.GitUserInfo{}
+	if !h.authService.Required() {
+		gitInfo = utils.GetGitUsername()
+	}
 
 	response := struct {
 		Username string `json:"username"`
@@ -346,55 +576,10 @@ func (h *APIHandler) GetMainScoreboardRank(w http.ResponseWriter, r *http.Reques
 
 // calculateMainScoreboardRank calculates the user's rank based on completed challenges
 func (h *APIHandler) calculateMainScoreboardRank(username string) int {
-	// Get all users and their completion counts (only count if ALL tests passed)
-	challenges := h.challengeService.GetChallenges()
+	// Count the challenges each user passed ALL tests of
 	userCompletions := make(map[string]int)
-
-	// Process all challenge scoreboards to count actual completions
-	for challengeID := range challenges {
-		// Read scoreboard file directly to check test results
-		scoreboardPath := fmt.Sprintf("../challenge-%d/SCOREBOARD.md", challengeID)
-		content, err := ioutil.ReadFile(scoreboardPath)
-		if err != nil {
-			// Try alternative path
-			scoreboardPath = fmt.Sprintf("challenge-%d/SCOREBOARD.md", challengeID)
-			content, err = ioutil.ReadFile(scoreboardPath)
-			if err != nil {
-				continue
-			}
-		}
-
-		// Parse scoreboard to find users who passed ALL tests
-		lines := strings.Split(string(content), "\n")
-		for _, line := range lines {
-			// Skip header and separator lines
-			if !strings.Contains(line, "|") || strings.Contains(line, "Username") || strings.Contains(line, "---") {
-				continue
-			}
-
-			parts := strings.Split(line, "|")
-			if len(parts) < 4 {
-				continue
-			}
-
-			username := strings.TrimSpace(parts[1])
-			passedTestsStr := strings.TrimSpace(parts[2])
-			totalTestsStr := strings.TrimSpace(parts[3])
-
-			// Skip empty usernames or placeholders
-			if username == "" || username == "------" {
-				continue
-			}
-
-			// Parse test counts
-			passedTests, err1 := strconv.Atoi(passedTestsStr)
-			totalTests, err2 := strconv.Atoi(totalTestsStr)
-
-			// Only count as completed if ALL tests passed
-			if err1 == nil && err2 == nil && passedTests > 0 && passedTests == totalTests {
-				userCompletions[username]++
-			}
-		}
+	for user, completions := range h.scoreboardService.Completions() {
+		userCompletions[user] = len(completions)
 	}
 
 	// Get the target user's completion count
@@ -414,15 +599,21 @@ func (h *APIHandler) calculateMainScoreboardRank(username string) int {
 	return rank
 }
 
-// GetMainLeaderboard returns the main leaderboard data
+// GetMainLeaderboard returns the main leaderboard data, or with ?team={id}
+// the members of a team ranked among themselves
 func (h *APIHandler) GetMainLeaderboard(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	team, ok := teamScope(h.teamService, h.authService, w, r)
+	if !ok {
+		return
+	}
+
 	// Calculate leaderboard data
-	leaderboard := h.calculateMainLeaderboard()
+	leaderboard := teamLeaderboard(h.calculateMainLeaderboard(), team)
 
 	response := struct {
 		Leaderboard []LeaderboardUser `json:"leaderboard"`
@@ -450,57 +641,7 @@ type LeaderboardUser struct {
 func (h *APIHandler) calculateMainLeaderboard() []LeaderboardUser {
 	challenges := h.challengeService.GetChallenges()
 	totalChallenges := len(challenges)
-	userCompletions := make(map[string]map[int]bool)
-
-	// Process all challenge scoreboards to find completions
-	for challengeID := range challenges {
-		// Read scoreboard file directly to check test results
-		scoreboardPath := fmt.Sprintf("../challenge-%d/SCOREBOARD.md", challengeID)
-		content, err := ioutil.ReadFile(scoreboardPath)
-		if err != nil {
-			// Try alternative path
-			scoreboardPath = fmt.Sprintf("challenge-%d/SCOREBOARD.md", challengeID)
-			content, err = ioutil.ReadFile(scoreboardPath)
-			if err != nil {
-				continue
-			}
-		}
-
-		// Parse scoreboard to find users who passed ALL tests
-		lines := strings.Split(string(content), "\n")
-		for _, line := range lines {
-			// Skip header and separator lines
-			if !strings.Contains(line, "|") || strings.Contains(line, "Username") || strings.Contains(line, "---") {
-				continue
-			}
-
-			parts := strings.Split(line, "|")
-			if len(parts) < 4 {
-				continue
-			}
-
-			username := strings.TrimSpace(parts[1])
-			passedTestsStr := strings.TrimSpace(parts[2])
-			totalTestsStr := strings.TrimSpace(parts[3])
-
-			// Skip empty usernames or placeholders
-			if username == "" || username == "------" {
-				continue
-			}
-
-			// Parse test counts
-			passedTests, err1 := strconv.Atoi(passedTestsStr)
-			totalTests, err2 := strconv.Atoi(totalTestsStr)
-
-			// Only count as completed if ALL tests passed
-			if err1 == nil && err2 == nil && passedTests > 0 && passedTests == totalTests {
-				if userCompletions[username] == nil {
-					userCompletions[username] = make(map[int]bool)
-				}
-				userCompletions[username][challengeID] = true
-			}
-		}
-	}
+	userCompletions := h.scoreboardService.Completions()
 
 	// Convert to leaderboard format
 	var leaderboard []LeaderboardUser
@@ -577,6 +718,7 @@ func (h *APIHandler) HandlePackageChallenge(w http.ResponseWriter, r *http.Reque
 	var request struct {
 		Code     string `json:"code"`
 		Username string `json:"username"`
+		Async    bool   `json:"async"` // Return a job ID instead of waiting for the run
 	}
 
 	body, err := ioutil.ReadAll(r.Body)
@@ -595,6 +737,9 @@ func (h *APIHandler) HandlePackageChallenge(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Code is required", http.StatusBadRequest)
 		return
 	}
+	if codeTooLarge(w, request.Code) {
+		return
+	}
 
 	// Use the existing package service
 	packageService := h.packageService
@@ -605,70 +750,67 @@ func (h *APIHandler) HandlePackageChallenge(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Convert PackageChallenge to Challenge format for ExecutionService
-	challengeForExecution := &models.Challenge{
-		ID:       0, // Package challenges don't use numeric IDs
-		Title:    challenge.Title,
-		TestFile: challenge.TestFile,
+	challengeForExecution := services.PackageExecutionChallenge(challenge)
+
+	// Submissions count for the signed-in user, whoever the request names
+	if action == "submit" {
+		username, ok := h.actingUser(w, r, request.Username)
+		if !ok {
+			return
+		}
+		request.Username = username
+	}
+
+	// Set username cookie if provided
+	if action == "submit" && request.Username != "" {
+		h.setUsernameCookie(w, request.Username)
 	}
 
 	// Run the actual tests using ExecutionService
-	result := h.executionService.RunCode(request.Code, challengeForExecution)
+	submittedAt := time.Now()
+	owner := h.jobOwner(r, request.Username)
+	h.startJob(w, r, owner, "package_"+action, request.Async, func(ctx context.Context, onOutput services.OutputFunc) interface{} {
+		result := h.executionService.RunCodeStream(ctx, request.Code, challengeForExecution, onOutput)
+		response := packageRunResponse(result, action)
+		if action == "submit" && ctx.Err() == nil {
+			record := h.storeSubmission(services.SubmissionRecord{
+				Submission: models.Submission{
+					Username:    request.Username,
+					Code:        request.Code,
+					SubmittedAt: submittedAt,
+				},
+				PackageName:        packageName,
+				PackageChallengeID: challengeId,
+			}, result)
+			response["submission_id"] = record.ID
+		}
+		return response
+	})
+}
 
-	// Format response
+// packageRunResponse formats the result of a package challenge run
+func packageRunResponse(result services.ExecutionResult, action string) map[string]interface{} {
 	response := map[string]interface{}{
 		"success":      result.Passed,
+		"status":       result.Status,
+		"message":      result.Message,
 		"execution_ms": result.ExecutionMs,
 		"output":       result.Output,
+		"report":       result.Report,
+		"tests_passed": 0,
+		"tests_total":  0,
+	}
+	if result.Report != nil {
+		response["tests_passed"] = result.Report.Passed
+		response["tests_total"] = result.Report.Total
 	}
-
-	// Count passed tests from output for display
-	testsPassed, testsTotal := h.parseTestResults(result.Output)
-	response["tests_passed"] = testsPassed
-	response["tests_total"] = testsTotal
 
 	if action == "submit" && result.Passed {
 		response["message"] = "Solution submitted successfully!"
 		response["show_pr_instructions"] = true
-
-		// Set username cookie if provided
-		if request.Username != "" {
-			h.setUsernameCookie(w, request.Username)
-		}
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
-
-// parseTestResults parses Go test output to count passed and total tests
-func (h *APIHandler) parseTestResults(output string) (passed int, total int) {
-	lines := strings.Split(output, "\n")
-
-	for _, line := range lines {
-		// Look for test result lines like "--- PASS: TestGetUsers" or "--- FAIL: TestCreateUser"
-		if strings.Contains(line, "--- PASS:") {
-			passed++
-			total++
-		} else if strings.Contains(line, "--- FAIL:") {
-			total++
-		}
-	}
-
-	// If no individual test results found, check for overall result
-	if total == 0 {
-		if strings.Contains(output, "PASS") && !strings.Contains(output, "FAIL") {
-			// Assume basic success case
-			passed = 1
-			total = 1
-		} else if strings.Contains(output, "FAIL") {
-			// Assume basic failure case
-			passed = 0
-			total = 1
-		}
 	}
 
-	return passed, total
+	return response
 }
 
 // SavePackageChallengeToFilesystem saves a package challenge submission to the filesystem
@@ -691,87 +833,31 @@ func (h *APIHandler) SavePackageChallengeToFilesystem(w http.ResponseWriter, r *
 		return
 	}
 
-	if request.Username == "" {
+	username, ok := h.actingUser(w, r, request.Username)
+	if !ok {
+		return
+	}
+	if username == "" {
 		http.Error(w, "Username is required", http.StatusBadRequest)
 		return
 	}
+	request.Username = username
 
 	// Set username cookie
 	h.setUsernameCookie(w, request.Username)
 
 	// Validate challenge exists
-	_, err = h.packageService.GetPackageChallenge(request.PackageName, request.ChallengeID)
+	challenge, err := h.packageService.GetPackageChallenge(request.PackageName, request.ChallengeID)
 	if err != nil {
 		http.Error(w, "Challenge not found", http.StatusNotFound)
 		return
 	}
 
 	// Save to filesystem
-	response := h.savePackageChallengeToFilesystem(request)
+	ref := services.ChallengeRef{PackageName: request.PackageName, PackageChallengeID: request.ChallengeID, Dir: challenge.Dir}
+	response := services.SaveSubmission(h.challengeService.RepoRoot(), services.SubmissionTarget(ref, request.Username), request.Code,
+		fmt.Sprintf("Add solution for %s %s by %s", request.PackageName, request.ChallengeID, request.Username))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
-
-// savePackageChallengeToFilesystem handles the actual file saving for package challenges
-func (h *APIHandler) savePackageChallengeToFilesystem(request struct {
-	Username    string `json:"username"`
-	PackageName string `json:"packageName"`
-	ChallengeID string `json:"challengeId"`
-	Code        string `json:"code"`
-}) services.SaveSubmissionResponse {
-	// Get working directory for correct relative paths
-	workDir, _ := os.Getwd()
-
-	// Try different path approaches to handle potential path issues
-	var submissionDir string
-	var fileSaved bool
-
-	// Try multiple path options for package challenges
-	pathOptions := []string{
-		// Option 1: From web-ui directory (standard case)
-		filepath.Join("..", "packages", request.PackageName, request.ChallengeID, "submissions", request.Username),
-		// Option 2: From root workspace
-		filepath.Join("packages", request.PackageName, request.ChallengeID, "submissions", request.Username),
-		// Option 3: Absolute path from detected workspace root
-		filepath.Join(workDir, "..", "packages", request.PackageName, request.ChallengeID, "submissions", request.Username),
-	}
-
-	for _, dirPath := range pathOptions {
-		err := os.MkdirAll(dirPath, 0755)
-		if err != nil {
-			continue
-		}
-
-		solutionFile := filepath.Join(dirPath, "solution.go")
-		err = ioutil.WriteFile(solutionFile, []byte(request.Code), 0644)
-		if err != nil {
-			continue
-		}
-
-		submissionDir = dirPath
-		fileSaved = true
-		break
-	}
-
-	if !fileSaved {
-		return services.SaveSubmissionResponse{
-			Success: false,
-			Message: "Failed to save solution to any available path",
-		}
-	}
-
-	// Return success response with git commands
-	relativePath := filepath.Join("packages", request.PackageName, request.ChallengeID, "submissions", request.Username, "solution.go")
-	return services.SaveSubmissionResponse{
-		Success:  true,
-		Message:  "Solution saved to filesystem",
-		FilePath: filepath.Join(submissionDir, "solution.go"),
-		GitCommands: []string{
-			"cd " + filepath.Join(workDir, ".."),
-			fmt.Sprintf("git add %s", relativePath),
-			fmt.Sprintf("git commit -m \"Add solution for %s %s by %s\"", request.PackageName, request.ChallengeID, request.Username),
-			"git push origin main",
-		},
-	}
-}
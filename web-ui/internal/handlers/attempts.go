@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"web-ui/internal/services"
+)
+
+// GetUserAttempts lists a user's attempts on a challenge, oldest first:
+// /api/users/{username}/challenges/{id}/attempts. The ID is a classic
+// challenge number, or a package challenge ID with ?package={package}. Only
+// the user and the mentors of their teams see the attempts.
+func (h *APIHandler) GetUserAttempts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse URL path: /api/users/{username}/challenges/{id}/attempts
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/users/"), "/")
+	if len(parts) != 4 || parts[0] == "" || parts[1] != "challenges" || parts[3] != "attempts" {
+		http.Error(w, "Invalid URL format. Expected: /api/users/{username}/challenges/{id}/attempts", http.StatusBadRequest)
+		return
+	}
+	username, challenge := parts[0], parts[2]
+	if !h.canViewSubmissions(r, username) {
+		http.Error(w, "Attempts not found", http.StatusNotFound)
+		return
+	}
+
+	var challengeID int
+	packageName := r.URL.Query().Get("package")
+	packageChallengeID := ""
+	if packageName != "" {
+		if _, err := h.packageService.GetPackageChallenge(packageName, challenge); err != nil {
+			http.Error(w, "Challenge not found", http.StatusNotFound)
+			return
+		}
+		packageChallengeID = challenge
+	} else {
+		id, err := strconv.Atoi(challenge)
+		if err != nil {
+			http.Error(w, "Invalid challenge ID", http.StatusBadRequest)
+			return
+		}
+		if _, exists := h.challengeService.GetChallenge(id); !exists {
+			http.Error(w, "Challenge not found", http.StatusNotFound)
+			return
+		}
+		challengeID = id
+	}
+
+	attempts, err := services.Attempts(h.submissionStore, username, challengeID, packageName, packageChallengeID)
+	if err != nil {
+		http.Error(w, "Failed to read submissions", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Username string             `json:"username"`
+		Attempts []services.Attempt `json:"attempts"`
+	}{
+		Username: username,
+		Attempts: attempts,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DiffAttempts compares two submissions: /api/attempts/{a}/diff/{b} returns
+// a unified and a side-by-side diff of their code and the tests that flipped
+// between passing and failing from a to b. Both have to be attempts on the
+// same challenge the viewing user may see, as in GetSubmission.
+func (h *APIHandler) DiffAttempts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse URL path: /api/attempts/{a}/diff/{b}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/attempts/"), "/")
+	if len(parts) != 3 || parts[1] != "diff" {
+		http.Error(w, "Invalid URL format. Expected: /api/attempts/{a}/diff/{b}", http.StatusBadRequest)
+		return
+	}
+
+	var records [2]*services.SubmissionRecord
+	for i, part := range []string{parts[0], parts[2]} {
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid attempt ID", http.StatusBadRequest)
+			return
+		}
+		// Other users' attempts are not found, as in GetSubmission
+		records[i], err = h.submissionStore.Get(id)
+		if err == services.ErrSubmissionNotFound || (err == nil && !h.canViewSubmissions(r, records[i].Username)) {
+			http.Error(w, "Attempt not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to read submission", http.StatusInternalServerError)
+			return
+		}
+	}
+	a, b := records[0], records[1]
+	if len(a.Code) > services.MaxCodeBytes || len(b.Code) > services.MaxCodeBytes {
+		// Stored before the size of solutions was limited
+		http.Error(w, "Attempts are too large to compare", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if a.ChallengeID != b.ChallengeID || a.PackageName != b.PackageName || a.PackageChallengeID != b.PackageChallengeID {
+		http.Error(w, "Attempts are on different challenges", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(services.DiffSubmissions(a, b))
+}
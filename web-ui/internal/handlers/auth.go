@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"embed"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"web-ui/internal/auth"
+	"web-ui/internal/utils"
+)
+
+// oauthStateCookie keeps the state of a provider login in the browser that
+// started it, so a callback cannot sign in someone else's browser
+const oauthStateCookie = "oauth_state"
+
+// AuthHandler handles signing in with local accounts and login providers
+type AuthHandler struct {
+	content     embed.FS
+	authService *auth.Service
+}
+
+// NewAuthHandler creates a new auth handler
+func NewAuthHandler(content embed.FS, authService *auth.Service) *AuthHandler {
+	return &AuthHandler{
+		content:     content,
+		authService: authService,
+	}
+}
+
+// credentials is the body of the register and login endpoints
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Register creates a local account and signs it in
+func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request credentials
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.authService.Register(strings.TrimSpace(request.Username), request.Password)
+	switch {
+	case err == auth.ErrSignupDisabled, err == auth.ErrUsernameReserved:
+		writeAuthResult(w, http.StatusForbidden, "", err.Error())
+		return
+	case err == auth.ErrUsernameTaken:
+		writeAuthResult(w, http.StatusConflict, "", err.Error())
+		return
+	case err != nil:
+		writeAuthResult(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+	h.signIn(w, r, user.Username)
+}
+
+// Login signs a local account in with its password
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request credentials
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.authService.Authenticate(strings.TrimSpace(request.Username), request.Password)
+	if err != nil {
+		writeAuthResult(w, http.StatusUnauthorized, "", err.Error())
+		return
+	}
+	h.signIn(w, r, user.Username)
+}
+
+// signIn starts a session for a user and answers with their username
+func (h *AuthHandler) signIn(w http.ResponseWriter, r *http.Request, username string) {
+	if err := h.startSession(w, r, username); err != nil {
+		log.Printf("Failed to start a session for %s: %v", username, err)
+		writeAuthResult(w, http.StatusInternalServerError, "", "Failed to sign in")
+		return
+	}
+	writeAuthResult(w, http.StatusOK, username, "Signed in as "+username)
+}
+
+// startSession signs a user in and sets the username cookie the pages show
+// the user with
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, username string) error {
+	if err := h.authService.StartSession(w, r, username); err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:    "username",
+		Value:   username,
+		Expires: time.Now().Add(30 * 24 * time.Hour),
+		Path:    "/",
+	})
+	return nil
+}
+
+// Logout ends the session of the request
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.authService.StopSession(w, r); err != nil {
+		log.Printf("Failed to end a session: %v", err)
+	}
+	http.SetCookie(w, &http.Cookie{Name: "username", Value: "", Path: "/", MaxAge: -1})
+	writeAuthResult(w, http.StatusOK, "", "Signed out")
+}
+
+// loginProvider is a login provider as the sign-in page and /api/auth/me list it
+type loginProvider struct {
+	Name     string `json:"name"`
+	Title    string `json:"title"`
+	LoginURL string `json:"loginUrl"`
+}
+
+// Me describes who the request is signed in as and how users can sign in
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username, signedIn := h.authService.RequestUser(r)
+	response := struct {
+		Authenticated bool            `json:"authenticated"`
+		Username      string          `json:"username,omitempty"`
+		Required      bool            `json:"required"` // Submitting and saving need signing in
+		Signup        bool            `json:"signup"`
+		Providers     []loginProvider `json:"providers"`
+	}{
+		Authenticated: signedIn,
+		Username:      username,
+		Required:      h.authService.Required(),
+		Signup:        h.authService.Signup(),
+		Providers:     h.loginProviders(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// loginProviders lists the login providers with their login URLs
+func (h *AuthHandler) loginProviders() []loginProvider {
+	providers := []loginProvider{}
+	for _, provider := range h.authService.Providers() {
+		providers = append(providers, loginProvider{
+			Name:     provider.Name(),
+			Title:    provider.Title(),
+			LoginURL: "/auth/" + provider.Name() + "/login",
+		})
+	}
+	return providers
+}
+
+// HandleProvider handles the login flow of a provider:
+// /auth/{provider}/login sends the user to the provider and
+// /auth/{provider}/callback signs them in when they come back
+func (h *AuthHandler) HandleProvider(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/auth/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	providerName, step := parts[0], parts[1]
+	redirectURL := h.authService.CallbackURL(r, providerName)
+
+	switch step {
+	case "login":
+		authURL, state, err := h.authService.BeginLogin(providerName, redirectURL, r.URL.Query().Get("next"))
+		if err == auth.ErrUnknownProvider {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to start signing in", http.StatusInternalServerError)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     oauthStateCookie,
+			Value:    state,
+			Path:     "/auth/",
+			MaxAge:   int((10 * time.Minute).Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode, // Sent along when the provider redirects back
+		})
+		http.Redirect(w, r, authURL, http.StatusFound)
+
+	case "callback":
+		query := r.URL.Query()
+		if message := query.Get("error_description"); message != "" || query.Get("error") != "" {
+			h.loginFailed(w, r, "Signing in was canceled: "+strings.TrimSpace(query.Get("error")+" "+message))
+			return
+		}
+		cookie, err := r.Cookie(oauthStateCookie)
+		if err != nil || cookie.Value == "" || cookie.Value != query.Get("state") {
+			h.loginFailed(w, r, "The login was not started in this browser; please sign in again")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Value: "", Path: "/auth/", MaxAge: -1})
+
+		user, next, err := h.authService.FinishLogin(r.Context(), providerName, query.Get("state"), query.Get("code"), redirectURL)
+		if err != nil {
+			log.Printf("Login with %s failed: %v", providerName, err)
+			h.loginFailed(w, r, err.Error())
+			return
+		}
+		if err := h.startSession(w, r, user.Username); err != nil {
+			log.Printf("Failed to start a session for %s: %v", user.Username, err)
+			h.loginFailed(w, r, "Failed to sign in")
+			return
+		}
+		http.Redirect(w, r, next, http.StatusFound)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// loginFailed shows the sign-in page with an error
+func (h *AuthHandler) loginFailed(w http.ResponseWriter, r *http.Request, message string) {
+	w.WriteHeader(http.StatusUnauthorized)
+	h.renderLogin(w, r, message)
+}
+
+// LoginPage renders the sign-in page
+func (h *AuthHandler) LoginPage(w http.ResponseWriter, r *http.Request) {
+	h.renderLogin(w, r, "")
+}
+
+// renderLogin renders the sign-in page with an optional error
+func (h *AuthHandler) renderLogin(w http.ResponseWriter, r *http.Request, message string) {
+	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/login.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	username, _ := h.authService.RequestUser(r)
+	data := struct {
+		Username  string
+		Error     string
+		Next      string
+		Signup    bool
+		Providers []loginProvider
+	}{
+		Username:  username,
+		Error:     message,
+		Next:      auth.LocalPath(r.URL.Query().Get("next")),
+		Signup:    h.authService.Signup(),
+		Providers: h.loginProviders(),
+	}
+
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Printf("Template execution error: %v", err)
+		// Don't call http.Error here since headers may already be sent during template execution
+	}
+}
+
+// writeAuthResult answers an auth endpoint in the shape the web UI shows
+// results of
+func writeAuthResult(w http.ResponseWriter, status int, username, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  status == http.StatusOK,
+		"username": username,
+		"message":  message,
+	})
+}
+
+// signedInUser returns the user a request acts as when it names username:
+// the signed-in user, or else username unless signing in is required. An
+// invalid username acts as nobody.
+func signedInUser(authService *auth.Service, r *http.Request, username string) string {
+	if signedIn, ok := authService.RequestUser(r); ok {
+		return signedIn
+	}
+	if authService.Required() || !auth.ValidUsername(username) {
+		return ""
+	}
+	return username
+}
+
+// viewingUser returns the user a request is made by: the signed-in user, or
+// else the username cookie unless signing in is required
+func viewingUser(authService *auth.Service, r *http.Request) string {
+	cookie, err := r.Cookie("username")
+	if err != nil {
+		return signedInUser(authService, r, "")
+	}
+	return signedInUser(authService, r, cookie.Value)
+}
+
+// sessionUser returns the user a request's session is signed in as, or ""
+// whatever the username cookie says. Teams and interviews are managed by
+// signed-in users only, even when signing in is not required, since the
+// cookie can name anyone.
+func sessionUser(authService *auth.Service, r *http.Request) string {
+	username, _ := authService.RequestUser(r)
+	return username
+}
+
+// actingUser returns the user a write request acts as: the signed-in user,
+// whatever username the request posts, or the posted username if signing in
+// is not required. ok is false when the request may not act and the error
+// was written.
+func (h *APIHandler) actingUser(w http.ResponseWriter, r *http.Request, posted string) (username string, ok bool) {
+	if signedIn, ok := h.authService.RequestUser(r); ok {
+		return signedIn, true
+	}
+	if h.authService.Required() {
+		http.Error(w, "Sign in required", http.StatusUnauthorized)
+		return "", false
+	}
+	if posted != "" && !auth.ValidUsername(posted) {
+		http.Error(w, "Invalid username", http.StatusBadRequest)
+		return "", false
+	}
+	return posted, true
+}
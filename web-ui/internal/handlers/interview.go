@@ -0,0 +1,731 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"web-ui/internal/models"
+	"web-ui/internal/services"
+	"web-ui/internal/utils"
+)
+
+// interviewCookiePrefix starts the name of the cookie holding the candidate
+// key of an interview session; the session ID completes it
+const interviewCookiePrefix = "interview_"
+
+// interviewTimelineUser is the user of the editing timelines of interview
+// candidates, who have no account; the session tells them apart
+const interviewTimelineUser = "candidate"
+
+// interviewError answers a failed interview operation with the status its
+// error calls for
+func interviewError(w http.ResponseWriter, err error) {
+	switch err {
+	case services.ErrInterviewNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case services.ErrNotInterviewer, services.ErrNotInterviewCandidate:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case services.ErrInterviewOver, services.ErrInterviewLinkUsed:
+		http.Error(w, err.Error(), http.StatusGone)
+	case services.ErrInterviewNotStarted:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case services.ErrInvalidTimeLimit, services.ErrInvalidChallengeCount, services.ErrInvalidInterviewChallenge, services.ErrInvalidCandidateName,
+		services.ErrEditBatchTooLarge, services.ErrTimelineTooLarge:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case services.ErrInvalidEdit:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case services.ErrTooManyAttempts:
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case services.ErrInterviewCodeTooLarge:
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		log.Printf("Interview operation failed: %v", err)
+		http.Error(w, "Failed to update the interview session", http.StatusInternalServerError)
+	}
+}
+
+// interviewTimeline returns the key of the editing timeline of a challenge
+// of a session
+func interviewTimeline(session *services.InterviewSession, challenge int) services.TimelineKey {
+	return services.TimelineKey{
+		User:      interviewTimelineUser,
+		Challenge: session.Challenges[challenge].Key(),
+		Session:   session.ID,
+	}
+}
+
+// candidateKey returns the candidate key a request carries for a session
+func candidateKey(r *http.Request, id string) string {
+	cookie, err := r.Cookie(interviewCookiePrefix + id)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// interviewSummary is a session in the interviewer's list, without the code
+type interviewSummary struct {
+	ID               string                        `json:"id"`
+	Candidate        string                        `json:"candidate,omitempty"`
+	Challenges       []services.InterviewChallenge `json:"challenges"`
+	TimeLimitMinutes int                           `json:"timeLimitMinutes"`
+	CreatedAt        time.Time                     `json:"createdAt"`
+	StartedAt        *time.Time                    `json:"startedAt,omitempty"`
+	State            services.InterviewState       `json:"state"`
+	EndReason        string                        `json:"endReason,omitempty"`
+	Attempts         int                           `json:"attempts"`
+}
+
+// summarizeInterview returns the summary of a session as of now
+func summarizeInterview(session *services.InterviewSession, now time.Time) interviewSummary {
+	_, reason, _ := session.Ended(now)
+	return interviewSummary{
+		ID:               session.ID,
+		Candidate:        session.Candidate,
+		Challenges:       session.Challenges,
+		TimeLimitMinutes: session.TimeLimitMinutes,
+		CreatedAt:        session.CreatedAt,
+		StartedAt:        session.StartedAt,
+		State:            session.State(now),
+		EndReason:        reason,
+		Attempts:         len(session.Attempts),
+	}
+}
+
+// interviewStatus is where a session stands, for the candidate's timer
+type interviewStatus struct {
+	State       services.InterviewState `json:"state"`
+	Deadline    *time.Time              `json:"deadline,omitempty"`
+	RemainingMs int64                   `json:"remainingMs"`
+	EndReason   string                  `json:"endReason,omitempty"`
+}
+
+// statusOf returns the status of a session as of now
+func statusOf(session *services.InterviewSession, now time.Time) interviewStatus {
+	_, reason, _ := session.Ended(now)
+	return interviewStatus{
+		State:       session.State(now),
+		Deadline:    session.Deadline,
+		RemainingMs: session.Remaining(now).Milliseconds(),
+		EndReason:   reason,
+	}
+}
+
+// HandleInterviews handles the interview session endpoints. Interviewers:
+//
+//	GET    /api/interviews                 the sessions of the interviewer
+//	POST   /api/interviews                 create a session: {"candidate", "timeLimitMinutes", "challenges"}
+//	GET    /api/interviews/{id}            a session with every attempt
+//	GET    /api/interviews/{id}/report     the report of a session
+//	POST   /api/interviews/{id}/end        end a session
+//	GET    /api/interviews/{id}/timeline   the edits and test runs of a challenge: ?challenge=0
+//	GET    /api/interviews/{id}/replay     the code of a challenge at a time: ?challenge=0&at={unix ms}
+//
+// Candidates, with the key their link gave them:
+//
+//	GET    /api/interviews/{id}/status     the state and remaining time of the session
+//	POST   /api/interviews/{id}/run        run the tests: {"challenge", "code"}
+//	POST   /api/interviews/{id}/submit     submit a solution: {"challenge", "code"}
+//	PUT    /api/interviews/{id}/draft      save the code in the editor: {"challenge", "code"}
+//	POST   /api/interviews/{id}/edits      record edits made in the editor: {"challenge", "sentAt", "ops"}
+//	POST   /api/interviews/{id}/finish     end the session early
+func (h *APIHandler) HandleInterviews(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/interviews"), "/")
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+
+	switch {
+	case len(parts) == 0 && r.Method == "GET":
+		h.listInterviews(w, r)
+	case len(parts) == 0 && r.Method == "POST":
+		h.createInterview(w, r)
+	case len(parts) == 1 && r.Method == "GET":
+		h.getInterview(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "report" && r.Method == "GET":
+		h.getInterviewReport(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "end" && r.Method == "POST":
+		h.endInterview(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "timeline" && r.Method == "GET":
+		h.getInterviewTimeline(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "replay" && r.Method == "GET":
+		h.replayInterview(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "status" && r.Method == "GET":
+		h.getInterviewStatus(w, r, parts[0])
+	case len(parts) == 2 && (parts[1] == services.AttemptRun || parts[1] == services.AttemptSubmit) && r.Method == "POST":
+		h.runInterviewAttempt(w, r, parts[0], parts[1])
+	case len(parts) == 2 && parts[1] == "draft" && r.Method == "PUT":
+		h.saveInterviewDraft(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "edits" && r.Method == "POST":
+		h.recordInterviewEdits(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "finish" && r.Method == "POST":
+		h.finishInterview(w, r, parts[0])
+	case len(parts) <= 2:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// interviewerSession returns a session of the interviewer a request is made
+// by. ok is false when there is none and the error was written.
+func (h *APIHandler) interviewerSession(w http.ResponseWriter, r *http.Request, id string) (*services.InterviewSession, bool) {
+	username, ok := h.sessionActor(w, r, services.ErrInterviewerRequired)
+	if !ok {
+		return nil, false
+	}
+	session, exists := h.interviewService.Session(id)
+	if !exists {
+		interviewError(w, services.ErrInterviewNotFound)
+		return nil, false
+	}
+	if !strings.EqualFold(session.Interviewer, username) {
+		interviewError(w, services.ErrNotInterviewer)
+		return nil, false
+	}
+	return session, true
+}
+
+// listInterviews returns the sessions of the interviewer, newest first
+func (h *APIHandler) listInterviews(w http.ResponseWriter, r *http.Request) {
+	username, ok := h.sessionActor(w, r, services.ErrInterviewerRequired)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	summaries := []interviewSummary{}
+	for _, session := range h.interviewService.InterviewerSessions(username) {
+		summaries = append(summaries, summarizeInterview(session, now))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries)
+}
+
+// createInterview creates a session and answers with it and its candidate
+// link, which is not shown again
+func (h *APIHandler) createInterview(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Candidate        string                        `json:"candidate"`
+		TimeLimitMinutes int                           `json:"timeLimitMinutes"`
+		Challenges       []services.InterviewChallenge `json:"challenges"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	username, ok := h.sessionActor(w, r, services.ErrInterviewerRequired)
+	if !ok {
+		return
+	}
+
+	// Take the titles from the challenges, which also checks they exist
+	for i := range request.Challenges {
+		challenge := &request.Challenges[i]
+		if challenge.IsPackage() {
+			packageChallenge, err := h.packageService.GetPackageChallenge(challenge.PackageName, challenge.PackageChallengeID)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Challenge not found: %s %s", challenge.PackageName, challenge.PackageChallengeID), http.StatusBadRequest)
+				return
+			}
+			challenge.ChallengeID = 0
+			challenge.Title = packageChallenge.Title
+			continue
+		}
+		classic, exists := h.challengeService.GetChallenge(challenge.ChallengeID)
+		if !exists {
+			http.Error(w, fmt.Sprintf("Challenge not found: %d", challenge.ChallengeID), http.StatusBadRequest)
+			return
+		}
+		challenge.PackageChallengeID = ""
+		challenge.Title = classic.Title
+	}
+
+	session, token, err := h.interviewService.Create(username, request.Candidate, request.Challenges, request.TimeLimitMinutes)
+	if err != nil {
+		interviewError(w, err)
+		return
+	}
+
+	response := struct {
+		Session *services.InterviewSession `json:"session"`
+		Link    string                     `json:"link"` // The candidate link; only its hash is kept
+	}{
+		Session: session,
+		Link:    h.authService.URL(r, "/interview/"+token),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// getInterview returns a session of the interviewer with every attempt
+func (h *APIHandler) getInterview(w http.ResponseWriter, r *http.Request, id string) {
+	session, ok := h.interviewerSession(w, r, id)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+// getInterviewReport returns the report of a session of the interviewer. It
+// is final once the session ended.
+func (h *APIHandler) getInterviewReport(w http.ResponseWriter, r *http.Request, id string) {
+	session, ok := h.interviewerSession(w, r, id)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(services.BuildInterviewReport(session, time.Now()))
+}
+
+// endInterview ends a session of the interviewer
+func (h *APIHandler) endInterview(w http.ResponseWriter, r *http.Request, id string) {
+	session, ok := h.interviewerSession(w, r, id)
+	if !ok {
+		return
+	}
+	session, err := h.interviewService.End(session.ID, session.Interviewer)
+	if err != nil {
+		interviewError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summarizeInterview(session, time.Now()))
+}
+
+// getInterviewStatus returns the state and remaining time of a session to
+// its candidate
+func (h *APIHandler) getInterviewStatus(w http.ResponseWriter, r *http.Request, id string) {
+	session, err := h.interviewService.CandidateSession(id, candidateKey(r, id))
+	if err != nil {
+		interviewError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statusOf(session, time.Now()))
+}
+
+// interviewAttemptRequest is the code of a challenge the candidate sends
+type interviewAttemptRequest struct {
+	Challenge int    `json:"challenge"` // Index in the session's challenges
+	Code      string `json:"code"`
+	Async     bool   `json:"async"` // Return a job ID instead of waiting for the run
+}
+
+// runInterviewAttempt runs the candidate's code for a challenge of a session
+// and records the attempt. Attempts are only accepted before the deadline.
+func (h *APIHandler) runInterviewAttempt(w http.ResponseWriter, r *http.Request, id, kind string) {
+	var request interviewAttemptRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	if codeTooLarge(w, request.Code) {
+		return
+	}
+	session, err := h.interviewService.AcceptAttempt(id, candidateKey(r, id), request.Challenge)
+	if err != nil {
+		interviewError(w, err)
+		return
+	}
+	submittedAt := time.Now()
+
+	var challenge *models.Challenge
+	target := session.Challenges[request.Challenge]
+	if target.IsPackage() {
+		packageChallenge, err := h.packageService.GetPackageChallenge(target.PackageName, target.PackageChallengeID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Challenge not found: %v", err), http.StatusNotFound)
+			return
+		}
+		challenge = services.PackageExecutionChallenge(packageChallenge)
+	} else {
+		classic, exists := h.challengeService.GetChallenge(target.ChallengeID)
+		if !exists {
+			http.Error(w, "Challenge not found", http.StatusNotFound)
+			return
+		}
+		challenge = classic
+	}
+
+	// Submissions are run the way the challenge's own submissions are
+	opts := services.RunOptions{}
+	if kind == services.AttemptSubmit {
+		opts.Benchmark = challenge.Benchmark != nil
+	}
+
+	h.startJob(w, r, "interview:"+session.ID, "interview_"+kind, request.Async, func(ctx context.Context, onOutput services.OutputFunc) interface{} {
+		result := h.executionService.RunCodeOptions(ctx, request.Code, challenge, opts, onOutput)
+		attempt := services.InterviewAttempt{
+			Challenge:   request.Challenge,
+			Kind:        kind,
+			Code:        request.Code,
+			SubmittedAt: submittedAt,
+			ElapsedMs:   session.Elapsed(submittedAt).Milliseconds(),
+			Result:      &result,
+		}
+		if ctx.Err() != nil {
+			// Canceled runs are not attempts
+			return attempt
+		}
+		if err := h.interviewService.RecordAttempt(session.ID, attempt); err != nil {
+			log.Printf("Failed to record an attempt of interview %s: %v", session.ID, err)
+		}
+		event := services.RunEventOf(kind, submittedAt, result)
+		if err := h.recorderService.RecordRun(interviewTimeline(session, request.Challenge), event); err != nil {
+			log.Printf("Failed to record a run in the timeline of interview %s: %v", session.ID, err)
+		}
+		return attempt
+	})
+}
+
+// saveInterviewDraft keeps the code in the candidate's editor, so the report
+// has it even if it was never run
+func (h *APIHandler) saveInterviewDraft(w http.ResponseWriter, r *http.Request, id string) {
+	var request interviewAttemptRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	if err := h.interviewService.SaveDraft(id, candidateKey(r, id), request.Challenge, request.Code); err != nil {
+		interviewError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// recordInterviewEdits adds a batch of edits the candidate made to a
+// challenge to its timeline. A batch that does not apply to the code
+// recorded so far is answered with 409 Conflict; the editor then sends
+// all of its code as a reset.
+func (h *APIHandler) recordInterviewEdits(w http.ResponseWriter, r *http.Request, id string) {
+	var request struct {
+		Challenge int `json:"challenge"` // Index in the session's challenges
+		services.EditBatch
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	session, err := h.interviewService.Accept(id, candidateKey(r, id), request.Challenge)
+	if err != nil {
+		interviewError(w, err)
+		return
+	}
+	if err := h.recorderService.RecordEdits(interviewTimeline(session, request.Challenge), request.EditBatch); err != nil {
+		interviewError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// timelineChallenge returns the challenge a timeline request of a session
+// of the interviewer is for. ok is false when there is none and the error
+// was written.
+func (h *APIHandler) timelineChallenge(w http.ResponseWriter, r *http.Request, id string) (services.TimelineKey, bool) {
+	session, ok := h.interviewerSession(w, r, id)
+	if !ok {
+		return services.TimelineKey{}, false
+	}
+	challenge, err := strconv.Atoi(r.URL.Query().Get("challenge"))
+	if err != nil || challenge < 0 || challenge >= len(session.Challenges) {
+		interviewError(w, services.ErrInvalidInterviewChallenge)
+		return services.TimelineKey{}, false
+	}
+	return interviewTimeline(session, challenge), true
+}
+
+// getInterviewTimeline returns the edits and test runs of a challenge of a
+// session of the interviewer
+func (h *APIHandler) getInterviewTimeline(w http.ResponseWriter, r *http.Request, id string) {
+	key, ok := h.timelineChallenge(w, r, id)
+	if !ok {
+		return
+	}
+	timeline, err := h.recorderService.Timeline(key)
+	if err != nil {
+		interviewError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(timeline)
+}
+
+// replayInterview returns the code of a challenge of a session of the
+// interviewer as it was at a time, by default now
+func (h *APIHandler) replayInterview(w http.ResponseWriter, r *http.Request, id string) {
+	key, ok := h.timelineChallenge(w, r, id)
+	if !ok {
+		return
+	}
+	at := time.Now().UnixMilli()
+	if value := r.URL.Query().Get("at"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid time: at must be in Unix milliseconds", http.StatusBadRequest)
+			return
+		}
+		at = parsed
+	}
+	state, err := h.recorderService.Replay(key, at)
+	if err != nil {
+		interviewError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// finishInterview ends a session early at the candidate's request
+func (h *APIHandler) finishInterview(w http.ResponseWriter, r *http.Request, id string) {
+	session, err := h.interviewService.Finish(id, candidateKey(r, id))
+	if err != nil {
+		interviewError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statusOf(session, time.Now()))
+}
+
+// interviewPackage is a package whose challenges can be chosen for interviews
+type interviewPackage struct {
+	Name       string
+	Challenges []*models.PackageChallenge
+}
+
+// InterviewsPage renders the interviewer's sessions and the form creating them
+func (h *WebHandler) InterviewsPage(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/interviews.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	username := sessionUser(h.authService, r)
+	var sessions []interviewSummary
+	if username != "" {
+		now := time.Now()
+		for _, session := range h.interviewService.InterviewerSessions(username) {
+			sessions = append(sessions, summarizeInterview(session, now))
+		}
+	}
+
+	var classic []*models.Challenge
+	for _, challenge := range h.challengeService.GetChallenges() {
+		classic = append(classic, challenge)
+	}
+	sort.Slice(classic, func(i, j int) bool { return classic[i].ID < classic[j].ID })
+
+	var packages []interviewPackage
+	for name := range h.packageService.GetPackages() {
+		if challenges := h.learningPathChallenges(name); len(challenges) > 0 {
+			packages = append(packages, interviewPackage{Name: name, Challenges: challenges})
+		}
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+
+	data := struct {
+		Username      string
+		Sessions      []interviewSummary
+		Classic       []*models.Challenge
+		Packages      []interviewPackage
+		MaxMinutes    int
+		MaxChallenges int
+	}{
+		Username:      username,
+		Sessions:      sessions,
+		Classic:       classic,
+		Packages:      packages,
+		MaxMinutes:    services.MaxInterviewMinutes,
+		MaxChallenges: services.MaxInterviewChallenges,
+	}
+
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Printf("Template execution error: %v", err)
+		// Don't call http.Error here since headers may already be sent during template execution
+	}
+}
+
+// InterviewReportPage renders the report of a session to its interviewer
+func (h *WebHandler) InterviewReportPage(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/interviews/"), "/")
+	session, exists := h.interviewService.Session(id)
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+	username := sessionUser(h.authService, r)
+	if username == "" {
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.Path), http.StatusSeeOther)
+		return
+	}
+	if !strings.EqualFold(session.Interviewer, username) {
+		http.Error(w, "Only the interviewer sees the report of a session", http.StatusForbidden)
+		return
+	}
+
+	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/interview_report.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Username string
+		Report   services.InterviewReport
+	}{
+		Username: username,
+		Report:   services.BuildInterviewReport(session, time.Now()),
+	}
+
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Printf("Template execution error: %v", err)
+		// Don't call http.Error here since headers may already be sent during template execution
+	}
+}
+
+// interviewTask is a challenge of an interview as the candidate works on it
+type interviewTask struct {
+	Index       int
+	Title       string
+	Description string
+	Code        string // The candidate's latest code, or the template
+}
+
+// HandleCandidate serves the candidate link of an interview session:
+// GET /interview/{token} shows the session and POST /interview/{token}/start
+// starts its clock. Opening the link does not start it, so link previews
+// cannot use it up.
+func (h *WebHandler) HandleCandidate(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/interview/"), "/"), "/")
+	token := parts[0]
+	switch {
+	case len(parts) == 1 && r.Method == "GET":
+		h.candidatePage(w, r, token)
+	case len(parts) == 2 && parts[1] == "start" && r.Method == "POST":
+		h.startInterview(w, r, token)
+	case len(parts) <= 2:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// startInterview starts the clock of a session and gives the browser that
+// started it the candidate key
+func (h *WebHandler) startInterview(w http.ResponseWriter, r *http.Request, token string) {
+	session, key, err := h.interviewService.Start(token)
+	if err != nil {
+		interviewError(w, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     interviewCookiePrefix + session.ID,
+		Value:    key,
+		Path:     "/",
+		Expires:  session.Deadline.Add(24 * time.Hour), // The candidate can still see their work afterwards
+		HttpOnly: true,
+		Secure:   h.authService.SecureCookies(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/interview/"+token, http.StatusSeeOther)
+}
+
+// candidatePage renders a session to its candidate: before the start, what
+// it holds; afterwards, the challenges with an editor that locks when the
+// time is up
+func (h *WebHandler) candidatePage(w http.ResponseWriter, r *http.Request, token string) {
+	session, exists := h.interviewService.LinkSession(token)
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+	now := time.Now()
+	started := false
+	if candidate, err := h.interviewService.CandidateSession(session.ID, candidateKey(r, session.ID)); err == nil {
+		session, started = candidate, true
+	} else if state := session.State(now); state != services.InterviewPending {
+		if state == services.InterviewEnded && session.StartedAt == nil {
+			interviewError(w, services.ErrInterviewOver)
+		} else {
+			interviewError(w, services.ErrInterviewLinkUsed)
+		}
+		return
+	}
+
+	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/interview.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var tasks []interviewTask
+	if started {
+		report := services.BuildInterviewReport(session, now)
+		for i, challenge := range session.Challenges {
+			task := interviewTask{Index: i, Title: challenge.Title, Code: report.Challenges[i].FinalCode}
+			var starter string
+			if challenge.IsPackage() {
+				if packageChallenge, err := h.packageService.GetPackageChallenge(challenge.PackageName, challenge.PackageChallengeID); err == nil {
+					task.Description, starter = packageChallenge.Description, packageChallenge.Template
+				}
+			} else if classic, exists := h.challengeService.GetChallenge(challenge.ChallengeID); exists {
+				task.Description, starter = classic.Description, classic.Template
+			}
+			if task.Code == "" {
+				task.Code = starter
+			}
+			tasks = append(tasks, task)
+		}
+	}
+
+	data := struct {
+		Username string
+		Token    string
+		Session  *services.InterviewSession
+		Started  bool
+		Status   interviewStatus
+		Tasks    []interviewTask
+	}{
+		Username: h.getUsernameFromCookie(r),
+		Token:    token,
+		Session:  session,
+		Started:  started,
+		Status:   statusOf(session, now),
+		Tasks:    tasks,
+	}
+
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Printf("Template execution error: %v", err)
+		// Don't call http.Error here since headers may already be sent during template execution
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"web-ui/internal/services"
+)
+
+// sseHeartbeat is how often an idle job stream sends a comment to keep
+// proxies from closing the connection
+const sseHeartbeat = 15 * time.Second
+
+// jobAccepted is the response of an endpoint that queued a job asynchronously
+type jobAccepted struct {
+	JobID     string             `json:"jobId"`
+	Status    services.JobStatus `json:"status"`
+	StatusURL string             `json:"statusUrl"`
+	StreamURL string             `json:"streamUrl"`
+}
+
+// jobOwner identifies who a job is queued for: the given username, the
+// signed-in user or username cookie, or the client address
+func (h *APIHandler) jobOwner(r *http.Request, username string) string {
+	if username != "" {
+		return username
+	}
+	if user := viewingUser(h.authService, r); user != "" {
+		return user
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// startJob queues run for owner. Asynchronous requests get the job ID back
+// right away; synchronous ones wait for the job and receive its result, and
+// the run is canceled if the client goes away.
+func (h *APIHandler) startJob(w http.ResponseWriter, r *http.Request, owner, kind string, async bool, run services.JobFunc) {
+	job, err := h.jobService.Submit(owner, kind, run)
+	if err == services.ErrJobQueueFull {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to queue job: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if async {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/api/jobs/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(jobAccepted{
+			JobID:     job.ID,
+			Status:    services.JobQueued,
+			StatusURL: "/api/jobs/" + job.ID,
+			StreamURL: "/api/jobs/" + job.ID + "/stream",
+		})
+		return
+	}
+
+	select {
+	case <-job.Done():
+	case <-r.Context().Done():
+		h.jobService.Cancel(job)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job.Snapshot().Result)
+}
+
+// HandleJob serves GET /api/jobs/{id}, GET /api/jobs/{id}/stream and
+// DELETE /api/jobs/{id}
+func (h *APIHandler) HandleJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/"), "/")
+	if len(parts) > 2 || (len(parts) == 2 && parts[1] != "stream") {
+		http.Error(w, "Invalid URL format. Expected: /api/jobs/{id} or /api/jobs/{id}/stream", http.StatusBadRequest)
+		return
+	}
+
+	job, exists := h.jobService.GetJob(parts[0])
+	if !exists {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && r.Method == "GET":
+		h.streamJob(w, r, job)
+	case len(parts) == 1 && r.Method == "GET":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job.Snapshot())
+	case len(parts) == 1 && r.Method == "DELETE":
+		if !h.jobService.Cancel(job) {
+			http.Error(w, "Job already finished", http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job.Snapshot())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// streamJob sends a job's progress as server-sent events: "status" when the
+// job changes state, "line" for every output line and a final "done" with
+// the job's result. Clients can resume with Last-Event-ID or ?from=N.
+func (h *APIHandler) streamJob(w http.ResponseWriter, r *http.Request, job *services.Job) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	from := 0
+	if lastID, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil {
+		from = lastID + 1
+	} else if n, err := strconv.Atoi(r.URL.Query().Get("from")); err == nil && n > 0 {
+		from = n
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	var lastStatus services.JobStatus
+	for {
+		lines, over, changed := job.Follow(from)
+		for _, line := range lines {
+			writeEvent(w, "line", strconv.Itoa(line.Seq), line)
+		}
+		from += len(lines)
+
+		snapshot := job.Snapshot()
+		if over {
+			writeEvent(w, "done", "", snapshot)
+			flusher.Flush()
+			return
+		}
+		if snapshot.Status != lastStatus {
+			lastStatus = snapshot.Status
+			writeEvent(w, "status", "", map[string]interface{}{"id": snapshot.ID, "status": snapshot.Status})
+		}
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes one server-sent event with a JSON payload
+func writeEvent(w http.ResponseWriter, event, id string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
@@ -0,0 +1,469 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"web-ui/internal/auth"
+	"web-ui/internal/models"
+	"web-ui/internal/services"
+	"web-ui/internal/utils"
+)
+
+// teamScope returns the team a leaderboard request is scoped to with
+// ?team={id}, or nil for everyone. Only members see the leaderboards of a
+// team. ok is false when the request may not see them and the error was
+// written.
+func teamScope(teamService *services.TeamService, authService *auth.Service, w http.ResponseWriter, r *http.Request) (team *models.Team, ok bool) {
+	id := r.URL.Query().Get("team")
+	if id == "" {
+		return nil, true
+	}
+	team, exists := teamService.Team(id)
+	if !exists {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return nil, false
+	}
+	if _, member := team.Member(sessionUser(authService, r)); !member {
+		http.Error(w, "Only members see the leaderboards of a team", http.StatusForbidden)
+		return nil, false
+	}
+	return team, true
+}
+
+// teamLeaderboard keeps the members of a team in the main leaderboard and
+// ranks them among themselves
+func teamLeaderboard(leaderboard []LeaderboardUser, team *models.Team) []LeaderboardUser {
+	if team == nil {
+		return leaderboard
+	}
+	members := team.MemberSet()
+	scoped := []LeaderboardUser{}
+	for _, user := range leaderboard {
+		if members[strings.ToLower(user.Username)] {
+			user.Rank = len(scoped) + 1
+			scoped = append(scoped, user)
+		}
+	}
+	return scoped
+}
+
+// teamScoreboard keeps the members of a team in a challenge scoreboard
+func teamScoreboard(entries []models.ScoreboardEntry, team *models.Team) []models.ScoreboardEntry {
+	if team == nil {
+		return entries
+	}
+	members := team.MemberSet()
+	scoped := []models.ScoreboardEntry{}
+	for _, entry := range entries {
+		if members[strings.ToLower(entry.Username)] {
+			scoped = append(scoped, entry)
+		}
+	}
+	return scoped
+}
+
+// teamPackageLeaderboard keeps the members of a team in a package leaderboard
+func teamPackageLeaderboard(entries []models.PackageScoreboardEntry, team *models.Team) []models.PackageScoreboardEntry {
+	if team == nil {
+		return entries
+	}
+	members := team.MemberSet()
+	var scoped []models.PackageScoreboardEntry
+	for _, entry := range entries {
+		if members[strings.ToLower(entry.Username)] {
+			scoped = append(scoped, entry)
+		}
+	}
+	return scoped
+}
+
+// teamView returns a team as a user may see it: the invite code is only
+// shown to owners and mentors
+func teamView(team *models.Team, username string) *models.Team {
+	if member, ok := team.Member(username); ok && member.Role != models.RoleMember {
+		return team
+	}
+	view := *team
+	view.InviteCode = ""
+	return &view
+}
+
+// teamError answers a failed team operation with the status its error calls for
+func teamError(w http.ResponseWriter, err error) {
+	switch err {
+	case services.ErrTeamNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case services.ErrNotTeamMember, services.ErrTeamPermission:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case services.ErrAlreadyTeamMember, services.ErrLastTeamOwner:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case services.ErrInvalidTeamName, services.ErrInvalidInviteCode, services.ErrInvalidTeamRole, services.ErrRemoveSelf:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Team operation failed: %v", err)
+		http.Error(w, "Failed to update the team", http.StatusInternalServerError)
+	}
+}
+
+// HandleTeams handles the team endpoints:
+//
+//	GET    /api/teams                            the teams of the user
+//	POST   /api/teams                            create a team: {"name"}
+//	POST   /api/teams/join                       join a team: {"inviteCode"}
+//	GET    /api/teams/{id}                       a team and its members
+//	POST   /api/teams/{id}/leave                 leave a team
+//	POST   /api/teams/{id}/invite-code           replace the invite code
+//	PUT    /api/teams/{id}/members/{username}    change a role: {"role"}
+//	DELETE /api/teams/{id}/members/{username}    remove a member
+func (h *APIHandler) HandleTeams(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/teams"), "/")
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+
+	switch {
+	case len(parts) == 0 && r.Method == "GET":
+		h.listTeams(w, r)
+	case len(parts) == 0 && r.Method == "POST":
+		h.createTeam(w, r)
+	case len(parts) == 1 && parts[0] == "join" && r.Method == "POST":
+		h.joinTeam(w, r)
+	case len(parts) == 1 && r.Method == "GET":
+		h.getTeam(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "leave" && r.Method == "POST":
+		h.leaveTeam(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "invite-code" && r.Method == "POST":
+		h.newInviteCode(w, r, parts[0])
+	case len(parts) == 3 && parts[1] == "members" && r.Method == "PUT":
+		h.setTeamRole(w, r, parts[0], parts[2])
+	case len(parts) == 3 && parts[1] == "members" && r.Method == "DELETE":
+		h.removeTeamMember(w, r, parts[0], parts[2])
+	case len(parts) <= 3:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// sessionActor returns the signed-in user a change is made by. ok is false
+// when nobody is signed in and the error was written; missing explains
+// what signing in is needed for.
+func (h *APIHandler) sessionActor(w http.ResponseWriter, r *http.Request, missing error) (string, bool) {
+	username := sessionUser(h.authService, r)
+	if username == "" {
+		http.Error(w, missing.Error(), http.StatusUnauthorized)
+		return "", false
+	}
+	return username, true
+}
+
+// teamActor returns the user a team change is made by. ok is false when
+// there is none and the error was written.
+func (h *APIHandler) teamActor(w http.ResponseWriter, r *http.Request) (string, bool) {
+	return h.sessionActor(w, r, services.ErrTeamMemberRequired)
+}
+
+// writeTeam answers with a team as the user may see it
+func writeTeam(w http.ResponseWriter, team *models.Team, username string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(teamView(team, username))
+}
+
+// listTeams returns the teams of the user
+func (h *APIHandler) listTeams(w http.ResponseWriter, r *http.Request) {
+	username := viewingUser(h.authService, r)
+	teams := []*models.Team{}
+	if username != "" {
+		for _, team := range h.teamService.UserTeams(username) {
+			teams = append(teams, teamView(team, username))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(teams)
+}
+
+// createTeam creates a team owned by the user
+func (h *APIHandler) createTeam(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	username, ok := h.teamActor(w, r)
+	if !ok {
+		return
+	}
+
+	team, err := h.teamService.CreateTeam(request.Name, username)
+	if err != nil {
+		teamError(w, err)
+		return
+	}
+	writeTeam(w, team, username)
+}
+
+// joinTeam adds the user to the team of an invite code
+func (h *APIHandler) joinTeam(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		InviteCode string `json:"inviteCode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	username, ok := h.teamActor(w, r)
+	if !ok {
+		return
+	}
+
+	team, err := h.teamService.JoinTeam(request.InviteCode, username)
+	if err != nil {
+		teamError(w, err)
+		return
+	}
+	writeTeam(w, team, username)
+}
+
+// getTeam returns a team the user is a member of
+func (h *APIHandler) getTeam(w http.ResponseWriter, r *http.Request, id string) {
+	team, exists := h.teamService.Team(id)
+	if !exists {
+		teamError(w, services.ErrTeamNotFound)
+		return
+	}
+	username := viewingUser(h.authService, r)
+	if _, member := team.Member(username); !member {
+		teamError(w, services.ErrNotTeamMember)
+		return
+	}
+	writeTeam(w, team, username)
+}
+
+// leaveTeam removes the user from a team
+func (h *APIHandler) leaveTeam(w http.ResponseWriter, r *http.Request, id string) {
+	username, ok := h.teamActor(w, r)
+	if !ok {
+		return
+	}
+	if err := h.teamService.LeaveTeam(id, username); err != nil {
+		teamError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// newInviteCode replaces the invite code of a team
+func (h *APIHandler) newInviteCode(w http.ResponseWriter, r *http.Request, id string) {
+	username, ok := h.teamActor(w, r)
+	if !ok {
+		return
+	}
+	team, err := h.teamService.NewInviteCode(id, username)
+	if err != nil {
+		teamError(w, err)
+		return
+	}
+	writeTeam(w, team, username)
+}
+
+// setTeamRole changes the role of a member
+func (h *APIHandler) setTeamRole(w http.ResponseWriter, r *http.Request, id, member string) {
+	var request struct {
+		Role models.TeamRole `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	username, ok := h.teamActor(w, r)
+	if !ok {
+		return
+	}
+
+	team, err := h.teamService.SetRole(id, username, member, request.Role)
+	if err != nil {
+		teamError(w, err)
+		return
+	}
+	writeTeam(w, team, username)
+}
+
+// removeTeamMember removes a member from a team
+func (h *APIHandler) removeTeamMember(w http.ResponseWriter, r *http.Request, id, member string) {
+	username, ok := h.teamActor(w, r)
+	if !ok {
+		return
+	}
+	team, err := h.teamService.RemoveMember(id, username, member)
+	if err != nil {
+		teamError(w, err)
+		return
+	}
+	writeTeam(w, team, username)
+}
+
+// TeamsPage renders the teams of the user, where they create and join teams
+func (h *WebHandler) TeamsPage(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/teams.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	username := sessionUser(h.authService, r)
+	var teams []*models.Team
+	if username != "" {
+		teams = h.teamService.UserTeams(username)
+	}
+
+	data := struct {
+		Username string
+		Teams    []*models.Team
+	}{
+		Username: username,
+		Teams:    teams,
+	}
+
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Printf("Template execution error: %v", err)
+		// Don't call http.Error here since headers may already be sent during template execution
+	}
+}
+
+// teamPackageColumn is a package challenge in the completion matrix
+type teamPackageColumn struct {
+	Key         string // "package/challenge", as in teamMatrixRow.Packages
+	Package     string
+	ChallengeID string
+	Title       string
+}
+
+// teamMatrixRow is a member's row in the completion matrix
+type teamMatrixRow struct {
+	Member    models.TeamMember
+	Classic   map[int]bool    // Completed classic challenges, by ID
+	Packages  map[string]bool // Completed package challenges, by "package/challenge"
+	Completed int
+}
+
+// TeamDashboardPage renders a team's dashboard: its members and the classic
+// and package challenges each of them completed
+func (h *WebHandler) TeamDashboardPage(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/teams/"), "/")
+	team, exists := h.teamService.Team(id)
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+	username := sessionUser(h.authService, r)
+	if username == "" {
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.Path), http.StatusSeeOther)
+		return
+	}
+	viewer, member := team.Member(username)
+	if !member {
+		http.Error(w, "Only members see the dashboard of a team", http.StatusForbidden)
+		return
+	}
+
+	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/team_dashboard.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Classic challenges, by ID
+	var classic []*models.Challenge
+	for _, challenge := range h.challengeService.GetChallenges() {
+		classic = append(classic, challenge)
+	}
+	sort.Slice(classic, func(i, j int) bool { return classic[i].ID < classic[j].ID })
+
+	rows := make(map[string]*teamMatrixRow)
+	for _, m := range team.Members {
+		rows[strings.ToLower(m.Username)] = &teamMatrixRow{
+			Member:   m,
+			Classic:  make(map[int]bool),
+			Packages: make(map[string]bool),
+		}
+	}
+	for user, completions := range h.scoreboardService.Completions() {
+		if row := rows[strings.ToLower(user)]; row != nil {
+			for challengeID := range completions {
+				row.Classic[challengeID] = true
+			}
+		}
+	}
+
+	// Package challenges, in the learning path order of each package
+	var packageNames []string
+	for name := range h.packageService.GetPackages() {
+		packageNames = append(packageNames, name)
+	}
+	sort.Strings(packageNames)
+	var packageColumns []teamPackageColumn
+	for _, name := range packageNames {
+		challenges := h.learningPathChallenges(name)
+		for _, challenge := range challenges {
+			packageColumns = append(packageColumns, teamPackageColumn{Key: name + "/" + challenge.ID, Package: name, ChallengeID: challenge.ID, Title: challenge.Title})
+		}
+		for user, stats := range h.packageCompletions(name, challenges) {
+			if row := rows[strings.ToLower(user)]; row != nil {
+				for challengeID := range stats.challengesCompleted {
+					row.Packages[name+"/"+challengeID] = true
+				}
+			}
+		}
+	}
+
+	var matrix []*teamMatrixRow
+	for _, row := range rows {
+		row.Completed = len(row.Classic) + len(row.Packages)
+		matrix = append(matrix, row)
+	}
+	sort.Slice(matrix, func(i, j int) bool {
+		if matrix[i].Completed != matrix[j].Completed {
+			return matrix[i].Completed > matrix[j].Completed
+		}
+		return strings.ToLower(matrix[i].Member.Username) < strings.ToLower(matrix[j].Member.Username)
+	})
+
+	data := struct {
+		Username       string
+		Team           *models.Team
+		Role           models.TeamRole
+		Classic        []*models.Challenge
+		PackageNames   []string
+		PackageColumns []teamPackageColumn
+		Matrix         []*teamMatrixRow
+	}{
+		Username:       username,
+		Team:           teamView(team, username),
+		Role:           viewer.Role,
+		Classic:        classic,
+		PackageNames:   packageNames,
+		PackageColumns: packageColumns,
+		Matrix:         matrix,
+	}
+
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Printf("Template execution error: %v", err)
+		// Don't call http.Error here since headers may already be sent during template execution
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"web-ui/internal/auth"
 	"web-ui/internal/models"
 	"web-ui/internal/services"
 	"web-ui/internal/utils"
@@ -25,6 +26,9 @@ type WebHandler struct {
 	scoreboardService *services.ScoreboardService
 	userService       *services.UserService
 	packageService    *services.PackageService
+	authService       *auth.Service
+	teamService       *services.TeamService
+	interviewService  *services.InterviewService
 }
 
 // NewWebHandler creates a new web handler
@@ -34,6 +38,9 @@ func NewWebHandler(
 	scoreboardService *services.ScoreboardService,
 	userService *services.UserService,
 	packageService *services.PackageService,
+	authService *auth.Service,
+	teamService *services.TeamService,
+	interviewService *services.InterviewService,
 ) *WebHandler {
 	return &WebHandler{
 		content:           content,
@@ -41,6 +48,9 @@ func NewWebHandler(
 		scoreboardService: scoreboardService,
 		userService:       userService,
 		packageService:    packageService,
+		authService:       authService,
+		teamService:       teamService,
+		interviewService:  interviewService,
 	}
 }
 
@@ -156,8 +166,9 @@ func (h *WebHandler) ChallengePage(w http.ResponseWriter, r *http.Request) {
 	// Get username from cookie first
 	username := h.getUsernameFromCookie(r)
 
-	// If no username from cookie, try to get it from Git config
-	if username == "" {
+	// If no username from cookie, try to get it from Git config, unless
+	// users have to sign in
+	if username == "" && !h.authService.Required() {
 		gitInfo := utils.GetGitUsername()
 		if gitInfo.Username != "" {
 			username = gitInfo.Username
@@ -170,7 +181,7 @@ func (h *WebHandler) ChallengePage(w http.ResponseWriter, r *http.Request) {
 	hasAttempted := false
 
 	if username != "" {
-		existingSolution = h.userService.GetExistingSolution(username, id)
+		existingSolution = h.userService.GetExistingSolution(username, challenge)
 		// Check if user has attempted this challenge
 		userAttempts := h.userService.GetUserAttempts(username, h.challengeService.GetChallenges())
 		hasAttempted = userAttempts.AttemptedIDs[id]
@@ -211,16 +222,31 @@ func (h *WebHandler) ScoreboardPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The leaderboard can be scoped to a team
+	team, ok := teamScope(h.teamService, h.authService, w, r)
+	if !ok {
+		return
+	}
+
 	// Get all challenges for the scoreboard overview
 	challenges := h.challengeService.GetChallenges()
 	scoreboards := h.scoreboardService.GetAllScoreboards()
+	if team != nil {
+		scoped := make(models.ScoreboardMap, len(scoreboards))
+		for id, entries := range scoreboards {
+			scoped[id] = teamScoreboard(entries, team)
+		}
+		scoreboards = scoped
+	}
 
 	data := struct {
 		Challenges  models.ChallengeMap
 		Scoreboards models.ScoreboardMap
+		Team        *models.Team
 	}{
 		Challenges:  challenges,
 		Scoreboards: scoreboards,
+		Team:        team,
 	}
 
 	err = tmpl.ExecuteTemplate(w, "base", data)
@@ -246,7 +272,12 @@ func (h *WebHandler) ScoreChallengeHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	team, ok := teamScope(h.teamService, h.authService, w, r)
+	if !ok {
+		return
+	}
 	scoreboard, _ := h.scoreboardService.GetScoreboard(id)
+	scoreboard = teamScoreboard(scoreboard, team)
 
 	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/challenge_scoreboard.html")
 	if err != nil {
@@ -258,9 +289,11 @@ func (h *WebHandler) ScoreChallengeHandler(w http.ResponseWriter, r *http.Reques
 	data := struct {
 		Challenge *models.Challenge
 		Entries   []models.ScoreboardEntry
+		Team      *models.Team
 	}{
 		Challenge: challenge,
 		Entries:   scoreboard,
+		Team:      team,
 	}
 
 	err = tmpl.ExecuteTemplate(w, "base", data)
@@ -270,13 +303,10 @@ func (h *WebHandler) ScoreChallengeHandler(w http.ResponseWriter, r *http.Reques
 	}
 }
 
-// getUsernameFromCookie retrieves the username from cookie
+// getUsernameFromCookie retrieves the signed-in user, or the username from
+// cookie when signing in is not required
 func (h *WebHandler) getUsernameFromCookie(r *http.Request) string {
-	cookie, err := r.Cookie("username")
-	if err != nil {
-		return ""
-	}
-	return cookie.Value
+	return viewingUser(h.authService, r)
 }
 
 // setUsernameCookie sets the username cookie
@@ -312,29 +342,14 @@ func (h *WebHandler) PackageDetailPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get challenges for this package
-	challengesMap, err := h.packageService.GetPackageChallenges(packageName)
-	if err != nil {
-		log.Printf("Error getting package challenges: %v", err)
-		challengesMap = make(map[string]*models.PackageChallenge)
-	}
-
-	// Convert map to sorted slice and add missing fields
-	var challenges []*models.PackageChallenge
-	challengeIDs := make([]string, 0, len(challengesMap))
-
-	// Collect and sort challenge IDs
-	for id := range challengesMap {
-		challengeIDs = append(challengeIDs, id)
+	// Leaderboards can be scoped to a team
+	team, ok := teamScope(h.teamService, h.authService, w, r)
+	if !ok {
+		return
 	}
-	sort.Strings(challengeIDs)
 
-	// Convert map to sorted slice using learning path order
-	for _, challengeID := range pkg.LearningPath {
-		if challenge, exists := challengesMap[challengeID]; exists {
-			challenges = append(challenges, challenge)
-		}
-	}
+	// Get challenges for this package
+	challenges := h.learningPathChallenges(packageName)
 
 	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/package_detail.html")
 	if err != nil {
@@ -375,7 +390,7 @@ func (h *WebHandler) PackageDetailPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create actual leaderboard using submission data
-	leaderboard := h.createPackageLeaderboard(packageName, challenges)
+	leaderboard := teamPackageLeaderboard(h.createPackageLeaderboard(packageName, challenges), team)
 
 	data := struct {
 		Package          *models.Package
@@ -386,6 +401,7 @@ func (h *WebHandler) PackageDetailPage(w http.ResponseWriter, r *http.Request) {
 		Leaderboard      []models.PackageScoreboardEntry
 		PackageAttempts  map[string]bool
 		SubmissionCounts map[string]int
+		Team             *models.Team
 	}{
 		Package:          pkg,
 		Challenges:       challenges,
@@ -395,6 +411,7 @@ func (h *WebHandler) PackageDetailPage(w http.ResponseWriter, r *http.Request) {
 		Leaderboard:      leaderboard,
 		PackageAttempts:  packageAttempts,
 		SubmissionCounts: submissionCounts,
+		Team:             team,
 	}
 
 	err = tmpl.ExecuteTemplate(w, "base", data)
@@ -442,8 +459,9 @@ func (h *WebHandler) PackageChallengePage(w http.ResponseWriter, r *http.Request
 	// Get username from cookie first
 	username := h.getUsernameFromCookie(r)
 
-	// If no username from cookie, try to get it from Git config
-	if username == "" {
+	// If no username from cookie, try to get it from Git config, unless
+	// users have to sign in
+	if username == "" && !h.authService.Required() {
 		gitInfo := utils.GetGitUsername()
 		if gitInfo.Username != "" {
 			username = gitInfo.Username
@@ -485,14 +503,14 @@ func (h *WebHandler) PackageChallengePage(w http.ResponseWriter, r *http.Request
 
 // hasUserAttemptedPackageChallenge checks if a user has attempted a package challenge
 func (h *WebHandler) hasUserAttemptedPackageChallenge(username, packageName, challengeID string) bool {
-	// Check if submission file exists in ../packages/{packageName}/{challengeID}/submissions/{username}/solution.go
-	submissionPath := filepath.Join("..", "packages", packageName, challengeID, "submissions", username, "solution.go")
+	// Check if submission file exists in {packageName}/{challengeID}/submissions/{username}/solution.go
+	submissionPath := filepath.Join(h.packageService.ChallengeDir(packageName, challengeID), "submissions", username, "solution.go")
 	if _, err := os.Stat(submissionPath); err == nil {
 		return true
 	}
 
 	// Try alternative path in case of different file naming
-	altSubmissionPath := filepath.Join("..", "packages", packageName, challengeID, "submissions", username, "solution-template.go")
+	altSubmissionPath := filepath.Join(h.packageService.ChallengeDir(packageName, challengeID), "submissions", username, "solution-template.go")
 	if _, err := os.Stat(altSubmissionPath); err == nil {
 		return true
 	}
@@ -507,14 +525,14 @@ func (h *WebHandler) getUserPackageChallengeSolution(username, packageName, chal
 	}
 
 	// Try solution.go first
-	submissionPath := filepath.Join("..", "packages", packageName, challengeID, "submissions", username, "solution.go")
+	submissionPath := filepath.Join(h.packageService.ChallengeDir(packageName, challengeID), "submissions", username, "solution.go")
 	content, err := ioutil.ReadFile(submissionPath)
 	if err == nil {
 		return string(content)
 	}
 
 	// Try solution-template.go as fallback
-	altSubmissionPath := filepath.Join("..", "packages", packageName, challengeID, "submissions", username, "solution-template.go")
+	altSubmissionPath := filepath.Join(h.packageService.ChallengeDir(packageName, challengeID), "submissions", username, "solution-template.go")
 	content, err = ioutil.ReadFile(altSubmissionPath)
 	if err == nil {
 		return string(content)
@@ -525,7 +543,7 @@ func (h *WebHandler) getUserPackageChallengeSolution(username, packageName, chal
 
 // countPackageChallengeSubmissions counts the number of submissions for a package challenge
 func (h *WebHandler) countPackageChallengeSubmissions(packageName, challengeID string) int {
-	submissionsDir := filepath.Join("..", "packages", packageName, challengeID, "submissions")
+	submissionsDir := filepath.Join(h.packageService.ChallengeDir(packageName, challengeID), "submissions")
 
 	// Check if submissions directory exists
 	if _, err := os.Stat(submissionsDir); os.IsNotExist(err) {
@@ -557,14 +575,67 @@ func (h *WebHandler) countPackageChallengeSubmissions(packageName, challengeID s
 	return count
 }
 
+// learningPathChallenges returns the challenges of a package in the order of
+// its learning path
+func (h *WebHandler) learningPathChallenges(packageName string) []*models.PackageChallenge {
+	pkg, err := h.packageService.GetPackage(packageName)
+	if err != nil {
+		return nil
+	}
+	challengesMap, err := h.packageService.GetPackageChallenges(packageName)
+	if err != nil {
+		log.Printf("Error getting package challenges: %v", err)
+		return nil
+	}
+
+	var challenges []*models.PackageChallenge
+	for _, challengeID := range pkg.LearningPath {
+		if challenge, exists := challengesMap[challengeID]; exists {
+			challenges = append(challenges, challenge)
+		}
+	}
+	return challenges
+}
+
 // createPackageLeaderboard creates a leaderboard for package challenges similar to classic challenges
 func (h *WebHandler) createPackageLeaderboard(packageName string, challenges []*models.PackageChallenge) []models.PackageScoreboardEntry {
 	var leaderboard []models.PackageScoreboardEntry
+	userStats := h.packageCompletions(packageName, challenges)
+
+	// Convert to leaderboard entries and sort
+	for username, stats := range userStats {
+		if stats.completedCount > 0 {
+			entry := models.PackageScoreboardEntry{
+				Username:    username,
+				PackageName: packageName,
+				ChallengeID: "", // Not specific to one challenge
+				SubmittedAt: stats.lastSubmission,
+				TestsPassed: stats.completedCount,
+				TestsTotal:  len(challenges),
+			}
+			leaderboard = append(leaderboard, entry)
+		}
+	}
+
+	// Sort by completed count (descending), then by submission time (ascending for earliest)
+	sort.Slice(leaderboard, func(i, j int) bool {
+		if leaderboard[i].TestsPassed != leaderboard[j].TestsPassed {
+			return leaderboard[i].TestsPassed > leaderboard[j].TestsPassed
+		}
+		return leaderboard[i].SubmittedAt.Before(leaderboard[j].SubmittedAt)
+	})
+
+	return leaderboard
+}
+
+// packageCompletions collects the package challenges each user has a
+// solution for, by the name of their submission directory
+func (h *WebHandler) packageCompletions(packageName string, challenges []*models.PackageChallenge) map[string]*userPackageStats {
 	userStats := make(map[string]*userPackageStats)
 
 	// Collect submission data for each challenge
 	for _, challenge := range challenges {
-		submissionsDir := filepath.Join("..", "packages", packageName, challenge.ID, "submissions")
+		submissionsDir := filepath.Join(h.packageService.ChallengeDir(packageName, challenge.ID), "submissions")
 
 		// Check if submissions directory exists
 		if _, err := os.Stat(submissionsDir); os.IsNotExist(err) {
@@ -609,30 +680,7 @@ func (h *WebHandler) createPackageLeaderboard(packageName string, challenges []*
 		}
 	}
 
-	// Convert to leaderboard entries and sort
-	for username, stats := range userStats {
-		if stats.completedCount > 0 {
-			entry := models.PackageScoreboardEntry{
-				Username:    username,
-				PackageName: packageName,
-				ChallengeID: "", // Not specific to one challenge
-				SubmittedAt: stats.lastSubmission,
-				TestsPassed: stats.completedCount,
-				TestsTotal:  len(challenges),
-			}
-			leaderboard = append(leaderboard, entry)
-		}
-	}
-
-	// Sort by completed count (descending), then by submission time (ascending for earliest)
-	sort.Slice(leaderboard, func(i, j int) bool {
-		if leaderboard[i].TestsPassed != leaderboard[j].TestsPassed {
-			return leaderboard[i].TestsPassed > leaderboard[j].TestsPassed
-		}
-		return leaderboard[i].SubmittedAt.Before(leaderboard[j].SubmittedAt)
-	})
-
-	return leaderboard
+	return userStats
 }
 
 // userPackageStats helper struct for collecting user statistics
@@ -0,0 +1,333 @@
+// Package leaderboard renders the classic and package challenge leaderboards
+// of the repository README from the challenge scoreboards.
+package leaderboard
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"web-ui/internal/scoreboard"
+)
+
+// Markers delimiting the leaderboard sections of the README. A section runs
+// from its heading to the end of its marker line.
+const (
+	ClassicHeading = "## 🏆 Top 10 Leaderboard"
+	ClassicEnd     = "<!-- END_CLASSIC_LEADERBOARD -->"
+	PackageHeading = "## 🚀 Package Challenges Leaderboard"
+	PackageEnd     = "<!-- END_PACKAGE_LEADERBOARD -->"
+)
+
+// userCompletions is a user and the number of challenges they completed
+type userCompletions struct {
+	username string
+	count    int
+}
+
+// rankUsers sorts users by completed challenges, most first, then by username
+func rankUsers(counts map[string]int) []userCompletions {
+	users := make([]userCompletions, 0, len(counts))
+	for username, count := range counts {
+		users = append(users, userCompletions{username, count})
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].count != users[j].count {
+			return users[i].count > users[j].count
+		}
+		return users[i].username < users[j].username
+	})
+	return users
+}
+
+// rankBadge returns the medal of the top three ranks, or the rank
+func rankBadge(rank int) string {
+	switch rank {
+	case 1:
+		return "🥇"
+	case 2:
+		return "🥈"
+	case 3:
+		return "🥉"
+	default:
+		return fmt.Sprintf("%d", rank)
+	}
+}
+
+// profileCell renders a user's avatar and profile link
+func profileCell(username string) string {
+	return fmt.Sprintf(`<img src="https://github.com/%s.png" width="24" height="24" style="border-radius: 50%%;"><br/>**[%s](https://github.com/%s)**`, username, username, username)
+}
+
+// Classic renders the classic leaderboard section from the scoreboard of
+// every challenge, keyed by challenge number. A challenge without a
+// scoreboard has a nil one.
+func Classic(boards map[int]*scoreboard.Scoreboard) string {
+	var challengeIDs []int
+	completed := make(map[string]map[int]bool)
+	counts := make(map[string]int)
+	for id, board := range boards {
+		challengeIDs = append(challengeIDs, id)
+		if board == nil {
+			continue
+		}
+		for _, entry := range board.Entries {
+			if !entry.Completed() {
+				continue
+			}
+			if completed[entry.Username] == nil {
+				completed[entry.Username] = make(map[int]bool)
+			}
+			completed[entry.Username][id] = true
+			counts[entry.Username]++
+		}
+	}
+	sort.Ints(challengeIDs)
+	users := rankUsers(counts)
+	total := len(challengeIDs)
+
+	lines := []string{
+		ClassicHeading,
+		"",
+		"Our most accomplished Go developers, ranked by number of challenges completed:",
+		"",
+		"> **Note**: The data below is automatically updated by GitHub Actions when challenge scoreboards change.",
+		"",
+	}
+
+	if len(users) > 0 {
+		lines = append(lines,
+			"| 🏅 | Developer | Solved | Rate | Achievement | Progress |",
+			"|:---:|:---:|:---:|:---:|:---:|:---|",
+		)
+		// The progress indicators show every challenge in two rows
+		half := (len(challengeIDs) + 1) / 2
+		for i, user := range users[:min(10, len(users))] {
+			var rows [2]strings.Builder
+			for j, id := range challengeIDs {
+				indicator := "⬜"
+				if completed[user.username][id] {
+					indicator = "✅"
+				}
+				rows[j/half].WriteString(indicator)
+			}
+			lines = append(lines, fmt.Sprintf("| %s | %s | **%d**/%d | **%.1f%%** | %s | %s<br/>%s |",
+				rankBadge(i+1), profileCell(user.username), user.count, total,
+				float64(user.count)/float64(total)*100, classicAchievement(user.count),
+				rows[0].String(), rows[1].String()))
+		}
+		lines = append(lines,
+			"",
+			`<div align="center">`,
+			"",
+			"✅ Completed • ⬜ Not Completed",
+			"",
+			fmt.Sprintf("*All %d challenges shown in two rows*", total),
+			"",
+			"</div>",
+		)
+	} else {
+		lines = append(lines, "No completed challenges yet. Be the first to solve a challenge!", "")
+	}
+
+	mostSolved := "0 by N/A"
+	if len(users) > 0 {
+		mostSolved = fmt.Sprintf("%d by %s", users[0].count, users[0].username)
+	}
+	lines = append(lines,
+		"",
+		fmt.Sprintf("*Updated automatically based on %d available challenges*", total),
+		"",
+		"### Challenge Progress Overview",
+		"",
+		fmt.Sprintf("- **Total Challenges Available**: %d", total),
+		fmt.Sprintf("- **Active Developers**: %d", len(users)),
+		fmt.Sprintf("- **Most Challenges Solved**: %s", mostSolved),
+		"",
+		ClassicEnd,
+		"",
+	)
+	return strings.Join(lines, "\n")
+}
+
+// classicAchievement returns the badge for a number of completed challenges
+func classicAchievement(count int) string {
+	switch {
+	case count >= 20:
+		return "Master"
+	case count >= 15:
+		return "Expert"
+	case count >= 10:
+		return "Advanced"
+	case count >= 5:
+		return "Intermediate"
+	default:
+		return "Beginner"
+	}
+}
+
+// Packages renders the package leaderboard section from the scoreboards of
+// every package challenge, keyed by package name and challenge ID. A
+// challenge without a scoreboard has a nil one.
+func Packages(boards map[string]map[string]*scoreboard.Scoreboard) string {
+	var packageNames []string
+	perPackage := make(map[string]map[string]int) // package -> user -> completed
+	overall := make(map[string]int)
+	totalChallenges := 0
+	for name, challenges := range boards {
+		packageNames = append(packageNames, name)
+		totalChallenges += len(challenges)
+		for _, board := range challenges {
+			if board == nil {
+				continue
+			}
+			for _, entry := range board.Entries {
+				if !entry.Completed() {
+					continue
+				}
+				if perPackage[name] == nil {
+					perPackage[name] = make(map[string]int)
+				}
+				perPackage[name][entry.Username]++
+				overall[entry.Username]++
+			}
+		}
+	}
+	sort.Strings(packageNames)
+	users := rankUsers(overall)
+
+	lines := []string{
+		PackageHeading,
+		"",
+		"Master Go packages through hands-on challenges! Each package offers a structured learning path with real-world scenarios.",
+		"",
+		"> **Note**: The data below is automatically updated by GitHub Actions when package challenge scoreboards change.",
+		"",
+	}
+
+	if len(users) > 0 {
+		lines = append(lines,
+			"| 🏅 | Developer | Total Solved | Packages | Achievement | Challenge Distribution |",
+			"|:---:|:---:|:---:|:---:|:---:|:---|",
+		)
+		for i, user := range users[:min(10, len(users))] {
+			var breakdown []string
+			for _, name := range packageNames {
+				if count := perPackage[name][user.username]; count > 0 {
+					breakdown = append(breakdown, fmt.Sprintf("**%s**: %d", name, count))
+				}
+			}
+			plural := "s"
+			if len(breakdown) == 1 {
+				plural = ""
+			}
+			lines = append(lines, fmt.Sprintf("| %s | %s | **%d** | **%d** pkg%s | %s | %s |",
+				rankBadge(i+1), profileCell(user.username), user.count, len(breakdown), plural,
+				packageAchievement(user.count), strings.Join(breakdown, " • ")))
+		}
+		lines = append(lines,
+			"",
+			`<div align="center">`,
+			"",
+			"🚀 **Package Challenges** - Learn Go packages through practical, real-world scenarios",
+			"",
+			"</div>",
+		)
+	} else {
+		lines = append(lines, "No completed package challenges yet. Be the first to solve a package challenge!", "")
+	}
+
+	lines = append(lines, "", "### 📦 Per-Package Progress", "")
+	for _, name := range packageNames {
+		packageUsers := rankUsers(perPackage[name])
+		if len(packageUsers) == 0 {
+			continue
+		}
+		total := len(boards[name])
+		lines = append(lines,
+			fmt.Sprintf("#### %s Package", titleCase(name)),
+			"",
+			"| Rank | Developer | Completed | Progress |",
+			"|:---:|:---:|:---:|:---|",
+		)
+		for i, user := range packageUsers[:min(5, len(packageUsers))] {
+			lines = append(lines, fmt.Sprintf("| %s | **[%s](https://github.com/%s)** | %d/%d | %s |",
+				rankBadge(i+1), user.username, user.username, user.count, total, progressBar(user.count, total)))
+		}
+		lines = append(lines, "")
+	}
+
+	lines = append(lines,
+		"### 📊 Package Challenge Statistics",
+		"",
+		fmt.Sprintf("- **Total Package Challenges Available**: %d", totalChallenges),
+		fmt.Sprintf("- **Active Package Learners**: %d", len(users)),
+		fmt.Sprintf("- **Available Packages**: %d (%s)", len(packageNames), strings.Join(packageNames, ", ")),
+		"",
+	)
+	if len(users) > 0 {
+		lines = append(lines, fmt.Sprintf("- **Most Package Challenges Solved**: %d by %s", users[0].count, users[0].username), "")
+	}
+	lines = append(lines, PackageEnd, "")
+	return strings.Join(lines, "\n")
+}
+
+// packageAchievement returns the badge for a number of completed package challenges
+func packageAchievement(count int) string {
+	switch {
+	case count >= 15:
+		return "🔥 Package Master"
+	case count >= 10:
+		return "⭐ Package Expert"
+	case count >= 5:
+		return "💪 Package Advanced"
+	case count >= 3:
+		return "🚀 Package Intermediate"
+	default:
+		return "🌱 Package Beginner"
+	}
+}
+
+// progressBar renders completed out of total as ten squares and a percentage
+func progressBar(completed, total int) string {
+	const length = 10
+	if total == 0 {
+		return strings.Repeat("⬜", length)
+	}
+	progress := float64(completed) / float64(total)
+	filled := int(progress * length)
+	return fmt.Sprintf("%s%s %.0f%%", strings.Repeat("🟩", filled), strings.Repeat("⬜", length-filled), progress*100)
+}
+
+// titleCase upper-cases the first letter of every word, e.g. "go-redis"
+// becomes "Go-Redis"
+func titleCase(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if i == 0 || !unicode.IsLetter(runes[i-1]) {
+			runes[i] = unicode.ToUpper(r)
+		}
+	}
+	return string(runes)
+}
+
+// ReplaceSection replaces the section from heading to the end of the end
+// marker's line in a README with content
+func ReplaceSection(readme, heading, end, content string) (string, error) {
+	start := strings.Index(readme, heading)
+	if start == -1 {
+		return "", fmt.Errorf("README has no %q section", heading)
+	}
+	stop := strings.Index(readme[start:], end)
+	if stop == -1 {
+		return "", fmt.Errorf("README has no %q marker after %q", end, heading)
+	}
+	stop += start + len(end)
+	if newline := strings.IndexByte(readme[stop:], '\n'); newline != -1 {
+		stop += newline + 1
+	} else {
+		stop = len(readme)
+	}
+	return readme[:start] + content + readme[stop:], nil
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"web-ui/internal/scoreboard"
 )
 
 // Challenge represents a coding challenge
@@ -14,6 +16,49 @@ type Challenge struct {
 	TestFile          string `json:"testFile"`
 	LearningMaterials string `json:"learningMaterials"`
 	Hints             string `json:"hints"`
+
+	// Optional, from metadata.json
+	EstimatedTime      string   `json:"estimatedTime,omitempty"` // e.g. "30-45 min"
+	Tags               []string `json:"tags,omitempty"`
+	Prerequisites      []string `json:"prerequisites,omitempty"`
+	LearningObjectives []string `json:"learningObjectives,omitempty"`
+
+	Execution *ExecutionConfig `json:"execution,omitempty"` // Optional per-challenge execution limits
+	Benchmark *BenchmarkConfig `json:"benchmark,omitempty"` // Optional benchmark comparison and thresholds
+	Dir       string           `json:"-"`                   // Directory holding the challenge's go.mod, if loaded from disk
+}
+
+// ExecutionConfig holds per-challenge execution settings declared in metadata.json.
+// Zero values fall back to the ExecutionService defaults.
+type ExecutionConfig struct {
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"` // Wall-clock limit for the test run
+	CPUSeconds     int `json:"cpu_seconds,omitempty"`     // CPU time limit for the test run
+	MemoryMB       int `json:"memory_mb,omitempty"`       // Address space limit
+	MaxProcesses   int `json:"max_processes,omitempty"`   // Process/thread limit
+	MaxFileSizeMB  int `json:"max_file_size_mb,omitempty"`
+	MaxOutputKB    int `json:"max_output_kb,omitempty"`
+
+	Race  bool `json:"race,omitempty"`  // Build and run the tests with the race detector
+	Count int  `json:"count,omitempty"` // Run every test this many times (-count)
+}
+
+// BenchmarkConfig holds the benchmark settings declared in metadata.json. The
+// solution's benchmarks are compared to the baseline benchmarks run against
+// the reference solution template.
+type BenchmarkConfig struct {
+	Count     int             `json:"count,omitempty"`     // Runs of each benchmark (-count); defaults to 1
+	Benchtime string          `json:"benchtime,omitempty"` // Run time per benchmark (-benchtime), e.g. "100ms"
+	Pairs     []BenchmarkPair `json:"pairs"`
+}
+
+// BenchmarkPair compares an optimized benchmark to its baseline. Sub-benchmarks
+// with the same name are compared with each other.
+type BenchmarkPair struct {
+	Baseline       string  `json:"baseline"`                    // e.g. "BenchmarkSlowSort"
+	Optimized      string  `json:"optimized"`                   // e.g. "BenchmarkOptimizedSort"
+	MinSpeedup     float64 `json:"min_speedup,omitempty"`       // Required baseline/optimized ns/op ratio
+	MaxBytesPerOp  *int64  `json:"max_bytes_per_op,omitempty"`  // Allowed B/op of every optimized case
+	MaxAllocsPerOp *int64  `json:"max_allocs_per_op,omitempty"` // Allowed allocs/op of every optimized case
 }
 
 // Submission represents a user's submitted solution
@@ -25,13 +70,17 @@ type Submission struct {
 	Passed      bool      `json:"passed"`
 	TestOutput  string    `json:"testOutput"`
 	ExecutionMs int64     `json:"executionMs"`
+	Status      string    `json:"status,omitempty"`   // Execution status, e.g. "passed", "timeout"
+	Message     string    `json:"message,omitempty"`  // Explains the status, e.g. a missed benchmark threshold
+	TestsPassed int       `json:"testsPassed"`        // Tests and subtests that passed
+	TestsTotal  int       `json:"testsTotal"`         // Tests and subtests that passed or failed
+	Coverage    *float64  `json:"coverage,omitempty"` // Statement coverage of the solution in percent
 }
 
-// ScoreboardEntry represents an entry in the scoreboard
+// ScoreboardEntry represents an entry in the scoreboard of a challenge
 type ScoreboardEntry struct {
-	Username    string    `json:"username"`
-	ChallengeID int       `json:"challengeId"`
-	SubmittedAt time.Time `json:"submittedAt"`
+	scoreboard.Entry
+	ChallengeID int `json:"challengeId"`
 }
 
 // UserAttemptedChallenges tracks attempted challenges by username
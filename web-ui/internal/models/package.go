@@ -54,6 +54,9 @@ type ChallengeMetadata struct {
 	BonusPoints         []string `json:"bonus_points"`
 	Icon                string   `json:"icon,omitempty"`
 	Order               int      `json:"order"`
+
+	Execution *ExecutionConfig `json:"execution,omitempty"` // Optional execution limits
+	Benchmark *BenchmarkConfig `json:"benchmark,omitempty"` // Optional benchmark thresholds
 }
 
 // PackageChallenge represents a challenge specific to a package
@@ -78,6 +81,9 @@ type PackageChallenge struct {
 	Icon                string   `json:"icon,omitempty"`
 	Order               int      `json:"order"`
 	Status              string   `json:"status,omitempty"` // "available", "coming-soon", etc.
+
+	Execution *ExecutionConfig `json:"execution,omitempty"` // Optional execution limits from metadata.json
+	Dir       string           `json:"-"`                   // Challenge directory holding its go.mod and go.sum
 }
 
 // PackageSubmission represents a user's submitted solution for a package challenge
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// TeamRole is what a member can do in a team
+type TeamRole string
+
+// Team roles, most privileged first
+const (
+	RoleOwner  TeamRole = "owner"  // Manages the team, its roles and its invite code
+	RoleMentor TeamRole = "mentor" // Invites and removes members
+	RoleMember TeamRole = "member"
+)
+
+// Valid reports whether the role is one of the team roles
+func (r TeamRole) Valid() bool {
+	return r == RoleOwner || r == RoleMentor || r == RoleMember
+}
+
+// Team is a group of users, e.g. a cohort, with leaderboards of its own
+type Team struct {
+	ID         string       `json:"id"` // In the team URLs, derived from the name
+	Name       string       `json:"name"`
+	InviteCode string       `json:"inviteCode,omitempty"` // Joins the team; only shown to owners and mentors
+	CreatedAt  time.Time    `json:"createdAt"`
+	Members    []TeamMember `json:"members"`
+}
+
+// TeamMember is a user in a team
+type TeamMember struct {
+	Username string    `json:"username"`
+	Role     TeamRole  `json:"role"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+// Member returns the membership of a user, ignoring case as GitHub does
+func (t *Team) Member(username string) (TeamMember, bool) {
+	for _, member := range t.Members {
+		if strings.EqualFold(member.Username, username) {
+			return member, true
+		}
+	}
+	return TeamMember{}, false
+}
+
+// RoleOf returns the role of a user in the team, or "" for non-members
+func (t *Team) RoleOf(username string) TeamRole {
+	member, _ := t.Member(username)
+	return member.Role
+}
+
+// MemberSet returns the team's usernames in lower case, for filtering
+// scoreboards whose usernames may differ in case
+func (t *Team) MemberSet() map[string]bool {
+	members := make(map[string]bool, len(t.Members))
+	for _, member := range t.Members {
+		members[strings.ToLower(member.Username)] = true
+	}
+	return members
+}
@@ -0,0 +1,227 @@
+package modproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ImportResult summarizes an Import run
+type ImportResult struct {
+	Downloaded int      // Module versions now in the store
+	Failed     []string // "module@version: error" for versions that could not be fetched
+}
+
+// Import downloads every module version required by the given module
+// directories into the store at dir. Each directory contributes its build list
+// (go mod download all) and every version pinned in its go.sum. The go
+// command's own GOPROXY and GOSUMDB are used, so this needs network access and
+// verifies everything against the checksum database once.
+func Import(ctx context.Context, dir string, moduleDirs []string, log io.Writer) (ImportResult, error) {
+	var result ImportResult
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return result, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return result, err
+	}
+
+	seen := make(map[string]bool)
+	failed := make(map[string]string)
+	for _, moduleDir := range moduleDirs {
+		fmt.Fprintf(log, "Importing modules of %s\n", moduleDir)
+
+		// The build list, as the go command resolves it for this module
+		modules, err := download(ctx, dir, moduleDir, "all")
+		if err != nil {
+			fmt.Fprintf(log, "  go mod download all: %v\n", err)
+		}
+
+		// Everything pinned in go.sum, including versions only needed for
+		// module graph pruning
+		pinned, err := readGoSum(filepath.Join(moduleDir, "go.sum"))
+		if err != nil && !os.IsNotExist(err) {
+			return result, err
+		}
+		var missing []string
+		for _, version := range pinned {
+			if !containsModule(modules, version) {
+				missing = append(missing, version)
+			}
+		}
+		if len(missing) > 0 {
+			more, err := download(ctx, dir, moduleDir, missing...)
+			if err != nil {
+				fmt.Fprintf(log, "  go mod download (go.sum): %v\n", err)
+			}
+			modules = append(modules, more...)
+		}
+
+		for _, m := range modules {
+			key := m.Path + "@" + m.Version
+			if m.Error != "" {
+				failed[key] = m.Error
+				continue
+			}
+			delete(failed, key)
+			if !seen[key] {
+				seen[key] = true
+				fmt.Fprintf(log, "  %s\n", key)
+			}
+		}
+	}
+
+	// The proxy only serves cache/download; the extracted sources next to it
+	// would just take up space
+	if err := removeExtracted(dir); err != nil {
+		fmt.Fprintf(log, "Failed to remove extracted module sources: %v\n", err)
+	}
+
+	result.Downloaded = len(seen)
+	for key, message := range failed {
+		result.Failed = append(result.Failed, key+": "+message)
+	}
+	sort.Strings(result.Failed)
+	return result, nil
+}
+
+// downloadedModule is an entry of `go mod download -json` output
+type downloadedModule struct {
+	Path    string
+	Version string
+	Error   string
+}
+
+// download runs `go mod download -json` for moduleDir with the store as the
+// module cache and returns what it reported. It works on a copy of go.mod and
+// go.sum so the challenge files are never rewritten.
+func download(ctx context.Context, store, moduleDir string, args ...string) ([]downloadedModule, error) {
+	workDir, err := os.MkdirTemp("", "modproxy-import-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+	for _, name := range []string{"go.mod", "go.sum"} {
+		data, err := os.ReadFile(filepath.Join(moduleDir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(workDir, name), data, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, "go", append([]string{"mod", "download", "-json"}, args...)...)
+	cmd.Dir = workDir
+	cmd.Env = append(os.Environ(),
+		"GOMODCACHE="+store,
+		"GOFLAGS=-mod=mod -modcacherw",
+		"GOTOOLCHAIN=local",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, runErr := cmd.Output()
+
+	var modules []downloadedModule
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for decoder.More() {
+		var m downloadedModule
+		if err := decoder.Decode(&m); err != nil {
+			break
+		}
+		if m.Path != "" {
+			modules = append(modules, m)
+		}
+	}
+
+	// go exits non-zero when any module failed; those carry their own Error
+	if runErr != nil && len(modules) == 0 {
+		return nil, fmt.Errorf("%v: %s", runErr, strings.TrimSpace(stderr.String()))
+	}
+	return modules, nil
+}
+
+// readGoSum returns the distinct "module@version" pairs listed in a go.sum
+func readGoSum(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	var versions []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		version := strings.TrimSuffix(fields[1], "/go.mod")
+		key := fields[0] + "@" + version
+		if !seen[key] {
+			seen[key] = true
+			versions = append(versions, key)
+		}
+	}
+	return versions, scanner.Err()
+}
+
+// containsModule reports whether modules has an entry for "module@version"
+func containsModule(modules []downloadedModule, key string) bool {
+	for _, m := range modules {
+		if m.Path+"@"+m.Version == key {
+			return true
+		}
+	}
+	return false
+}
+
+// removeExtracted deletes everything in the store but cache/download
+func removeExtracted(dir string) error {
+	for _, keep := range []struct{ dir, name string }{{dir, "cache"}, {filepath.Join(dir, "cache"), "download"}} {
+		entries, err := os.ReadDir(keep.dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.Name() == keep.name {
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(keep.dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FindModuleDirs returns the directories below repoRoot that hold a challenge
+// go.mod: challenge-N and packages/<package>/<challenge>. Submissions are
+// skipped; they must build against the challenge's dependencies.
+func FindModuleDirs(repoRoot string) ([]string, error) {
+	var dirs []string
+	for _, pattern := range []string{"challenge-*/go.mod", "packages/*/*/go.mod"} {
+		matches, err := filepath.Glob(filepath.Join(repoRoot, pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			dirs = append(dirs, filepath.Dir(match))
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
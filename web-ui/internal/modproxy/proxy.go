@@ -0,0 +1,229 @@
+// Package modproxy serves Go modules from a local directory using the GOPROXY
+// protocol, so submissions can be built without internet access.
+//
+// The directory is a module cache (GOMODCACHE): the proxy serves the files in
+// its cache/download subtree, which already uses the GOPROXY layout. Import
+// fills it from the go.mod and go.sum files of the challenges.
+package modproxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Proxy is an http.Handler implementing the GOPROXY protocol over a local
+// module cache
+type Proxy struct {
+	dir string
+}
+
+// NewProxy creates a proxy serving the modules stored in dir
+func NewProxy(dir string) *Proxy {
+	return &Proxy{dir: dir}
+}
+
+// Dir returns the module cache directory the proxy serves from
+func (p *Proxy) Dir() string {
+	return p.dir
+}
+
+// downloadDir is the GOPROXY-layout part of the module cache
+func (p *Proxy) downloadDir() string {
+	return filepath.Join(p.dir, "cache", "download")
+}
+
+// Modules lists the paths of all modules with at least one stored version
+func (p *Proxy) Modules() []string {
+	root := p.downloadDir()
+	var modules []string
+	filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if info.Name() == "sumdb" && filepath.Dir(file) == root {
+			return filepath.SkipDir
+		}
+		if info.Name() != "@v" {
+			return nil
+		}
+		if rel, err := filepath.Rel(root, filepath.Dir(file)); err == nil && len(p.versions(file, true)) > 0 {
+			if module, ok := unescapePath(filepath.ToSlash(rel)); ok {
+				modules = append(modules, module)
+			}
+		}
+		return filepath.SkipDir
+	})
+	sort.Strings(modules)
+	return modules
+}
+
+// ServeHTTP answers the GOPROXY requests $module/@v/list, $module/@latest and
+// $module/@v/$version.{info,mod,zip}. Unknown modules and versions get 404,
+// which makes the go command report them as unavailable.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Module paths and versions arrive escaped ("!" before upper-case letters),
+	// exactly as they are stored on disk
+	request := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if request == "" || strings.Contains(request, "..") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(request, "/@v/list"):
+		versionDir := filepath.Join(p.downloadDir(), filepath.FromSlash(strings.TrimSuffix(request, "/list")))
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, version := range p.versions(versionDir, false) {
+			w.Write([]byte(version + "\n"))
+		}
+	case strings.HasSuffix(request, "/@latest"):
+		versionDir := filepath.Join(p.downloadDir(), filepath.FromSlash(strings.TrimSuffix(request, "/@latest")), "@v")
+		versions := p.versions(versionDir, true)
+		if len(versions) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		p.serveFile(w, r, filepath.Join(versionDir, escapeVersion(versions[len(versions)-1])+".info"), "application/json")
+	case strings.Contains(request, "/@v/"):
+		contentType := "application/octet-stream"
+		switch path.Ext(request) {
+		case ".info":
+			contentType = "application/json"
+		case ".mod":
+			contentType = "text/plain; charset=utf-8"
+		case ".zip":
+			contentType = "application/zip"
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		p.serveFile(w, r, filepath.Join(p.downloadDir(), filepath.FromSlash(request)), contentType)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveFile sends a stored file, or 404 when it does not exist
+func (p *Proxy) serveFile(w http.ResponseWriter, r *http.Request, file, contentType string) {
+	f, err := os.Open(file)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+// versions returns the stored versions of a module in semver order. A version
+// counts as stored when its .info and .mod files exist. Pseudo-versions are
+// left out of @v/list, as the protocol requires, unless withPseudo is set.
+func (p *Proxy) versions(versionDir string, withPseudo bool) []string {
+	entries, err := os.ReadDir(versionDir)
+	if err != nil {
+		return nil
+	}
+
+	var versions []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".info") {
+			continue
+		}
+		escaped := strings.TrimSuffix(name, ".info")
+		if _, err := os.Stat(filepath.Join(versionDir, escaped+".mod")); err != nil {
+			continue
+		}
+		version, ok := unescapePath(escaped)
+		if !ok || !validSemver(version) {
+			continue
+		}
+		if !withPseudo && isPseudoVersion(version, versionDir, escaped) {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareSemver(versions[i], versions[j]) < 0
+	})
+	return versions
+}
+
+// isPseudoVersion reports whether a stored version was resolved from a commit
+// rather than a tag, using the origin recorded in its .info file when present
+func isPseudoVersion(version, versionDir, escaped string) bool {
+	data, err := os.ReadFile(filepath.Join(versionDir, escaped+".info"))
+	if err == nil {
+		var info struct {
+			Origin *struct {
+				Ref string `json:"Ref"`
+			} `json:"Origin"`
+		}
+		if json.Unmarshal(data, &info) == nil && info.Origin != nil && strings.HasPrefix(info.Origin.Ref, "refs/tags/") {
+			return false
+		}
+	}
+	// vX.Y.Z-yyyymmddhhmmss-abcdefabcdef and its pre-release variants
+	pre := prerelease(version)
+	if i := strings.LastIndex(pre, "-"); i >= 0 {
+		commit := pre[i+1:]
+		timestamp := pre[:i]
+		if j := strings.LastIndexAny(timestamp, "-."); j >= 0 {
+			timestamp = timestamp[j+1:]
+		}
+		return len(commit) == 12 && len(timestamp) == 14 && isDigits(timestamp)
+	}
+	return false
+}
+
+// escapeVersion applies the module cache's case escaping to a version
+func escapeVersion(version string) string {
+	var b strings.Builder
+	for _, r := range version {
+		if 'A' <= r && r <= 'Z' {
+			b.WriteByte('!')
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// unescapePath reverses the module cache's case escaping ("!x" -> "X")
+func unescapePath(escaped string) (string, bool) {
+	var b strings.Builder
+	bang := false
+	for _, r := range escaped {
+		switch {
+		case bang:
+			if r < 'a' || r > 'z' {
+				return "", false
+			}
+			b.WriteRune(r - 'a' + 'A')
+			bang = false
+		case r == '!':
+			bang = true
+		case 'A' <= r && r <= 'Z':
+			return "", false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), !bang
+}
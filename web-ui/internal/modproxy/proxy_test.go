@@ -0,0 +1,254 @@
+package modproxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testCache is a module cache holding versions copied from a real one
+const testCache = "testdata/modcache"
+
+func TestProxyModules(t *testing.T) {
+	want := []string{
+		"github.com/BurntSushi/toml",
+		"github.com/davecgh/go-spew",
+		"github.com/pmezard/go-difflib",
+		"oras.land/oras-go",
+	}
+	if got := NewProxy(testCache).Modules(); !reflect.DeepEqual(got, want) {
+		t.Errorf("modules = %q, want %q", got, want)
+	}
+	if got := NewProxy(t.TempDir()).Modules(); len(got) != 0 {
+		t.Errorf("modules of an empty cache = %q", got)
+	}
+}
+
+func TestProxyServeHTTP(t *testing.T) {
+	ts := httptest.NewServer(NewProxy(testCache))
+	defer ts.Close()
+
+	stored := func(file string) string {
+		data, err := os.ReadFile(filepath.Join(testCache, "cache", "download", filepath.FromSlash(file)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{
+			// Pseudo-versions are left out and versions are in semver order
+			name:        "list",
+			path:        "/github.com/!burnt!sushi/toml/@v/list",
+			status:      http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+			body:        "v0.2.0\nv1.2.0\nv1.6.0\n",
+		},
+		{
+			name:   "list with pre-release",
+			path:   "/oras.land/oras-go/@v/list",
+			status: http.StatusOK,
+			body:   "v0.5.0\nv1.1.0-rc1\nv1.1.0\n",
+		},
+		{
+			// A .mod without .info, e.g. of an +incompatible version, is not stored
+			name:   "list with a go.mod only",
+			path:   "/github.com/pmezard/go-difflib/@v/list",
+			status: http.StatusOK,
+			body:   "v1.0.0\n",
+		},
+		{
+			name:   "list of pseudo-versions only",
+			path:   "/github.com/davecgh/go-spew/@v/list",
+			status: http.StatusOK,
+		},
+		{
+			name:   "list of an unknown module",
+			path:   "/example.com/missing/@v/list",
+			status: http.StatusOK,
+		},
+		{
+			name:        "latest",
+			path:        "/github.com/!burnt!sushi/toml/@latest",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        stored("github.com/!burnt!sushi/toml/@v/v1.6.0.info"),
+		},
+		{
+			name:   "latest pseudo-version",
+			path:   "/github.com/davecgh/go-spew/@latest",
+			status: http.StatusOK,
+			body:   stored("github.com/davecgh/go-spew/@v/v0.0.0-20171005155431-ecdeabc65495.info"),
+		},
+		{
+			name:   "latest of an unknown module",
+			path:   "/example.com/missing/@latest",
+			status: http.StatusNotFound,
+		},
+		{
+			name:        "info",
+			path:        "/oras.land/oras-go/@v/v1.1.0-rc1.info",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        stored("oras.land/oras-go/@v/v1.1.0-rc1.info"),
+		},
+		{
+			name:        "mod",
+			path:        "/github.com/!burnt!sushi/toml/@v/v1.2.0.mod",
+			status:      http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+			body:        stored("github.com/!burnt!sushi/toml/@v/v1.2.0.mod"),
+		},
+		{
+			name:        "zip",
+			path:        "/github.com/pmezard/go-difflib/@v/v1.0.0.zip",
+			status:      http.StatusOK,
+			contentType: "application/zip",
+			body:        stored("github.com/pmezard/go-difflib/@v/v1.0.0.zip"),
+		},
+		{
+			name:        "head",
+			method:      "HEAD",
+			path:        "/github.com/pmezard/go-difflib/@v/v1.0.0.zip",
+			status:      http.StatusOK,
+			contentType: "application/zip",
+		},
+		{
+			name:   "zip not stored",
+			path:   "/github.com/!burnt!sushi/toml/@v/v1.2.0.zip",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "unescaped path",
+			path:   "/github.com/BurntSushi/toml/@v/v1.2.0.mod",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "other file",
+			path:   "/github.com/pmezard/go-difflib/@v/v1.0.0.ziphash",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "version directory",
+			path:   "/github.com/pmezard/go-difflib/@v/",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "outside the cache",
+			path:   "/../../proxy.go/@v/x.mod",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "root",
+			path:   "/",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "post",
+			method: "POST",
+			path:   "/github.com/pmezard/go-difflib/@v/list",
+			status: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			req, err := http.NewRequest(method, ts.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Keep the client from cleaning the path
+			req.URL.Opaque = tt.path
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.contentType != "" && resp.Header.Get("Content-Type") != tt.contentType {
+				t.Errorf("content type = %q, want %q", resp.Header.Get("Content-Type"), tt.contentType)
+			}
+			if tt.status == http.StatusOK && string(body) != tt.body {
+				t.Errorf("body = %q, want %q", truncate(body), truncate([]byte(tt.body)))
+			}
+		})
+	}
+}
+
+// truncate shortens a response body for error messages
+func truncate(body []byte) string {
+	if len(body) > 200 {
+		return string(body[:200]) + "..."
+	}
+	return string(body)
+}
+
+func TestProxyServesGoCommand(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	ts := httptest.NewServer(NewProxy(testCache))
+	defer ts.Close()
+
+	dir, cache := t.TempDir(), t.TempDir()
+	goCmd := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command(goBin, args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GOPROXY="+ts.URL,
+			"GOSUMDB=off",
+			"GOFLAGS=-modcacherw",
+			"GOMODCACHE="+cache,
+			"GO111MODULE=on",
+			"GOTOOLCHAIN=local",
+		)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("go %s: %v\n%s", strings.Join(args, " "), err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	var module struct{ Version, Zip, Sum string }
+	if err := json.Unmarshal([]byte(goCmd("mod", "download", "-json", "github.com/pmezard/go-difflib@v1.0.0")), &module); err != nil {
+		t.Fatal(err)
+	}
+	// The checksum recorded in go.sum files for this version
+	if module.Version != "v1.0.0" || module.Zip == "" || module.Sum != "h1:4DBwDE0NGyQoBHbLQYPwSUPoCMWR5BEzIk/f1lZbAQM=" {
+		t.Errorf("downloaded %+v", module)
+	}
+
+	if got, want := goCmd("list", "-m", "-versions", "github.com/BurntSushi/toml"), "github.com/BurntSushi/toml v0.2.0 v1.2.0 v1.6.0"; got != want {
+		t.Errorf("versions = %q, want %q", got, want)
+	}
+	// Without tagged versions the latest pseudo-version is used
+	if got, want := goCmd("list", "-m", "github.com/davecgh/go-spew@latest"), "github.com/davecgh/go-spew v0.0.0-20171005155431-ecdeabc65495"; got != want {
+		t.Errorf("latest = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,117 @@
+package modproxy
+
+import (
+	"strings"
+)
+
+// parseSemver splits "vMAJOR.MINOR.PATCH[-PRERELEASE][+BUILD]" into its
+// numeric parts and pre-release. Build metadata is ignored.
+func parseSemver(version string) (core [3]string, pre string, ok bool) {
+	if !strings.HasPrefix(version, "v") {
+		return core, "", false
+	}
+	version = version[1:]
+	if i := strings.IndexByte(version, '+'); i >= 0 {
+		version = version[:i]
+	}
+	if i := strings.IndexByte(version, '-'); i >= 0 {
+		version, pre = version[:i], version[i+1:]
+		if pre == "" {
+			return core, "", false
+		}
+	}
+
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return core, "", false
+	}
+	for i, part := range parts {
+		if part == "" || !isDigits(part) || (len(part) > 1 && part[0] == '0') {
+			return core, "", false
+		}
+		core[i] = part
+	}
+	return core, pre, true
+}
+
+// validSemver reports whether version is a full semantic version
+func validSemver(version string) bool {
+	_, _, ok := parseSemver(version)
+	return ok
+}
+
+// prerelease returns the pre-release part of a version, without the dash
+func prerelease(version string) string {
+	_, pre, _ := parseSemver(version)
+	return pre
+}
+
+// compareSemver orders two valid versions following semver precedence
+func compareSemver(a, b string) int {
+	coreA, preA, _ := parseSemver(a)
+	coreB, preB, _ := parseSemver(b)
+	for i := range coreA {
+		if c := compareNumeric(coreA[i], coreB[i]); c != 0 {
+			return c
+		}
+	}
+
+	// A release sorts after all of its pre-releases
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+
+	fieldsA, fieldsB := strings.Split(preA, "."), strings.Split(preB, ".")
+	for i := 0; i < len(fieldsA) && i < len(fieldsB); i++ {
+		x, y := fieldsA[i], fieldsB[i]
+		if x == y {
+			continue
+		}
+		numX, numY := isDigits(x), isDigits(y)
+		switch {
+		case numX && numY:
+			return compareNumeric(x, y)
+		case numX:
+			return -1
+		case numY:
+			return 1
+		case x < y:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch {
+	case len(fieldsA) < len(fieldsB):
+		return -1
+	case len(fieldsA) > len(fieldsB):
+		return 1
+	}
+	return 0
+}
+
+// compareNumeric compares two decimal strings without leading zeros
+func compareNumeric(a, b string) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// isDigits reports whether s consists only of ASCII digits
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,118 @@
+package modproxy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareSemver(t *testing.T) {
+	// In ascending precedence; each version compares below every later one
+	ordered := []string{
+		"v0.0.0-20170626231645-782f4967f2dc",
+		"v0.0.0-20171005155431-ecdeabc65495",
+		"v0.2.0",
+		"v1.0.0-alpha",
+		"v1.0.0-alpha.1",
+		"v1.0.0-alpha.beta",
+		"v1.0.0-beta",
+		"v1.0.0-beta.2",
+		"v1.0.0-beta.11",
+		"v1.0.0-rc.1",
+		"v1.0.0",
+		"v1.1.1-0.20220607204713-0a9f2b05b636",
+		"v1.2.0",
+		"v1.10.0",
+		"v2.0.0+incompatible",
+	}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			if got := compareSemver(a, b); got != want {
+				t.Errorf("compareSemver(%s, %s) = %d, want %d", a, b, got, want)
+			}
+		}
+	}
+	if compareSemver("v1.0.0+build.1", "v1.0.0") != 0 {
+		t.Errorf("build metadata changed the order")
+	}
+}
+
+func TestValidSemver(t *testing.T) {
+	tests := []struct {
+		version string
+		valid   bool
+	}{
+		{"v1.2.3", true},
+		{"v0.0.0-20171005155431-ecdeabc65495", true},
+		{"v1.1.0-rc1", true},
+		{"v2.0.0+incompatible", true},
+		{"1.2.3", false},
+		{"v1.2", false},
+		{"v1.2.3.4", false},
+		{"v01.2.3", false},
+		{"v1.2.x", false},
+		{"v1.2.3-", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := validSemver(tt.version); got != tt.valid {
+			t.Errorf("validSemver(%q) = %v, want %v", tt.version, got, tt.valid)
+		}
+	}
+}
+
+func TestIsPseudoVersion(t *testing.T) {
+	dir := t.TempDir()
+	tagged := `{"Version":"v1.0.0-20240101000000-abcdefabcdef","Origin":{"VCS":"git","Ref":"refs/tags/v1.0.0-20240101000000-abcdefabcdef"}}`
+	if err := os.WriteFile(filepath.Join(dir, "v1.0.0-20240101000000-abcdefabcdef.info"), []byte(tagged), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		version string
+		pseudo  bool
+	}{
+		{"v0.0.0-20171005155431-ecdeabc65495", true},
+		{"v1.1.1-0.20220607204713-0a9f2b05b636", true},
+		{"v1.2.0-pre.0.20220607204713-0a9f2b05b636", true},
+		{"v1.2.0", false},
+		{"v1.1.0-rc1", false},
+		{"v1.0.0-2024-abcdefabcdef", false},
+		// A tag that looks like a pseudo-version, as its .info tells
+		{"v1.0.0-20240101000000-abcdefabcdef", false},
+	}
+	for _, tt := range tests {
+		if got := isPseudoVersion(tt.version, dir, escapeVersion(tt.version)); got != tt.pseudo {
+			t.Errorf("isPseudoVersion(%s) = %v, want %v", tt.version, got, tt.pseudo)
+		}
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	tests := []struct {
+		escaped, path string
+		ok            bool
+	}{
+		{"github.com/!burnt!sushi/toml", "github.com/BurntSushi/toml", true},
+		{"v1.0.0-!r!c1", "v1.0.0-RC1", true},
+		{"oras.land/oras-go", "oras.land/oras-go", true},
+		{"github.com/BurntSushi/toml", "", false},
+		{"github.com/!", "", false},
+		{"github.com/!1", "", false},
+	}
+	for _, tt := range tests {
+		path, ok := unescapePath(tt.escaped)
+		if ok != tt.ok || ok && path != tt.path {
+			t.Errorf("unescapePath(%q) = %q, %v, want %q, %v", tt.escaped, path, ok, tt.path, tt.ok)
+		}
+	}
+	if got := escapeVersion("v1.0.0-RC1"); got != "v1.0.0-!r!c1" {
+		t.Errorf("escapeVersion = %q", got)
+	}
+}
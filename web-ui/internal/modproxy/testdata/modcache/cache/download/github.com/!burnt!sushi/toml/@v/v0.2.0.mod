@@ -0,0 +1 @@
+module github.com/BurntSushi/toml
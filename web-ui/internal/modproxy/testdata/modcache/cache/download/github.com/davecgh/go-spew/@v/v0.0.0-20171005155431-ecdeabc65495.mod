@@ -0,0 +1 @@
+module github.com/davecgh/go-spew
@@ -0,0 +1 @@
+module github.com/pmezard/go-difflib
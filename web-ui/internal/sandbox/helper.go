@@ -0,0 +1,27 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+)
+
+// helperArg is the first argument that turns the current binary into the
+// sandbox helper instead of running its normal main function
+const helperArg = "__sandbox-exec"
+
+// RunHelperIfRequested must be called at the very start of main in every
+// binary that runs commands with resource limits. When the process was started
+// as the sandbox helper it applies the requested limits, replaces itself with
+// the target program and never returns.
+func RunHelperIfRequested() {
+	if len(os.Args) < 2 || os.Args[1] != helperArg {
+		return
+	}
+
+	if err := runHelper(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(126)
+	}
+	// runHelper only returns on error because it execs the target program
+	os.Exit(126)
+}
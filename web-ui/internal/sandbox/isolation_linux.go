@@ -0,0 +1,308 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Prctl options and securebits used to drop privileges, which the syscall
+// package does not export
+const (
+	prSetSecurebits  = 28
+	prCapbsetDrop    = 24
+	prSetNoNewPrivs  = 38
+	lastCapability   = 63
+	secbitNoRoot     = 1 << 0
+	secbitNoRootLock = 1 << 1
+	secbitNoFixup    = 1 << 2
+	secbitNoFixLock  = 1 << 3
+)
+
+// systemPaths are bound read-only into the isolated root so dynamically linked
+// binaries and the go tool keep working
+var systemPaths = []string{"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/usr"}
+
+// devices are bound into the isolated /dev
+var devices = []string{"/dev/null", "/dev/zero", "/dev/random", "/dev/urandom"}
+
+// scratchSize is the size of the writable /tmp inside the isolated root
+const scratchSize = "256m"
+
+// isolatedHosts is the /etc/hosts seen inside the namespace
+const isolatedHosts = "127.0.0.1 localhost\n::1 localhost\n"
+
+// setIsolatedProcessAttrs starts the command in new user, mount, network, PID,
+// IPC and UTS namespaces. The server user becomes root inside the user
+// namespace so the helper can set up mounts; dropPrivileges removes every
+// capability again before the untrusted program starts.
+func setIsolatedProcessAttrs(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET |
+			syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		GidMappingsEnableSetgroups: false,
+	}
+}
+
+// dropPrivileges makes sure the program exec'd next has no capabilities in the
+// namespace even though it runs as uid 0 there. Capabilities and securebits
+// are per thread, so the caller must hold the OS thread it execs from.
+func dropPrivileges() error {
+	bits := secbitNoRoot | secbitNoRootLock | secbitNoFixup | secbitNoFixLock
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetSecurebits, uintptr(bits), 0); errno != 0 {
+		return fmt.Errorf("set securebits: %v", errno)
+	}
+	for capability := 0; capability <= lastCapability; capability++ {
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prCapbsetDrop, uintptr(capability), 0); errno != 0 && errno != syscall.EINVAL {
+			return fmt.Errorf("drop capability %d: %v", capability, errno)
+		}
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("set no_new_privs: %v", errno)
+	}
+	return nil
+}
+
+// probeIsolation runs the helper in namespaces once without a target program
+func probeIsolation() error {
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate sandbox helper: %v", err)
+	}
+
+	root, err := os.MkdirTemp("", "sandbox-root-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(root)
+
+	workDir, err := os.MkdirTemp("", "sandbox-probe-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	cmd := exec.Command(self, helperArg, "-isolate", "-probe", "-root", root, "-workdir", workDir)
+	setIsolatedProcessAttrs(cmd)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		message := strings.TrimPrefix(strings.TrimSpace(string(output)), isolationErrorPrefix)
+		if message == "" {
+			return fmt.Errorf("namespaces unavailable: %v", err)
+		}
+		return fmt.Errorf("namespaces unavailable: %v: %s", err, message)
+	}
+	return nil
+}
+
+// setupIsolation runs inside the new namespaces. It builds a minimal root on a
+// tmpfs, with read-only system directories, a read-only workspace at /work
+// (except for the writable directories in it), a writable /tmp and only the
+// loopback interface, and then pivots into it.
+func setupIsolation(root, workDir string, readOnly, writable []string) error {
+	// Keep every mount change private to this namespace
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %v", err)
+	}
+	if err := syscall.Mount("tmpfs", root, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755,size=16m"); err != nil {
+		return fmt.Errorf("mount root: %v", err)
+	}
+
+	for _, path := range append(systemPaths, readOnly...) {
+		if err := bindReadOnly(path, filepath.Join(root, path)); err != nil {
+			return err
+		}
+	}
+	if err := bindReadOnly(workDir, filepath.Join(root, isolatedWorkDir)); err != nil {
+		return err
+	}
+	for _, path := range writable {
+		target := isolatedPath(path, workDir)
+		if target == path {
+			return fmt.Errorf("writable path %s is outside the workspace", path)
+		}
+		if err := syscall.Mount(path, filepath.Join(root, target), "", syscall.MS_BIND, ""); err != nil {
+			return fmt.Errorf("bind %s: %v", path, err)
+		}
+	}
+
+	tmp := filepath.Join(root, "tmp")
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", tmp, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777,size="+scratchSize); err != nil {
+		return fmt.Errorf("mount /tmp: %v", err)
+	}
+
+	for _, device := range devices {
+		target := filepath.Join(root, device)
+		if err := touch(target); err != nil {
+			return err
+		}
+		if err := syscall.Mount(device, target, "", syscall.MS_BIND, ""); err != nil {
+			return fmt.Errorf("bind %s: %v", device, err)
+		}
+	}
+
+	proc := filepath.Join(root, "proc")
+	if err := os.MkdirAll(proc, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("proc", proc, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount /proc: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(root, "etc", "hosts"), []byte(isolatedHosts), 0644); err != nil {
+		return err
+	}
+
+	oldRoot := filepath.Join(root, ".oldroot")
+	if err := os.MkdirAll(oldRoot, 0700); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(root, oldRoot); err != nil {
+		return fmt.Errorf("pivot_root: %v", err)
+	}
+	if err := syscall.Chdir("/"); err != nil {
+		return err
+	}
+	if err := syscall.Unmount("/.oldroot", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("detach old root: %v", err)
+	}
+	if err := os.Remove("/.oldroot"); err != nil {
+		return err
+	}
+	if err := syscall.Mount("", "/", "", syscall.MS_REMOUNT|syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("remount root read-only: %v", err)
+	}
+
+	if err := bringUpLoopback(); err != nil {
+		return fmt.Errorf("bring up loopback: %v", err)
+	}
+	return nil
+}
+
+// bindReadOnly makes src visible read-only at dst. Missing sources are skipped
+// and symlinks (such as /bin -> usr/bin) are recreated instead of bound.
+func bindReadOnly(src, dst string) error {
+	info, err := os.Lstat(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(dst); err == nil {
+		// Already visible through an earlier bind, e.g. GOROOT under /usr
+		return nil
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case info.IsDir():
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+	default:
+		if err := touch(dst); err != nil {
+			return err
+		}
+	}
+
+	if err := syscall.Mount(src, dst, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s: %v", src, err)
+	}
+
+	// A remount inside a user namespace must keep the flags the kernel locked
+	// on the original mount, otherwise it fails with EPERM
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dst, &stat); err != nil {
+		return err
+	}
+	flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+	for _, f := range []struct{ st, ms int64 }{
+		{stNoSUID, syscall.MS_NOSUID},
+		{stNoDev, syscall.MS_NODEV},
+		{stNoExec, syscall.MS_NOEXEC},
+		{stNoATime, syscall.MS_NOATIME},
+		{stNoDirATime, syscall.MS_NODIRATIME},
+		{stRelATime, syscall.MS_RELATIME},
+	} {
+		if stat.Flags&f.st != 0 {
+			flags |= uintptr(f.ms)
+		}
+	}
+	if err := syscall.Mount("", dst, "", flags, ""); err != nil {
+		return fmt.Errorf("remount %s read-only: %v", src, err)
+	}
+	return nil
+}
+
+// statfs mount flags, which the syscall package does not export
+const (
+	stNoSUID     = 0x2
+	stNoDev      = 0x4
+	stNoExec     = 0x8
+	stNoATime    = 0x400
+	stNoDirATime = 0x800
+	stRelATime   = 0x1000
+)
+
+// touch creates an empty file (and its parent directories) to bind over
+func touch(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// bringUpLoopback sets IFF_UP on lo, the only interface in a new network namespace
+func bringUpLoopback() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	// struct ifreq: interface name followed by a union whose first member is the flags
+	var req struct {
+		name  [syscall.IFNAMSIZ]byte
+		flags uint16
+		_     [22]byte
+	}
+	copy(req.name[:], "lo")
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCGIFFLAGS, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return errno
+	}
+	req.flags |= syscall.IFF_UP | syscall.IFF_RUNNING
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunIsolated(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(dir+"/out", 0755); err != nil {
+		t.Fatal(err)
+	}
+	spec := Spec{
+		Path:          "/bin/sh",
+		Args:          []string{"-c", "pwd; touch /work/file || echo read-only; touch /work/out/file && echo writable"},
+		Dir:           dir,
+		Isolation:     IsolationStrict,
+		WritablePaths: []string{dir + "/out"},
+		Limits:        Limits{WallTime: 30 * time.Second},
+	}
+	result := Run(context.Background(), spec)
+
+	if err := IsolationSupported(); err != nil {
+		// Strict isolation fails rather than running the command unconfined
+		if result.Outcome != OutcomeIsolationFailed || result.Err == nil {
+			t.Errorf("outcome = %s (%v), want %s", result.Outcome, result.Err, OutcomeIsolationFailed)
+		}
+		spec.Isolation = IsolationAuto
+		if result := Run(context.Background(), spec); result.Outcome != OutcomeExited || result.Isolated {
+			t.Errorf("auto isolation did not fall back: %s, isolated %v", result.Outcome, result.Isolated)
+		}
+		t.Skipf("namespaces are unavailable: %v", err)
+	}
+
+	if !result.Success() || !result.Isolated {
+		t.Fatalf("outcome = %s, exit code %d, isolated %v\n%s", result.Outcome, result.ExitCode, result.Isolated, result.Output)
+	}
+	if got := string(result.Output); !strings.HasPrefix(got, "/work\n") || !strings.Contains(got, "read-only\n") || !strings.HasSuffix(got, "writable\n") {
+		t.Errorf("output = %q, want the workspace at /work, read-only but for out", got)
+	}
+	if _, err := os.Stat(dir + "/out/file"); err != nil {
+		t.Errorf("file written to the writable directory is missing: %v", err)
+	}
+}
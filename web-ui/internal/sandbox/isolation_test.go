@@ -0,0 +1,60 @@
+package sandbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIsolation(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Isolation
+		err   bool
+	}{
+		{"", IsolationNone, false},
+		{"none", IsolationNone, false},
+		{" Auto ", IsolationAuto, false},
+		{"STRICT", IsolationStrict, false},
+		{"on", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseIsolation(tt.value)
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("ParseIsolation(%q) = %q, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestIsolatedPath(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"/tmp/ws", "/work"},
+		{"/tmp/ws/challenge.test", "/work/challenge.test"},
+		{"/tmp/ws/sub/dir", "/work/sub/dir"},
+		{"/tmp/wsx/challenge.test", "/tmp/wsx/challenge.test"},
+		{"/usr/local/go/bin/go", "/usr/local/go/bin/go"},
+	}
+	for _, tt := range tests {
+		if got := isolatedPath(tt.path, "/tmp/ws"); got != tt.want {
+			t.Errorf("isolatedPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestIsolatedEnv(t *testing.T) {
+	base := []string{"PATH=/usr/bin", "HOME=/root", "AWS_SECRET_ACCESS_KEY=x", "GOROOT=/usr/local/go", "PATHEXT=.exe", "TZ=UTC"}
+	want := []string{
+		"HOME=/tmp",
+		"TMPDIR=/tmp",
+		"GOCACHE=/tmp/.cache/go-build",
+		"GOPATH=/tmp/go",
+		"GOPROXY=off",
+		"PATH=/usr/bin",
+		"GOROOT=/usr/local/go",
+		"TZ=UTC",
+	}
+	if got := isolatedEnv(base); !reflect.DeepEqual(got, want) {
+		t.Errorf("isolatedEnv =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !race
+
+package sandbox
+
+// raceEnabled reports whether the tests are built with the race detector,
+// whose runtime needs more address space than memory limit tests allow
+const raceEnabled = false
@@ -0,0 +1,296 @@
+// Package sandbox runs untrusted commands with wall-clock deadlines, resource
+// limits and cleanup of the whole process tree.
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// waitDelay is how long Wait keeps reading output after the process exited or
+// was killed, so that orphaned grandchildren cannot hold the pipes open forever
+const waitDelay = 2 * time.Second
+
+// Limits describes the resources a sandboxed command may use. Zero values mean
+// "no limit" for every field.
+type Limits struct {
+	WallTime       time.Duration // Deadline for the whole run, enforced by killing the process group
+	CPUTime        time.Duration // RLIMIT_CPU, rounded up to whole seconds
+	MemoryBytes    uint64        // RLIMIT_AS
+	MaxProcesses   uint64        // RLIMIT_NPROC (counted per user by the kernel)
+	MaxFileSize    uint64        // RLIMIT_FSIZE
+	MaxOutputBytes int64         // Combined stdout/stderr cap; the run is killed when exceeded
+}
+
+// hasRlimits reports whether any limit must be applied with setrlimit
+func (l Limits) hasRlimits() bool {
+	return l.CPUTime > 0 || l.MemoryBytes > 0 || l.MaxProcesses > 0 || l.MaxFileSize > 0
+}
+
+// Outcome describes how a sandboxed command finished
+type Outcome string
+
+const (
+	OutcomeExited      Outcome = "exited"       // Process ran to completion (any exit code)
+	OutcomeTimeout     Outcome = "timeout"      // Wall-clock deadline was hit
+	OutcomeCPULimit    Outcome = "cpu_limit"    // RLIMIT_CPU was hit
+	OutcomeMemoryLimit Outcome = "memory_limit" // Process ran out of address space
+	OutcomeOutputLimit Outcome = "output_limit" // Process wrote more than MaxOutputBytes
+	OutcomeCanceled    Outcome = "canceled"     // Caller canceled the context
+	OutcomeStartFailed Outcome = "start_failed" // Process could not be started
+
+	OutcomeIsolationFailed Outcome = "isolation_failed" // Namespaces could not be set up
+)
+
+// Spec describes a command to run inside the sandbox
+type Spec struct {
+	Path   string
+	Args   []string
+	Dir    string
+	Env    []string // nil means inherit the server environment
+	Limits Limits
+
+	// Stream, if set, receives the combined output as it is produced, up to
+	// MaxOutputBytes. Writes happen while the process runs and must not block.
+	Stream io.Writer
+
+	// Isolation runs the command in its own namespaces with only Dir (read-only),
+	// system directories and ReadOnlyPaths visible and loopback-only networking
+	Isolation     Isolation
+	ReadOnlyPaths []string
+	// WritablePaths are directories inside Dir that stay writable under
+	// isolation, e.g. for a coverage profile, given by their host paths.
+	WritablePaths []string
+}
+
+// Result is the outcome of a sandboxed run
+type Result struct {
+	Outcome  Outcome
+	ExitCode int
+	Output   []byte
+	Duration time.Duration
+	CPUTime  time.Duration
+	Isolated bool  // The command ran in its own namespaces
+	Err      error // Set when the process could not be started or waited for
+}
+
+// Success reports whether the command ran to completion with exit code 0
+func (r Result) Success() bool {
+	return r.Outcome == OutcomeExited && r.ExitCode == 0
+}
+
+// Run executes spec and blocks until the process and all of its descendants
+// are gone. Resource limits are applied through a re-exec of the current
+// binary (see RunHelperIfRequested) so they are in place before the untrusted
+// program starts.
+func Run(ctx context.Context, spec Spec) Result {
+	runCtx := ctx
+	if spec.Limits.WallTime > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, spec.Limits.WallTime)
+		defer cancel()
+	}
+	runCtx, abort := context.WithCancel(runCtx)
+	defer abort()
+
+	isolated := false
+	if spec.Isolation == IsolationAuto || spec.Isolation == IsolationStrict {
+		if err := IsolationSupported(); err == nil {
+			isolated = true
+		} else if spec.Isolation == IsolationStrict {
+			return Result{Outcome: OutcomeIsolationFailed, Err: err}
+		}
+	}
+
+	path, args, dir, env := spec.Path, spec.Args, spec.Dir, spec.Env
+	if isolated || (spec.Limits.hasRlimits() && helperSupported) {
+		self, err := os.Executable()
+		if err != nil {
+			return Result{Outcome: OutcomeStartFailed, Err: fmt.Errorf("failed to locate sandbox helper: %v", err)}
+		}
+		helper := helperArgs(spec.Limits)
+		if isolated {
+			root, err := os.MkdirTemp("", "sandbox-root-")
+			if err != nil {
+				return Result{Outcome: OutcomeStartFailed, Err: err}
+			}
+			defer os.RemoveAll(root)
+
+			helper = append(helper, "-isolate", "-root", root, "-workdir", spec.Dir)
+			for _, p := range spec.ReadOnlyPaths {
+				helper = append(helper, "-ro", p)
+			}
+			for _, p := range spec.WritablePaths {
+				helper = append(helper, "-rw", p)
+			}
+			path = isolatedPath(path, spec.Dir)
+			env = isolatedEnv(env)
+			dir = ""
+		}
+		args = append(helper, append([]string{"--", path}, args...)...)
+		path = self
+	}
+
+	output := &limitedBuffer{limit: spec.Limits.MaxOutputBytes, onExceed: abort, stream: spec.Stream}
+
+	cmd := exec.CommandContext(runCtx, path, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = waitDelay
+	if isolated {
+		setIsolatedProcessAttrs(cmd)
+	} else {
+		setProcessGroup(cmd)
+	}
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd.Process.Pid)
+	}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return Result{Outcome: OutcomeStartFailed, Err: err}
+	}
+	waitErr := cmd.Wait()
+	// Reap anything the program left running in the background
+	killProcessGroup(cmd.Process.Pid)
+
+	result := Result{
+		Outcome:  OutcomeExited,
+		ExitCode: cmd.ProcessState.ExitCode(),
+		Output:   output.Bytes(),
+		Duration: time.Since(start),
+		CPUTime:  cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime(),
+		Isolated: isolated,
+	}
+	if waitErr != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(waitErr, &exitErr) {
+			result.Err = waitErr
+		}
+	}
+
+	switch {
+	case isolated && result.ExitCode == isolationExitCode && bytes.HasPrefix(result.Output, []byte(isolationErrorPrefix)):
+		result.Outcome = OutcomeIsolationFailed
+		result.Err = errors.New(strings.TrimSpace(strings.TrimPrefix(string(result.Output), isolationErrorPrefix)))
+	case output.Exceeded():
+		result.Outcome = OutcomeOutputLimit
+	case ctx.Err() == context.Canceled:
+		result.Outcome = OutcomeCanceled
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		result.Outcome = OutcomeTimeout
+	case spec.Limits.CPUTime > 0 && killedByCPULimit(cmd.ProcessState) && result.CPUTime >= cpuLimitSeconds(spec.Limits.CPUTime)-time.Second:
+		result.Outcome = OutcomeCPULimit
+	case spec.Limits.MemoryBytes > 0 && result.ExitCode != 0 && outOfMemory(result.Output):
+		result.Outcome = OutcomeMemoryLimit
+	}
+
+	return result
+}
+
+// cpuLimitSeconds rounds a CPU limit up to the whole seconds used by RLIMIT_CPU
+func cpuLimitSeconds(d time.Duration) time.Duration {
+	return time.Duration((d+time.Second-1)/time.Second) * time.Second
+}
+
+// outOfMemoryMarkers are printed by the Go runtime and libc when an allocation
+// fails because of RLIMIT_AS
+var outOfMemoryMarkers = [][]byte{
+	[]byte("fatal error: runtime: out of memory"),
+	[]byte("runtime: cannot allocate memory"),
+	[]byte("fatal error: out of memory"),
+	[]byte("failed to create new OS thread"),
+	[]byte("failed to reserve page summary memory"),
+}
+
+// outOfMemory checks the output of a failed run for allocation failures
+func outOfMemory(output []byte) bool {
+	for _, marker := range outOfMemoryMarkers {
+		if bytes.Contains(output, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// helperArgs encodes limits as arguments for the sandbox helper
+func helperArgs(l Limits) []string {
+	args := []string{helperArg}
+	if l.CPUTime > 0 {
+		args = append(args, "-cpu", strconv.FormatInt(int64(cpuLimitSeconds(l.CPUTime)/time.Second), 10))
+	}
+	if l.MemoryBytes > 0 {
+		args = append(args, "-as", strconv.FormatUint(l.MemoryBytes, 10))
+	}
+	if l.MaxProcesses > 0 {
+		args = append(args, "-nproc", strconv.FormatUint(l.MaxProcesses, 10))
+	}
+	if l.MaxFileSize > 0 {
+		args = append(args, "-fsize", strconv.FormatUint(l.MaxFileSize, 10))
+	}
+	return args
+}
+
+// limitedBuffer collects output up to a limit and reports when it was exceeded.
+// Accepted output is also copied to stream, if set.
+type limitedBuffer struct {
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	limit    int64
+	exceeded bool
+	onExceed func()
+	stream   io.Writer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.exceeded {
+		return len(p), nil
+	}
+	if b.limit > 0 && int64(b.buf.Len()+len(p)) > b.limit {
+		accepted := p[:b.limit-int64(b.buf.Len())]
+		b.buf.Write(accepted)
+		b.copyToStream(accepted)
+		b.exceeded = true
+		if b.onExceed != nil {
+			b.onExceed()
+		}
+		return len(p), nil
+	}
+	b.copyToStream(p)
+	return b.buf.Write(p)
+}
+
+// copyToStream forwards output to the stream; its errors never fail the run
+func (b *limitedBuffer) copyToStream(p []byte) {
+	if b.stream != nil && len(p) > 0 {
+		b.stream.Write(p)
+	}
+}
+
+// Bytes returns a copy of the collected output
+func (b *limitedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+// Exceeded reports whether the output limit was hit
+func (b *limitedBuffer) Exceeded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exceeded
+}
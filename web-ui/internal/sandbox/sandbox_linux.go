@@ -0,0 +1,129 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// rlimitNPROC is RLIMIT_NPROC, which the syscall package does not export
+const rlimitNPROC = 0x6
+
+// helperSupported reports whether limits can be applied through the helper
+const helperSupported = true
+
+// setProcessGroup starts the command in its own process group so the whole
+// tree can be killed at once
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup sends SIGKILL to every process in the group led by pid
+func killProcessGroup(pid int) error {
+	err := syscall.Kill(-pid, syscall.SIGKILL)
+	if err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	return nil
+}
+
+// killedByCPULimit reports whether the process died from RLIMIT_CPU. The soft
+// limit raises SIGXCPU, which Go programs ignore, so the hard limit one second
+// later delivers SIGKILL.
+func killedByCPULimit(state *os.ProcessState) bool {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return false
+	}
+	return status.Signal() == syscall.SIGXCPU || status.Signal() == syscall.SIGKILL
+}
+
+// runHelper applies the limits passed on the command line and execs the target
+func runHelper(args []string) error {
+	fs := flag.NewFlagSet(helperArg, flag.ContinueOnError)
+	cpu := fs.Uint64("cpu", 0, "RLIMIT_CPU in seconds")
+	as := fs.Uint64("as", 0, "RLIMIT_AS in bytes")
+	nproc := fs.Uint64("nproc", 0, "RLIMIT_NPROC")
+	fsize := fs.Uint64("fsize", 0, "RLIMIT_FSIZE in bytes")
+	isolate := fs.Bool("isolate", false, "set up the isolated root before running the program")
+	probe := fs.Bool("probe", false, "only check that isolation works")
+	root := fs.String("root", "", "empty directory to build the isolated root in")
+	workDir := fs.String("workdir", "", "workspace mounted read-only at /work")
+	var readOnly stringList
+	fs.Var(&readOnly, "ro", "extra host path visible read-only (repeatable)")
+	var writable stringList
+	fs.Var(&writable, "rw", "directory inside the workspace that stays writable (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *isolate {
+		// Privileges are dropped per thread, so stay on the thread that execs
+		runtime.LockOSThread()
+
+		err := setupIsolation(*root, *workDir, readOnly, writable)
+		if err == nil {
+			err = dropPrivileges()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s%v\n", isolationErrorPrefix, err)
+			os.Exit(isolationExitCode)
+		}
+		if *probe {
+			os.Exit(0)
+		}
+		if err := os.Chdir(isolatedWorkDir); err != nil {
+			return err
+		}
+	}
+
+	if fs.NArg() == 0 {
+		return errors.New("no program given")
+	}
+
+	limits := []struct {
+		resource int
+		name     string
+		soft     uint64
+		hard     uint64
+	}{
+		// The extra second on the hard CPU limit turns the ignored SIGXCPU into SIGKILL
+		{syscall.RLIMIT_CPU, "RLIMIT_CPU", *cpu, *cpu + 1},
+		{syscall.RLIMIT_AS, "RLIMIT_AS", *as, *as},
+		{syscall.RLIMIT_FSIZE, "RLIMIT_FSIZE", *fsize, *fsize},
+		{rlimitNPROC, "RLIMIT_NPROC", *nproc, *nproc},
+	}
+	for _, l := range limits {
+		if l.soft == 0 {
+			continue
+		}
+		if err := syscall.Setrlimit(l.resource, &syscall.Rlimit{Cur: l.soft, Max: l.hard}); err != nil {
+			return fmt.Errorf("failed to set %s: %v", l.name, err)
+		}
+	}
+
+	path, err := exec.LookPath(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, fs.Args(), os.Environ())
+}
+
+// stringList collects a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -0,0 +1,82 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunLimits(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		spec    Spec
+		outcome Outcome
+		noRace  bool // The race detector cannot start under the limits
+	}{
+		{
+			name:    "cpu time",
+			spec:    Spec{Path: "/bin/sh", Args: []string{"-c", "while :; do :; done"}, Limits: Limits{CPUTime: time.Second}},
+			outcome: OutcomeCPULimit,
+		},
+		{
+			name:    "memory",
+			spec:    Spec{Path: self, Env: append(os.Environ(), allocateEnv+"=1"), Limits: Limits{MemoryBytes: 1 << 30}},
+			outcome: OutcomeMemoryLimit,
+			noRace:  true,
+		},
+		{
+			name:    "file size",
+			spec:    Spec{Path: "/bin/sh", Args: []string{"-c", "head -c 100000 /dev/zero > big"}, Limits: Limits{MaxFileSize: 1000}},
+			outcome: OutcomeExited,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.noRace && raceEnabled {
+				t.Skip("ThreadSanitizer cannot map its shadow memory under the limits")
+			}
+			spec := tt.spec
+			spec.Dir = t.TempDir()
+			spec.Limits.WallTime = 30 * time.Second
+			result := Run(context.Background(), spec)
+			if result.Outcome != tt.outcome || result.Success() {
+				t.Errorf("outcome = %s, exit code %d, want a failed %s\n%s", result.Outcome, result.ExitCode, tt.outcome, result.Output)
+			}
+		})
+	}
+}
+
+func TestRunKillsProcessTree(t *testing.T) {
+	result := shell(context.Background(), "sleep 30 & echo $!", Limits{WallTime: 10 * time.Second})
+	if !result.Success() {
+		t.Fatalf("outcome = %s, exit code %d", result.Outcome, result.ExitCode)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(result.Output)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The background process is killed; its new parent may not have reaped it yet
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+		if err != nil || strings.Contains(string(stat), ") Z ") {
+			return
+		}
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("background process %d survived the run", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,46 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// helperSupported is false outside Linux: only the wall-clock deadline and the
+// output limit are enforced there
+const helperSupported = false
+
+// setProcessGroup is a no-op on platforms without Linux process groups
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the process itself; descendants are not tracked
+func killProcessGroup(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return nil
+	}
+	if err := process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
+
+// killedByCPULimit is always false because RLIMIT_CPU is not applied
+func killedByCPULimit(state *os.ProcessState) bool {
+	return false
+}
+
+// setIsolatedProcessAttrs is never reached because isolation is unsupported
+func setIsolatedProcessAttrs(cmd *exec.Cmd) {}
+
+// probeIsolation always fails because namespaces are Linux-only
+func probeIsolation() error {
+	return errors.New("namespaces are only supported on Linux")
+}
+
+// runHelper is never reached because the helper is not used on this platform
+func runHelper(args []string) error {
+	return errors.New("resource limits are only supported on Linux")
+}
@@ -0,0 +1,191 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// allocateEnv makes the test binary allocate until it runs out of memory,
+// serving as the untrusted program of memory limit tests
+const allocateEnv = "SANDBOX_TEST_ALLOCATE"
+
+// TestMain lets the test binary serve as the sandbox helper, as the server
+// binary does
+func TestMain(m *testing.M) {
+	RunHelperIfRequested()
+	if os.Getenv(allocateEnv) != "" {
+		var chunks [][]byte
+		for {
+			chunk := make([]byte, 64<<20)
+			chunk[0] = 1
+			chunks = append(chunks, chunk)
+		}
+	}
+	os.Exit(m.Run())
+}
+
+// shell runs a shell script in the sandbox
+func shell(ctx context.Context, script string, limits Limits) Result {
+	return Run(ctx, Spec{Path: "/bin/sh", Args: []string{"-c", script}, Limits: limits})
+}
+
+func TestRun(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	tests := []struct {
+		name     string
+		script   string
+		limits   Limits
+		outcome  Outcome
+		exitCode int
+		output   string
+	}{
+		{
+			name:    "success",
+			script:  "echo hello",
+			outcome: OutcomeExited,
+			output:  "hello\n",
+		},
+		{
+			name:     "exit code",
+			script:   "echo oops >&2; exit 3",
+			outcome:  OutcomeExited,
+			exitCode: 3,
+			output:   "oops\n",
+		},
+		{
+			name:    "wall time",
+			script:  "echo started; sleep 10",
+			limits:  Limits{WallTime: 200 * time.Millisecond},
+			outcome: OutcomeTimeout,
+			output:  "started\n",
+		},
+		{
+			name:    "output limit",
+			script:  "while :; do echo 0123456789; done",
+			limits:  Limits{MaxOutputBytes: 25, WallTime: 10 * time.Second},
+			outcome: OutcomeOutputLimit,
+			output:  "0123456789\n0123456789\n012",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := shell(context.Background(), tt.script, tt.limits)
+			if result.Outcome != tt.outcome || result.Err != nil {
+				t.Errorf("outcome = %s (%v), want %s", result.Outcome, result.Err, tt.outcome)
+			}
+			if tt.outcome == OutcomeExited && result.ExitCode != tt.exitCode {
+				t.Errorf("exit code = %d, want %d", result.ExitCode, tt.exitCode)
+			}
+			if result.Success() != (tt.outcome == OutcomeExited && tt.exitCode == 0) {
+				t.Errorf("success = %v", result.Success())
+			}
+			if string(result.Output) != tt.output {
+				t.Errorf("output = %q, want %q", result.Output, tt.output)
+			}
+		})
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	start := time.Now()
+	if result := shell(ctx, "sleep 10", Limits{}); result.Outcome != OutcomeCanceled {
+		t.Errorf("outcome = %s, want %s", result.Outcome, OutcomeCanceled)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("canceled run took %v", elapsed)
+	}
+}
+
+func TestRunStartFailed(t *testing.T) {
+	result := Run(context.Background(), Spec{Path: filepath.Join(t.TempDir(), "missing")})
+	if result.Outcome != OutcomeStartFailed || result.Err == nil {
+		t.Errorf("outcome = %s (%v), want %s with an error", result.Outcome, result.Err, OutcomeStartFailed)
+	}
+}
+
+func TestRunStream(t *testing.T) {
+	var stream bytes.Buffer
+	result := Run(context.Background(), Spec{
+		Path:   "/bin/sh",
+		Args:   []string{"-c", "echo one; echo two"},
+		Limits: Limits{MaxOutputBytes: 6},
+		Stream: &stream,
+	})
+	if result.Outcome != OutcomeOutputLimit || stream.String() != "one\ntw" || string(result.Output) != stream.String() {
+		t.Errorf("outcome %s, output %q, streamed %q", result.Outcome, result.Output, stream.String())
+	}
+}
+
+func TestOutOfMemory(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    bool
+	}{
+		{"oom-page-summary.txt", true}, // Too little address space to start
+		{"oom-arena-map.txt", true},    // Failed to grow the heap
+		{"oom-heap.txt", true},         // Failed to allocate a large block
+		{"stack-overflow.txt", false},
+	}
+	for _, tt := range tests {
+		output, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := outOfMemory(output); got != tt.want {
+			t.Errorf("outOfMemory(%s) = %v, want %v", tt.fixture, got, tt.want)
+		}
+	}
+}
+
+func TestHelperArgs(t *testing.T) {
+	tests := []struct {
+		limits Limits
+		want   string
+	}{
+		{Limits{}, ""},
+		{Limits{WallTime: time.Minute, MaxOutputBytes: 1 << 20}, ""},
+		{Limits{CPUTime: 1500 * time.Millisecond}, "-cpu 2"},
+		{Limits{CPUTime: 2 * time.Second, MemoryBytes: 1 << 30, MaxProcesses: 64, MaxFileSize: 1 << 20}, "-cpu 2 -as 1073741824 -nproc 64 -fsize 1048576"},
+	}
+	for _, tt := range tests {
+		args := helperArgs(tt.limits)
+		if args[0] != helperArg {
+			t.Fatalf("helper arguments start with %q", args[0])
+		}
+		if got := strings.Join(args[1:], " "); got != tt.want {
+			t.Errorf("helperArgs(%+v) = %q, want %q", tt.limits, got, tt.want)
+		}
+		if tt.limits.hasRlimits() != (tt.want != "") {
+			t.Errorf("hasRlimits(%+v) = %v", tt.limits, tt.limits.hasRlimits())
+		}
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	exceeded := 0
+	var stream bytes.Buffer
+	b := &limitedBuffer{limit: 10, onExceed: func() { exceeded++ }, stream: &stream}
+	for _, write := range []string{"0123", "4567", "89ab", "cdef"} {
+		if n, err := b.Write([]byte(write)); n != len(write) || err != nil {
+			t.Errorf("Write(%q) = %d, %v", write, n, err)
+		}
+	}
+	if string(b.Bytes()) != "0123456789" || stream.String() != "0123456789" || !b.Exceeded() || exceeded != 1 {
+		t.Errorf("kept %q, streamed %q, exceeded %v (%d calls)", b.Bytes(), stream.String(), b.Exceeded(), exceeded)
+	}
+
+	unlimited := &limitedBuffer{}
+	unlimited.Write(bytes.Repeat([]byte("x"), 1<<16))
+	if len(unlimited.Bytes()) != 1<<16 || unlimited.Exceeded() {
+		t.Errorf("a buffer without a limit kept %d bytes", len(unlimited.Bytes()))
+	}
+}
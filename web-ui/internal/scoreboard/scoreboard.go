@@ -0,0 +1,358 @@
+// Package scoreboard reads and writes the SCOREBOARD.md files of classic and
+// package challenges.
+//
+// A scoreboard is a Markdown title followed by a table. The reader maps the
+// table's columns by their headers, so it accepts both the test count format
+//
+//	| Username   | Passed Tests | Total Tests |
+//
+// and the older ranked format (| Rank | Username | Solution | Date Submitted |).
+// The writer always emits the canonical test count format, adding Submitted At
+// and Execution Time columns only when an entry has them.
+package scoreboard
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileName is the name of a scoreboard file in a challenge directory
+const FileName = "SCOREBOARD.md"
+
+// Entry is a user's row on a scoreboard
+type Entry struct {
+	Username    string    `json:"username"`
+	Passed      int       `json:"passed"`                // Tests that passed
+	Total       int       `json:"total"`                 // Tests that ran
+	SubmittedAt time.Time `json:"submittedAt,omitempty"` // Zero if the scoreboard does not record it
+	ExecutionMs int64     `json:"executionMs,omitempty"` // Zero if the scoreboard does not record it
+}
+
+// Completed reports whether every test of the entry passed
+func (e Entry) Completed() bool {
+	return e.Total > 0 && e.Passed == e.Total
+}
+
+// Beats reports whether e is a better entry of a user than other: it
+// completed the challenge where other did not, or passed a larger share of
+// the tests
+func (e Entry) Beats(other Entry) bool {
+	if e.Completed() != other.Completed() {
+		return e.Completed()
+	}
+	if e.Total == 0 || other.Total == 0 {
+		return e.Passed > other.Passed
+	}
+	// Compare passed/total without rounding
+	return e.Passed*other.Total > other.Passed*e.Total
+}
+
+// Score returns the percentage of tests that passed
+func (e Entry) Score() int {
+	if e.Total == 0 {
+		return 0
+	}
+	return e.Passed * 100 / e.Total
+}
+
+// Scoreboard is the content of a SCOREBOARD.md file
+type Scoreboard struct {
+	Title   string  // The heading without "# ", e.g. "Scoreboard for challenge-1"
+	Entries []Entry // In file order
+}
+
+// Find returns the entry of a user
+func (s *Scoreboard) Find(username string) (Entry, bool) {
+	for _, entry := range s.Entries {
+		if entry.Username == username {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Upsert replaces the entry of the user, or adds it if there is none
+func (s *Scoreboard) Upsert(entry Entry) {
+	for i := range s.Entries {
+		if s.Entries[i].Username == entry.Username {
+			s.Entries[i] = entry
+			return
+		}
+	}
+	s.Entries = append(s.Entries, entry)
+}
+
+// Remove deletes the entry of a user and reports whether there was one
+func (s *Scoreboard) Remove(username string) bool {
+	for i := range s.Entries {
+		if s.Entries[i].Username == username {
+			s.Entries = append(s.Entries[:i], s.Entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Sort orders the entries by passed tests, most first, then by username
+func (s *Scoreboard) Sort() {
+	sort.SliceStable(s.Entries, func(i, j int) bool {
+		a, b := s.Entries[i], s.Entries[j]
+		if a.Passed != b.Passed {
+			return a.Passed > b.Passed
+		}
+		return a.Username < b.Username
+	})
+}
+
+// Columns the reader understands; other columns, such as Rank, are ignored
+const (
+	columnIgnored = iota
+	columnUsername
+	columnPassed
+	columnTotal
+	columnScore // "passed/total"
+	columnSubmitted
+	columnExecution
+)
+
+// columnNames maps lower-case column headers to columns
+var columnNames = map[string]int{
+	"username":       columnUsername,
+	"user":           columnUsername,
+	"passed tests":   columnPassed,
+	"passed":         columnPassed,
+	"total tests":    columnTotal,
+	"total":          columnTotal,
+	"score":          columnScore,
+	"submitted at":   columnSubmitted,
+	"date submitted": columnSubmitted,
+	"date":           columnSubmitted,
+	"execution time": columnExecution,
+}
+
+// timeLayouts are the submission time formats the reader accepts
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ReadFile reads a scoreboard file
+func ReadFile(path string) (*Scoreboard, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Parse parses the content of a scoreboard file
+func Parse(data []byte) (*Scoreboard, error) {
+	return Read(bytes.NewReader(data))
+}
+
+// Read parses a scoreboard. The title is the first "# " heading and the
+// entries come from the first table; rows without a username, such as
+// placeholder rows of dashes, are skipped.
+func Read(r io.Reader) (*Scoreboard, error) {
+	s := &Scoreboard{Entries: []Entry{}}
+	var columns []int // nil until the header row is read
+	separated := false
+	lineNumber := 0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		if !strings.HasPrefix(line, "|") {
+			if s.Title == "" && columns == nil && strings.HasPrefix(line, "# ") {
+				s.Title = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+			}
+			if columns != nil && separated && line != "" {
+				break // The table ended
+			}
+			continue
+		}
+
+		cells := splitRow(line)
+		switch {
+		case columns == nil:
+			columns = make([]int, len(cells))
+			hasUsername := false
+			for i, cell := range cells {
+				columns[i] = columnNames[strings.ToLower(cell)]
+				hasUsername = hasUsername || columns[i] == columnUsername
+			}
+			if !hasUsername {
+				return nil, fmt.Errorf("line %d: table has no Username column", lineNumber)
+			}
+		case !separated:
+			if !isSeparator(cells) {
+				return nil, fmt.Errorf("line %d: expected the table's separator row", lineNumber)
+			}
+			separated = true
+		default:
+			entry, err := parseRow(cells, columns)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+			if entry.Username != "" {
+				s.Entries = append(s.Entries, entry)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// splitRow returns the trimmed cells of a table row
+func splitRow(line string) []string {
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	cells := strings.Split(line, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+// isSeparator reports whether the cells are a table separator row
+func isSeparator(cells []string) bool {
+	for _, cell := range cells {
+		if strings.Trim(cell, "-:") != "" || !strings.Contains(cell, "-") {
+			return false
+		}
+	}
+	return true
+}
+
+// isPlaceholder reports whether a cell has no value: empty, or dashes only
+func isPlaceholder(cell string) bool {
+	return strings.Trim(cell, "-") == ""
+}
+
+// parseRow parses a table row into an entry. A row whose username is a
+// placeholder returns an entry without a username.
+func parseRow(cells []string, columns []int) (Entry, error) {
+	var entry Entry
+	for i, column := range columns {
+		if i >= len(cells) || isPlaceholder(cells[i]) {
+			continue
+		}
+		cell := cells[i]
+		var err error
+		switch column {
+		case columnUsername:
+			entry.Username = cell
+		case columnPassed:
+			entry.Passed, err = strconv.Atoi(cell)
+		case columnTotal:
+			entry.Total, err = strconv.Atoi(cell)
+		case columnScore:
+			passed, total, found := strings.Cut(cell, "/")
+			if !found {
+				continue // A score without test counts, e.g. points
+			}
+			if entry.Passed, err = strconv.Atoi(strings.TrimSpace(passed)); err == nil {
+				entry.Total, err = strconv.Atoi(strings.TrimSpace(total))
+			}
+		case columnSubmitted:
+			entry.SubmittedAt, err = parseTime(cell)
+		case columnExecution:
+			var d time.Duration
+			d, err = time.ParseDuration(cell)
+			entry.ExecutionMs = d.Milliseconds()
+		}
+		if err != nil {
+			return Entry{}, fmt.Errorf("invalid value %q: %v", cell, err)
+		}
+	}
+	if entry.Username == "" {
+		return Entry{}, nil
+	}
+	return entry, nil
+}
+
+// parseTime parses a submission time in any of the accepted layouts
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format")
+}
+
+// Format renders a scoreboard in the canonical format
+func Format(s *Scoreboard) []byte {
+	var buf bytes.Buffer
+	Write(&buf, s)
+	return buf.Bytes()
+}
+
+// Write writes a scoreboard in the canonical format
+func Write(w io.Writer, s *Scoreboard) error {
+	withSubmitted, withExecution := false, false
+	for _, entry := range s.Entries {
+		withSubmitted = withSubmitted || !entry.SubmittedAt.IsZero()
+		withExecution = withExecution || entry.ExecutionMs > 0
+	}
+
+	// The header and separator are spaced as the scoreboard workflows write them
+	header := "| Username   | Passed Tests | Total Tests |"
+	separator := "|------------|--------------|-------------|"
+	if withSubmitted {
+		header += " Submitted At |"
+		separator += "--------------|"
+	}
+	if withExecution {
+		header += " Execution Time |"
+		separator += "----------------|"
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "# %s\n%s\n%s\n", s.Title, header, separator)
+	for _, entry := range s.Entries {
+		fmt.Fprintf(&out, "| %s | %d | %d |", entry.Username, entry.Passed, entry.Total)
+		if withSubmitted {
+			if entry.SubmittedAt.IsZero() {
+				out.WriteString(" - |")
+			} else {
+				fmt.Fprintf(&out, " %s |", entry.SubmittedAt.Format(time.RFC3339))
+			}
+		}
+		if withExecution {
+			if entry.ExecutionMs > 0 {
+				fmt.Fprintf(&out, " %dms |", entry.ExecutionMs)
+			} else {
+				out.WriteString(" - |")
+			}
+		}
+		out.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// WriteFile writes a scoreboard file in the canonical format
+func WriteFile(path string, s *Scoreboard) error {
+	return os.WriteFile(path, Format(s), 0644)
+}
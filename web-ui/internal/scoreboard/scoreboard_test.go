@@ -0,0 +1,244 @@
+package scoreboard
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// repoScoreboards returns every SCOREBOARD.md of the repository's classic and
+// package challenges
+func repoScoreboards(t *testing.T) []string {
+	t.Helper()
+	var paths []string
+	for _, pattern := range []string{
+		filepath.Join("..", "..", "..", "challenge-*", FileName),
+		filepath.Join("..", "..", "..", "packages", "*", "*", FileName),
+	} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no SCOREBOARD.md files found")
+	}
+	return paths
+}
+
+func TestRoundTripRepoScoreboards(t *testing.T) {
+	for _, path := range repoScoreboards(t) {
+		t.Run(path, func(t *testing.T) {
+			original, err := ReadFile(path)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if original.Title == "" {
+				t.Errorf("no title")
+			}
+
+			formatted := Format(original)
+			reparsed, err := Parse(formatted)
+			if err != nil {
+				t.Fatalf("parse formatted scoreboard: %v\n%s", err, formatted)
+			}
+			if !reflect.DeepEqual(original, reparsed) {
+				t.Errorf("round trip changed the scoreboard\nbefore: %+v\nafter:  %+v", original, reparsed)
+			}
+			if again := Format(reparsed); !bytes.Equal(formatted, again) {
+				t.Errorf("formatting is not stable\nfirst:\n%s\nsecond:\n%s", formatted, again)
+			}
+		})
+	}
+}
+
+func TestRepoScoreboardEntries(t *testing.T) {
+	for _, path := range repoScoreboards(t) {
+		s, err := ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		seen := make(map[string]bool)
+		for _, entry := range s.Entries {
+			if entry.Username == "" || strings.Trim(entry.Username, "-") == "" {
+				t.Errorf("%s: placeholder row read as an entry: %+v", path, entry)
+			}
+			if entry.Total <= 0 || entry.Passed > entry.Total {
+				t.Errorf("%s: implausible test counts: %+v", path, entry)
+			}
+			if seen[entry.Username] {
+				t.Errorf("%s: %s is listed twice", path, entry.Username)
+			}
+			seen[entry.Username] = true
+		}
+	}
+}
+
+func TestFormatKeepsCanonicalFiles(t *testing.T) {
+	canonical := "# Scoreboard for challenge-1\n" +
+		"| Username   | Passed Tests | Total Tests |\n" +
+		"|------------|--------------|-------------|\n" +
+		"| alice | 6 | 6 |\n" +
+		"| bob | 4 | 6 |\n"
+	s, err := Parse([]byte(canonical))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(Format(s)); got != canonical {
+		t.Errorf("got:\n%s\nwant:\n%s", got, canonical)
+	}
+
+	// The classic scoreboards are written in the canonical format
+	matches, _ := filepath.Glob(filepath.Join("..", "..", "..", "challenge-*", FileName))
+	for _, path := range matches {
+		s, err := ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Format(s); !bytes.Equal(got, data) {
+			t.Errorf("%s is not in the canonical format\ngot:\n%s", path, got)
+		}
+	}
+}
+
+func TestReadFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *Scoreboard
+	}{
+		{
+			name: "package scoreboard with blank line and trailing space",
+			content: "# Scoreboard for gin challenge-1\n\n" +
+				"| Username   | Passed Tests | Total Tests |\n" +
+				"|------------|--------------|-------------|\n" +
+				"| alice | 13 | 13 | ",
+			want: &Scoreboard{
+				Title:   "Scoreboard for gin challenge-1",
+				Entries: []Entry{{Username: "alice", Passed: 13, Total: 13}},
+			},
+		},
+		{
+			name: "ranked format",
+			content: "# Scoreboard for challenge-2\n" +
+				"| Rank | Username | Solution | Date Submitted |\n" +
+				"|------|----------|----------|----------------|\n" +
+				"| 1 | alice | [Solution](submissions/alice/solution-template.go) | 2024-03-01 |\n" +
+				"| 2 | 1234 | [Solution](submissions/1234/solution-template.go) | - |\n",
+			want: &Scoreboard{
+				Title: "Scoreboard for challenge-2",
+				Entries: []Entry{
+					{Username: "alice", SubmittedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+					{Username: "1234"},
+				},
+			},
+		},
+		{
+			name: "score column and placeholder row",
+			content: "# Scoreboard\n\n" +
+				"| Rank | User | Score | Date |\n" +
+				"|------|------|-------|------|\n" +
+				"| - | - | - | - | \n" +
+				"| 1 | bob | 7/8 | 2024-05-06 10:00:00 |\n",
+			want: &Scoreboard{
+				Title: "Scoreboard",
+				Entries: []Entry{
+					{Username: "bob", Passed: 7, Total: 8, SubmittedAt: time.Date(2024, 5, 6, 10, 0, 0, 0, time.UTC)},
+				},
+			},
+		},
+		{
+			name: "extended canonical format",
+			content: "# Scoreboard for challenge-3\n" +
+				"| Username   | Passed Tests | Total Tests | Submitted At | Execution Time |\n" +
+				"|------------|--------------|-------------|--------------|----------------|\n" +
+				"| carol | 5 | 5 | 2024-01-02T03:04:05Z | 1.5s |\n" +
+				"| dave | 3 | 5 | - | - |\n",
+			want: &Scoreboard{
+				Title: "Scoreboard for challenge-3",
+				Entries: []Entry{
+					{Username: "carol", Passed: 5, Total: 5, SubmittedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), ExecutionMs: 1500},
+					{Username: "dave", Passed: 3, Total: 5},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := map[string]string{
+		"no username column": "# Scoreboard\n| Rank | Score |\n|---|---|\n",
+		"missing separator":  "# Scoreboard\n| Username | Passed Tests | Total Tests |\n| alice | 1 | 1 |\n",
+		"bad test count":     "# Scoreboard\n| Username | Passed Tests | Total Tests |\n|---|---|---|\n| alice | one | 1 |\n",
+	}
+	for name, content := range tests {
+		if _, err := Parse([]byte(content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestWriteExtraColumns(t *testing.T) {
+	s := &Scoreboard{
+		Title: "Scoreboard for challenge-3",
+		Entries: []Entry{
+			{Username: "carol", Passed: 5, Total: 5, ExecutionMs: 1500},
+			{Username: "dave", Passed: 3, Total: 5},
+		},
+	}
+	want := "# Scoreboard for challenge-3\n" +
+		"| Username   | Passed Tests | Total Tests | Execution Time |\n" +
+		"|------------|--------------|-------------|----------------|\n" +
+		"| carol | 5 | 5 | 1500ms |\n" +
+		"| dave | 3 | 5 | - |\n"
+	if got := string(Format(s)); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSortAndUpsert(t *testing.T) {
+	s := &Scoreboard{Entries: []Entry{
+		{Username: "bob", Passed: 4, Total: 6},
+		{Username: "alice", Passed: 6, Total: 6},
+		{Username: "Zed", Passed: 4, Total: 6},
+	}}
+	s.Upsert(Entry{Username: "bob", Passed: 6, Total: 6})
+	s.Upsert(Entry{Username: "carol", Passed: 1, Total: 6})
+	s.Sort()
+
+	var order []string
+	for _, entry := range s.Entries {
+		order = append(order, entry.Username)
+	}
+	want := []string{"alice", "bob", "Zed", "carol"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("got order %v, want %v", order, want)
+	}
+	if entry, ok := s.Find("bob"); !ok || !entry.Completed() || entry.Score() != 100 {
+		t.Errorf("bob: got %+v, %v", entry, ok)
+	}
+	if !s.Remove("carol") || s.Remove("carol") {
+		t.Errorf("Remove did not delete carol exactly once")
+	}
+}
@@ -2,11 +2,14 @@ package server
 
 import (
 	"embed"
+	"encoding/json"
 	"io/fs"
 	"log"
 	"net/http"
 	"strings"
 
+	"web-ui/internal/auth"
+	"web-ui/internal/config"
 	"web-ui/internal/handlers"
 	"web-ui/internal/services"
 )
@@ -19,6 +22,14 @@ type Server struct {
 	userService       *services.UserService
 	executionService  *services.ExecutionService
 	packageService    *services.PackageService
+	jobService        *services.JobService
+	submissionStore   services.SubmissionStore
+	contentWatcher    *services.ContentWatcher
+	authService       *auth.Service
+	teamService       *services.TeamService
+	interviewService  *services.InterviewService
+	recorderService   *services.RecorderService
+	features          config.Features
 }
 
 // NewServer creates a new server instance
@@ -29,6 +40,14 @@ func NewServer(
 	userService *services.UserService,
 	executionService *services.ExecutionService,
 	packageService *services.PackageService,
+	jobService *services.JobService,
+	submissionStore services.SubmissionStore,
+	contentWatcher *services.ContentWatcher,
+	authService *auth.Service,
+	teamService *services.TeamService,
+	interviewService *services.InterviewService,
+	recorderService *services.RecorderService,
+	features config.Features,
 ) *Server {
 	return &Server{
 		content:           content,
@@ -37,6 +56,14 @@ func NewServer(
 		userService:       userService,
 		executionService:  executionService,
 		packageService:    packageService,
+		jobService:        jobService,
+		submissionStore:   submissionStore,
+		contentWatcher:    contentWatcher,
+		authService:       authService,
+		teamService:       teamService,
+		interviewService:  interviewService,
+		recorderService:   recorderService,
+		features:          features,
 	}
 }
 
@@ -54,6 +81,13 @@ func (s *Server) SetupRoutes() *http.ServeMux {
 		s.userService,
 		s.executionService,
 		s.packageService,
+		s.jobService,
+		s.submissionStore,
+		s.contentWatcher,
+		s.authService,
+		s.teamService,
+		s.interviewService,
+		s.recorderService,
 	)
 
 	webHandler := handlers.NewWebHandler(
@@ -62,29 +96,74 @@ func (s *Server) SetupRoutes() *http.ServeMux {
 		s.scoreboardService,
 		s.userService,
 		s.packageService,
+		s.authService,
+		s.teamService,
+		s.interviewService,
 	)
 
+	authHandler := handlers.NewAuthHandler(s.content, s.authService)
+
+	// Saving submissions into the repository can be turned off
+	saveToFilesystem := apiHandler.SaveSubmissionToFilesystem
+	savePackageToFilesystem := apiHandler.SavePackageChallengeToFilesystem
+	if !s.features.SaveToFilesystem {
+		saveToFilesystem = featureDisabled("Saving submissions to the filesystem")
+		savePackageToFilesystem = saveToFilesystem
+	}
+
+	// API requests are small JSON bodies: larger ones are cut off before
+	// they are read
+	api := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, http.MaxBytesHandler(handler, maxRequestBytes))
+	}
+
 	// API routes
-	mux.HandleFunc("/api/challenges", apiHandler.GetAllChallenges)
-	mux.HandleFunc("/api/challenges/", apiHandler.GetChallengeByID)
-	mux.HandleFunc("/api/submissions", apiHandler.HandleSubmissions)
-	mux.HandleFunc("/api/scoreboard/", apiHandler.GetScoreboard)
-	mux.HandleFunc("/api/run", apiHandler.RunCode)
-	mux.HandleFunc("/api/save-to-filesystem", apiHandler.SaveSubmissionToFilesystem)
-	mux.HandleFunc("/api/refresh-attempts", apiHandler.RefreshUserAttempts)
-	mux.HandleFunc("/api/git-username", apiHandler.GetGitUsername)
-	mux.HandleFunc("/api/main-scoreboard-rank", apiHandler.GetMainScoreboardRank)
-	mux.HandleFunc("/api/main-leaderboard", apiHandler.GetMainLeaderboard)
+	api("/api/challenges", apiHandler.GetAllChallenges)
+	api("/api/challenges/", apiHandler.GetChallengeByID)
+	api("/api/submissions", apiHandler.HandleSubmissions)
+	api("/api/submissions/", apiHandler.GetSubmission)
+	api("/api/users/", apiHandler.GetUserAttempts)
+	api("/api/attempts/", apiHandler.DiffAttempts)
+	api("/api/scoreboard/", apiHandler.GetScoreboard)
+	api("/api/run", apiHandler.RunCode)
+	api("/api/jobs/", apiHandler.HandleJob)
+	api("/api/save-to-filesystem", saveToFilesystem)
+	api("/api/refresh-attempts", apiHandler.RefreshUserAttempts)
+	api("/api/git-username", apiHandler.GetGitUsername)
+	api("/api/main-scoreboard-rank", apiHandler.GetMainScoreboardRank)
+	api("/api/main-leaderboard", apiHandler.GetMainLeaderboard)
+	api("/api/admin/reload", apiHandler.ReloadContent)
+
+	// Auth routes
+	api("/api/auth/register", authHandler.Register)
+	api("/api/auth/login", authHandler.Login)
+	api("/api/auth/logout", authHandler.Logout)
+	api("/api/auth/me", authHandler.Me)
+	mux.HandleFunc("/auth/", authHandler.HandleProvider)
+	mux.HandleFunc("/login", authHandler.LoginPage)
+
+	// Team routes
+	api("/api/teams", apiHandler.HandleTeams)
+	api("/api/teams/", apiHandler.HandleTeams)
+
+	// Interview routes
+	api("/api/interviews", apiHandler.HandleInterviews)
+	api("/api/interviews/", apiHandler.HandleInterviews)
 
 	// Package challenge API routes
-	mux.HandleFunc("/api/packages/", apiHandler.HandlePackageChallenge)
-	mux.HandleFunc("/api/packages-save-to-filesystem", apiHandler.SavePackageChallengeToFilesystem)
+	api("/api/packages/", apiHandler.HandlePackageChallenge)
+	api("/api/packages-save-to-filesystem", savePackageToFilesystem)
 
 	// Web routes
 	mux.HandleFunc("/", webHandler.HomePage)
 	mux.HandleFunc("/challenge/", webHandler.ChallengePage)
 	mux.HandleFunc("/scoreboard", webHandler.ScoreboardPage)
 	mux.HandleFunc("/scoreboard/", webHandler.ScoreChallengeHandler)
+	mux.HandleFunc("/teams", webHandler.TeamsPage)
+	mux.HandleFunc("/teams/", webHandler.TeamDashboardPage)
+	mux.HandleFunc("/interviews", webHandler.InterviewsPage)
+	mux.HandleFunc("/interviews/", webHandler.InterviewReportPage)
+	mux.HandleFunc("/interview/", webHandler.HandleCandidate)
 	mux.HandleFunc("/packages/", func(w http.ResponseWriter, r *http.Request) {
 		// Route to appropriate handler based on URL structure
 		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
@@ -102,6 +181,22 @@ func (s *Server) SetupRoutes() *http.ServeMux {
 	return mux
 }
 
+// maxRequestBytes is the largest API request body read
+const maxRequestBytes = 1 << 20
+
+// featureDisabled answers the requests of a feature the configuration turned
+// off, in the shape the web UI shows failures of
+func featureDisabled(feature string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": feature + " is turned off on this server",
+		})
+	}
+}
+
 // setupStaticFiles configures static file serving
 func (s *Server) setupStaticFiles(mux *http.ServeMux) {
 	fsys, err := fs.Sub(s.content, "static")
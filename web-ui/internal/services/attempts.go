@@ -0,0 +1,141 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// Attempt summarizes one submission in a user's history on a challenge
+type Attempt struct {
+	ID          int64     `json:"id"`
+	Number      int       `json:"number"` // 1 for the user's first attempt
+	SubmittedAt time.Time `json:"submittedAt"`
+	Passed      bool      `json:"passed"`
+	Status      string    `json:"status"`
+	TestsPassed int       `json:"testsPassed"`
+	TestsTotal  int       `json:"testsTotal"`
+	ExecutionMs int64     `json:"executionMs"`
+	Coverage    *float64  `json:"coverage,omitempty"`
+}
+
+// TestFlip is a test that passed in one attempt and not in the other
+type TestFlip struct {
+	Name string     `json:"name"`
+	From TestStatus `json:"from"` // Empty if the test did not run, e.g. the build failed
+	To   TestStatus `json:"to"`
+}
+
+// SubmissionDiff compares the code and test results of two submissions
+type SubmissionDiff struct {
+	From       Attempt    `json:"from"`
+	To         Attempt    `json:"to"`
+	Unified    string     `json:"unified"`    // diff -u of the code; empty if it is the same
+	SideBySide []DiffRow  `json:"sideBySide"` // The code of both attempts aligned line by line
+	Fixed      []TestFlip `json:"fixed"`      // Tests that pass only in To
+	Broken     []TestFlip `json:"broken"`     // Tests that pass only in From
+}
+
+// Attempts returns a user's attempts on a challenge, oldest first. Classic
+// challenges are selected by challengeID, package challenges by packageName
+// and packageChallengeID.
+func Attempts(store SubmissionStore, username string, challengeID int, packageName, packageChallengeID string) ([]Attempt, error) {
+	page, err := store.Query(SubmissionQuery{
+		Username:           username,
+		ChallengeID:        challengeID,
+		PackageName:        packageName,
+		PackageChallengeID: packageChallengeID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := []Attempt{}
+	for i := len(page.Submissions) - 1; i >= 0; i-- {
+		attempt := attemptOf(&page.Submissions[i])
+		attempt.Number = len(attempts) + 1
+		attempts = append(attempts, attempt)
+	}
+	return attempts, nil
+}
+
+// DiffSubmissions compares two submissions: how the code changed from a to b
+// and which tests flipped between passing and failing
+func DiffSubmissions(a, b *SubmissionRecord) SubmissionDiff {
+	script := diffLines(splitLines(a.Code), splitLines(b.Code))
+	diff := SubmissionDiff{
+		From:       attemptOf(a),
+		To:         attemptOf(b),
+		Unified:    unifiedDiff(fmt.Sprintf("attempt %d", a.ID), fmt.Sprintf("attempt %d", b.ID), script),
+		SideBySide: sideBySide(script),
+		Fixed:      []TestFlip{},
+		Broken:     []TestFlip{},
+	}
+
+	before, after := testStatuses(a), testStatuses(b)
+	var names []string
+	seen := make(map[string]bool)
+	for _, statuses := range []*orderedStatuses{before, after} {
+		for _, name := range statuses.names {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	for _, name := range names {
+		from, to := before.status[name], after.status[name]
+		switch {
+		case from != TestPassed && to == TestPassed:
+			diff.Fixed = append(diff.Fixed, TestFlip{Name: name, From: from, To: to})
+		case from == TestPassed && to != TestPassed:
+			diff.Broken = append(diff.Broken, TestFlip{Name: name, From: from, To: to})
+		}
+	}
+	return diff
+}
+
+// attemptOf summarizes a submission
+func attemptOf(record *SubmissionRecord) Attempt {
+	return Attempt{
+		ID:          record.ID,
+		SubmittedAt: record.SubmittedAt,
+		Passed:      record.Passed,
+		Status:      record.Status,
+		TestsPassed: record.TestsPassed,
+		TestsTotal:  record.TestsTotal,
+		ExecutionMs: record.ExecutionMs,
+		Coverage:    record.Coverage,
+	}
+}
+
+// orderedStatuses maps test names to statuses, remembering the test order
+type orderedStatuses struct {
+	names  []string
+	status map[string]TestStatus
+}
+
+// testStatuses returns the status of every test and subtest of a submission
+func testStatuses(record *SubmissionRecord) *orderedStatuses {
+	if record.Result == nil {
+		return reportStatuses(nil)
+	}
+	return reportStatuses(record.Result.Report)
+}
+
+// reportStatuses returns the status of every test and subtest of a report
+func reportStatuses(report *TestReport) *orderedStatuses {
+	statuses := &orderedStatuses{status: make(map[string]TestStatus)}
+	if report == nil {
+		return statuses
+	}
+	var walk func(tests []*TestResult)
+	walk = func(tests []*TestResult) {
+		for _, test := range tests {
+			statuses.names = append(statuses.names, test.Name)
+			statuses.status[test.Name] = test.Status
+			walk(test.Subtests)
+		}
+	}
+	walk(report.Tests)
+	return statuses
+}
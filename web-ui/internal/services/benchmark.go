@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"web-ui/internal/models"
+	"web-ui/internal/sandbox"
+)
+
+// BenchmarkResult is the result of one benchmark, aggregated over its runs
+type BenchmarkResult struct {
+	Name        string  `json:"name"`       // e.g. "BenchmarkOptimizedSort/1000"
+	Runs        int     `json:"runs"`       // Number of runs (-count)
+	Iterations  int64   `json:"iterations"` // b.N of the median run
+	NsPerOp     float64 `json:"nsPerOp"`    // Median over the runs
+	BytesPerOp  int64   `json:"bytesPerOp"`
+	AllocsPerOp int64   `json:"allocsPerOp"`
+}
+
+// BenchmarkCase compares one optimized (sub-)benchmark to the baseline one
+// with the same name
+type BenchmarkCase struct {
+	Name            string  `json:"name"` // Sub-benchmark name, e.g. "1000"; empty without sub-benchmarks
+	BaselineNsPerOp float64 `json:"baselineNsPerOp"`
+	NsPerOp         float64 `json:"nsPerOp"`
+	BytesPerOp      int64   `json:"bytesPerOp"`
+	AllocsPerOp     int64   `json:"allocsPerOp"`
+	Speedup         float64 `json:"speedup"` // BaselineNsPerOp / NsPerOp
+}
+
+// BenchmarkComparison is the outcome of one baseline/optimized pair
+type BenchmarkComparison struct {
+	Baseline   string          `json:"baseline"`
+	Optimized  string          `json:"optimized"`
+	Speedup    float64         `json:"speedup"` // Geometric mean of the case speedups
+	MinSpeedup float64         `json:"minSpeedup,omitempty"`
+	Cases      []BenchmarkCase `json:"cases"`
+	Passed     bool            `json:"passed"`             // All thresholds of the pair were met
+	Failures   []string        `json:"failures,omitempty"` // Thresholds that were missed
+}
+
+// BenchmarkReport holds the results of a benchmark run and how they compare to
+// the baseline of the reference solution
+type BenchmarkReport struct {
+	Results       []BenchmarkResult     `json:"results"`
+	Comparisons   []BenchmarkComparison `json:"comparisons,omitempty"`
+	ThresholdsMet bool                  `json:"thresholdsMet"`
+	Message       string                `json:"message,omitempty"` // Why benchmarks were skipped or not compared
+	Output        string                `json:"output"`
+}
+
+// runBenchmark runs the solution's benchmarks after its tests passed and
+// compares them to the baseline of the challenge's reference solution
+func (es *ExecutionService) runBenchmark(ctx context.Context, ws *workspace, challenge *models.Challenge, binary string, limits sandbox.Limits, onOutput OutputFunc) *BenchmarkReport {
+	config := challenge.Benchmark
+	if config == nil {
+		config = &models.BenchmarkConfig{}
+	}
+	report := &BenchmarkReport{ThresholdsMet: true}
+
+	output := newLineWriter(PhaseBenchmark, onOutput)
+	results, run := es.runBenchmarkBinary(ctx, binary, ".", config, limits, output.Writer())
+	output.Flush()
+	report.Results = results
+	report.Output = string(run.Output)
+	if !run.Success() {
+		report.ThresholdsMet = !hasBenchmarkThresholds(config)
+		report.Message = "Benchmarks did not finish: " + benchmarkFailure(run)
+		return report
+	}
+	if len(config.Pairs) == 0 {
+		return report
+	}
+
+	baseline, err := es.benchmarkBaseline(ctx, ws, config, limits)
+	if err != nil {
+		report.ThresholdsMet = !hasBenchmarkThresholds(config)
+		report.Message = fmt.Sprintf("Benchmarks could not be compared to the reference solution: %v", err)
+		return report
+	}
+	report.Comparisons, report.ThresholdsMet = compareBenchmarks(config.Pairs, baseline, results)
+	return report
+}
+
+// benchmarkBaseline returns the baseline benchmarks of the config's pairs, run
+// against the solution template. It is measured once per workspace and
+// config and kept in the baselines directory next to the workspace, so that
+// runs building in the workspace never see it being written.
+func (es *ExecutionService) benchmarkBaseline(ctx context.Context, ws *workspace, config *models.BenchmarkConfig, limits sandbox.Limits) (map[string]BenchmarkResult, error) {
+	ws.baselineMu.Lock()
+	defer ws.baselineMu.Unlock()
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	configJSON, _ := json.Marshal(config)
+	hash := sha256.Sum256(configJSON)
+	file := baselineFile(ws.dir, hex.EncodeToString(hash[:6]))
+
+	var results []BenchmarkResult
+	if data, err := ioutil.ReadFile(file); err == nil && json.Unmarshal(data, &results) == nil {
+		return benchmarksByName(results), nil
+	}
+
+	tempDir, err := ioutil.TempDir("", "challenge-baseline")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	binary := filepath.Join(tempDir, testBinaryName)
+	build := es.runGo(ctx, ws.dir, nil, "test", "-c", "-mod=readonly", "-o", binary)
+	if !build.Success() {
+		return nil, fmt.Errorf("the reference solution does not build")
+	}
+
+	var names []string
+	for _, pair := range config.Pairs {
+		names = append(names, regexp.QuoteMeta(pair.Baseline))
+	}
+	results, run := es.runBenchmarkBinary(ctx, binary, "^("+strings.Join(names, "|")+")$", config, limits, nil)
+	if !run.Success() {
+		return nil, fmt.Errorf("baseline benchmarks did not finish: %s", benchmarkFailure(run))
+	}
+
+	if data, err := json.Marshal(results); err == nil && os.MkdirAll(filepath.Dir(file), 0755) == nil {
+		tmp := file + ".tmp"
+		if ioutil.WriteFile(tmp, data, 0644) == nil {
+			os.Rename(tmp, file)
+		}
+	}
+	return benchmarksByName(results), nil
+}
+
+// runBenchmarkBinary runs the benchmarks matching pattern of a compiled test
+// binary in the sandbox, skipping all tests. GOMAXPROCS is fixed to 1 so that
+// results are comparable between machines and runs.
+func (es *ExecutionService) runBenchmarkBinary(ctx context.Context, binary, pattern string, config *models.BenchmarkConfig, limits sandbox.Limits, stream io.Writer) ([]BenchmarkResult, sandbox.Result) {
+	count := config.Count
+	if count <= 0 {
+		count = 1
+	}
+	args := []string{
+		"-test.run=^$",
+		"-test.bench=" + pattern,
+		"-test.benchmem",
+		"-test.cpu=1",
+		fmt.Sprintf("-test.count=%d", count),
+	}
+	if config.Benchtime != "" {
+		args = append(args, "-test.benchtime="+config.Benchtime)
+	}
+
+	run := sandbox.Run(ctx, sandbox.Spec{
+		Path:          binary,
+		Args:          args,
+		Dir:           filepath.Dir(binary),
+		Env:           es.goEnv,
+		Limits:        limits,
+		Isolation:     es.isolation,
+		ReadOnlyPaths: es.isolatedReadOnlyPaths(ctx),
+		Stream:        stream,
+	})
+	return parseBenchmarkOutput(run.Output), run
+}
+
+// benchmarkFailure describes why a benchmark run failed
+func benchmarkFailure(run sandbox.Result) string {
+	if run.Outcome == sandbox.OutcomeExited {
+		return fmt.Sprintf("exit status %d", run.ExitCode)
+	}
+	return string(run.Outcome)
+}
+
+// hasBenchmarkThresholds reports whether any pair declares a threshold, i.e.
+// whether benchmarks count toward passing
+func hasBenchmarkThresholds(config *models.BenchmarkConfig) bool {
+	for _, pair := range config.Pairs {
+		if pairHasThresholds(pair) {
+			return true
+		}
+	}
+	return false
+}
+
+// pairHasThresholds reports whether a pair declares any threshold
+func pairHasThresholds(pair models.BenchmarkPair) bool {
+	return pair.MinSpeedup > 0 || pair.MaxBytesPerOp != nil || pair.MaxAllocsPerOp != nil
+}
+
+// benchmarkRun is one result line of go test -bench
+type benchmarkRun struct {
+	iterations  int64
+	nsPerOp     float64
+	bytesPerOp  int64
+	allocsPerOp int64
+}
+
+// parseBenchmarkOutput parses the result lines of go test -bench -benchmem,
+// e.g. "BenchmarkSlowSort/10  1000000  1052 ns/op  80 B/op  1 allocs/op", and
+// aggregates the runs of each benchmark to their median
+func parseBenchmarkOutput(output []byte) []BenchmarkResult {
+	runs := make(map[string][]benchmarkRun)
+	var order []string
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		iterations, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		run := benchmarkRun{iterations: iterations, nsPerOp: -1}
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, unit := fields[i], fields[i+1]
+			switch unit {
+			case "ns/op":
+				run.nsPerOp, _ = strconv.ParseFloat(value, 64)
+			case "B/op":
+				run.bytesPerOp, _ = strconv.ParseInt(value, 10, 64)
+			case "allocs/op":
+				run.allocsPerOp, _ = strconv.ParseInt(value, 10, 64)
+			}
+		}
+		if run.nsPerOp < 0 {
+			continue
+		}
+
+		name := fields[0]
+		if _, seen := runs[name]; !seen {
+			order = append(order, name)
+		}
+		runs[name] = append(runs[name], run)
+	}
+
+	results := make([]BenchmarkResult, 0, len(order))
+	for _, name := range order {
+		benchRuns := runs[name]
+		sort.Slice(benchRuns, func(i, j int) bool { return benchRuns[i].nsPerOp < benchRuns[j].nsPerOp })
+		median := benchRuns[len(benchRuns)/2]
+		results = append(results, BenchmarkResult{
+			Name:        name,
+			Runs:        len(benchRuns),
+			Iterations:  median.iterations,
+			NsPerOp:     median.nsPerOp,
+			BytesPerOp:  median.bytesPerOp,
+			AllocsPerOp: median.allocsPerOp,
+		})
+	}
+	return results
+}
+
+// benchmarksByName indexes benchmark results by name
+func benchmarksByName(results []BenchmarkResult) map[string]BenchmarkResult {
+	byName := make(map[string]BenchmarkResult, len(results))
+	for _, result := range results {
+		byName[result.Name] = result
+	}
+	return byName
+}
+
+// compareBenchmarks compares the optimized benchmarks of each pair to their
+// baseline and checks the pair's thresholds. Pairs without thresholds are
+// informational and never fail.
+func compareBenchmarks(pairs []models.BenchmarkPair, baseline map[string]BenchmarkResult, results []BenchmarkResult) ([]BenchmarkComparison, bool) {
+	allPassed := true
+	comparisons := make([]BenchmarkComparison, 0, len(pairs))
+	for _, pair := range pairs {
+		comparison := BenchmarkComparison{
+			Baseline:   pair.Baseline,
+			Optimized:  pair.Optimized,
+			MinSpeedup: pair.MinSpeedup,
+		}
+
+		logSum := 0.0
+		for _, result := range results {
+			if result.Name != pair.Optimized && !strings.HasPrefix(result.Name, pair.Optimized+"/") {
+				continue
+			}
+			sub := strings.TrimPrefix(result.Name, pair.Optimized)
+			base, ok := baseline[pair.Baseline+sub]
+			if !ok || result.NsPerOp <= 0 {
+				continue
+			}
+
+			benchCase := BenchmarkCase{
+				Name:            strings.TrimPrefix(sub, "/"),
+				BaselineNsPerOp: base.NsPerOp,
+				NsPerOp:         result.NsPerOp,
+				BytesPerOp:      result.BytesPerOp,
+				AllocsPerOp:     result.AllocsPerOp,
+				Speedup:         base.NsPerOp / result.NsPerOp,
+			}
+			comparison.Cases = append(comparison.Cases, benchCase)
+			logSum += math.Log(benchCase.Speedup)
+
+			if pair.MaxBytesPerOp != nil && result.BytesPerOp > *pair.MaxBytesPerOp {
+				comparison.Failures = append(comparison.Failures,
+					fmt.Sprintf("%s: %d B/op, at most %d allowed", result.Name, result.BytesPerOp, *pair.MaxBytesPerOp))
+			}
+			if pair.MaxAllocsPerOp != nil && result.AllocsPerOp > *pair.MaxAllocsPerOp {
+				comparison.Failures = append(comparison.Failures,
+					fmt.Sprintf("%s: %d allocs/op, at most %d allowed", result.Name, result.AllocsPerOp, *pair.MaxAllocsPerOp))
+			}
+		}
+
+		if len(comparison.Cases) == 0 {
+			comparison.Failures = append(comparison.Failures,
+				fmt.Sprintf("%s has no results to compare with %s", pair.Optimized, pair.Baseline))
+		} else {
+			comparison.Speedup = math.Exp(logSum / float64(len(comparison.Cases)))
+			if pair.MinSpeedup > 0 && comparison.Speedup < pair.MinSpeedup {
+				comparison.Failures = append(comparison.Failures,
+					fmt.Sprintf("%s is %.2fx as fast as %s, at least %.2fx required", pair.Optimized, comparison.Speedup, pair.Baseline, pair.MinSpeedup))
+			}
+		}
+
+		if !pairHasThresholds(pair) {
+			comparison.Failures = nil
+		}
+		comparison.Passed = len(comparison.Failures) == 0
+		if !comparison.Passed {
+			allPassed = false
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	return comparisons, allPassed
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"web-ui/internal/models"
+)
+
+func TestParseBenchmarkOutput(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []BenchmarkResult
+	}{
+		{
+			// Three runs of each benchmark aggregate to their median
+			fixture: "benchmark-count.txt",
+			want: []BenchmarkResult{
+				{Name: "BenchmarkSlowSort/10", Runs: 3, Iterations: 20000, NsPerOp: 146.7, BytesPerOp: 80, AllocsPerOp: 1},
+				{Name: "BenchmarkSlowSort/100", Runs: 3, Iterations: 20000, NsPerOp: 5917, BytesPerOp: 896, AllocsPerOp: 1},
+				{Name: "BenchmarkFastSort/10", Runs: 3, Iterations: 20000, NsPerOp: 125.3, BytesPerOp: 80, AllocsPerOp: 1},
+				{Name: "BenchmarkFastSort/100", Runs: 3, Iterations: 20000, NsPerOp: 1598, BytesPerOp: 896, AllocsPerOp: 1},
+			},
+		},
+		{
+			// The failed benchmark has no result line
+			fixture: "benchmark-failed.txt",
+			want: []BenchmarkResult{
+				{Name: "BenchmarkSlowSort/10", Runs: 1, Iterations: 20000, NsPerOp: 132.4, BytesPerOp: 80, AllocsPerOp: 1},
+				{Name: "BenchmarkSlowSort/100", Runs: 1, Iterations: 20000, NsPerOp: 5710, BytesPerOp: 896, AllocsPerOp: 1},
+				{Name: "BenchmarkFastSort/10", Runs: 1, Iterations: 20000, NsPerOp: 170.7, BytesPerOp: 80, AllocsPerOp: 1},
+				{Name: "BenchmarkFastSort/100", Runs: 1, Iterations: 20000, NsPerOp: 1853, BytesPerOp: 896, AllocsPerOp: 1},
+			},
+		},
+		{
+			// Without -benchmem
+			fixture: "benchmark-nomem.txt",
+			want: []BenchmarkResult{
+				{Name: "BenchmarkFastSort/10", Runs: 1, Iterations: 20000, NsPerOp: 207.8},
+				{Name: "BenchmarkFastSort/100", Runs: 1, Iterations: 20000, NsPerOp: 2076},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got := parseBenchmarkOutput(readFixture(t, tt.fixture))
+			if len(got) != len(tt.want) {
+				t.Fatalf("parsed %d benchmarks, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("benchmark %d = %+v\nwant %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	if got := parseBenchmarkOutput([]byte("BenchmarkTruncated/10  \t  20000\t  12")); len(got) != 0 {
+		t.Errorf("parsed a line without ns/op: %+v", got)
+	}
+}
+
+func TestCompareBenchmarks(t *testing.T) {
+	results := parseBenchmarkOutput(readFixture(t, "benchmark-count.txt"))
+	baseline := benchmarksByName(results)
+	limit := func(n int64) *int64 { return &n }
+	// Geometric mean of 146.7/125.3 and 5917/1598
+	speedup := math.Sqrt(146.7 / 125.3 * 5917 / 1598)
+
+	tests := []struct {
+		name    string
+		pair    models.BenchmarkPair
+		passed  bool
+		failure string // In the failures, if the pair did not pass
+	}{
+		{
+			name:   "informational",
+			pair:   models.BenchmarkPair{},
+			passed: true,
+		},
+		{
+			name:   "fast enough",
+			pair:   models.BenchmarkPair{MinSpeedup: 2},
+			passed: true,
+		},
+		{
+			name:    "too slow",
+			pair:    models.BenchmarkPair{MinSpeedup: 3},
+			failure: "BenchmarkFastSort is 2.08x as fast as BenchmarkSlowSort, at least 3.00x required",
+		},
+		{
+			name:    "too many bytes",
+			pair:    models.BenchmarkPair{MaxBytesPerOp: limit(100)},
+			failure: "BenchmarkFastSort/100: 896 B/op, at most 100 allowed",
+		},
+		{
+			name:   "few enough allocations",
+			pair:   models.BenchmarkPair{MaxAllocsPerOp: limit(1)},
+			passed: true,
+		},
+		{
+			name:    "too many allocations",
+			pair:    models.BenchmarkPair{MaxAllocsPerOp: limit(0)},
+			failure: "BenchmarkFastSort/10: 1 allocs/op, at most 0 allowed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pair := tt.pair
+			pair.Baseline, pair.Optimized = "BenchmarkSlowSort", "BenchmarkFastSort"
+			comparisons, passed := compareBenchmarks([]models.BenchmarkPair{pair}, baseline, results)
+			comparison := comparisons[0]
+			if passed != tt.passed || comparison.Passed != tt.passed {
+				t.Errorf("passed = %v, want %v (failures %q)", passed, tt.passed, comparison.Failures)
+			}
+			if tt.failure != "" && !strings.Contains(strings.Join(comparison.Failures, "\n"), tt.failure) {
+				t.Errorf("failures = %q, want %q", comparison.Failures, tt.failure)
+			}
+			if math.Abs(comparison.Speedup-speedup) > 1e-9 {
+				t.Errorf("speedup = %v, want %v", comparison.Speedup, speedup)
+			}
+			if len(comparison.Cases) != 2 || comparison.Cases[0].Name != "10" || comparison.Cases[1].Name != "100" {
+				t.Errorf("cases = %+v, want 10 and 100", comparison.Cases)
+			}
+		})
+	}
+}
+
+func TestCompareBenchmarksMissing(t *testing.T) {
+	results := parseBenchmarkOutput(readFixture(t, "benchmark-nomem.txt"))
+	pairs := []models.BenchmarkPair{
+		{Baseline: "BenchmarkSlowSort", Optimized: "BenchmarkFastSort"},
+		{Baseline: "BenchmarkSlowSort", Optimized: "BenchmarkFastSort", MinSpeedup: 1},
+	}
+	comparisons, passed := compareBenchmarks(pairs, map[string]BenchmarkResult{}, results)
+	if passed || !comparisons[0].Passed || comparisons[1].Passed {
+		t.Errorf("without a baseline only the pair with thresholds fails, got %+v", comparisons)
+	}
+	if want := "BenchmarkFastSort has no results to compare with BenchmarkSlowSort"; len(comparisons[1].Failures) != 1 || comparisons[1].Failures[0] != want {
+		t.Errorf("failures = %q, want %q", comparisons[1].Failures, want)
+	}
+}
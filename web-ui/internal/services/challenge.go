@@ -4,38 +4,57 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 
 	"web-ui/internal/models"
 )
 
-// ChallengeService handles challenge-related operations
+// ChallengeService handles challenge-related operations. The challenges are
+// a snapshot that LoadChallenges replaces as a whole, so a map returned by
+// GetChallenges never changes.
 type ChallengeService struct {
+	repoRoot string
+
+	mu         sync.RWMutex
 	challenges models.ChallengeMap
 }
 
-// NewChallengeService creates a new challenge service
-func NewChallengeService() *ChallengeService {
+// NewChallengeService creates a new challenge service for the challenge-N
+// directories in repoRoot
+func NewChallengeService(repoRoot string) *ChallengeService {
 	return &ChallengeService{
+		repoRoot:   repoRoot,
 		challenges: make(models.ChallengeMap),
 	}
 }
 
+// RepoRoot returns the repository root holding the challenges
+func (cs *ChallengeService) RepoRoot() string {
+	return cs.repoRoot
+}
+
 // LoadChallenges loads all challenges from the filesystem
 func (cs *ChallengeService) LoadChallenges() error {
 	// Find challenge directories (challenge-1, challenge-2, etc.)
-	challengeDirs, err := filepath.Glob("../challenge-*")
+	challengeDirs, err := filepath.Glob(filepath.Join(cs.repoRoot, "challenge-*"))
 	if err != nil {
 		return fmt.Errorf("failed to find challenge directories: %v", err)
 	}
 
+	cs.mu.RLock()
+	previous := cs.challenges
+	cs.mu.RUnlock()
+
+	challenges := make(models.ChallengeMap)
 	for _, dir := range challengeDirs {
 		// Extract challenge number
 		re := regexp.MustCompile(`challenge-(\d+)`)
-		match := re.FindStringSubmatch(dir)
+		match := re.FindStringSubmatch(filepath.Base(dir))
 		if len(match) < 2 {
 			continue
 		}
@@ -48,13 +67,20 @@ func (cs *ChallengeService) LoadChallenges() error {
 		challenge, err := cs.loadSingleChallenge(id, dir)
 		if err != nil {
 			log.Printf("Warning: Could not load challenge %d: %v", id, err)
+			// A challenge caught in the middle of an edit keeps its last version
+			if old, ok := previous[id]; ok {
+				challenges[id] = old
+			}
 			continue
 		}
 
-		cs.challenges[id] = challenge
+		challenges[id] = challenge
 	}
 
-	log.Printf("Loaded %d challenges", len(cs.challenges))
+	cs.mu.Lock()
+	cs.challenges = challenges
+	cs.mu.Unlock()
+	log.Printf("Loaded %d challenges", len(challenges))
 	return nil
 }
 
@@ -67,11 +93,17 @@ func (cs *ChallengeService) loadSingleChallenge(id int, dir string) (*models.Cha
 		return nil, fmt.Errorf("could not read README: %v", err)
 	}
 
-	// Extract title from README (first heading)
-	title := cs.extractTitle(string(readmeContent), id)
-
-	// Determine difficulty level
-	difficulty := cs.determineDifficulty(id)
+	// The title and difficulty of metadata.json win over the README heading
+	// and the built-in difficulties
+	metadata := cs.loadMetadata(dir)
+	title := metadata.Title
+	if title == "" {
+		title = cs.extractTitle(string(readmeContent), id)
+	}
+	difficulty := metadata.Difficulty
+	if difficulty == "" {
+		difficulty = cs.determineDifficulty(id)
+	}
 
 	// Read solution template
 	templatePath := filepath.Join(dir, "solution-template.go")
@@ -111,14 +143,53 @@ func (cs *ChallengeService) loadSingleChallenge(id int, dir string) (*models.Cha
 		TestFile:          string(testContent),
 		LearningMaterials: string(learningContent),
 		Hints:             string(hintsContent),
+
+		EstimatedTime:      metadata.EstimatedTime,
+		Tags:               metadata.Tags,
+		Prerequisites:      metadata.Prerequisites,
+		LearningObjectives: metadata.LearningObjectives,
+
+		// Execution limits and benchmark thresholds are optional
+		Execution: metadata.Execution,
+		Benchmark: metadata.Benchmark,
+		Dir:       dir,
 	}
 
 	return challenge, nil
 }
 
-// extractTitle extracts the title from README content
+// loadMetadata reads a challenge's optional metadata.json, reporting the
+// fields that are missing or invalid. Without a usable file the metadata is
+// empty and the challenge falls back to its defaults.
+func (cs *ChallengeService) loadMetadata(dir string) *models.ChallengeMetadata {
+	name := filepath.Join(filepath.Base(dir), MetadataFileName)
+	metadataContent, err := ioutil.ReadFile(filepath.Join(dir, MetadataFileName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: Could not read %s: %v", name, err)
+		}
+		return &models.ChallengeMetadata{}
+	}
+
+	metadata, problems, err := ParseChallengeMetadata(metadataContent)
+	if err != nil {
+		log.Printf("Warning: Could not parse %s: %v", name, err)
+		return &models.ChallengeMetadata{}
+	}
+	for _, problem := range problems {
+		log.Printf("Warning: %s: %s", name, problem)
+	}
+
+	return metadata
+}
+
+// titleRe matches a top-level Markdown heading, e.g. "# Challenge 1: Sum of
+// Two Numbers", but not section headings like "## Overview"
+var titleRe = regexp.MustCompile(`(?m)^ {0,3}#[ \t]+(.+?)[ \t#]*$`)
+
+// extractTitle extracts the title from the first top-level heading of README
+// content
 func (cs *ChallengeService) extractTitle(readmeContent string, id int) string {
-	titleRe := regexp.MustCompile(`#\s+(.+)`)
 	titleMatch := titleRe.FindStringSubmatch(readmeContent)
 
 	if len(titleMatch) >= 2 {
@@ -131,7 +202,8 @@ func (cs *ChallengeService) extractTitle(readmeContent string, id int) string {
 	return fmt.Sprintf("Challenge %d", id)
 }
 
-// determineDifficulty determines the difficulty level based on challenge ID
+// determineDifficulty determines the difficulty level based on challenge ID,
+// for challenges whose metadata.json does not set it
 func (cs *ChallengeService) determineDifficulty(id int) string {
 	switch {
 	case id <= 3 || id == 6 || id == 18 || id == 21 || id == 22:
@@ -181,11 +253,15 @@ func (cs *ChallengeService) filterWebUIDescription(content string) string {
 
 // GetChallenges returns all challenges
 func (cs *ChallengeService) GetChallenges() models.ChallengeMap {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
 	return cs.challenges
 }
 
 // GetChallenge returns a specific challenge by ID
 func (cs *ChallengeService) GetChallenge(id int) (*models.Challenge, bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
 	challenge, exists := cs.challenges[id]
 	return challenge, exists
 }
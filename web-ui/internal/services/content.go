@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileStamp is what a content file is compared by between scans
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// ContentChange is a content file that changed between two scans
+type ContentChange struct {
+	Path   string `json:"path"`   // Relative to the repository root, e.g. "challenge-1/README.md"
+	Change string `json:"change"` // "added", "modified" or "removed"
+}
+
+// ReloadResult describes a reload of the challenge content
+type ReloadResult struct {
+	Changes    []ContentChange `json:"changes"` // Since the previous scan
+	Challenges int             `json:"challenges"`
+	Packages   int             `json:"packages"`
+	DurationMs int64           `json:"durationMs"`
+}
+
+// ContentWatcher polls the challenge and package directories and reloads the
+// challenge, scoreboard and package services when their files change. Each
+// service swaps in its new snapshot at once, so requests see either the old
+// or the new content. The submissions directories are not watched; none of
+// the services load them.
+type ContentWatcher struct {
+	challengeService  *ChallengeService
+	scoreboardService *ScoreboardService
+	packageService    *PackageService
+
+	mu     sync.Mutex // Serializes scans and reloads
+	stamps map[string]fileStamp
+}
+
+// NewContentWatcher creates a content watcher for the services
+func NewContentWatcher(challengeService *ChallengeService, scoreboardService *ScoreboardService, packageService *PackageService) *ContentWatcher {
+	return &ContentWatcher{
+		challengeService:  challengeService,
+		scoreboardService: scoreboardService,
+		packageService:    packageService,
+	}
+}
+
+// Watch scans the content every interval until ctx is canceled, reloading
+// what changed. The first scan is the baseline the later ones compare to.
+func (cw *ContentWatcher) Watch(ctx context.Context, interval time.Duration) {
+	cw.mu.Lock()
+	if cw.stamps == nil {
+		cw.stamps = cw.scan()
+	}
+	cw.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cw.check()
+		}
+	}
+}
+
+// check reloads the services whose content changed since the last scan
+func (cw *ContentWatcher) check() {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	start := time.Now()
+	stamps := cw.scan()
+	changes := diffStamps(cw.stamps, stamps)
+	cw.stamps = stamps
+	if len(changes) == 0 {
+		return
+	}
+
+	var challenges, packages bool
+	for _, change := range changes {
+		if strings.HasPrefix(change.Path, cw.packagesPrefix()) {
+			packages = true
+		} else {
+			challenges = true
+		}
+	}
+	result := cw.reload(changes, challenges, packages, start)
+	log.Printf("Content changed: %s; reloaded %d challenges and %d packages in %dms",
+		describeChanges(changes), result.Challenges, result.Packages, result.DurationMs)
+}
+
+// Reload rereads all challenges, scoreboards and packages, whether their
+// files changed or not, and reports the files that changed since the last scan
+func (cw *ContentWatcher) Reload() ReloadResult {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	start := time.Now()
+	stamps := cw.scan()
+	changes := diffStamps(cw.stamps, stamps)
+	cw.stamps = stamps
+	result := cw.reload(changes, true, true, start)
+	if len(changes) > 0 {
+		log.Printf("Reloaded content on request: %s", describeChanges(changes))
+	} else {
+		log.Printf("Reloaded content on request, no files changed")
+	}
+	return result
+}
+
+// reload reloads the classic challenges with their scoreboards, the
+// packages, or both
+func (cw *ContentWatcher) reload(changes []ContentChange, challenges, packages bool, start time.Time) ReloadResult {
+	if challenges {
+		if err := cw.challengeService.LoadChallenges(); err != nil {
+			log.Printf("Failed to reload challenges: %v", err)
+		} else if err := cw.scoreboardService.LoadScoreboards(cw.challengeService.GetChallenges()); err != nil {
+			log.Printf("Failed to reload scoreboards: %v", err)
+		}
+	}
+	if packages {
+		if err := cw.packageService.LoadPackages(); err != nil {
+			log.Printf("Failed to reload packages: %v", err)
+		}
+	}
+
+	if changes == nil {
+		changes = []ContentChange{}
+	}
+	return ReloadResult{
+		Changes:    changes,
+		Challenges: len(cw.challengeService.GetChallenges()),
+		Packages:   len(cw.packageService.GetPackages()),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+}
+
+// packagesPrefix returns the slash-separated path of the packages directory
+// relative to the repository root, with a trailing slash, e.g. "packages/"
+func (cw *ContentWatcher) packagesPrefix() string {
+	rel, err := filepath.Rel(cw.challengeService.RepoRoot(), cw.packageService.packagesPath)
+	if err != nil {
+		return filepath.ToSlash(cw.packageService.packagesPath) + "/"
+	}
+	return filepath.ToSlash(rel) + "/"
+}
+
+// scan stamps every file under the challenge and package directories, keyed
+// by its slash-separated path relative to the repository root
+func (cw *ContentWatcher) scan() map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	root := cw.challengeService.RepoRoot()
+	dirs, _ := filepath.Glob(filepath.Join(root, "challenge-*"))
+	dirs = append(dirs, cw.packageService.packagesPath)
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				// Files can disappear while they are walked
+				return nil
+			}
+			if entry.IsDir() {
+				if entry.Name() == submissionsDirName {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil || !info.Mode().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				rel = path
+			}
+			stamps[filepath.ToSlash(rel)] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+			return nil
+		})
+	}
+	return stamps
+}
+
+// diffStamps lists the files added, modified and removed between two scans,
+// by path. There are no changes without a previous scan.
+func diffStamps(previous, current map[string]fileStamp) []ContentChange {
+	if previous == nil {
+		return nil
+	}
+	var changes []ContentChange
+	for path, stamp := range current {
+		old, ok := previous[path]
+		switch {
+		case !ok:
+			changes = append(changes, ContentChange{Path: path, Change: "added"})
+		case old.size != stamp.size || !old.modTime.Equal(stamp.modTime):
+			changes = append(changes, ContentChange{Path: path, Change: "modified"})
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			changes = append(changes, ContentChange{Path: path, Change: "removed"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// describeChanges sums up changes for the log, naming the first few files
+func describeChanges(changes []ContentChange) string {
+	const named = 5
+	var parts []string
+	for _, change := range changes[:min(named, len(changes))] {
+		parts = append(parts, change.Path+" "+change.Change)
+	}
+	if len(changes) > named {
+		parts = append(parts, fmt.Sprintf("and %d more", len(changes)-named))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffStamps(t *testing.T) {
+	at := func(seconds int64) time.Time { return time.Unix(1700000000+seconds, 0) }
+	previous := map[string]fileStamp{
+		"challenge-1/README.md":                       {size: 100, modTime: at(0)},
+		"challenge-1/solution-template.go":            {size: 50, modTime: at(0)},
+		"packages/gin/package.json":                   {size: 300, modTime: at(0)},
+		"packages/gin/challenge-1-basic-routing/a.go": {size: 10, modTime: at(0)},
+	}
+	// with returns previous with some stamps replaced, or removed if nil
+	with := func(stamps map[string]*fileStamp) map[string]fileStamp {
+		current := make(map[string]fileStamp)
+		for path, stamp := range previous {
+			current[path] = stamp
+		}
+		for path, stamp := range stamps {
+			if stamp == nil {
+				delete(current, path)
+			} else {
+				current[path] = *stamp
+			}
+		}
+		return current
+	}
+
+	tests := []struct {
+		name     string
+		previous map[string]fileStamp
+		current  map[string]fileStamp
+		want     []ContentChange
+	}{
+		{
+			name:     "first scan",
+			previous: nil,
+			current:  previous,
+		},
+		{
+			name:     "unchanged",
+			previous: previous,
+			current:  with(nil),
+		},
+		{
+			name:     "new file",
+			previous: previous,
+			current:  with(map[string]*fileStamp{"challenge-2/README.md": {size: 10, modTime: at(5)}}),
+			want:     []ContentChange{{Path: "challenge-2/README.md", Change: "added"}},
+		},
+		{
+			name:     "removed file",
+			previous: previous,
+			current:  with(map[string]*fileStamp{"packages/gin/package.json": nil}),
+			want:     []ContentChange{{Path: "packages/gin/package.json", Change: "removed"}},
+		},
+		{
+			name:     "newer file",
+			previous: previous,
+			current:  with(map[string]*fileStamp{"challenge-1/README.md": {size: 100, modTime: at(1)}}),
+			want:     []ContentChange{{Path: "challenge-1/README.md", Change: "modified"}},
+		},
+		{
+			// e.g. rewritten within the file system's time resolution
+			name:     "same modification time, new size",
+			previous: previous,
+			current:  with(map[string]*fileStamp{"challenge-1/README.md": {size: 101, modTime: at(0)}}),
+			want:     []ContentChange{{Path: "challenge-1/README.md", Change: "modified"}},
+		},
+		{
+			name:     "same time in another location",
+			previous: previous,
+			current:  with(map[string]*fileStamp{"challenge-1/README.md": {size: 100, modTime: at(0).UTC()}}),
+		},
+		{
+			name:     "everything removed",
+			previous: previous,
+			current:  map[string]fileStamp{},
+			want: []ContentChange{
+				{Path: "challenge-1/README.md", Change: "removed"},
+				{Path: "challenge-1/solution-template.go", Change: "removed"},
+				{Path: "packages/gin/challenge-1-basic-routing/a.go", Change: "removed"},
+				{Path: "packages/gin/package.json", Change: "removed"},
+			},
+		},
+		{
+			// Changes are sorted by path
+			name:     "several changes",
+			previous: previous,
+			current: with(map[string]*fileStamp{
+				"packages/gin/package.json":                   {size: 301, modTime: at(9)},
+				"challenge-1/solution-template.go":            nil,
+				"challenge-1/metadata.json":                   {size: 20, modTime: at(9)},
+				"packages/gin/challenge-1-basic-routing/a.go": {size: 10, modTime: at(-1)},
+			}),
+			want: []ContentChange{
+				{Path: "challenge-1/metadata.json", Change: "added"},
+				{Path: "challenge-1/solution-template.go", Change: "removed"},
+				{Path: "packages/gin/challenge-1-basic-routing/a.go", Change: "modified"},
+				{Path: "packages/gin/package.json", Change: "modified"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffStamps(tt.previous, tt.current); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changes = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeChanges(t *testing.T) {
+	var changes []ContentChange
+	for _, path := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		changes = append(changes, ContentChange{Path: path, Change: "added"})
+	}
+	if got, want := describeChanges(changes[:1]), "a added"; got != want {
+		t.Errorf("describeChanges = %q, want %q", got, want)
+	}
+	if got, want := describeChanges(changes), "a added, b added, c added, d added, e added, and 2 more"; got != want {
+		t.Errorf("describeChanges = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The coverage profile is written to the one writable directory of a run
+const (
+	coverageDirName     = "coverage"
+	coverageProfileName = "cover.out"
+)
+
+// Coverage statuses of a line
+const (
+	LineCovered   = "covered"   // Every block on the line ran
+	LineUncovered = "uncovered" // No block on the line ran
+	LinePartial   = "partial"   // Some blocks on the line ran, e.g. `if err != nil { return err }`
+)
+
+// CoverageBlock is a basic block of solution-template.go from the profile
+type CoverageBlock struct {
+	StartLine  int `json:"startLine"`
+	StartCol   int `json:"startCol"`
+	EndLine    int `json:"endLine"`
+	EndCol     int `json:"endCol"`
+	Statements int `json:"statements"`
+	Count      int `json:"count"` // Times the block ran
+}
+
+// CoverageLine is the coverage of one line of solution-template.go
+type CoverageLine struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"` // covered, uncovered or partial
+	Count  int    `json:"count"`  // Highest run count of a block on the line
+}
+
+// CoverageReport holds the statement coverage of solution-template.go
+type CoverageReport struct {
+	Percent    float64         `json:"percent"`
+	Statements int             `json:"statements"`
+	Covered    int             `json:"covered"` // Statements that ran at least once
+	Lines      []CoverageLine  `json:"lines"`
+	Blocks     []CoverageBlock `json:"blocks"`
+}
+
+// parseCoverProfile reads a -coverprofile file and returns the coverage of
+// the blocks in the file with the given base name. Profile lines look like
+// "challenge7/solution-template.go:12.34,15.2 3 1".
+func parseCoverProfile(profile []byte, fileName string) *CoverageReport {
+	blocks := make(map[[4]int]*CoverageBlock)
+	for _, line := range strings.Split(string(profile), "\n") {
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		colon := strings.LastIndex(line, ":")
+		if colon < 0 || path.Base(line[:colon]) != fileName {
+			continue
+		}
+		fields := strings.Fields(line[colon+1:])
+		if len(fields) != 3 {
+			continue
+		}
+
+		var pos [4]int
+		start, end, ok := strings.Cut(fields[0], ",")
+		if !ok || !parseLineCol(start, &pos[0], &pos[1]) || !parseLineCol(end, &pos[2], &pos[3]) {
+			continue
+		}
+		statements, err1 := strconv.Atoi(fields[1])
+		count, err2 := strconv.Atoi(fields[2])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+
+		// Blocks can repeat, e.g. in merged profiles; their counts add up
+		if block, ok := blocks[pos]; ok {
+			block.Count += count
+			continue
+		}
+		blocks[pos] = &CoverageBlock{
+			StartLine:  pos[0],
+			StartCol:   pos[1],
+			EndLine:    pos[2],
+			EndCol:     pos[3],
+			Statements: statements,
+			Count:      count,
+		}
+	}
+
+	report := &CoverageReport{Lines: []CoverageLine{}, Blocks: []CoverageBlock{}}
+	lines := make(map[int]*CoverageLine)
+	for _, block := range blocks {
+		report.Blocks = append(report.Blocks, *block)
+		report.Statements += block.Statements
+		if block.Count > 0 {
+			report.Covered += block.Statements
+		}
+
+		status := LineUncovered
+		if block.Count > 0 {
+			status = LineCovered
+		}
+		for n := block.StartLine; n <= block.EndLine; n++ {
+			line, ok := lines[n]
+			if !ok {
+				lines[n] = &CoverageLine{Line: n, Status: status, Count: block.Count}
+				continue
+			}
+			if line.Status != status {
+				line.Status = LinePartial
+			}
+			if block.Count > line.Count {
+				line.Count = block.Count
+			}
+		}
+	}
+	for _, line := range lines {
+		report.Lines = append(report.Lines, *line)
+	}
+
+	sort.Slice(report.Blocks, func(i, j int) bool {
+		a, b := report.Blocks[i], report.Blocks[j]
+		if a.StartLine != b.StartLine {
+			return a.StartLine < b.StartLine
+		}
+		return a.StartCol < b.StartCol
+	})
+	sort.Slice(report.Lines, func(i, j int) bool { return report.Lines[i].Line < report.Lines[j].Line })
+	if report.Statements > 0 {
+		report.Percent = float64(report.Covered) * 100 / float64(report.Statements)
+	}
+	return report
+}
+
+// parseLineCol parses "line.col"
+func parseLineCol(s string, line, col *int) bool {
+	l, c, ok := strings.Cut(s, ".")
+	if !ok {
+		return false
+	}
+	var err error
+	if *line, err = strconv.Atoi(l); err != nil {
+		return false
+	}
+	*col, err = strconv.Atoi(c)
+	return err == nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// coverageLines writes lines as "line:status:count"
+func coverageLines(lines []CoverageLine) string {
+	var parts []string
+	for _, line := range lines {
+		parts = append(parts, fmt.Sprintf("%d:%s:%d", line.Line, line.Status, line.Count))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseCoverProfile(t *testing.T) {
+	tests := []struct {
+		fixture             string
+		fileName            string
+		statements, covered int
+		blocks              int
+		lines               string
+	}{
+		{
+			// Line 6 holds a covered condition and an uncovered return
+			fixture:    "coverage-count.out",
+			fileName:   solutionFileName,
+			statements: 7, covered: 4, blocks: 7,
+			lines: "6:partial:3 7:covered:3 11:covered:1 12:covered:1 13:covered:1 14:uncovered:0 18:uncovered:0 19:uncovered:0",
+		},
+		{
+			fixture:    "coverage-set.out",
+			fileName:   solutionFileName,
+			statements: 7, covered: 4, blocks: 7,
+			lines: "6:partial:1 7:covered:1 11:covered:1 12:covered:1 13:covered:1 14:uncovered:0 18:uncovered:0 19:uncovered:0",
+		},
+		{
+			// Two runs appended; the second one covers the return on line 6
+			fixture:    "coverage-merged.out",
+			fileName:   solutionFileName,
+			statements: 7, covered: 5, blocks: 7,
+			lines: "6:covered:4 7:covered:3 11:covered:1 12:covered:1 13:covered:1 14:uncovered:0 18:uncovered:0 19:uncovered:0",
+		},
+		{
+			fixture:    "coverage-count.out",
+			fileName:   "helper.go",
+			statements: 1, covered: 1, blocks: 1,
+			lines: "3:covered:1",
+		},
+		{
+			fixture:  "coverage-count.out",
+			fileName: "missing.go",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture+"/"+tt.fileName, func(t *testing.T) {
+			report := parseCoverProfile(readFixture(t, tt.fixture), tt.fileName)
+			if report.Statements != tt.statements || report.Covered != tt.covered || len(report.Blocks) != tt.blocks {
+				t.Errorf("%d of %d statements covered in %d blocks, want %d of %d in %d",
+					report.Covered, report.Statements, len(report.Blocks), tt.covered, tt.statements, tt.blocks)
+			}
+			if got := coverageLines(report.Lines); got != tt.lines {
+				t.Errorf("lines = %s\nwant %s", got, tt.lines)
+			}
+			want := 0.0
+			if tt.statements > 0 {
+				want = float64(tt.covered) * 100 / float64(tt.statements)
+			}
+			if report.Percent != want {
+				t.Errorf("percent = %v, want %v", report.Percent, want)
+			}
+			for i := 1; i < len(report.Blocks); i++ {
+				if a, b := report.Blocks[i-1], report.Blocks[i]; a.StartLine > b.StartLine || a.StartLine == b.StartLine && a.StartCol > b.StartCol {
+					t.Errorf("blocks out of order: %+v before %+v", a, b)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCoverProfileMalformed(t *testing.T) {
+	profile := strings.Join([]string{
+		"mode: count",
+		"solution-template.go:6.2,6.12 1 3",
+		"solution-template.go:6.2-6.12 1 3",
+		"solution-template.go:7.2,7.19 one 3",
+		"solution-template.go:8.x,8.19 1 3",
+		"solution-template.go:9.2,9.19 1",
+		"no colon here",
+	}, "\n")
+	report := parseCoverProfile([]byte(profile), solutionFileName)
+	if len(report.Blocks) != 1 || report.Blocks[0].StartLine != 6 {
+		t.Errorf("blocks = %+v, want only the well-formed one", report.Blocks)
+	}
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severities of a diagnostic
+const (
+	SeverityError   = "error"   // The solution cannot be tested
+	SeverityWarning = "warning" // Suspicious code that still builds
+)
+
+// Diagnostic is a compiler error or vet finding at a position in the source
+type Diagnostic struct {
+	File      string `json:"file"` // Base name for the solution and test files, full path otherwise; empty if unknown
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	EndLine   int    `json:"endLine,omitempty"` // End of the reported range, when vet gives one
+	EndColumn int    `json:"endColumn,omitempty"`
+	Severity  string `json:"severity"`
+	Message   string `json:"message"`
+	Source    string `json:"source"`             // "compiler" or "vet"
+	Analyzer  string `json:"analyzer,omitempty"` // Vet analyzer that reported it, e.g. "printf"
+}
+
+// testVetAnalyzers are the analyzers go test runs before testing. Their
+// findings fail the build like compiler errors; other vet findings are warnings.
+var testVetAnalyzers = map[string]bool{
+	"atomic":        true,
+	"bools":         true,
+	"buildtag":      true,
+	"directive":     true,
+	"errorsas":      true,
+	"ifaceassert":   true,
+	"nilfunc":       true,
+	"printf":        true,
+	"slog":          true,
+	"stdversion":    true,
+	"stringintconv": true,
+	"tests":         true,
+}
+
+var diagnosticPosRe = regexp.MustCompile(`^(?:vet: )?(\S+?\.go):(\d+)(?::(\d+))?: (.*)$`)
+
+// parseBuildDiagnostics extracts the errors from go build output, e.g.
+// "/tmp/challenge-exec1/solution-template.go:3:85: undefined: x". Indented
+// lines continue the previous error; errors without a position, e.g. from the
+// linker, are kept with an empty file.
+func parseBuildDiagnostics(output string) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case line == "" || strings.HasPrefix(line, "# "):
+			continue
+		case strings.HasPrefix(line, "\t") && len(diagnostics) > 0:
+			last := &diagnostics[len(diagnostics)-1]
+			last.Message += "\n" + strings.TrimSpace(line)
+			continue
+		case strings.HasPrefix(line, "FAIL") || strings.HasPrefix(line, "go: downloading"):
+			continue
+		}
+
+		diagnostic := Diagnostic{Severity: SeverityError, Source: "compiler", Message: line}
+		if match := diagnosticPosRe.FindStringSubmatch(line); match != nil {
+			diagnostic.File = diagnosticFile(match[1])
+			diagnostic.Line, _ = strconv.Atoi(match[2])
+			diagnostic.Column, _ = strconv.Atoi(match[3])
+			diagnostic.Message = match[4]
+		}
+		if diagnostic.Message == "too many errors" {
+			// The compiler gives up; newer ones put the note at the last error
+			continue
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics
+}
+
+// vetFinding is one diagnostic in `go vet -json` output
+type vetFinding struct {
+	Posn    string `json:"posn"`
+	End     string `json:"end"`
+	Message string `json:"message"`
+}
+
+// parseVetDiagnostics reads `go vet -json` output: "# package" headers and
+// one JSON object per package mapping analyzer names to their findings.
+// Findings of the analyzers go test runs are errors, the rest warnings.
+func parseVetDiagnostics(output []byte) ([]Diagnostic, error) {
+	var body bytes.Buffer
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		if !bytes.HasPrefix(line, []byte("#")) {
+			body.Write(line)
+			body.WriteByte('\n')
+		}
+	}
+
+	diagnostics := []Diagnostic{}
+	seen := make(map[string]bool)
+	decoder := json.NewDecoder(&body)
+	for decoder.More() {
+		var packages map[string]map[string]json.RawMessage
+		if err := decoder.Decode(&packages); err != nil {
+			return diagnostics, err
+		}
+		for _, analyzers := range packages {
+			for analyzer, raw := range analyzers {
+				var findings []vetFinding
+				if json.Unmarshal(raw, &findings) != nil {
+					// {"error": ...} when the package did not type-check
+					continue
+				}
+				for _, finding := range findings {
+					// The package and its test variant report the same findings
+					key := finding.Posn + "|" + finding.Message
+					if seen[key] {
+						continue
+					}
+					seen[key] = true
+
+					diagnostic := Diagnostic{
+						Severity: SeverityWarning,
+						Message:  finding.Message,
+						Source:   "vet",
+						Analyzer: analyzer,
+					}
+					if testVetAnalyzers[analyzer] {
+						diagnostic.Severity = SeverityError
+					}
+					diagnostic.File, diagnostic.Line, diagnostic.Column = parsePosition(finding.Posn)
+					if file, line, column := parsePosition(finding.End); file == diagnostic.File && line > 0 {
+						diagnostic.EndLine, diagnostic.EndColumn = line, column
+					}
+					diagnostics = append(diagnostics, diagnostic)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return diagnostics, nil
+}
+
+// parsePosition splits "file.go:line:col" and maps the file name
+func parsePosition(posn string) (file string, line, column int) {
+	parts := strings.Split(posn, ":")
+	if len(parts) < 3 {
+		return diagnosticFile(posn), 0, 0
+	}
+	line, _ = strconv.Atoi(parts[len(parts)-2])
+	column, _ = strconv.Atoi(parts[len(parts)-1])
+	return diagnosticFile(strings.Join(parts[:len(parts)-2], ":")), line, column
+}
+
+// diagnosticFile maps the temporary and workspace paths of the solution and
+// test files back to their base names
+func diagnosticFile(path string) string {
+	base := filepath.Base(path)
+	if base == solutionFileName || base == solutionTestFileName {
+		return base
+	}
+	return path
+}
+
+// hasErrors reports whether any diagnostic is an error
+func hasErrors(diagnostics []Diagnostic) bool {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// formatDiagnostics renders diagnostics the way the go command prints them
+func formatDiagnostics(diagnostics []Diagnostic) string {
+	var out strings.Builder
+	for _, d := range diagnostics {
+		switch {
+		case d.File == "":
+			out.WriteString(d.Message)
+		case d.Column > 0:
+			fmt.Fprintf(&out, "%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+		default:
+			fmt.Fprintf(&out, "%s:%d: %s", d.File, d.Line, d.Message)
+		}
+		if d.Analyzer != "" {
+			fmt.Fprintf(&out, " (%s)", d.Analyzer)
+		}
+		out.WriteByte('\n')
+	}
+	return out.String()
+}
+
+// vet runs go vet on the package in dir with every analyzer. A vet run that
+// fails for other reasons is logged and yields no diagnostics; the test run
+// goes ahead without them.
+func (es *ExecutionService) vet(ctx context.Context, dir string, buildFlags []string) []Diagnostic {
+	args := append(append([]string{"vet", "-json"}, buildFlags...), ".")
+	result := es.runGo(ctx, dir, nil, args...)
+	if !result.Success() {
+		log.Printf("go vet failed: %s %s", result.Outcome, strings.TrimSpace(string(result.Output)))
+		return nil
+	}
+	diagnostics, err := parseVetDiagnostics(result.Output)
+	if err != nil {
+		log.Printf("Failed to parse go vet output: %v", err)
+	}
+	return diagnostics
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBuildDiagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string // Formatted diagnostics
+	}{
+		{
+			// A multi-line error continues on indented lines
+			name:   "errors",
+			output: string(readFixture(t, "diagnostics-build.txt")),
+			want: `solution-template.go:4:17: undefined: x
+solution-template.go:8:14: cannot use name (variable of type string) as int value in variable declaration
+solution-template.go:9:9: invalid operation: "hi " + n (mismatched types untyped string and int)
+solution-template.go:13:13: not enough arguments in call to Sum
+have (int)
+want (int, int)
+solution_test.go:6:18: invalid operation: Sum(1, 2) != "3" (mismatched types int and untyped string)
+`,
+		},
+		{
+			// The compiler stops after ten errors
+			name:   "too many errors",
+			output: string(readFixture(t, "diagnostics-too-many.txt")),
+			want: `solution-template.go:4:6: undefined: v1
+solution-template.go:5:6: undefined: v2
+solution-template.go:6:6: undefined: v3
+solution-template.go:7:6: undefined: v4
+solution-template.go:8:6: undefined: v5
+solution-template.go:9:6: undefined: v6
+solution-template.go:10:6: undefined: v7
+solution-template.go:11:6: undefined: v8
+solution-template.go:12:6: undefined: v9
+solution-template.go:13:6: undefined: v10
+`,
+		},
+		{
+			name:   "older compiler",
+			output: "# example.com/challenge5\n/tmp/challenge-exec1/solution-template.go:4:6: undefined: v1\ntoo many errors\n",
+			want:   "solution-template.go:4:6: undefined: v1\n",
+		},
+		{
+			name:   "no position",
+			output: "go: downloading example.com/dep v1.0.0\nlink: duplicated definition of symbol main.F\n",
+			want:   "link: duplicated definition of symbol main.F\n",
+		},
+		{
+			name: "no output",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics := parseBuildDiagnostics(tt.output)
+			if got := formatDiagnostics(diagnostics); got != tt.want {
+				t.Errorf("diagnostics =\n%s\nwant\n%s", got, tt.want)
+			}
+			for _, diagnostic := range diagnostics {
+				if diagnostic.Severity != SeverityError || diagnostic.Source != "compiler" {
+					t.Errorf("diagnostic %+v is not a compiler error", diagnostic)
+				}
+			}
+		})
+	}
+}
+
+func TestParseVetDiagnostics(t *testing.T) {
+	captured := string(readFixture(t, "diagnostics-vet.json"))
+	want := `solution-template.go:13:17: Describe passes lock by value: example.com/challenge4.Cache contains sync.Mutex (copylocks)
+solution-template.go:14:22: fmt.Sprintf format %d has arg "many" of wrong type string (printf)
+solution-template.go:19:2: unreachable code (unreachable)
+solution_test.go:7:19: (*testing.common).Errorf format %s reads arg #1, but call has 0 args (printf)
+`
+	tests := []struct {
+		name   string
+		output string
+	}{
+		{"one object", captured},
+		{
+			// Older go commands print a header and an object per package,
+			// the test variant repeating the package's findings
+			name:   "per package",
+			output: "# example.com/challenge4\n" + captured + "# [example.com/challenge4.test]\n" + captured,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics, err := parseVetDiagnostics([]byte(tt.output))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := formatDiagnostics(diagnostics); got != want {
+				t.Fatalf("diagnostics =\n%s\nwant\n%s", got, want)
+			}
+
+			// Only the analyzers go test runs fail the build
+			severities := []string{SeverityWarning, SeverityError, SeverityWarning, SeverityError}
+			for i, diagnostic := range diagnostics {
+				if diagnostic.Severity != severities[i] || diagnostic.Source != "vet" {
+					t.Errorf("diagnostic %d is a %s %s, want a vet %s", i, diagnostic.Source, diagnostic.Severity, severities[i])
+				}
+			}
+			if d := diagnostics[0]; d.EndLine != 13 || d.EndColumn != 22 {
+				t.Errorf("copylocks finding ends at %d:%d, want 13:22", d.EndLine, d.EndColumn)
+			}
+		})
+	}
+
+	if _, err := parseVetDiagnostics([]byte(captured[:len(captured)/2])); err == nil {
+		t.Errorf("no error for cut-off output")
+	}
+}
+
+func TestParsePosition(t *testing.T) {
+	tests := []struct {
+		posn         string
+		file         string
+		line, column int
+	}{
+		{"/tmp/challenge-exec1/solution-template.go:14:22", solutionFileName, 14, 22},
+		{"/tmp/challenge-exec1/solution_test.go:7:19", solutionTestFileName, 7, 19},
+		{`C:\work\helper.go:3:1`, `C:\work\helper.go`, 3, 1},
+		{"/usr/local/go/src/fmt/print.go:1:2", "/usr/local/go/src/fmt/print.go", 1, 2},
+		{"solution-template.go", solutionFileName, 0, 0},
+	}
+	for _, tt := range tests {
+		file, line, column := parsePosition(tt.posn)
+		if file != tt.file || line != tt.line || column != tt.column {
+			t.Errorf("parsePosition(%q) = %s, %d, %d, want %s, %d, %d", tt.posn, file, line, column, tt.file, tt.line, tt.column)
+		}
+	}
+	if strings.Contains(formatDiagnostics([]Diagnostic{{File: "a.go", Line: 3, Message: "m"}}), ":0:") {
+		t.Errorf("a diagnostic without a column was printed with one")
+	}
+}
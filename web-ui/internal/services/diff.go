@@ -0,0 +1,315 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of lines in a diff
+const (
+	DiffEqual   = "equal"
+	DiffDelete  = "delete"
+	DiffInsert  = "insert"
+	DiffChanged = "changed" // Side-by-side rows only: a deleted line next to an inserted one
+)
+
+// diffContext is the number of unchanged lines around each hunk of a unified diff
+const diffContext = 3
+
+// DiffLine is one line of an edit script; line numbers are 1-based, and 0
+// on the side the line is not in
+type DiffLine struct {
+	Kind    string `json:"kind"`
+	OldLine int    `json:"oldLine,omitempty"`
+	NewLine int    `json:"newLine,omitempty"`
+	Text    string `json:"text"`
+}
+
+// DiffSide is one side of a side-by-side row
+type DiffSide struct {
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// DiffRow is a row of a side-by-side diff. Left is nil for an inserted line
+// and Right for a deleted one.
+type DiffRow struct {
+	Kind  string    `json:"kind"`
+	Left  *DiffSide `json:"left"`
+	Right *DiffSide `json:"right"`
+}
+
+// diffLines returns the shortest edit script turning a into b, computed with
+// Myers' algorithm after stripping the common prefix and suffix. Past
+// maxDiffEdits the lines in between are replaced whole.
+func diffLines(a, b []string) []DiffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var script []DiffLine
+	for i := 0; i < prefix; i++ {
+		script = append(script, DiffLine{Kind: DiffEqual, OldLine: i + 1, NewLine: i + 1, Text: a[i]})
+	}
+	for _, line := range myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		if line.OldLine > 0 {
+			line.OldLine += prefix
+		}
+		if line.NewLine > 0 {
+			line.NewLine += prefix
+		}
+		script = append(script, line)
+	}
+	for i := 0; i < suffix; i++ {
+		oldIndex, newIndex := len(a)-suffix+i, len(b)-suffix+i
+		script = append(script, DiffLine{Kind: DiffEqual, OldLine: oldIndex + 1, NewLine: newIndex + 1, Text: a[oldIndex]})
+	}
+	return script
+}
+
+// maxDiffEdits bounds the edits myersDiff searches for. The frontiers it
+// keeps grow with the square of the edits, so code that differs more is
+// shown as deleted and inserted whole.
+const maxDiffEdits = 2000
+
+// myersDiff is the greedy O((N+M)D) algorithm: for every number of edits d
+// it records the furthest reaching path on each diagonal k = x - y, then
+// walks the recorded frontiers back from the end. Only the diagonals a
+// step can reach, -d-1 to d+1, are recorded for it.
+func myersDiff(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	// at returns the frontier on diagonal k before step d
+	at := func(d, k int) int {
+		return trace[d][k+d+1]
+	}
+
+	found := false
+search:
+	for d := 0; d <= n+m && d <= maxDiffEdits; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // Down: insert b[y]
+			} else {
+				x = v[offset+k-1] + 1 // Right: delete a[x]
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break search
+			}
+		}
+	}
+	if !found {
+		return replaceAll(a, b)
+	}
+
+	var reversed []DiffLine
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(d, k-1) < at(d, k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(d, prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, DiffLine{Kind: DiffEqual, OldLine: x, NewLine: y, Text: a[x-1]})
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			reversed = append(reversed, DiffLine{Kind: DiffInsert, NewLine: y, Text: b[y-1]})
+		} else {
+			reversed = append(reversed, DiffLine{Kind: DiffDelete, OldLine: x, Text: a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+
+	script := make([]DiffLine, len(reversed))
+	for i, line := range reversed {
+		script[len(reversed)-1-i] = line
+	}
+	return script
+}
+
+// replaceAll returns the edit script deleting every line of a and then
+// inserting every line of b
+func replaceAll(a, b []string) []DiffLine {
+	script := make([]DiffLine, 0, len(a)+len(b))
+	for i, line := range a {
+		script = append(script, DiffLine{Kind: DiffDelete, OldLine: i + 1, Text: line})
+	}
+	for i, line := range b {
+		script = append(script, DiffLine{Kind: DiffInsert, NewLine: i + 1, Text: line})
+	}
+	return script
+}
+
+// unifiedDiff renders an edit script in the unified format of diff -u
+func unifiedDiff(oldName, newName string, script []DiffLine) string {
+	var out strings.Builder
+	for start := 0; start < len(script); {
+		// Find the next change and the end of its hunk: the hunk goes on while
+		// changes are at most two contexts apart
+		first := start
+		for first < len(script) && script[first].Kind == DiffEqual {
+			first++
+		}
+		if first == len(script) {
+			break
+		}
+		last := first
+		for i := first; i < len(script); i++ {
+			if script[i].Kind != DiffEqual {
+				last = i
+			} else if i-last > 2*diffContext {
+				break
+			}
+		}
+		from := max(first-diffContext, start)
+		to := min(last+diffContext+1, len(script))
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		oldStart, oldCount, newStart, newCount := hunkRange(script[from:to])
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", formatRange(oldStart, oldCount), formatRange(newStart, newCount))
+		for _, line := range script[from:to] {
+			switch line.Kind {
+			case DiffEqual:
+				out.WriteString(" ")
+			case DiffDelete:
+				out.WriteString("-")
+			case DiffInsert:
+				out.WriteString("+")
+			}
+			out.WriteString(line.Text)
+			out.WriteString("\n")
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// hunkRange returns the first line and line count of a hunk on both sides.
+// A side without lines starts at the line before the hunk, as in diff -u.
+func hunkRange(hunk []DiffLine) (oldStart, oldCount, newStart, newCount int) {
+	for _, line := range hunk {
+		if line.OldLine > 0 {
+			if oldCount == 0 {
+				oldStart = line.OldLine
+			}
+			oldCount++
+		}
+		if line.NewLine > 0 {
+			if newCount == 0 {
+				newStart = line.NewLine
+			}
+			newCount++
+		}
+	}
+	if oldCount == 0 {
+		oldStart = precedingLine(hunk, true)
+	}
+	if newCount == 0 {
+		newStart = precedingLine(hunk, false)
+	}
+	return oldStart, oldCount, newStart, newCount
+}
+
+// precedingLine returns the line number a side without lines in a hunk is
+// reported at: the line before the hunk's first line on the other side,
+// shifted by the lines only the other side has
+func precedingLine(hunk []DiffLine, old bool) int {
+	for _, line := range hunk {
+		if old && line.NewLine > 0 {
+			return line.NewLine - 1
+		}
+		if !old && line.OldLine > 0 {
+			return line.OldLine - 1
+		}
+	}
+	return 0
+}
+
+// formatRange formats a hunk range like diff -u: "start,count", or just
+// "start" for a single line
+func formatRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// sideBySide aligns an edit script into rows. Deleted lines followed by
+// inserted ones are paired up as changed rows.
+func sideBySide(script []DiffLine) []DiffRow {
+	rows := []DiffRow{}
+	for i := 0; i < len(script); {
+		if script[i].Kind == DiffEqual {
+			line := script[i]
+			rows = append(rows, DiffRow{
+				Kind:  DiffEqual,
+				Left:  &DiffSide{Line: line.OldLine, Text: line.Text},
+				Right: &DiffSide{Line: line.NewLine, Text: line.Text},
+			})
+			i++
+			continue
+		}
+
+		var deleted, inserted []DiffLine
+		for ; i < len(script) && script[i].Kind == DiffDelete; i++ {
+			deleted = append(deleted, script[i])
+		}
+		for ; i < len(script) && script[i].Kind == DiffInsert; i++ {
+			inserted = append(inserted, script[i])
+		}
+		for j := 0; j < len(deleted) || j < len(inserted); j++ {
+			row := DiffRow{}
+			if j < len(deleted) {
+				row.Left = &DiffSide{Line: deleted[j].OldLine, Text: deleted[j].Text}
+			}
+			if j < len(inserted) {
+				row.Right = &DiffSide{Line: inserted[j].NewLine, Text: inserted[j].Text}
+			}
+			switch {
+			case row.Left != nil && row.Right != nil:
+				row.Kind = DiffChanged
+			case row.Left != nil:
+				row.Kind = DiffDelete
+			default:
+				row.Kind = DiffInsert
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// splitLines splits code into lines, without a trailing empty line for a
+// final newline
+func splitLines(code string) []string {
+	if code == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(code, "\n"), "\n")
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// formatScript writes an edit script one line per entry as
+// "<kind> <old line> <new line> <text>", with - and + for deletions and
+// insertions
+func formatScript(script []DiffLine) []string {
+	marks := map[string]string{DiffEqual: "=", DiffDelete: "-", DiffInsert: "+"}
+	lines := []string{}
+	for _, line := range script {
+		lines = append(lines, fmt.Sprintf("%s %d %d %s", marks[line.Kind], line.OldLine, line.NewLine, line.Text))
+	}
+	return lines
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want []string
+	}{
+		{
+			name: "both empty",
+			want: []string{},
+		},
+		{
+			name: "old empty",
+			b:    []string{"x", "y"},
+			want: []string{"+ 0 1 x", "+ 0 2 y"},
+		},
+		{
+			name: "new empty",
+			a:    []string{"x", "y"},
+			want: []string{"- 1 0 x", "- 2 0 y"},
+		},
+		{
+			name: "same",
+			a:    []string{"x", "y"},
+			b:    []string{"x", "y"},
+			want: []string{"= 1 1 x", "= 2 2 y"},
+		},
+		{
+			name: "insertions only",
+			a:    []string{"a", "c", "e"},
+			b:    []string{"a", "b", "c", "d", "e"},
+			want: []string{"= 1 1 a", "+ 0 2 b", "= 2 3 c", "+ 0 4 d", "= 3 5 e"},
+		},
+		{
+			name: "deletions only",
+			a:    []string{"a", "b", "c", "d", "e"},
+			b:    []string{"a", "c", "e"},
+			want: []string{"= 1 1 a", "- 2 0 b", "= 3 2 c", "- 4 0 d", "= 5 3 e"},
+		},
+		{
+			// Line numbers in the middle are shifted past the common prefix
+			name: "prefix and suffix trimmed",
+			a:    []string{"p", "q", "old", "r", "s"},
+			b:    []string{"p", "q", "new", "extra", "r", "s"},
+			want: []string{"= 1 1 p", "= 2 2 q", "- 3 0 old", "+ 0 3 new", "+ 0 4 extra", "= 4 5 r", "= 5 6 s"},
+		},
+		{
+			name: "only a prefix in common",
+			a:    []string{"p", "x"},
+			b:    []string{"p", "y", "z"},
+			want: []string{"= 1 1 p", "- 2 0 x", "+ 0 2 y", "+ 0 3 z"},
+		},
+		{
+			name: "only a suffix in common",
+			a:    []string{"x", "s"},
+			b:    []string{"s"},
+			want: []string{"- 1 0 x", "= 2 1 s"},
+		},
+		{
+			name: "moved line",
+			a:    []string{"a", "b", "c"},
+			b:    []string{"b", "c", "a"},
+			want: []string{"- 1 0 a", "= 2 1 b", "= 3 2 c", "+ 0 3 a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatScript(diffLines(tt.a, tt.b)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffLines(%q, %q) =\n%q\nwant\n%q", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesTooManyEdits(t *testing.T) {
+	// Code that differs on every line replaces the lines in between whole
+	var a, b []string
+	for i := 0; i < maxDiffEdits; i++ {
+		a = append(a, fmt.Sprintf("old %d", i))
+		b = append(b, fmt.Sprintf("new %d", i))
+	}
+	a = append([]string{"package main"}, a...)
+	b = append([]string{"package main"}, b...)
+
+	script := diffLines(a, b)
+	if len(script) != 1+2*maxDiffEdits {
+		t.Fatalf("got %d lines, want %d", len(script), 1+2*maxDiffEdits)
+	}
+	for i, line := range script[1:] {
+		want := DiffDelete
+		if i >= maxDiffEdits {
+			want = DiffInsert
+		}
+		if line.Kind != want {
+			t.Fatalf("line %d is %s, want %s", i+2, line.Kind, want)
+		}
+	}
+	if script[maxDiffEdits].OldLine != maxDiffEdits+1 || script[maxDiffEdits+1].NewLine != 2 {
+		t.Errorf("replaced lines are numbered %+v, %+v", script[maxDiffEdits], script[maxDiffEdits+1])
+	}
+}
+
+func TestDiffLinesRandom(t *testing.T) {
+	// The scripts rebuild both sides and keep as many lines as the longest
+	// common subsequence has
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(3)))
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		var before, after []string
+		equal := 0
+		for _, line := range diffLines(a, b) {
+			if line.Kind != DiffInsert {
+				before = append(before, line.Text)
+			}
+			if line.Kind != DiffDelete {
+				after = append(after, line.Text)
+			}
+			if line.Kind == DiffEqual {
+				equal++
+			}
+		}
+		if len(before) != len(a) || len(after) != len(b) || len(a) > 0 && !reflect.DeepEqual(before, a) || len(b) > 0 && !reflect.DeepEqual(after, b) {
+			t.Fatalf("diffLines(%q, %q) rebuilds %q and %q", a, b, before, after)
+		}
+		if lcs := longestCommonSubsequence(a, b); equal != lcs {
+			t.Fatalf("diffLines(%q, %q) keeps %d lines, want %d", a, b, equal, lcs)
+		}
+	}
+}
+
+// longestCommonSubsequence returns the length of the longest common
+// subsequence of a and b
+func longestCommonSubsequence(a, b []string) int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] > lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	return lengths[0][0]
+}
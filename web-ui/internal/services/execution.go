@@ -1,127 +1,609 @@
 package services
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"web-ui/internal/models"
+	"web-ui/internal/sandbox"
+)
+
+// Default limits for a test run when the challenge does not override them
+const (
+	defaultTestTimeout  = 30 * time.Second
+	defaultCPUTime      = 60 * time.Second
+	defaultMemoryBytes  = 1 << 30
+	defaultMaxProcesses = 4096 // RLIMIT_NPROC counts every process of the server user
+	defaultMaxFileSize  = 64 << 20
+	defaultMaxOutput    = 1 << 20
+	defaultBuildTimeout = 5 * time.Minute
+)
+
+// File names used inside workspaces and the temporary execution directory
+const (
+	testBinaryName       = "solution.test"
+	benchBinaryName      = "solution-bench.test"
+	solutionFileName     = "solution-template.go"
+	solutionTestFileName = "solution_test.go"
 )
 
 // ExecutionService handles code execution and testing
-type ExecutionService struct{}
+type ExecutionService struct {
+	limits       sandbox.Limits
+	buildTimeout time.Duration
+	isolation    sandbox.Isolation
+	goEnv        []string // Environment of go commands and tests; nil inherits the server's
+
+	workspacesMu  sync.Mutex
+	workspaces    map[string]*workspace // Prepared workspaces by challenge directory
+	workspaceRoot string
+
+	goRootOnce sync.Once
+	goRoot     string
+
+	test2jsonOnce sync.Once
+	test2json     string
+}
 
 // NewExecutionService creates a new execution service
 func NewExecutionService() *ExecutionService {
-	return &ExecutionService{}
+	return &ExecutionService{
+		limits: sandbox.Limits{
+			WallTime:       defaultTestTimeout,
+			CPUTime:        defaultCPUTime,
+			MemoryBytes:    defaultMemoryBytes,
+			MaxProcesses:   defaultMaxProcesses,
+			MaxFileSize:    defaultMaxFileSize,
+			MaxOutputBytes: defaultMaxOutput,
+		},
+		buildTimeout:  defaultBuildTimeout,
+		isolation:     sandbox.IsolationNone,
+		workspaces:    make(map[string]*workspace),
+		workspaceRoot: filepath.Join(os.TempDir(), "web-ui-workspaces"),
+	}
+}
+
+// SetIsolation selects whether test binaries run in their own namespaces
+func (es *ExecutionService) SetIsolation(mode sandbox.Isolation) {
+	es.isolation = mode
+}
+
+// SetLimits replaces the default limits of a test run, which challenges can
+// still override, and the time limit of builds. Zero values keep the defaults.
+func (es *ExecutionService) SetLimits(limits sandbox.Limits, buildTimeout time.Duration) {
+	if limits.WallTime > 0 {
+		es.limits.WallTime = limits.WallTime
+	}
+	if limits.CPUTime > 0 {
+		es.limits.CPUTime = limits.CPUTime
+	}
+	if limits.MemoryBytes > 0 {
+		es.limits.MemoryBytes = limits.MemoryBytes
+	}
+	if limits.MaxProcesses > 0 {
+		es.limits.MaxProcesses = limits.MaxProcesses
+	}
+	if limits.MaxFileSize > 0 {
+		es.limits.MaxFileSize = limits.MaxFileSize
+	}
+	if limits.MaxOutputBytes > 0 {
+		es.limits.MaxOutputBytes = limits.MaxOutputBytes
+	}
+	if buildTimeout > 0 {
+		es.buildTimeout = buildTimeout
+	}
+}
+
+// SetModuleProxy makes every run resolve modules through the GOPROXY at
+// proxyURL only. modules lists the module paths it serves; they are exempt
+// from checksum database lookups, which would need the internet.
+func (es *ExecutionService) SetModuleProxy(proxyURL string, modules []string) {
+	env := make([]string, 0, len(os.Environ())+4)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "GOPROXY=") && !strings.HasPrefix(kv, "GOFLAGS=") && !strings.HasPrefix(kv, "GONOSUMDB=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env,
+		"GOPROXY="+proxyURL,
+		"GOFLAGS=-mod=mod",
+		"GONOSUMDB="+strings.Join(modules, ","),
+	)
+	es.goEnv = env
 }
 
+// Isolation describes the effective isolation mode, resolving "auto" against
+// what the host supports
+func (es *ExecutionService) Isolation() (sandbox.Isolation, error) {
+	if es.isolation == sandbox.IsolationNone {
+		return sandbox.IsolationNone, nil
+	}
+	if err := sandbox.IsolationSupported(); err != nil {
+		if es.isolation == sandbox.IsolationAuto {
+			return sandbox.IsolationNone, err
+		}
+		return es.isolation, err
+	}
+	return sandbox.IsolationStrict, nil
+}
+
+// ExecutionStatus describes how a run ended
+type ExecutionStatus string
+
+const (
+	StatusPassed      ExecutionStatus = "passed"       // All tests passed
+	StatusFailed      ExecutionStatus = "failed"       // Tests failed
+	StatusBuildFailed ExecutionStatus = "build_failed" // Solution or tests did not compile
+	StatusError       ExecutionStatus = "error"        // The run could not be carried out
+	StatusTimeout     ExecutionStatus = "timeout"      // Wall-clock or CPU deadline was hit
+	StatusMemoryLimit ExecutionStatus = "oom"          // Memory limit was hit
+	StatusOutputLimit ExecutionStatus = "output_limit" // Output limit was hit
+	StatusRace        ExecutionStatus = "race"         // The race detector reported a data race
+
+	StatusIsolationError ExecutionStatus = "isolation_error" // Namespaces could not be set up
+)
+
 // ExecutionResult represents the result of code execution
 type ExecutionResult struct {
-	Passed      bool   `json:"passed"`
-	Output      string `json:"output"`
-	ExecutionMs int64  `json:"executionMs"`
+	Passed      bool             `json:"passed"`
+	Status      ExecutionStatus  `json:"status"`
+	Message     string           `json:"message,omitempty"` // Explains which limit was hit, if any
+	Output      string           `json:"output"`
+	ExecutionMs int64            `json:"executionMs"`
+	Report      *TestReport      `json:"report,omitempty"`      // Per-test results; nil when the tests never ran
+	Benchmark   *BenchmarkReport `json:"benchmark,omitempty"`   // Benchmark results, in benchmark mode
+	Coverage    *CoverageReport  `json:"coverage,omitempty"`    // Coverage of the solution, in coverage mode
+	Races       []DataRace       `json:"races,omitempty"`       // Data races, for challenges run with the race detector
+	Diagnostics []Diagnostic     `json:"diagnostics,omitempty"` // Compiler errors and vet findings
 }
 
+// errorResult builds the result for a run that could not be carried out
+func errorResult(format string, args ...interface{}) ExecutionResult {
+	return ExecutionResult{
+		Passed: false,
+		Status: StatusError,
+		Output: fmt.Sprintf(format, args...),
+	}
+}
+
+// Output phases reported to an OutputFunc
+const (
+	PhaseBuild     = "build"     // go mod, go get, compiling the test binary and go vet
+	PhaseTest      = "test"      // Running the tests
+	PhaseBenchmark = "benchmark" // Running the benchmarks
+)
+
+// OutputFunc receives the output of a run line by line while it is produced
+type OutputFunc func(phase, line string)
+
 // RunCode executes the provided code against a challenge's tests
 func (es *ExecutionService) RunCode(code string, challenge *models.Challenge) ExecutionResult {
+	return es.RunCodeContext(context.Background(), code, challenge)
+}
+
+// RunCodeContext executes the provided code against a challenge's tests. The
+// test binary is compiled first and then run in the sandbox with the
+// challenge's limits; canceling ctx kills the whole process tree.
+func (es *ExecutionService) RunCodeContext(ctx context.Context, code string, challenge *models.Challenge) ExecutionResult {
+	return es.RunCodeStream(ctx, code, challenge, nil)
+}
+
+// RunCodeStream is RunCodeContext with the build and test output passed to
+// onOutput as it happens. onOutput may be nil.
+func (es *ExecutionService) RunCodeStream(ctx context.Context, code string, challenge *models.Challenge, onOutput OutputFunc) ExecutionResult {
+	return es.RunCodeOptions(ctx, code, challenge, RunOptions{}, onOutput)
+}
+
+// RunOptions selects optional execution modes
+type RunOptions struct {
+	// Benchmark runs the benchmarks once the tests pass and compares them to
+	// the challenge's baseline. Missed thresholds fail the run.
+	Benchmark bool
+	// Coverage instruments the solution and reports which of its lines the
+	// tests ran
+	Coverage bool
+	// Race runs the tests with the race detector even if the challenge does
+	// not ask for it
+	Race bool
+	// Run only runs the tests matching the pattern, as go test -run does
+	Run string
+}
+
+// RunCodeOptions is RunCodeStream with optional execution modes
+func (es *ExecutionService) RunCodeOptions(ctx context.Context, code string, challenge *models.Challenge, opts RunOptions, onOutput OutputFunc) ExecutionResult {
 	start := time.Now()
+	buildOutput := newLineWriter(PhaseBuild, onOutput)
+	defer buildOutput.Flush()
 
 	// Create temporary directory for execution
 	tempDir, err := ioutil.TempDir("", "challenge-exec")
 	if err != nil {
-		return ExecutionResult{
-			Passed: false,
-			Output: fmt.Sprintf("Failed to create temporary directory: %v", err),
-		}
+		return errorResult("Failed to create temporary directory: %v", err)
 	}
 	defer os.RemoveAll(tempDir)
 
 	// Write the submitted code to temporary file
-	codePath := filepath.Join(tempDir, "solution-template.go")
+	codePath := filepath.Join(tempDir, solutionFileName)
 	err = ioutil.WriteFile(codePath, []byte(code), 0644)
 	if err != nil {
-		return ExecutionResult{
-			Passed: false,
-			Output: fmt.Sprintf("Failed to write code file: %v", err),
-		}
+		return errorResult("Failed to write code file: %v", err)
 	}
 
-	// Write the test file to temporary directory
-	testPath := filepath.Join(tempDir, "solution_test.go")
-	err = ioutil.WriteFile(testPath, []byte(challenge.TestFile), 0644)
+	// The challenge's prepared workspace has its modules downloaded and its
+	// dependencies compiled already
+	ws, err := es.workspaceFor(ctx, challenge, buildOutput)
 	if err != nil {
-		return ExecutionResult{
-			Passed: false,
-			Output: fmt.Sprintf("Failed to write test file: %v", err),
+		return errorResult("Failed to prepare workspace: %v", err)
+	}
+	pkg := ws.module
+
+	// Compile the test binary outside the resource limits; the compiler is trusted
+	buildDir := ws.dir
+	var buildFlags []string
+	requiredPackages := es.detectRequiredPackages(code, challenge.ID)
+	if ws.provides(requiredPackages) && !opts.Coverage {
+		// Build in the workspace with only the solution file swapped in, so
+		// the pinned versions and the warm build cache are used as they are
+		overlay, err := writeOverlay(tempDir, ws.dir)
+		if err != nil {
+			ws.mu.RUnlock()
+			return errorResult("Failed to write build overlay: %v", err)
 		}
+		buildFlags = []string{"-mod=readonly", "-overlay", overlay}
+	} else {
+		// The solution imports modules the challenge does not pin: add them
+		// to a copy of the workspace module. Coverage builds need a copy too,
+		// as the go command instruments the files on disk, not the overlay.
+		err = copyModuleFiles(ws.dir, tempDir)
+		ws.mu.RUnlock()
+		if err != nil {
+			return errorResult("Failed to copy go.mod: %v", err)
+		}
+		err = ioutil.WriteFile(filepath.Join(tempDir, solutionTestFileName), []byte(challenge.TestFile), 0644)
+		if err != nil {
+			return errorResult("Failed to write test file: %v", err)
+		}
+		err = es.installDependencies(ctx, tempDir, ws.missing(requiredPackages), buildOutput)
+		if err != nil {
+			return errorResult("Failed to install dependencies: %v", err)
+		}
+		buildDir = tempDir
+	}
+	compile := func(binary string, flags ...string) sandbox.Result {
+		// go vet runs as a phase of its own once the build succeeds
+		args := append(append([]string{"test", "-c", "-vet=off"}, buildFlags...), flags...)
+		return es.runGo(ctx, buildDir, buildOutput.Writer(), append(args, "-o", filepath.Join(tempDir, binary))...)
 	}
 
-	// Initialize Go module
-	err = es.initGoModule(tempDir, challenge.ID)
-	if err != nil {
+	race := opts.Race || (challenge.Execution != nil && challenge.Execution.Race)
+	var testFlags []string
+	if race {
+		testFlags = append(testFlags, "-race")
+	}
+	if opts.Coverage {
+		// The race detector needs atomic counters
+		mode := "count"
+		if race {
+			mode = "atomic"
+		}
+		testFlags = append(testFlags, "-cover", "-covermode="+mode)
+	}
+	build := compile(testBinaryName, testFlags...)
+	benchBinary := testBinaryName
+	if build.Success() && opts.Benchmark && len(testFlags) > 0 {
+		// Coverage counters and race checks would slow down the benchmarks
+		benchBinary = benchBinaryName
+		build = compile(benchBinary)
+	}
+	var diagnostics []Diagnostic
+	if build.Success() {
+		diagnostics = es.vet(ctx, buildDir, buildFlags)
+		for _, line := range strings.Split(strings.TrimSuffix(formatDiagnostics(diagnostics), "\n"), "\n") {
+			if line != "" {
+				buildOutput.Line("vet: " + line)
+			}
+		}
+	}
+	if buildDir == ws.dir {
+		ws.mu.RUnlock()
+	}
+	buildOutput.Flush()
+	if !build.Success() {
+		result := es.resultFromRun(build, es.buildLimits(), start)
+		if result.Status == StatusFailed {
+			result.Status = StatusBuildFailed
+			result.Output = fmt.Sprintf("%sFAIL\t%s [build failed]\n", build.Output, pkg)
+			result.Report = buildFailedReport(pkg, string(build.Output))
+			result.Diagnostics = parseBuildDiagnostics(string(build.Output))
+		}
+		return result
+	}
+	if hasErrors(diagnostics) {
+		// The findings go test itself rejects a package for
+		vetOutput := formatDiagnostics(diagnostics)
 		return ExecutionResult{
-			Passed: false,
-			Output: fmt.Sprintf("Failed to initialize Go module: %v", err),
+			Status:      StatusBuildFailed,
+			Message:     "go vet found problems in the code",
+			Output:      fmt.Sprintf("# %s\n%sFAIL\t%s [build failed]\n", pkg, vetOutput, pkg),
+			ExecutionMs: time.Since(start).Milliseconds(),
+			Report:      buildFailedReport(pkg, vetOutput),
+			Diagnostics: diagnostics,
 		}
 	}
 
-	// Automatically detect and install dependencies based on imports
-	err = es.installDependencies(tempDir, code, challenge.ID)
-	if err != nil {
-		return ExecutionResult{
-			Passed: false,
-			Output: fmt.Sprintf("Failed to install dependencies: %v", err),
+	// Run the compiled tests in the sandbox. The binary writes the framed
+	// verbose output that test2json understands, exactly as under go test -json.
+	limits := es.limitsFor(challenge)
+	testLimits := limits
+	args := []string{"-test.v=test2json"}
+	if race {
+		// The race detector reserves terabytes of address space for its
+		// shadow memory; the other limits still apply
+		testLimits.MemoryBytes = 0
+	}
+	if challenge.Execution != nil && challenge.Execution.Count > 1 {
+		args = append(args, fmt.Sprintf("-test.count=%d", challenge.Execution.Count))
+	}
+	if opts.Run != "" {
+		args = append(args, "-test.run="+opts.Run)
+	}
+	var writable []string
+	if opts.Coverage {
+		// The profile goes to the one directory the run may write to
+		coverDir := filepath.Join(tempDir, coverageDirName)
+		if err := os.Mkdir(coverDir, 0755); err != nil {
+			return errorResult("Failed to create coverage directory: %v", err)
+		}
+		writable = append(writable, coverDir)
+		args = append(args, "-test.coverprofile="+filepath.Join(coverageDirName, coverageProfileName))
+	}
+	testOutput := newLineWriter(PhaseTest, onOutput)
+	run := sandbox.Run(ctx, sandbox.Spec{
+		Path:          filepath.Join(tempDir, testBinaryName),
+		Args:          args,
+		Dir:           tempDir,
+		Env:           es.goEnv,
+		Limits:        testLimits,
+		Isolation:     es.isolation,
+		ReadOnlyPaths: es.isolatedReadOnlyPaths(ctx),
+		WritablePaths: writable,
+		Stream:        testOutput.Writer(),
+	})
+	testOutput.Flush()
+
+	result := es.resultFromRun(run, testLimits, start)
+	result.Diagnostics = diagnostics
+	if run.Outcome == sandbox.OutcomeStartFailed || run.Outcome == sandbox.OutcomeIsolationFailed {
+		return result
+	}
+	result.Report, result.Output = es.testReport(ctx, pkg, run.Output)
+	if result.Report != nil && result.Report.Status == TestIncomplete && result.Status == StatusFailed {
+		// The binary exited early, e.g. with a panic or os.Exit in the solution
+		result.Report.Status = TestFailed
+	}
+	if race {
+		// A data race fails the run even if every test passed
+		result.Races = parseDataRaces(result.Output)
+		if len(result.Races) > 0 && (result.Status == StatusPassed || result.Status == StatusFailed) {
+			result.Passed = false
+			result.Status = StatusRace
+			result.Message = fmt.Sprintf("The race detector found %d data race(s)", len(result.Races))
+		}
+	}
+	if opts.Coverage {
+		// The profile is only written when the tests ran to the end
+		if profile, err := ioutil.ReadFile(filepath.Join(tempDir, coverageDirName, coverageProfileName)); err == nil {
+			result.Coverage = parseCoverProfile(profile, solutionFileName)
 		}
 	}
 
-	// Run tests
-	cmd := exec.Command("go", "test", "-v")
-	cmd.Dir = tempDir
+	if opts.Benchmark && result.Passed {
+		result.Benchmark = es.runBenchmark(ctx, ws, challenge, filepath.Join(tempDir, benchBinary), limits, onOutput)
+		if !result.Benchmark.ThresholdsMet {
+			result.Passed = false
+			result.Status = StatusFailed
+			result.Message = "Performance thresholds not met"
+			if result.Benchmark.Message != "" {
+				result.Message = result.Benchmark.Message
+			}
+		}
+	}
+	return result
+}
 
-	output, err := cmd.CombinedOutput()
-	executionTime := time.Since(start).Milliseconds()
-	outputStr := string(output)
+// testReport converts the framed output of a test binary into a TestReport and
+// the plain verbose output. Without test2json only the plain output is returned.
+func (es *ExecutionService) testReport(ctx context.Context, pkg string, output []byte) (*TestReport, string) {
+	tool := es.test2jsonPath()
+	if tool == "" {
+		return nil, string(stripTestFraming(output))
+	}
 
+	cmd := exec.CommandContext(ctx, tool, "-p", pkg)
+	cmd.Stdin = bytes.NewReader(output)
+	events, err := cmd.Output()
+	if err != nil {
+		log.Printf("Failed to convert test output with test2json: %v", err)
+		return nil, string(stripTestFraming(output))
+	}
+	return parseTestEvents(events)
+}
+
+// test2jsonPath locates the go tool that converts test output to JSON events.
+// Recent toolchains build it on first use, so `go tool -n` is asked for it.
+func (es *ExecutionService) test2jsonPath() string {
+	es.test2jsonOnce.Do(func() {
+		result := es.runGo(context.Background(), "", nil, "tool", "-n", "test2json")
+		if !result.Success() {
+			log.Printf("test2json is unavailable, per-test results are disabled: %s", strings.TrimSpace(string(result.Output)))
+			return
+		}
+		es.test2json = strings.TrimSpace(string(result.Output))
+	})
+	return es.test2json
+}
+
+// stripTestFraming removes the control bytes -test.v=test2json puts around
+// framing lines and error output
+func stripTestFraming(output []byte) []byte {
+	return bytes.Map(func(r rune) rune {
+		switch r {
+		case '\x16', '\x0e', '\x0f':
+			return -1
+		}
+		return r
+	}, output)
+}
+
+// resultFromRun converts a sandbox result into an ExecutionResult
+func (es *ExecutionService) resultFromRun(run sandbox.Result, limits sandbox.Limits, start time.Time) ExecutionResult {
 	result := ExecutionResult{
-		Output:      outputStr,
-		ExecutionMs: executionTime,
+		Output:      string(run.Output),
+		ExecutionMs: time.Since(start).Milliseconds(),
 	}
 
-	if err == nil {
-		result.Passed = true
-	} else {
-		// Check if tests ran but failed (this is the key logic!)
-		if _, ok := err.(*exec.ExitError); ok {
-			// Test ran but failed - this means tests executed but some failed
-			result.Passed = false // Tests failed, so Passed = false
+	switch run.Outcome {
+	case sandbox.OutcomeExited:
+		if run.Err != nil {
+			// Command couldn't be waited for - this is a real error
+			result.Status = StatusError
+			result.Output = fmt.Sprintf("Failed to run tests: %v\n%s", run.Err, result.Output)
+		} else if run.ExitCode == 0 {
+			result.Passed = true
+			result.Status = StatusPassed
 		} else {
-			// Command couldn't be run - this is a real error
-			result.Passed = false
-			result.Output = fmt.Sprintf("Failed to run tests: %v\n%s", err, outputStr)
+			// Tests executed but some failed
+			result.Status = StatusFailed
 		}
+	case sandbox.OutcomeTimeout:
+		result.Status = StatusTimeout
+		result.Message = fmt.Sprintf("Time limit of %s exceeded", limits.WallTime)
+	case sandbox.OutcomeCPULimit:
+		result.Status = StatusTimeout
+		result.Message = fmt.Sprintf("CPU time limit of %s exceeded", limits.CPUTime)
+	case sandbox.OutcomeMemoryLimit:
+		result.Status = StatusMemoryLimit
+		result.Message = fmt.Sprintf("Memory limit of %d MB exceeded", limits.MemoryBytes>>20)
+	case sandbox.OutcomeOutputLimit:
+		result.Status = StatusOutputLimit
+		result.Message = fmt.Sprintf("Output limit of %d KB exceeded", limits.MaxOutputBytes>>10)
+	case sandbox.OutcomeIsolationFailed:
+		result.Status = StatusIsolationError
+		result.Message = fmt.Sprintf("Sandbox isolation failed: %v", run.Err)
+	case sandbox.OutcomeCanceled:
+		result.Status = StatusError
+		result.Message = "Run was canceled"
+	default:
+		result.Status = StatusError
+		result.Output = fmt.Sprintf("Failed to run tests: %v\n%s", run.Err, result.Output)
 	}
 
 	return result
 }
 
-// initGoModule initializes a Go module in the temporary directory
-func (es *ExecutionService) initGoModule(tempDir string, challengeID int) error {
-	// Initialize go.mod
-	cmd := exec.Command("go", "mod", "init", fmt.Sprintf("challenge-%d", challengeID))
-	cmd.Dir = tempDir
-	return cmd.Run()
+// limitsFor returns the sandbox limits for a challenge's test run
+func (es *ExecutionService) limitsFor(challenge *models.Challenge) sandbox.Limits {
+	limits := es.limits
+	config := challenge.Execution
+	if config == nil {
+		return limits
+	}
+
+	if config.TimeoutSeconds > 0 {
+		limits.WallTime = time.Duration(config.TimeoutSeconds) * time.Second
+	}
+	if config.CPUSeconds > 0 {
+		limits.CPUTime = time.Duration(config.CPUSeconds) * time.Second
+	}
+	if config.MemoryMB > 0 {
+		limits.MemoryBytes = uint64(config.MemoryMB) << 20
+	}
+	if config.MaxProcesses > 0 {
+		limits.MaxProcesses = uint64(config.MaxProcesses)
+	}
+	if config.MaxFileSizeMB > 0 {
+		limits.MaxFileSize = uint64(config.MaxFileSizeMB) << 20
+	}
+	if config.MaxOutputKB > 0 {
+		limits.MaxOutputBytes = int64(config.MaxOutputKB) << 10
+	}
+	return limits
+}
+
+// isolatedReadOnlyPaths lists host paths test binaries may need inside the
+// namespace besides the system directories: the Go toolchain, which tests
+// that shell out to "go run" depend on
+func (es *ExecutionService) isolatedReadOnlyPaths(ctx context.Context) []string {
+	if es.isolation == sandbox.IsolationNone {
+		return nil
+	}
+
+	es.goRootOnce.Do(func() {
+		result := es.runGo(ctx, "", nil, "env", "GOROOT")
+		if result.Success() {
+			es.goRoot = strings.TrimSpace(string(result.Output))
+		}
+	})
+	if es.goRoot == "" {
+		return nil
+	}
+	return []string{es.goRoot}
 }
 
-// installDependencies installs dependencies for the given challenge
-func (es *ExecutionService) installDependencies(tempDir string, code string, challengeID int) error {
-	// Detect imports from the code
-	requiredPackages := es.detectRequiredPackages(code, challengeID)
+// buildLimits returns the limits for trusted go tool invocations
+func (es *ExecutionService) buildLimits() sandbox.Limits {
+	return sandbox.Limits{
+		WallTime:       es.buildTimeout,
+		MaxOutputBytes: es.limits.MaxOutputBytes,
+	}
+}
 
+// runGo runs a go tool command in dir with the build limits, copying its
+// output to stream if it is not nil
+func (es *ExecutionService) runGo(ctx context.Context, dir string, stream io.Writer, args ...string) sandbox.Result {
+	return sandbox.Run(ctx, sandbox.Spec{
+		Path:   "go",
+		Args:   args,
+		Dir:    dir,
+		Env:    es.goEnv,
+		Limits: es.buildLimits(),
+		Stream: stream,
+	})
+}
+
+// runGoErr runs a go tool command and converts failures into an error
+func (es *ExecutionService) runGoErr(ctx context.Context, dir string, stream io.Writer, args ...string) error {
+	result := es.runGo(ctx, dir, stream, args...)
+	if result.Success() {
+		return nil
+	}
+	if result.Err != nil {
+		return result.Err
+	}
+	if result.Outcome != sandbox.OutcomeExited {
+		return fmt.Errorf("go %s: %s\nOutput: %s", strings.Join(args, " "), result.Outcome, string(result.Output))
+	}
+	return fmt.Errorf("go %s: exit status %d\nOutput: %s", strings.Join(args, " "), result.ExitCode, string(result.Output))
+}
+
+// initGoModule initializes a Go module for a challenge without a go.mod
+func (es *ExecutionService) initGoModule(ctx context.Context, tempDir string, challengeID int, output *lineWriter) error {
+	// Initialize go.mod
+	return es.runGoErr(ctx, tempDir, output.Writer(), "mod", "init", fmt.Sprintf("challenge-%d", challengeID))
+}
+
+// installDependencies adds the given packages to the module in tempDir
+func (es *ExecutionService) installDependencies(ctx context.Context, tempDir string, requiredPackages []string, output *lineWriter) error {
 	if len(requiredPackages) == 0 {
 		return nil // No external dependencies needed
 	}
@@ -129,19 +611,14 @@ func (es *ExecutionService) installDependencies(tempDir string, code string, cha
 	// Install each required package
 	for _, pkg := range requiredPackages {
 		fmt.Printf("Installing dependency: %s\n", pkg)
-		cmd := exec.Command("go", "get", pkg)
-		cmd.Dir = tempDir
-
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("failed to install package %s: %v\nOutput: %s", pkg, err, string(output))
+		output.Line("Installing dependency: " + pkg)
+		if err := es.runGoErr(ctx, tempDir, output.Writer(), "get", pkg); err != nil {
+			return fmt.Errorf("failed to install package %s: %v", pkg, err)
 		}
 	}
 
 	// Run go mod tidy to clean up dependencies
-	tidyCmd := exec.Command("go", "mod", "tidy")
-	tidyCmd.Dir = tempDir
-	tidyCmd.Run() // Ignore errors for tidy
+	es.runGo(ctx, tempDir, output.Writer(), "mod", "tidy") // Ignore errors for tidy
 
 	return nil
 }
@@ -275,74 +752,56 @@ func (es *ExecutionService) isExternalPackage(importPath string) bool {
 	return strings.Contains(importPath, ".")
 }
 
-// SaveSubmissionRequest represents a request to save a submission to filesystem
-type SaveSubmissionRequest struct {
-	Username    string `json:"username"`
-	ChallengeID int    `json:"challengeId"`
-	Code        string `json:"code"`
+// lineWriter splits streamed output into lines for an OutputFunc. A nil
+// *lineWriter discards everything, so runs without a listener pay nothing.
+type lineWriter struct {
+	phase    string
+	onOutput OutputFunc
+	partial  []byte
 }
 
-// SaveSubmissionResponse represents the response from saving a submission
-type SaveSubmissionResponse struct {
-	Success     bool     `json:"success"`
-	Message     string   `json:"message"`
-	FilePath    string   `json:"filePath"`
-	GitCommands []string `json:"gitCommands"`
+// newLineWriter returns a lineWriter for phase, or nil when onOutput is nil
+func newLineWriter(phase string, onOutput OutputFunc) *lineWriter {
+	if onOutput == nil {
+		return nil
+	}
+	return &lineWriter{phase: phase, onOutput: onOutput}
 }
 
-// SaveSubmissionToFilesystem saves a user's submission to the filesystem
-func (es *ExecutionService) SaveSubmissionToFilesystem(request SaveSubmissionRequest) SaveSubmissionResponse {
-	// Get working directory for correct relative paths
-	workDir, _ := os.Getwd()
-
-	// Try different path approaches to handle potential path issues
-	var submissionDir string
-	var fileSaved bool
-
-	// Try multiple path options to ensure it works in different environments
-	pathOptions := []string{
-		// Option 1: From web-ui directory (standard case)
-		filepath.Join("..", fmt.Sprintf("challenge-%d", request.ChallengeID), "submissions", request.Username),
-		// Option 2: From root workspace
-		filepath.Join(fmt.Sprintf("challenge-%d", request.ChallengeID), "submissions", request.Username),
-		// Option 3: Absolute path from detected workspace root
-		filepath.Join(workDir, "..", fmt.Sprintf("challenge-%d", request.ChallengeID), "submissions", request.Username),
+// Writer returns w as an io.Writer, or a nil interface for a nil lineWriter
+func (w *lineWriter) Writer() io.Writer {
+	if w == nil {
+		return nil
 	}
+	return w
+}
 
-	for _, dirPath := range pathOptions {
-		err := os.MkdirAll(dirPath, 0755)
-		if err != nil {
-			continue
-		}
-
-		solutionFile := filepath.Join(dirPath, "solution-template.go")
-		err = ioutil.WriteFile(solutionFile, []byte(request.Code), 0644)
-		if err != nil {
-			continue
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
 		}
-
-		submissionDir = dirPath
-		fileSaved = true
-		break
+		w.Line(string(w.partial[:i]))
+		w.partial = w.partial[i+1:]
 	}
+	return len(p), nil
+}
 
-	if !fileSaved {
-		return SaveSubmissionResponse{
-			Success: false,
-			Message: "Failed to save solution to any available path",
-		}
+// Line reports a complete line
+func (w *lineWriter) Line(line string) {
+	if w == nil {
+		return
 	}
+	w.onOutput(w.phase, string(stripTestFraming([]byte(line))))
+}
 
-	// Return success response with git commands
-	return SaveSubmissionResponse{
-		Success:  true,
-		Message:  "Solution saved to filesystem",
-		FilePath: filepath.Join(submissionDir, "solution-template.go"),
-		GitCommands: []string{
-			"cd " + filepath.Join(workDir, ".."),
-			fmt.Sprintf("git add %s", filepath.Join(fmt.Sprintf("challenge-%d", request.ChallengeID), "submissions", request.Username, "solution-template.go")),
-			fmt.Sprintf("git commit -m \"Add solution for Challenge %d\"", request.ChallengeID),
-			"git push origin main",
-		},
+// Flush reports output that did not end with a newline
+func (w *lineWriter) Flush() {
+	if w == nil || len(w.partial) == 0 {
+		return
 	}
+	w.Line(string(w.partial))
+	w.partial = nil
 }
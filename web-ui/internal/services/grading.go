@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"web-ui/internal/models"
+	"web-ui/internal/scoreboard"
+)
+
+// Layout of the submissions committed to the repository
+const (
+	submissionsDirName  = "submissions"
+	classicSolutionFile = "solution-template.go"
+	packageSolutionFile = "solution.go"
+)
+
+// ChallengeRef identifies a classic or package challenge and its directory
+type ChallengeRef struct {
+	ChallengeID        int    // Classic challenge number; 0 for a package challenge
+	PackageName        string // Package challenges only
+	PackageChallengeID string
+	Dir                string // Holds the challenge's SCOREBOARD.md and submissions
+}
+
+// String names the challenge, e.g. "challenge-1" or "gin/challenge-1-basic-routing"
+func (c ChallengeRef) String() string {
+	if c.PackageName != "" {
+		return c.PackageName + "/" + c.PackageChallengeID
+	}
+	return fmt.Sprintf("challenge-%d", c.ChallengeID)
+}
+
+// ScoreboardTitle returns the title the scoreboard workflows give the
+// challenge's scoreboard
+func (c ChallengeRef) ScoreboardTitle() string {
+	if c.PackageName != "" {
+		return fmt.Sprintf("Scoreboard for %s %s", c.PackageName, c.PackageChallengeID)
+	}
+	return fmt.Sprintf("Scoreboard for challenge-%d", c.ChallengeID)
+}
+
+// ScoreboardPath returns the path of the challenge's SCOREBOARD.md
+func (c ChallengeRef) ScoreboardPath() string {
+	return filepath.Join(c.Dir, scoreboard.FileName)
+}
+
+// GradeTarget is a committed submission: a user's solution in the
+// submissions directory of a challenge
+type GradeTarget struct {
+	ChallengeRef
+	Username string
+	Path     string // The solution file
+	Code     string // The solution; read from Path if empty
+}
+
+// String names the submission, e.g. "challenge-1/alice"
+func (t GradeTarget) String() string {
+	return t.ChallengeRef.String() + "/" + t.Username
+}
+
+// GradeResult is the outcome of grading a submission
+type GradeResult struct {
+	GradeTarget
+	Entry  scoreboard.Entry // The submission's scoreboard row
+	Result ExecutionResult
+}
+
+// GradingService runs the committed submissions through the execution
+// service, so scoreboards agree with what the web UI reports
+type GradingService struct {
+	challengeService *ChallengeService
+	packageService   *PackageService
+	executionService *ExecutionService
+}
+
+// NewGradingService creates a new grading service
+func NewGradingService(challengeService *ChallengeService, packageService *PackageService, executionService *ExecutionService) *GradingService {
+	return &GradingService{
+		challengeService: challengeService,
+		packageService:   packageService,
+		executionService: executionService,
+	}
+}
+
+// Challenges returns every classic challenge by number, then every package
+// challenge by package and ID
+func (gs *GradingService) Challenges() ([]ChallengeRef, error) {
+	var refs []ChallengeRef
+	for id, challenge := range gs.challengeService.GetChallenges() {
+		refs = append(refs, ChallengeRef{ChallengeID: id, Dir: challenge.Dir})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].ChallengeID < refs[j].ChallengeID })
+
+	packageNames, err := gs.packageService.GetPackageNames()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages: %v", err)
+	}
+	for _, packageName := range packageNames {
+		challenges, err := gs.packageService.GetPackageChallenges(packageName)
+		if err != nil {
+			return nil, err
+		}
+		var packageRefs []ChallengeRef
+		for id, challenge := range challenges {
+			packageRefs = append(packageRefs, ChallengeRef{PackageName: packageName, PackageChallengeID: id, Dir: challenge.Dir})
+		}
+		sort.Slice(packageRefs, func(i, j int) bool { return packageRefs[i].PackageChallengeID < packageRefs[j].PackageChallengeID })
+		refs = append(refs, packageRefs...)
+	}
+	return refs, nil
+}
+
+// Submissions returns the committed submissions of a challenge by username
+func (gs *GradingService) Submissions(challenge ChallengeRef) ([]GradeTarget, error) {
+	entries, err := os.ReadDir(filepath.Join(challenge.Dir, submissionsDirName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []GradeTarget
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		target := SubmissionTarget(challenge, entry.Name())
+		if _, err := os.Stat(target.Path); err != nil {
+			continue
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// SubmissionTarget returns the submission of a user to a challenge, which
+// may not exist
+func SubmissionTarget(challenge ChallengeRef, username string) GradeTarget {
+	solutionFile := classicSolutionFile
+	if challenge.PackageName != "" {
+		solutionFile = packageSolutionFile
+	}
+	return GradeTarget{
+		ChallengeRef: challenge,
+		Username:     username,
+		Path:         filepath.Join(challenge.Dir, submissionsDirName, username, solutionFile),
+	}
+}
+
+// SubmissionOwner maps a slash-separated path relative to the repository
+// root to the challenge and user whose submissions directory holds it. ok is
+// false for paths outside the users' submission directories.
+func SubmissionOwner(challenges []ChallengeRef, repoRoot, path string) (challenge ChallengeRef, username string, ok bool) {
+	for _, challenge := range challenges {
+		dir, err := RepoPath(repoRoot, challenge.Dir)
+		if err != nil {
+			continue
+		}
+		rest, found := strings.CutPrefix(path, dir+"/"+submissionsDirName+"/")
+		if !found {
+			continue
+		}
+		username, _, found := strings.Cut(rest, "/")
+		return challenge, username, found && username != ""
+	}
+	return ChallengeRef{}, "", false
+}
+
+// RepoPath returns the slash-separated path of a file or directory, which
+// may not exist, relative to the repository root. Symbolic links are
+// resolved on both sides first, as git reports paths in the resolved tree.
+func RepoPath(repoRoot, path string) (string, error) {
+	rel, err := filepath.Rel(resolveSymlinks(repoRoot), resolveSymlinks(path))
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// resolveSymlinks returns the absolute path with the symbolic links of its
+// longest existing leading part resolved
+func resolveSymlinks(path string) string {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rest := ""
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		if filepath.Dir(dir) == dir {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// SaveSubmissionRequest represents a request to save a submission to filesystem
+type SaveSubmissionRequest struct {
+	Username    string `json:"username"`
+	ChallengeID int    `json:"challengeId"`
+	Code        string `json:"code"`
+}
+
+// SaveSubmissionResponse represents the response from saving a submission
+type SaveSubmissionResponse struct {
+	Success     bool     `json:"success"`
+	Message     string   `json:"message"`
+	FilePath    string   `json:"filePath"`
+	GitCommands []string `json:"gitCommands"`
+}
+
+// SaveSubmission writes code as the submission of target and returns the git
+// commands that commit it to the repository at repoRoot
+func SaveSubmission(repoRoot string, target GradeTarget, code string, commitMessage string) SaveSubmissionResponse {
+	if err := os.MkdirAll(filepath.Dir(target.Path), 0755); err != nil {
+		return SaveSubmissionResponse{Success: false, Message: fmt.Sprintf("Failed to save solution: %v", err)}
+	}
+	if err := ioutil.WriteFile(target.Path, []byte(code), 0644); err != nil {
+		return SaveSubmissionResponse{Success: false, Message: fmt.Sprintf("Failed to save solution: %v", err)}
+	}
+
+	relativePath, err := filepath.Rel(repoRoot, target.Path)
+	if err != nil {
+		relativePath = target.Path
+	}
+	return SaveSubmissionResponse{
+		Success:  true,
+		Message:  "Solution saved to filesystem",
+		FilePath: target.Path,
+		GitCommands: []string{
+			"cd " + repoRoot,
+			fmt.Sprintf("git add %s", filepath.ToSlash(relativePath)),
+			fmt.Sprintf("git commit -m \"%s\"", commitMessage),
+			"git push origin main",
+		},
+	}
+}
+
+// Grade runs submissions on up to workers at a time. onResult, if not nil,
+// is called as each submission finishes, one call at a time; the results
+// are returned in the order of targets.
+func (gs *GradingService) Grade(ctx context.Context, targets []GradeTarget, workers int, onResult func(GradeResult)) []GradeResult {
+	results := make([]GradeResult, len(targets))
+	indexes := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < max(workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				result := gs.grade(ctx, targets[i])
+				results[i] = result
+				if onResult != nil {
+					mu.Lock()
+					onResult(result)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range targets {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// grade runs one submission the way the web UI runs submissions, with the
+// benchmark thresholds the challenge declares
+func (gs *GradingService) grade(ctx context.Context, target GradeTarget) GradeResult {
+	opts := RunOptions{}
+	if challenge, err := gs.Challenge(target.ChallengeRef); err == nil {
+		opts.Benchmark = challenge.Benchmark != nil
+	}
+	result := gs.Run(ctx, target, opts, nil)
+	return GradeResult{
+		GradeTarget: target,
+		Entry:       gradeEntry(target.Username, result),
+		Result:      result,
+	}
+}
+
+// Run runs a submission with optional execution modes, passing its output to
+// onOutput as it happens
+func (gs *GradingService) Run(ctx context.Context, target GradeTarget, opts RunOptions, onOutput OutputFunc) ExecutionResult {
+	challenge, err := gs.Challenge(target.ChallengeRef)
+	code := target.Code
+	if err == nil && code == "" {
+		var data []byte
+		if data, err = ioutil.ReadFile(target.Path); err != nil {
+			err = fmt.Errorf("failed to read submission: %v", err)
+		}
+		code = string(data)
+	}
+	if err != nil {
+		return errorResult("%v", err)
+	}
+	return gs.executionService.RunCodeOptions(ctx, code, challenge, opts, onOutput)
+}
+
+// Challenge loads a challenge for execution
+func (gs *GradingService) Challenge(ref ChallengeRef) (*models.Challenge, error) {
+	if ref.PackageName == "" {
+		challenge, exists := gs.challengeService.GetChallenge(ref.ChallengeID)
+		if !exists {
+			return nil, fmt.Errorf("challenge %d not found", ref.ChallengeID)
+		}
+		return challenge, nil
+	}
+
+	challenge, err := gs.packageService.GetPackageChallenge(ref.PackageName, ref.PackageChallengeID)
+	if err != nil {
+		return nil, err
+	}
+	return PackageExecutionChallenge(challenge), nil
+}
+
+// gradeEntry turns a run into a scoreboard row. Tests repeated with
+// -test.count are counted once. A run without test results, e.g. one that
+// did not build, counts as one failed test, as in the scoreboard workflows;
+// so does a failed run whose tests all passed, e.g. one that found a race.
+func gradeEntry(username string, result ExecutionResult) scoreboard.Entry {
+	entry := scoreboard.Entry{Username: username}
+	statuses := reportStatuses(result.Report)
+	for _, name := range statuses.names {
+		switch statuses.status[name] {
+		case TestPassed:
+			entry.Passed++
+			entry.Total++
+		case TestFailed, TestIncomplete:
+			entry.Total++
+		}
+	}
+	if entry.Total == 0 || (!result.Passed && entry.Passed == entry.Total) {
+		entry.Total++
+	}
+	return entry
+}
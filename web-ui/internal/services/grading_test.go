@@ -0,0 +1,123 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGradeEntry(t *testing.T) {
+	report := func(fixture string) *TestReport {
+		report, _ := parseTestEvents(readFixture(t, fixture))
+		return report
+	}
+	passing := &TestReport{Tests: []*TestResult{
+		{Name: "TestSum", Status: TestPassed, Subtests: []*TestResult{{Name: "TestSum/negative", Status: TestPassed}}},
+		{Name: "TestLater", Status: TestSkipped},
+	}}
+	tests := []struct {
+		name          string
+		result        ExecutionResult
+		passed, total int
+	}{
+		{
+			name:   "passed",
+			result: ExecutionResult{Passed: true, Report: passing},
+			passed: 2, total: 2,
+		},
+		{
+			// Skipped tests are not counted
+			name:   "failed tests",
+			result: ExecutionResult{Report: report("testreport-mixed.jsonl")},
+			passed: 3, total: 5,
+		},
+		{
+			// Every test counts once, with its worst run
+			name:   "repeated with -count",
+			result: ExecutionResult{Report: report("testreport-count.jsonl")},
+			passed: 1, total: 4,
+		},
+		{
+			name:   "killed",
+			result: ExecutionResult{Report: report("testreport-killed.jsonl")},
+			passed: 2, total: 4,
+		},
+		{
+			name:   "build failed",
+			result: ExecutionResult{Status: StatusBuildFailed, Report: buildFailedReport("example.com/report", "syntax error")},
+			passed: 0, total: 1,
+		},
+		{
+			name:   "no report",
+			result: errorResult("challenge not found"),
+			passed: 0, total: 1,
+		},
+		{
+			// e.g. benchmark thresholds missed or a race found
+			name:   "tests passed but the run failed",
+			result: ExecutionResult{Report: passing},
+			passed: 2, total: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := gradeEntry("alice", tt.result)
+			if entry.Username != "alice" || entry.Passed != tt.passed || entry.Total != tt.total {
+				t.Errorf("entry = %+v, want alice with %d/%d", entry, tt.passed, tt.total)
+			}
+			if entry.Completed() != tt.result.Passed {
+				t.Errorf("completed = %v for a run that passed = %v", entry.Completed(), tt.result.Passed)
+			}
+		})
+	}
+}
+
+func TestSubmissionOwner(t *testing.T) {
+	root := t.TempDir()
+	challenges := []ChallengeRef{
+		{ChallengeID: 1, Dir: filepath.Join(root, "challenge-1")},
+		{ChallengeID: 10, Dir: filepath.Join(root, "challenge-10")},
+		{PackageName: "gin", PackageChallengeID: "challenge-1-basic-routing", Dir: filepath.Join(root, "packages", "gin", "challenge-1-basic-routing")},
+	}
+	for _, challenge := range challenges {
+		if err := os.MkdirAll(challenge.Dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The same tree reached through a symbolic link, as with -repo
+	link := filepath.Join(t.TempDir(), "repo")
+	if err := os.Symlink(root, link); err != nil {
+		t.Fatal(err)
+	}
+	var linked []ChallengeRef
+	for _, challenge := range challenges {
+		challenge.Dir = filepath.Join(link, strings.TrimPrefix(challenge.Dir, root))
+		linked = append(linked, challenge)
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		challenge string
+		username  string
+	}{
+		{"classic", "challenge-1/submissions/alice/solution-template.go", "challenge-1", "alice"},
+		{"other classic", "challenge-10/submissions/bob/solution-template.go", "challenge-10", "bob"},
+		{"package", "packages/gin/challenge-1-basic-routing/submissions/carol/solution.go", "gin/challenge-1-basic-routing", "carol"},
+		{"nested file", "challenge-1/submissions/alice/notes/README.md", "challenge-1", "alice"},
+		{"challenge file", "challenge-1/solution-template.go", "", ""},
+		{"scoreboard", "challenge-1/SCOREBOARD.md", "", ""},
+		{"file in submissions", "challenge-1/submissions/README.md", "", ""},
+		{"unknown challenge", "challenge-2/submissions/alice/solution-template.go", "", ""},
+		{"outside challenges", "web-ui/main.go", "", ""},
+	}
+	for _, tt := range tests {
+		for name, refs := range map[string][]ChallengeRef{"direct": challenges, "symlinked": linked} {
+			challenge, username, ok := SubmissionOwner(refs, root, tt.path)
+			if ok != (tt.username != "") || ok && (challenge.String() != tt.challenge || username != tt.username) {
+				t.Errorf("%s, %s: SubmissionOwner(%s) = %s, %q, %v, want %s, %q", tt.name, name, tt.path, challenge, username, ok, tt.challenge, tt.username)
+			}
+		}
+	}
+}
@@ -0,0 +1,672 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InterviewsDirName is the directory in the data directory holding the
+// interview sessions, one file each
+const InterviewsDirName = "interviews"
+
+// Limits of interview sessions. A session is rewritten whole on every
+// change, so what it keeps of each attempt is bounded too.
+const (
+	MaxInterviewMinutes    = 8 * 60
+	MaxInterviewChallenges = 10
+	MaxInterviewAttempts   = 100 // Runs and submissions per challenge
+	maxCandidateName       = 100
+	maxAttemptOutput       = 16 << 10 // Bytes of test output kept with an attempt
+)
+
+// Errors of interview sessions
+var (
+	ErrInterviewNotFound         = errors.New("interview session not found")
+	ErrInterviewLinkUsed         = errors.New("this interview link was already used")
+	ErrInterviewNotStarted       = errors.New("the interview has not started")
+	ErrInterviewOver             = errors.New("the interview is over")
+	ErrNotInterviewer            = errors.New("only the interviewer of the session can do this")
+	ErrNotInterviewCandidate     = errors.New("open the interview from the candidate link")
+	ErrInterviewerRequired       = errors.New("sign in to hold interviews")
+	ErrInvalidTimeLimit          = fmt.Errorf("time limits are 1 to %d minutes", MaxInterviewMinutes)
+	ErrInvalidChallengeCount     = fmt.Errorf("choose 1 to %d challenges", MaxInterviewChallenges)
+	ErrInvalidInterviewChallenge = errors.New("no such challenge in the interview")
+	ErrInvalidCandidateName      = fmt.Errorf("candidate names have at most %d characters", maxCandidateName)
+	ErrTooManyAttempts           = fmt.Errorf("at most %d runs and submissions can be made per challenge", MaxInterviewAttempts)
+	ErrInterviewCodeTooLarge     = fmt.Errorf("code is too large, the limit is %d KB", MaxCodeBytes>>10)
+)
+
+// InterviewState is where an interview session is in its life
+type InterviewState string
+
+// Interview session states
+const (
+	InterviewPending InterviewState = "pending" // The candidate has not started yet
+	InterviewActive  InterviewState = "active"  // The clock is running
+	InterviewEnded   InterviewState = "ended"   // Time ran out or the session was ended
+)
+
+// Reasons an interview session ended
+const (
+	InterviewExpired  = "expired"  // The time limit ran out
+	InterviewFinished = "finished" // The candidate finished early
+	InterviewCanceled = "canceled" // The interviewer ended it
+)
+
+// Kinds of interview attempts
+const (
+	AttemptRun    = "run"
+	AttemptSubmit = "submit"
+)
+
+// InterviewChallenge is a challenge of an interview: a classic challenge by
+// ID or a package challenge by package and challenge ID
+type InterviewChallenge struct {
+	ChallengeID        int    `json:"challengeId,omitempty"`
+	PackageName        string `json:"packageName,omitempty"`        // e.g. "gin"
+	PackageChallengeID string `json:"packageChallengeId,omitempty"` // e.g. "challenge-1-basic-routing"
+	Title              string `json:"title"`
+}
+
+// IsPackage reports whether the challenge is a package challenge
+func (c InterviewChallenge) IsPackage() bool {
+	return c.PackageName != ""
+}
+
+// Key names the challenge in recorded timelines: "3" or "gin/challenge-1-basic-routing"
+func (c InterviewChallenge) Key() string {
+	if c.IsPackage() {
+		return c.PackageName + "/" + c.PackageChallengeID
+	}
+	return fmt.Sprint(c.ChallengeID)
+}
+
+// InterviewAttempt is a test run or submission the candidate made
+type InterviewAttempt struct {
+	Challenge   int              `json:"challenge"` // Index in the session's challenges
+	Kind        string           `json:"kind"`      // AttemptRun or AttemptSubmit
+	Code        string           `json:"code"`
+	SubmittedAt time.Time        `json:"submittedAt"`
+	ElapsedMs   int64            `json:"elapsedMs"` // From the start of the session to SubmittedAt
+	Result      *ExecutionResult `json:"result"`
+}
+
+// InterviewDraft is the code of a challenge as the candidate's editor last
+// saved it
+type InterviewDraft struct {
+	Code    string    `json:"code"`
+	SavedAt time.Time `json:"savedAt"`
+}
+
+// InterviewSession is a timed interview: the challenges a candidate solves,
+// the time they have and everything they did. The clock starts when the
+// candidate opens the session's link.
+type InterviewSession struct {
+	ID               string                 `json:"id"`
+	Interviewer      string                 `json:"interviewer"`
+	Candidate        string                 `json:"candidate,omitempty"` // As the interviewer named them
+	Challenges       []InterviewChallenge   `json:"challenges"`
+	TimeLimitMinutes int                    `json:"timeLimitMinutes"`
+	CreatedAt        time.Time              `json:"createdAt"`
+	StartedAt        *time.Time             `json:"startedAt,omitempty"`
+	Deadline         *time.Time             `json:"deadline,omitempty"`
+	EndedAt          *time.Time             `json:"endedAt,omitempty"` // Set when ended before the deadline
+	EndReason        string                 `json:"endReason,omitempty"`
+	Drafts           map[int]InterviewDraft `json:"drafts,omitempty"` // By challenge index
+	Attempts         []InterviewAttempt     `json:"attempts"`
+}
+
+// attemptsOn returns the number of attempts made on a challenge
+func (s *InterviewSession) attemptsOn(challenge int) int {
+	n := 0
+	for _, attempt := range s.Attempts {
+		if attempt.Challenge == challenge {
+			n++
+		}
+	}
+	return n
+}
+
+// State returns the state of the session at now
+func (s *InterviewSession) State(now time.Time) InterviewState {
+	switch {
+	case s.EndedAt != nil || (s.Deadline != nil && !now.Before(*s.Deadline)):
+		return InterviewEnded
+	case s.StartedAt != nil:
+		return InterviewActive
+	default:
+		return InterviewPending
+	}
+}
+
+// Ended returns when and why the session ended, if it did by now
+func (s *InterviewSession) Ended(now time.Time) (time.Time, string, bool) {
+	if s.EndedAt != nil {
+		return *s.EndedAt, s.EndReason, true
+	}
+	if s.Deadline != nil && !now.Before(*s.Deadline) {
+		return *s.Deadline, InterviewExpired, true
+	}
+	return time.Time{}, "", false
+}
+
+// Remaining returns the time the candidate has left at now
+func (s *InterviewSession) Remaining(now time.Time) time.Duration {
+	switch s.State(now) {
+	case InterviewPending:
+		return time.Duration(s.TimeLimitMinutes) * time.Minute
+	case InterviewActive:
+		return s.Deadline.Sub(now)
+	default:
+		return 0
+	}
+}
+
+// Elapsed returns the time from the start of the session to t
+func (s *InterviewSession) Elapsed(t time.Time) time.Duration {
+	if s.StartedAt == nil {
+		return 0
+	}
+	return t.Sub(*s.StartedAt)
+}
+
+// interviewEntry is a session with the hashes of its secrets, as stored
+type interviewEntry struct {
+	Session       *InterviewSession `json:"session"`
+	LinkHash      string            `json:"linkHash"`                // The candidate link's token
+	CandidateHash string            `json:"candidateHash,omitempty"` // The candidate's key, once started
+}
+
+// InterviewService keeps the interview sessions and enforces their time
+// limits. Sessions handed out are snapshots that never change; every change
+// replaces the session, so callers must not modify them.
+type InterviewService struct {
+	dir string // Empty keeps the sessions in memory only
+
+	mu      sync.RWMutex
+	entries map[string]*interviewEntry // By session ID
+	links   map[string]string          // Session IDs by link hash
+}
+
+// NewInterviewService creates an interview service keeping its sessions in
+// the interviews directory of dataDir
+func NewInterviewService(dataDir string) (*InterviewService, error) {
+	is := &InterviewService{
+		entries: make(map[string]*interviewEntry),
+		links:   make(map[string]string),
+	}
+	if dataDir == "" {
+		return is, nil
+	}
+	is.dir = filepath.Join(dataDir, InterviewsDirName)
+	files, err := ioutil.ReadDir(is.dir)
+	if os.IsNotExist(err) {
+		return is, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(is.dir, file.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var entry interviewEntry
+		if err := json.Unmarshal(data, &entry); err != nil || entry.Session == nil {
+			return nil, fmt.Errorf("%s: invalid interview session: %v", path, err)
+		}
+		is.entries[entry.Session.ID] = &entry
+		is.links[entry.LinkHash] = entry.Session.ID
+	}
+	return is, nil
+}
+
+// Create creates a session of an interviewer and returns it with the token
+// of its candidate link. Only the token's hash is kept, so it cannot be
+// shown again.
+func (is *InterviewService) Create(interviewer, candidate string, challenges []InterviewChallenge, minutes int) (*InterviewSession, string, error) {
+	candidate = strings.TrimSpace(candidate)
+	switch {
+	case interviewer == "":
+		return nil, "", ErrInterviewerRequired
+	case len(challenges) == 0 || len(challenges) > MaxInterviewChallenges:
+		return nil, "", ErrInvalidChallengeCount
+	case minutes < 1 || minutes > MaxInterviewMinutes:
+		return nil, "", ErrInvalidTimeLimit
+	case len([]rune(candidate)) > maxCandidateName:
+		return nil, "", ErrInvalidCandidateName
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := randomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	session := &InterviewSession{
+		ID:               id,
+		Interviewer:      interviewer,
+		Candidate:        candidate,
+		Challenges:       append([]InterviewChallenge(nil), challenges...),
+		TimeLimitMinutes: minutes,
+		CreatedAt:        time.Now(),
+		Attempts:         []InterviewAttempt{},
+	}
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if err := is.put(&interviewEntry{Session: session, LinkHash: hashSecret(token)}); err != nil {
+		return nil, "", err
+	}
+	return session, token, nil
+}
+
+// Session returns a session by ID
+func (is *InterviewService) Session(id string) (*InterviewSession, bool) {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+	entry, ok := is.entries[id]
+	if !ok {
+		return nil, false
+	}
+	return entry.Session, true
+}
+
+// InterviewerSessions returns the sessions of an interviewer, newest first
+func (is *InterviewService) InterviewerSessions(interviewer string) []*InterviewSession {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+	var sessions []*InterviewSession
+	for _, entry := range is.entries {
+		if strings.EqualFold(entry.Session.Interviewer, interviewer) {
+			sessions = append(sessions, entry.Session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	return sessions
+}
+
+// LinkSession returns the session of a candidate link
+func (is *InterviewService) LinkSession(token string) (*InterviewSession, bool) {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+	id, ok := is.links[hashSecret(token)]
+	if !ok {
+		return nil, false
+	}
+	return is.entries[id].Session, true
+}
+
+// Start starts the clock of the session of a candidate link and returns the
+// key the candidate proves themselves with from then on. A link starts its
+// session once; after that it only opens the session along with the key.
+func (is *InterviewService) Start(token string) (*InterviewSession, string, error) {
+	key, err := randomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	id, ok := is.links[hashSecret(token)]
+	if !ok {
+		return nil, "", ErrInterviewNotFound
+	}
+	entry := is.entries[id]
+	now := time.Now()
+	switch entry.Session.State(now) {
+	case InterviewEnded:
+		return nil, "", ErrInterviewOver
+	case InterviewActive:
+		return nil, "", ErrInterviewLinkUsed
+	}
+
+	session := copyInterview(entry.Session)
+	deadline := now.Add(time.Duration(session.TimeLimitMinutes) * time.Minute)
+	session.StartedAt = &now
+	session.Deadline = &deadline
+	if err := is.put(&interviewEntry{Session: session, LinkHash: entry.LinkHash, CandidateHash: hashSecret(key)}); err != nil {
+		return nil, "", err
+	}
+	return session, key, nil
+}
+
+// CandidateSession returns a session to its candidate, whatever its state
+func (is *InterviewService) CandidateSession(id, key string) (*InterviewSession, error) {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+	entry, err := is.candidateEntry(id, key)
+	if err != nil {
+		return nil, err
+	}
+	return entry.Session, nil
+}
+
+// Accept checks that the candidate may still work on a challenge of a
+// session, i.e. that it is active, and returns the session. Runs and
+// submissions accepted before the deadline count even if they finish after it.
+func (is *InterviewService) Accept(id, key string, challenge int) (*InterviewSession, error) {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+	entry, err := is.activeEntry(id, key)
+	if err != nil {
+		return nil, err
+	}
+	if challenge < 0 || challenge >= len(entry.Session.Challenges) {
+		return nil, ErrInvalidInterviewChallenge
+	}
+	return entry.Session, nil
+}
+
+// AcceptAttempt is Accept for a run or submission, which also needs the
+// candidate to have attempts left on the challenge
+func (is *InterviewService) AcceptAttempt(id, key string, challenge int) (*InterviewSession, error) {
+	session, err := is.Accept(id, key, challenge)
+	if err != nil {
+		return nil, err
+	}
+	if session.attemptsOn(challenge) >= MaxInterviewAttempts {
+		return nil, ErrTooManyAttempts
+	}
+	return session, nil
+}
+
+// RecordAttempt adds an accepted run or submission to a session. Only the
+// start of a long test output is kept.
+func (is *InterviewService) RecordAttempt(id string, attempt InterviewAttempt) error {
+	if len(attempt.Code) > MaxCodeBytes {
+		return ErrInterviewCodeTooLarge
+	}
+	if attempt.Result != nil && len(attempt.Result.Output) > maxAttemptOutput {
+		result := *attempt.Result
+		result.Output = strings.ToValidUTF8(result.Output[:maxAttemptOutput], "") + "\n[output truncated]"
+		attempt.Result = &result
+	}
+
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	entry, ok := is.entries[id]
+	if !ok {
+		return ErrInterviewNotFound
+	}
+	if entry.Session.attemptsOn(attempt.Challenge) >= MaxInterviewAttempts {
+		// Runs accepted at the same time went over the limit
+		return ErrTooManyAttempts
+	}
+	session := copyInterview(entry.Session)
+	session.Attempts = append(session.Attempts, attempt)
+	return is.put(&interviewEntry{Session: session, LinkHash: entry.LinkHash, CandidateHash: entry.CandidateHash})
+}
+
+// SaveDraft keeps the code in the candidate's editor for a challenge, while
+// the session is active. Saving the code there already is does nothing.
+func (is *InterviewService) SaveDraft(id, key string, challenge int, code string) error {
+	if len(code) > MaxCodeBytes {
+		return ErrInterviewCodeTooLarge
+	}
+
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	entry, err := is.activeEntry(id, key)
+	if err != nil {
+		return err
+	}
+	if challenge < 0 || challenge >= len(entry.Session.Challenges) {
+		return ErrInvalidInterviewChallenge
+	}
+	if draft, ok := entry.Session.Drafts[challenge]; ok && draft.Code == code {
+		return nil
+	}
+	session := copyInterview(entry.Session)
+	session.Drafts[challenge] = InterviewDraft{Code: code, SavedAt: time.Now()}
+	return is.put(&interviewEntry{Session: session, LinkHash: entry.LinkHash, CandidateHash: entry.CandidateHash})
+}
+
+// Finish ends an active session early at the candidate's request
+func (is *InterviewService) Finish(id, key string) (*InterviewSession, error) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	entry, err := is.activeEntry(id, key)
+	if err != nil {
+		return nil, err
+	}
+	return is.end(entry, InterviewFinished)
+}
+
+// End ends a session that has not ended yet at the interviewer's request. A
+// session ended before it started cannot be started any more.
+func (is *InterviewService) End(id, interviewer string) (*InterviewSession, error) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	entry, ok := is.entries[id]
+	if !ok {
+		return nil, ErrInterviewNotFound
+	}
+	if !strings.EqualFold(entry.Session.Interviewer, interviewer) {
+		return nil, ErrNotInterviewer
+	}
+	if entry.Session.State(time.Now()) == InterviewEnded {
+		return nil, ErrInterviewOver
+	}
+	return is.end(entry, InterviewCanceled)
+}
+
+// candidateEntry returns the entry of a session if key is its candidate's.
+// is.mu must be held.
+func (is *InterviewService) candidateEntry(id, key string) (*interviewEntry, error) {
+	entry, ok := is.entries[id]
+	if !ok {
+		return nil, ErrInterviewNotFound
+	}
+	if key == "" || entry.CandidateHash == "" || hashSecret(key) != entry.CandidateHash {
+		return nil, ErrNotInterviewCandidate
+	}
+	return entry, nil
+}
+
+// activeEntry returns the entry of a session the candidate may work on right
+// now. is.mu must be held.
+func (is *InterviewService) activeEntry(id, key string) (*interviewEntry, error) {
+	entry, err := is.candidateEntry(id, key)
+	if err != nil {
+		return nil, err
+	}
+	switch entry.Session.State(time.Now()) {
+	case InterviewPending:
+		return nil, ErrInterviewNotStarted
+	case InterviewEnded:
+		return nil, ErrInterviewOver
+	}
+	return entry, nil
+}
+
+// end ends the session of an entry for a reason. is.mu must be held.
+func (is *InterviewService) end(entry *interviewEntry, reason string) (*InterviewSession, error) {
+	now := time.Now()
+	session := copyInterview(entry.Session)
+	session.EndedAt = &now
+	session.EndReason = reason
+	if err := is.put(&interviewEntry{Session: session, LinkHash: entry.LinkHash, CandidateHash: entry.CandidateHash}); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// put stores an entry, replacing its previous version, and saves it. is.mu
+// must be held.
+func (is *InterviewService) put(entry *interviewEntry) error {
+	if err := is.save(entry); err != nil {
+		return err
+	}
+	is.entries[entry.Session.ID] = entry
+	is.links[entry.LinkHash] = entry.Session.ID
+	return nil
+}
+
+// save writes an entry to its file, replacing it at once
+func (is *InterviewService) save(entry *interviewEntry) error {
+	if is.dir == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(is.dir, 0700); err != nil {
+		return err
+	}
+	path := filepath.Join(is.dir, entry.Session.ID+".json")
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// copyInterview returns a copy of a session that can be changed
+func copyInterview(session *InterviewSession) *InterviewSession {
+	copied := *session
+	copied.Attempts = append([]InterviewAttempt(nil), session.Attempts...)
+	copied.Drafts = make(map[int]InterviewDraft, len(session.Drafts)+1)
+	for challenge, draft := range session.Drafts {
+		copied.Drafts[challenge] = draft
+	}
+	return &copied
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// hashSecret returns the key a link token or candidate key is stored under
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// InterviewReport is the outcome of an interview session: per challenge,
+// every attempt with its code, test results and timing, and the final code
+type InterviewReport struct {
+	ID               string                     `json:"id"`
+	Interviewer      string                     `json:"interviewer"`
+	Candidate        string                     `json:"candidate,omitempty"`
+	TimeLimitMinutes int                        `json:"timeLimitMinutes"`
+	State            InterviewState             `json:"state"`
+	Final            bool                       `json:"final"` // The session ended; the report no longer changes
+	StartedAt        *time.Time                 `json:"startedAt,omitempty"`
+	EndedAt          *time.Time                 `json:"endedAt,omitempty"`
+	EndReason        string                     `json:"endReason,omitempty"`
+	DurationMs       int64                      `json:"durationMs"` // From the start to the end, or to now while active
+	Solved           int                        `json:"solved"`
+	Challenges       []InterviewChallengeReport `json:"challenges"`
+}
+
+// InterviewChallengeReport is what the candidate did on a challenge
+type InterviewChallengeReport struct {
+	InterviewChallenge
+	Runs          int                `json:"runs"`
+	Submissions   int                `json:"submissions"`
+	Passed        bool               `json:"passed"`                  // A submission passed every test
+	TestsPassed   int                `json:"testsPassed"`             // Of the last submission, or else the last run
+	TestsTotal    int                `json:"testsTotal"`              // Of the last submission, or else the last run
+	FirstTryMs    *int64             `json:"firstTryMs,omitempty"`    // Into the session when the challenge was first run
+	SolvedAfterMs *int64             `json:"solvedAfterMs,omitempty"` // Into the session when a submission first passed
+	FinalCode     string             `json:"finalCode"`               // The latest of the last attempt and the last draft
+	FinalSavedAt  *time.Time         `json:"finalSavedAt,omitempty"`
+	Attempts      []InterviewAttempt `json:"attempts"` // In the order they were made
+}
+
+// BuildInterviewReport reports on a session as of now
+func BuildInterviewReport(session *InterviewSession, now time.Time) InterviewReport {
+	report := InterviewReport{
+		ID:               session.ID,
+		Interviewer:      session.Interviewer,
+		Candidate:        session.Candidate,
+		TimeLimitMinutes: session.TimeLimitMinutes,
+		State:            session.State(now),
+		StartedAt:        session.StartedAt,
+		Challenges:       make([]InterviewChallengeReport, len(session.Challenges)),
+	}
+	end := now
+	if endedAt, reason, ok := session.Ended(now); ok {
+		report.Final = true
+		report.EndedAt = &endedAt
+		report.EndReason = reason
+		end = endedAt
+	}
+	if session.StartedAt != nil {
+		report.DurationMs = session.Elapsed(end).Milliseconds()
+	}
+
+	attempts := append([]InterviewAttempt(nil), session.Attempts...)
+	sort.SliceStable(attempts, func(i, j int) bool { return attempts[i].SubmittedAt.Before(attempts[j].SubmittedAt) })
+	for i, challenge := range session.Challenges {
+		report.Challenges[i] = InterviewChallengeReport{InterviewChallenge: challenge, Attempts: []InterviewAttempt{}}
+	}
+	for _, attempt := range attempts {
+		if attempt.Challenge < 0 || attempt.Challenge >= len(report.Challenges) {
+			continue
+		}
+		c := &report.Challenges[attempt.Challenge]
+		c.Attempts = append(c.Attempts, attempt)
+		elapsed := attempt.ElapsedMs
+		if c.FirstTryMs == nil {
+			c.FirstTryMs = &elapsed
+		}
+		submitted := attempt.SubmittedAt
+		c.FinalCode, c.FinalSavedAt = attempt.Code, &submitted
+
+		passed := attempt.Result != nil && attempt.Result.Passed
+		if attempt.Kind == AttemptSubmit {
+			c.Submissions++
+			if passed && !c.Passed {
+				c.Passed = true
+				c.SolvedAfterMs = &elapsed
+			}
+		} else {
+			c.Runs++
+		}
+		// The last submission decides the test counts; runs only until then
+		if attempt.Result != nil && attempt.Result.Report != nil && (attempt.Kind == AttemptSubmit || c.Submissions == 0) {
+			c.TestsPassed = attempt.Result.Report.Passed
+			c.TestsTotal = attempt.Result.Report.Total
+		}
+	}
+	for i, draft := range session.Drafts {
+		if i < 0 || i >= len(report.Challenges) {
+			continue
+		}
+		c := &report.Challenges[i]
+		if c.FinalSavedAt == nil || draft.SavedAt.After(*c.FinalSavedAt) {
+			savedAt := draft.SavedAt
+			c.FinalCode, c.FinalSavedAt = draft.Code, &savedAt
+		}
+	}
+	for _, c := range report.Challenges {
+		if c.Passed {
+			report.Solved++
+		}
+	}
+	return report
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startInterview creates a session of two challenges and starts it,
+// returning its ID and the candidate's key
+func startInterview(t *testing.T, is *InterviewService) (string, string) {
+	t.Helper()
+	challenges := []InterviewChallenge{{ChallengeID: 1, Title: "One"}, {ChallengeID: 2, Title: "Two"}}
+	_, token, err := is.Create("bob", "Jane", challenges, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, key, err := is.Start(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session.ID, key
+}
+
+func TestInterviewAttemptLimits(t *testing.T) {
+	is, err := NewInterviewService(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, key := startInterview(t, is)
+
+	for i := 0; i < MaxInterviewAttempts; i++ {
+		if _, err := is.AcceptAttempt(id, key, 0); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+		if err := is.RecordAttempt(id, InterviewAttempt{Challenge: 0, Kind: AttemptRun, Code: "package main"}); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+	if _, err := is.AcceptAttempt(id, key, 0); err != ErrTooManyAttempts {
+		t.Errorf("attempt past the limit: %v, want %v", err, ErrTooManyAttempts)
+	}
+	if err := is.RecordAttempt(id, InterviewAttempt{Challenge: 0, Kind: AttemptRun}); err != ErrTooManyAttempts {
+		t.Errorf("recording an attempt past the limit: %v, want %v", err, ErrTooManyAttempts)
+	}
+	if _, err := is.AcceptAttempt(id, key, 1); err != nil {
+		t.Errorf("attempt on another challenge: %v", err)
+	}
+
+	large := strings.Repeat("x", MaxCodeBytes+1)
+	if err := is.RecordAttempt(id, InterviewAttempt{Challenge: 1, Kind: AttemptRun, Code: large}); err != ErrInterviewCodeTooLarge {
+		t.Errorf("recording too much code: %v, want %v", err, ErrInterviewCodeTooLarge)
+	}
+	if err := is.SaveDraft(id, key, 1, large); err != ErrInterviewCodeTooLarge {
+		t.Errorf("saving too much code: %v, want %v", err, ErrInterviewCodeTooLarge)
+	}
+
+	// Only the start of a long output is kept
+	result := &ExecutionResult{Output: strings.Repeat("y", 2*maxAttemptOutput)}
+	if err := is.RecordAttempt(id, InterviewAttempt{Challenge: 1, Kind: AttemptSubmit, Result: result}); err != nil {
+		t.Fatal(err)
+	}
+	session, _ := is.Session(id)
+	kept := session.Attempts[len(session.Attempts)-1].Result.Output
+	if len(kept) > maxAttemptOutput+100 || !strings.HasSuffix(kept, "[output truncated]") {
+		t.Errorf("kept %d bytes of output, want at most %d and a note", len(kept), maxAttemptOutput)
+	}
+	if len(result.Output) != 2*maxAttemptOutput {
+		t.Errorf("the attempt's own result was changed")
+	}
+}
+
+func TestInterviewDraftUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	is, err := NewInterviewService(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, key := startInterview(t, is)
+	if err := is.SaveDraft(id, key, 0, "package main"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Saving the same code again does not rewrite the session
+	path := filepath.Join(dir, InterviewsDirName, id+".json")
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := is.SaveDraft(id, key, 0, "package main"); err != nil {
+		t.Fatal(err)
+	}
+	if after, err := os.Stat(path); err != nil || !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("saving the same draft rewrote the session")
+	}
+}
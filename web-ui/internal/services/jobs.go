@@ -0,0 +1,346 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMaxQueuedPerUser bounds how many jobs one user can have waiting
+	defaultMaxQueuedPerUser = 5
+	// jobRetention is how long finished jobs stay available for polling
+	jobRetention = time.Hour
+)
+
+// ErrJobQueueFull is returned when a user already has too many queued jobs
+var ErrJobQueueFull = errors.New("too many queued jobs, wait for earlier runs to finish")
+
+// JobStatus describes where a job is in its lifecycle
+type JobStatus string
+
+const (
+	JobQueued   JobStatus = "queued"
+	JobRunning  JobStatus = "running"
+	JobFinished JobStatus = "finished"
+	JobCanceled JobStatus = "canceled"
+)
+
+// JobFunc performs the work of a job. It reports output lines through
+// onOutput, must stop when ctx is canceled and returns the job's result.
+type JobFunc func(ctx context.Context, onOutput OutputFunc) interface{}
+
+// JobLine is one line of output produced by a job
+type JobLine struct {
+	Seq   int    `json:"seq"`
+	Phase string `json:"phase"`
+	Text  string `json:"text"`
+}
+
+// Job is a unit of work executed by the JobService. ID, Kind and Owner never
+// change; use Snapshot for the rest.
+type Job struct {
+	ID    string
+	Kind  string // e.g. "run" or "submission"
+	Owner string // User the job is queued for
+
+	mu         sync.Mutex
+	status     JobStatus
+	createdAt  time.Time
+	startedAt  *time.Time
+	finishedAt *time.Time
+	result     interface{}
+	run        JobFunc
+	cancel     context.CancelFunc
+	lines      []JobLine
+	changed    chan struct{} // Closed and replaced whenever the job changes
+	done       chan struct{} // Closed once the job finished or was canceled
+}
+
+// JobSnapshot is a consistent copy of a job's state
+type JobSnapshot struct {
+	ID         string      `json:"id"`
+	Kind       string      `json:"kind"`
+	Owner      string      `json:"owner"`
+	Status     JobStatus   `json:"status"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	StartedAt  *time.Time  `json:"startedAt,omitempty"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+	Lines      int         `json:"lines"` // Number of output lines so far
+	Result     interface{} `json:"result,omitempty"`
+}
+
+// Snapshot returns the current state of the job
+func (j *Job) Snapshot() JobSnapshot {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return JobSnapshot{
+		ID:         j.ID,
+		Kind:       j.Kind,
+		Owner:      j.Owner,
+		Status:     j.status,
+		CreatedAt:  j.createdAt,
+		StartedAt:  j.startedAt,
+		FinishedAt: j.finishedAt,
+		Lines:      len(j.lines),
+		Result:     j.result,
+	}
+}
+
+// Follow returns the output lines from index from on, whether the job is
+// over, and a channel that is closed on the next change
+func (j *Job) Follow(from int) ([]JobLine, bool, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var lines []JobLine
+	if from < len(j.lines) {
+		lines = append(lines, j.lines[from:]...)
+	}
+	return lines, j.isOver(), j.changed
+}
+
+// Done is closed once the job finished or was canceled
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// isOver reports whether the job has its final status and result; j.mu must
+// be held
+func (j *Job) isOver() bool {
+	return j.finishedAt != nil
+}
+
+// notify wakes everyone following the job; j.mu must be held
+func (j *Job) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// appendLine records an output line
+func (j *Job) appendLine(phase, text string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.lines = append(j.lines, JobLine{Seq: len(j.lines), Phase: phase, Text: text})
+	j.notify()
+}
+
+// JobService runs jobs on a bounded pool of workers. Each owner has their own
+// queue and workers take jobs from the owners in turn, so one user submitting
+// many runs cannot starve everyone else.
+type JobService struct {
+	mu               sync.Mutex
+	wake             *sync.Cond
+	jobs             map[string]*Job
+	queues           map[string][]*Job
+	owners           []string // Owners with queued jobs, in round-robin order
+	maxQueuedPerUser int
+}
+
+// NewJobService creates a job service and starts its workers
+func NewJobService(workers int) *JobService {
+	if workers < 1 {
+		workers = 1
+	}
+	js := &JobService{
+		jobs:             make(map[string]*Job),
+		queues:           make(map[string][]*Job),
+		maxQueuedPerUser: defaultMaxQueuedPerUser,
+	}
+	js.wake = sync.NewCond(&js.mu)
+	for i := 0; i < workers; i++ {
+		go js.worker()
+	}
+	return js
+}
+
+// Submit queues a job for owner and returns it immediately
+func (js *JobService) Submit(owner, kind string, run JobFunc) (*Job, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	js.pruneLocked()
+	if len(js.queues[owner]) >= js.maxQueuedPerUser {
+		return nil, ErrJobQueueFull
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{
+		ID:        id,
+		Kind:      kind,
+		Owner:     owner,
+		status:    JobQueued,
+		createdAt: time.Now(),
+		run:       run,
+		changed:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	js.jobs[id] = job
+
+	if len(js.queues[owner]) == 0 {
+		js.owners = append(js.owners, owner)
+	}
+	js.queues[owner] = append(js.queues[owner], job)
+	js.wake.Signal()
+
+	return job, nil
+}
+
+// GetJob returns a job by ID
+func (js *JobService) GetJob(id string) (*Job, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	job, ok := js.jobs[id]
+	return job, ok
+}
+
+// Cancel removes a queued job or kills a running one. It reports false when
+// the job was already over.
+func (js *JobService) Cancel(job *Job) bool {
+	js.mu.Lock()
+	queued := js.removeQueuedLocked(job)
+	js.mu.Unlock()
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.isOver() || job.status == JobCanceled {
+		return false
+	}
+	if queued {
+		// Never reached a worker, so nobody else finishes it
+		job.finishLocked(JobCanceled, nil)
+		return true
+	}
+	// The worker finishes the job once the run has stopped
+	job.status = JobCanceled
+	job.notify()
+	if job.cancel != nil {
+		job.cancel()
+	}
+	return true
+}
+
+// worker executes queued jobs until the process exits
+func (js *JobService) worker() {
+	for {
+		job := js.next()
+		js.execute(job)
+	}
+}
+
+// next blocks until a job is queued and takes it from the next owner in turn
+func (js *JobService) next() *Job {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	for len(js.owners) == 0 {
+		js.wake.Wait()
+	}
+
+	owner := js.owners[0]
+	js.owners = js.owners[1:]
+	queue := js.queues[owner]
+	job := queue[0]
+	if len(queue) > 1 {
+		js.queues[owner] = queue[1:]
+		js.owners = append(js.owners, owner)
+	} else {
+		delete(js.queues, owner)
+	}
+	return job
+}
+
+// execute runs a job taken from the queue
+func (js *JobService) execute(job *Job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	job.mu.Lock()
+	if job.status == JobCanceled {
+		// Canceled between leaving the queue and starting
+		job.finishLocked(JobCanceled, nil)
+		job.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	job.status = JobRunning
+	job.startedAt = &now
+	job.cancel = cancel
+	job.notify()
+	job.mu.Unlock()
+
+	result := job.run(ctx, job.appendLine)
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	status := JobFinished
+	if job.status == JobCanceled {
+		status = JobCanceled
+	}
+	job.finishLocked(status, result)
+}
+
+// finishLocked moves the job to its final status; j.mu must be held
+func (j *Job) finishLocked(status JobStatus, result interface{}) {
+	now := time.Now()
+	j.status = status
+	j.finishedAt = &now
+	j.result = result
+	j.run = nil
+	j.cancel = nil
+	j.notify()
+	close(j.done)
+}
+
+// removeQueuedLocked takes a job out of its owner's queue; js.mu must be held
+func (js *JobService) removeQueuedLocked(job *Job) bool {
+	queue := js.queues[job.Owner]
+	for i, queued := range queue {
+		if queued != job {
+			continue
+		}
+		queue = append(queue[:i:i], queue[i+1:]...)
+		if len(queue) > 0 {
+			js.queues[job.Owner] = queue
+			return true
+		}
+		delete(js.queues, job.Owner)
+		for k, owner := range js.owners {
+			if owner == job.Owner {
+				js.owners = append(js.owners[:k:k], js.owners[k+1:]...)
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// pruneLocked forgets jobs that finished longer than jobRetention ago; js.mu
+// must be held
+func (js *JobService) pruneLocked() {
+	cutoff := time.Now().Add(-jobRetention)
+	for id, job := range js.jobs {
+		job.mu.Lock()
+		expired := job.finishedAt != nil && job.finishedAt.Before(cutoff)
+		job.mu.Unlock()
+		if expired {
+			delete(js.jobs, id)
+		}
+	}
+}
+
+// newJobID returns a random, unguessable job ID
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
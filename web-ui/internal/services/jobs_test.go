@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockWorker submits a job that occupies the only worker of js until the
+// returned function is called
+func blockWorker(t *testing.T, js *JobService) func() {
+	t.Helper()
+	started, release := make(chan struct{}), make(chan struct{})
+	_, err := js.Submit("blocker", "run", func(ctx context.Context, onOutput OutputFunc) interface{} {
+		close(started)
+		<-release
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	return func() { close(release) }
+}
+
+// waitJob waits for a job to be over
+func waitJob(t *testing.T, job *Job) JobSnapshot {
+	t.Helper()
+	select {
+	case <-job.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("job %s did not finish", job.ID)
+	}
+	return job.Snapshot()
+}
+
+func TestJobServiceRoundRobin(t *testing.T) {
+	tests := []struct {
+		name   string
+		submit string // Jobs in submission order, named by owner and number
+		want   string // Jobs in execution order
+	}{
+		{"one owner", "a1 a2 a3", "a1 a2 a3"},
+		{"two owners", "a1 a2 a3 b1 b2", "a1 b1 a2 b2 a3"},
+		{"three owners", "a1 a2 b1 c1 c2 c3", "a1 b1 c1 a2 c2 c3"},
+		{"interleaved", "a1 b1 a2 b2 a3", "a1 b1 a2 b2 a3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			js := NewJobService(1)
+			release := blockWorker(t, js)
+
+			var mu sync.Mutex
+			var order []string
+			var jobs []*Job
+			for _, name := range strings.Fields(tt.submit) {
+				name := name
+				job, err := js.Submit(name[:1], "run", func(ctx context.Context, onOutput OutputFunc) interface{} {
+					mu.Lock()
+					order = append(order, name)
+					mu.Unlock()
+					return name
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				jobs = append(jobs, job)
+			}
+			release()
+
+			for _, job := range jobs {
+				if snapshot := waitJob(t, job); snapshot.Status != JobFinished || snapshot.Result == nil {
+					t.Errorf("job of %s: %+v", job.Owner, snapshot)
+				}
+			}
+			if got := strings.Join(order, " "); got != tt.want {
+				t.Errorf("ran %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobServiceQueueLimit(t *testing.T) {
+	js := NewJobService(1)
+	release := blockWorker(t, js)
+	defer release()
+
+	noop := func(ctx context.Context, onOutput OutputFunc) interface{} { return nil }
+	for i := 0; i < defaultMaxQueuedPerUser; i++ {
+		if _, err := js.Submit("alice", "run", noop); err != nil {
+			t.Fatalf("job %d: %v", i+1, err)
+		}
+	}
+	if _, err := js.Submit("alice", "run", noop); err != ErrJobQueueFull {
+		t.Errorf("job past the limit: %v, want %v", err, ErrJobQueueFull)
+	}
+	if _, err := js.Submit("bob", "run", noop); err != nil {
+		t.Errorf("another owner's job: %v", err)
+	}
+}
+
+func TestJobServiceCancel(t *testing.T) {
+	js := NewJobService(1)
+	release := blockWorker(t, js)
+
+	ran := make(chan string, 3)
+	running := make(chan struct{})
+	active, err := js.Submit("alice", "run", func(ctx context.Context, onOutput OutputFunc) interface{} {
+		ran <- "running"
+		onOutput(PhaseTest, "started")
+		close(running)
+		<-ctx.Done()
+		return "stopped"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, err := js.Submit("alice", "run", func(ctx context.Context, onOutput OutputFunc) interface{} {
+		ran <- "queued"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A queued job leaves the queue and never runs
+	if !js.Cancel(queued) {
+		t.Fatal("canceling a queued job failed")
+	}
+	if snapshot := waitJob(t, queued); snapshot.Status != JobCanceled || snapshot.StartedAt != nil {
+		t.Errorf("canceled queued job: %+v", snapshot)
+	}
+	if js.Cancel(queued) {
+		t.Errorf("canceled a job twice")
+	}
+
+	// A running job has its context canceled and keeps its result
+	release()
+	<-running
+	lines, over, _ := active.Follow(0)
+	if over || len(lines) != 1 || lines[0].Text != "started" || lines[0].Phase != PhaseTest {
+		t.Errorf("lines of the running job = %+v, over %v", lines, over)
+	}
+	if !js.Cancel(active) {
+		t.Fatal("canceling a running job failed")
+	}
+	snapshot := waitJob(t, active)
+	if snapshot.Status != JobCanceled || snapshot.Result != "stopped" || snapshot.Lines != 1 {
+		t.Errorf("canceled running job: %+v", snapshot)
+	}
+	if _, over, _ := active.Follow(1); !over {
+		t.Errorf("canceled job is not over")
+	}
+
+	close(ran)
+	var got []string
+	for name := range ran {
+		got = append(got, name)
+	}
+	if !reflect.DeepEqual(got, []string{"running"}) {
+		t.Errorf("ran %v, want only the running job", got)
+	}
+	if _, ok := js.GetJob(queued.ID); !ok {
+		t.Errorf("canceled job is gone before its retention ended")
+	}
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"web-ui/internal/models"
+)
+
+// MetadataFileName is the optional file describing a challenge, in the
+// format of models.ChallengeMetadata
+const MetadataFileName = "metadata.json"
+
+// Difficulties are the difficulty levels a challenge can have, easiest first
+var Difficulties = []string{"Beginner", "Intermediate", "Advanced"}
+
+// MetadataProblem is a field of a metadata.json that is missing or invalid
+type MetadataProblem struct {
+	Field   string // e.g. "difficulty", "tags[1]" or "benchmark.pairs[0].baseline"
+	Message string // e.g. "is required"
+
+	path    fieldPath
+	missing bool // Field is a required field that is missing
+}
+
+// String describes the problem, e.g. "difficulty: is required"
+func (p MetadataProblem) String() string {
+	return p.Field + ": " + p.Message
+}
+
+// schema describes a JSON value with the subset of JSON Schema metadata.json
+// needs
+type schema struct {
+	Type        string             // "object", "array", "string", "integer", "number" or "boolean"
+	Properties  map[string]*schema // Objects; other properties are rejected
+	Required    []string           // Objects
+	Items       *schema            // Arrays
+	Enum        []string           // Strings
+	MinLength   int                // Strings
+	NonNegative bool               // Integers and numbers
+}
+
+var (
+	nonEmptyString    = &schema{Type: "string", MinLength: 1}
+	stringList        = &schema{Type: "array", Items: nonEmptyString}
+	nonNegativeInt    = &schema{Type: "integer", NonNegative: true}
+	nonNegativeNumber = &schema{Type: "number", NonNegative: true}
+)
+
+// challengeMetadataSchema is the schema of a classic challenge's metadata.json.
+// It matches models.ChallengeMetadata, which package challenges use as well.
+var challengeMetadataSchema = &schema{
+	Type:     "object",
+	Required: []string{"title", "difficulty"},
+	Properties: map[string]*schema{
+		"title":                 nonEmptyString,
+		"description":           {Type: "string"},
+		"short_description":     {Type: "string"},
+		"difficulty":            {Type: "string", Enum: Difficulties},
+		"estimated_time":        nonEmptyString,
+		"learning_objectives":   stringList,
+		"prerequisites":         stringList,
+		"tags":                  stringList,
+		"real_world_connection": {Type: "string"},
+		"requirements":          stringList,
+		"bonus_points":          stringList,
+		"icon":                  {Type: "string"},
+		"order":                 nonNegativeInt,
+		"execution": {
+			Type: "object",
+			Properties: map[string]*schema{
+				"timeout_seconds":  nonNegativeInt,
+				"cpu_seconds":      nonNegativeInt,
+				"memory_mb":        nonNegativeInt,
+				"max_processes":    nonNegativeInt,
+				"max_file_size_mb": nonNegativeInt,
+				"max_output_kb":    nonNegativeInt,
+				"race":             {Type: "boolean"},
+				"count":            nonNegativeInt,
+			},
+		},
+		"benchmark": {
+			Type:     "object",
+			Required: []string{"pairs"},
+			Properties: map[string]*schema{
+				"count":     nonNegativeInt,
+				"benchtime": nonEmptyString,
+				"pairs": {
+					Type: "array",
+					Items: &schema{
+						Type:     "object",
+						Required: []string{"baseline", "optimized"},
+						Properties: map[string]*schema{
+							"baseline":          nonEmptyString,
+							"optimized":         nonEmptyString,
+							"min_speedup":       nonNegativeNumber,
+							"max_bytes_per_op":  nonNegativeInt,
+							"max_allocs_per_op": nonNegativeInt,
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+// ParseChallengeMetadata validates the content of a metadata.json against
+// the challenge metadata schema and decodes it. A field with problems is left
+// out of the metadata, as if it was missing, so the challenge falls back to
+// its default for it: a bad tag drops the tag, a bad benchmark threshold the
+// threshold. An object that misses a required field, such as a benchmark pair
+// without a baseline, is left out as a whole. An error means the content is
+// not a JSON object.
+func ParseChallengeMetadata(data []byte) (*models.ChallengeMetadata, []MetadataProblem, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, nil, err
+	}
+	if _, ok := value.(map[string]interface{}); !ok {
+		return nil, nil, fmt.Errorf("metadata must be a JSON object")
+	}
+
+	problems := challengeMetadataSchema.validate(nil, value)
+	for _, problem := range problems {
+		path := problem.path
+		if problem.missing {
+			// The object without the field is left out as a whole
+			path = path[:len(path)-1]
+		}
+		markDropped(value, path)
+	}
+
+	valid, err := json.Marshal(withoutDropped(value))
+	if err != nil {
+		return nil, nil, err
+	}
+	var metadata models.ChallengeMetadata
+	if err := json.Unmarshal(valid, &metadata); err != nil {
+		return nil, nil, err
+	}
+	return &metadata, problems, nil
+}
+
+// validate checks value, found at path, against the schema
+func (s *schema) validate(path fieldPath, value interface{}) []MetadataProblem {
+	problem := func(format string, args ...interface{}) []MetadataProblem {
+		return []MetadataProblem{{Field: path.String(), Message: fmt.Sprintf(format, args...), path: path}}
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return problem("must be an object")
+		}
+		var problems []MetadataProblem
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				field := path.with(name)
+				problems = append(problems, MetadataProblem{Field: field.String(), Message: "is required", path: field, missing: true})
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			field := path.with(name)
+			property, known := s.Properties[name]
+			if !known {
+				problems = append(problems, MetadataProblem{Field: field.String(), Message: "is not a known field", path: field})
+				continue
+			}
+			problems = append(problems, property.validate(field, object[name])...)
+		}
+		return problems
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return problem("must be an array")
+		}
+		var problems []MetadataProblem
+		for i, item := range items {
+			problems = append(problems, s.Items.validate(path.with(i), item)...)
+		}
+		return problems
+
+	case "string":
+		text, ok := value.(string)
+		switch {
+		case !ok:
+			return problem("must be a string")
+		case len(strings.TrimSpace(text)) < s.MinLength:
+			return problem("must not be empty")
+		case len(s.Enum) > 0 && !containsString(s.Enum, text):
+			return problem("must be one of %s, not %q", strings.Join(s.Enum, ", "), text)
+		}
+		return nil
+
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return problem("must be a number")
+		}
+		if s.Type == "integer" {
+			if _, err := strconv.ParseInt(number.String(), 10, 64); err != nil {
+				return problem("must be a whole number")
+			}
+		}
+		parsed, err := number.Float64()
+		if err != nil {
+			return problem("must be a number")
+		}
+		if s.NonNegative && parsed < 0 {
+			return problem("must not be negative")
+		}
+		return nil
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return problem("must be true or false")
+		}
+		return nil
+	}
+	return problem("has an unknown schema type %q", s.Type)
+}
+
+// droppedValue takes the place of a value with problems until withoutDropped
+// leaves it out
+type droppedValue struct{}
+
+// fieldPath locates a value in metadata.json by property names and array
+// indexes
+type fieldPath []interface{}
+
+// with returns the path of a property (string) or array item (int) of the
+// value at p
+func (p fieldPath) with(step interface{}) fieldPath {
+	return append(append(fieldPath{}, p...), step)
+}
+
+// String writes the path as e.g. "benchmark.pairs[0].baseline"
+func (p fieldPath) String() string {
+	var b strings.Builder
+	for _, step := range p {
+		switch step := step.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", step)
+		case string:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(step)
+		}
+	}
+	return b.String()
+}
+
+// markDropped replaces the value at path inside root with a droppedValue. The
+// root itself is never dropped, nor is anything inside a dropped value.
+func markDropped(root interface{}, path fieldPath) {
+	if len(path) == 0 {
+		return
+	}
+	parent := root
+	for _, step := range path[:len(path)-1] {
+		switch container := parent.(type) {
+		case map[string]interface{}:
+			parent = container[step.(string)]
+		case []interface{}:
+			parent = container[step.(int)]
+		default:
+			return
+		}
+	}
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		container[path[len(path)-1].(string)] = droppedValue{}
+	case []interface{}:
+		container[path[len(path)-1].(int)] = droppedValue{}
+	}
+}
+
+// withoutDropped returns value with the properties and array items that were
+// marked dropped left out
+func withoutDropped(value interface{}) interface{} {
+	switch container := value.(type) {
+	case map[string]interface{}:
+		for name, property := range container {
+			if _, ok := property.(droppedValue); ok {
+				delete(container, name)
+				continue
+			}
+			container[name] = withoutDropped(property)
+		}
+	case []interface{}:
+		kept := make([]interface{}, 0, len(container))
+		for _, item := range container {
+			if _, ok := item.(droppedValue); !ok {
+				kept = append(kept, withoutDropped(item))
+			}
+		}
+		return kept
+	}
+	return value
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
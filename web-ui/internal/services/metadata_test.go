@@ -0,0 +1,143 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"web-ui/internal/models"
+)
+
+func TestParseChallengeMetadata(t *testing.T) {
+	maxAllocs := int64(0)
+	tests := []struct {
+		name     string
+		data     string
+		metadata *models.ChallengeMetadata
+		problems []string
+	}{
+		{
+			name: "valid",
+			data: `{
+				"title": "Sum",
+				"difficulty": "Beginner",
+				"tags": ["math", "basics"],
+				"order": 1,
+				"execution": {"timeout_seconds": 20, "race": true},
+				"benchmark": {"count": 3, "pairs": [{"baseline": "BenchmarkSlow", "optimized": "BenchmarkFast", "min_speedup": 2, "max_allocs_per_op": 0}]}
+			}`,
+			metadata: &models.ChallengeMetadata{
+				Title:      "Sum",
+				Difficulty: "Beginner",
+				Tags:       []string{"math", "basics"},
+				Order:      1,
+				Execution:  &models.ExecutionConfig{TimeoutSeconds: 20, Race: true},
+				Benchmark: &models.BenchmarkConfig{Count: 3, Pairs: []models.BenchmarkPair{
+					{Baseline: "BenchmarkSlow", Optimized: "BenchmarkFast", MinSpeedup: 2, MaxAllocsPerOp: &maxAllocs},
+				}},
+			},
+		},
+		{
+			name:     "missing title",
+			data:     `{"difficulty": "Advanced"}`,
+			metadata: &models.ChallengeMetadata{Difficulty: "Advanced"},
+			problems: []string{"title: is required"},
+		},
+		{
+			name:     "unknown field",
+			data:     `{"title": "Sum", "difficulty": "Beginner", "author": "someone"}`,
+			metadata: &models.ChallengeMetadata{Title: "Sum", Difficulty: "Beginner"},
+			problems: []string{"author: is not a known field"},
+		},
+		{
+			name:     "bad difficulty and order",
+			data:     `{"title": "Sum", "difficulty": "Expert", "order": -1}`,
+			metadata: &models.ChallengeMetadata{Title: "Sum"},
+			problems: []string{
+				`difficulty: must be one of Beginner, Intermediate, Advanced, not "Expert"`,
+				"order: must not be negative",
+			},
+		},
+		{
+			name:     "empty tag",
+			data:     `{"title": "Sum", "difficulty": "Beginner", "tags": ["math", " ", "basics"]}`,
+			metadata: &models.ChallengeMetadata{Title: "Sum", Difficulty: "Beginner", Tags: []string{"math", "basics"}},
+			problems: []string{"tags[1]: must not be empty"},
+		},
+		{
+			name:     "bad execution limit",
+			data:     `{"title": "Sum", "difficulty": "Beginner", "execution": {"memory_mb": "lots", "timeout_seconds": 5, "count": 1.5}}`,
+			metadata: &models.ChallengeMetadata{Title: "Sum", Difficulty: "Beginner", Execution: &models.ExecutionConfig{TimeoutSeconds: 5}},
+			problems: []string{"execution.count: must be a whole number", "execution.memory_mb: must be a number"},
+		},
+		{
+			// Only the threshold is left out, not the pair or the benchmark
+			name: "bad benchmark threshold",
+			data: `{"title": "Sum", "difficulty": "Beginner", "benchmark": {"count": 2, "pairs": [
+				{"baseline": "BenchmarkA", "optimized": "BenchmarkB", "min_speedup": -2},
+				{"baseline": "BenchmarkC", "optimized": "BenchmarkD", "min_speedup": 1.5}
+			]}}`,
+			metadata: &models.ChallengeMetadata{Title: "Sum", Difficulty: "Beginner", Benchmark: &models.BenchmarkConfig{Count: 2, Pairs: []models.BenchmarkPair{
+				{Baseline: "BenchmarkA", Optimized: "BenchmarkB"},
+				{Baseline: "BenchmarkC", Optimized: "BenchmarkD", MinSpeedup: 1.5},
+			}}},
+			problems: []string{"benchmark.pairs[0].min_speedup: must not be negative"},
+		},
+		{
+			// A pair without a benchmark to compare is left out as a whole
+			name: "incomplete benchmark pair",
+			data: `{"title": "Sum", "difficulty": "Beginner", "benchmark": {"pairs": [
+				{"baseline": "BenchmarkA", "min_speedup": -2},
+				{"baseline": "BenchmarkC", "optimized": "BenchmarkD"}
+			]}}`,
+			metadata: &models.ChallengeMetadata{Title: "Sum", Difficulty: "Beginner", Benchmark: &models.BenchmarkConfig{Pairs: []models.BenchmarkPair{
+				{Baseline: "BenchmarkC", Optimized: "BenchmarkD"},
+			}}},
+			problems: []string{"benchmark.pairs[0].optimized: is required", "benchmark.pairs[0].min_speedup: must not be negative"},
+		},
+		{
+			name:     "benchmark without pairs",
+			data:     `{"title": "Sum", "difficulty": "Beginner", "benchmark": {"count": 2}}`,
+			metadata: &models.ChallengeMetadata{Title: "Sum", Difficulty: "Beginner"},
+			problems: []string{"benchmark.pairs: is required"},
+		},
+		{
+			// Names that look like paths are plain property names
+			name:     "odd field names",
+			data:     `{"title": "Sum", "difficulty": "Beginner", "tags.x": 1, "tags[5]": 2, "execution": {"a.b": true}}`,
+			metadata: &models.ChallengeMetadata{Title: "Sum", Difficulty: "Beginner", Execution: &models.ExecutionConfig{}},
+			problems: []string{"execution.a.b: is not a known field", "tags.x: is not a known field", "tags[5]: is not a known field"},
+		},
+		{
+			name:     "wrong types",
+			data:     `{"title": 7, "difficulty": "Beginner", "tags": "math", "execution": []}`,
+			metadata: &models.ChallengeMetadata{Difficulty: "Beginner"},
+			problems: []string{"execution: must be an object", "tags: must be an array", "title: must be a string"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata, problems, err := ParseChallengeMetadata([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(metadata, tt.metadata) {
+				t.Errorf("metadata = %+v\nwant %+v", metadata, tt.metadata)
+			}
+			var got []string
+			for _, problem := range problems {
+				got = append(got, problem.String())
+			}
+			if !reflect.DeepEqual(got, tt.problems) {
+				t.Errorf("problems = %q\nwant %q", got, tt.problems)
+			}
+		})
+	}
+}
+
+func TestParseChallengeMetadataInvalid(t *testing.T) {
+	for _, data := range []string{``, `{"title": "Sum",`, `["title"]`, `"Sum"`, `null`} {
+		if metadata, _, err := ParseChallengeMetadata([]byte(data)); err == nil {
+			t.Errorf("ParseChallengeMetadata(%q) = %+v, want an error", data, metadata)
+		}
+	}
+}
@@ -1,32 +1,61 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"web-ui/internal/models"
 )
 
 type PackageService struct {
-	httpClient   *http.Client
 	packagesPath string
+	githubStars  bool // RefreshStars fetches the star counts from GitHub
+	stars        *starStore
+
+	// The package catalog is a snapshot that LoadPackages replaces as a
+	// whole, with the challenges of every package; nil until the first
+	// load. Packages and challenges handed out must not be modified.
+	mu         sync.RWMutex
+	packages   map[string]*models.Package
+	challenges map[string]map[string]*models.PackageChallenge // By package, then challenge ID
 }
 
-func NewPackageService() *PackageService {
+func NewPackageService(packagesPath string) *PackageService {
 	return &PackageService{
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		packagesPath: "../packages", // Relative to web-ui directory
+		packagesPath: packagesPath,
+		githubStars:  true,
+		stars:        newStarStore(""),
 	}
 }
 
+// SetGitHubStars selects whether RefreshStars fetches the star counts from
+// GitHub; without it, the last known counts or else those in package.json
+// are shown
+func (s *PackageService) SetGitHubStars(enabled bool) {
+	s.githubStars = enabled
+}
+
+// SetStarsFile keeps the last known star counts in a file, e.g.
+// StarsFileName in the data directory, and uses the counts already in it
+func (s *PackageService) SetStarsFile(path string) {
+	s.stars = newStarStore(path)
+}
+
+// ChallengeDir returns the directory of a package challenge, which may not
+// exist
+func (s *PackageService) ChallengeDir(packageID, challengeID string) string {
+	return filepath.Join(s.packagesPath, packageID, challengeID)
+}
+
 type PackageMetadata struct {
 	Name             string   `json:"name"`
 	DisplayName      string   `json:"display_name"`
@@ -44,22 +73,46 @@ type PackageMetadata struct {
 	RealWorldUsage   []string `json:"real_world_usage"`
 }
 
+// LoadPackages reads the package catalog with the challenges of every
+// package and swaps it in for the one GetPackages returns. It does not go to
+// the network; the packages have the last known star counts.
 func (s *PackageService) LoadPackages() error {
-	// This method is called to ensure packages are loaded
-	// Load packages and count them for logging
-	packages := s.GetPackages()
-	fmt.Printf("Loaded %d packages with real-time GitHub stars\n", len(packages))
+	s.mu.RLock()
+	previous, previousChallenges := s.packages, s.challenges
+	s.mu.RUnlock()
+
+	packages, challenges := s.readPackages(previous, previousChallenges)
+	s.mu.Lock()
+	s.packages, s.challenges = packages, challenges
+	s.mu.Unlock()
+	fmt.Printf("Loaded %d packages\n", len(packages))
 	return nil
 }
 
+// GetPackages returns the package catalog loaded last, or reads it if it was
+// never loaded
 func (s *PackageService) GetPackages() map[string]*models.Package {
+	s.mu.RLock()
+	packages := s.packages
+	s.mu.RUnlock()
+	if packages != nil {
+		return packages
+	}
+	packages, _ = s.readPackages(nil, nil)
+	return packages
+}
+
+// readPackages reads every package and its challenges from the filesystem.
+// A package that cannot be read keeps its version in previous, if any.
+func (s *PackageService) readPackages(previous map[string]*models.Package, previousChallenges map[string]map[string]*models.PackageChallenge) (map[string]*models.Package, map[string]map[string]*models.PackageChallenge) {
 	packages := make(map[string]*models.Package)
+	challenges := make(map[string]map[string]*models.PackageChallenge)
 
 	// Read packages directory
 	entries, err := os.ReadDir(s.packagesPath)
 	if err != nil {
 		fmt.Printf("Error reading packages directory: %v\n", err)
-		return packages
+		return packages, challenges
 	}
 
 	for _, entry := range entries {
@@ -67,21 +120,97 @@ func (s *PackageService) GetPackages() map[string]*models.Package {
 			packagePath := filepath.Join(s.packagesPath, entry.Name())
 			if pkg := s.loadPackage(packagePath, entry.Name()); pkg != nil {
 				packages[pkg.Name] = pkg
+				challenges[pkg.Name] = s.readChallenges(packagePath, pkg.Name)
+			} else if old, ok := previous[entry.Name()]; ok {
+				packages[old.Name] = old
+				challenges[old.Name] = previousChallenges[old.Name]
 			}
 		}
 	}
 
-	return packages
+	return packages, challenges
 }
 
-func (s *PackageService) loadPackage(packagePath, packageName string) *models.Package {
-	// Ensure httpClient is initialized
-	if s.httpClient == nil {
-		s.httpClient = &http.Client{
-			Timeout: 30 * time.Second,
+// readChallenges reads the challenges of a package by ID
+func (s *PackageService) readChallenges(packagePath, packageID string) map[string]*models.PackageChallenge {
+	challenges := make(map[string]*models.PackageChallenge)
+	for _, challenge := range s.loadChallenges(packagePath) {
+		// Create a copy to avoid the loop variable reference issue
+		challengeCopy := challenge
+		challengeCopy.PackageName = packageID
+		challenges[challenge.ID] = &challengeCopy
+	}
+	return challenges
+}
+
+// RefreshStars fetches the star counts of the loaded packages from GitHub,
+// unless SetGitHubStars turned that off, and swaps in a catalog with the new
+// counts. Pages keep showing the last known counts in the meantime.
+func (s *PackageService) RefreshStars(ctx context.Context) error {
+	if !s.githubStars {
+		return nil
+	}
+	var repos []string
+	for _, pkg := range s.GetPackages() {
+		if repo, ok := githubRepo(pkg.GitHubURL); ok {
+			repos = append(repos, repo)
+		}
+	}
+	sort.Strings(repos)
+
+	changed, err := s.stars.refresh(ctx, repos)
+	if changed {
+		s.applyStars()
+	}
+	return err
+}
+
+// WatchStars refreshes the star counts now and then every interval until ctx
+// is canceled. Without an interval, they are refreshed once.
+func (s *PackageService) WatchStars(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		if err := s.RefreshStars(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("GitHub stars not refreshed: %v", err)
+		}
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.RefreshStars(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("GitHub stars not refreshed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// applyStars swaps in a catalog whose packages have the last known star
+// counts
+func (s *PackageService) applyStars() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.packages == nil {
+		return
+	}
+	packages := make(map[string]*models.Package, len(s.packages))
+	for name, pkg := range s.packages {
+		packages[name] = pkg
+		if repo, ok := githubRepo(pkg.GitHubURL); ok {
+			if stars, ok := s.stars.get(repo); ok && stars != pkg.Stars {
+				updated := *pkg
+				updated.Stars = stars
+				packages[name] = &updated
+			}
 		}
 	}
+	s.packages = packages
+}
 
+func (s *PackageService) loadPackage(packagePath, packageName string) *models.Package {
 	// Load package.json
 	metadataPath := filepath.Join(packagePath, "package.json")
 	metadataBytes, err := os.ReadFile(metadataPath)
@@ -96,10 +225,11 @@ func (s *PackageService) loadPackage(packagePath, packageName string) *models.Pa
 		return nil
 	}
 
-	// Fetch real-time GitHub stars
-	stars := s.fetchGitHubStars(metadata.GitHubURL)
-	if stars > 0 {
-		metadata.Stars = stars
+	// The last known GitHub stars beat the count in package.json
+	if repo, ok := githubRepo(metadata.GitHubURL); ok {
+		if stars, ok := s.stars.get(repo); ok {
+			metadata.Stars = stars
+		}
 	}
 
 	// Load challenge details dynamically
@@ -368,6 +498,12 @@ func (s *PackageService) loadChallenge(challengePath, challengeName string) *mod
 		}
 	}
 
+	// Execution limits are optional and fall back to ExecutionService defaults
+	var execution *models.ExecutionConfig
+	if metadata != nil {
+		execution = metadata.Execution
+	}
+
 	return &models.PackageChallenge{
 		ID:                challengeName,
 		Title:             title,
@@ -377,6 +513,8 @@ func (s *PackageService) loadChallenge(challengePath, challengeName string) *mod
 		TestFile:          testFile,
 		Hints:             hints,
 		LearningMaterials: learningMaterials, // Use learning.md for learning materials tab
+		Execution:         execution,
+		Dir:               challengePath,
 	}
 }
 
@@ -388,45 +526,21 @@ func (s *PackageService) readFileContent(filePath string) string {
 	return string(content)
 }
 
-func (s *PackageService) fetchGitHubStars(githubURL string) int {
-	// Add more robust nil checking
-	if s == nil || s.httpClient == nil || githubURL == "" {
-		return 0
-	}
-
-	// Extract owner/repo from GitHub URL
-	parts := strings.Split(githubURL, "/")
-	if len(parts) < 2 {
-		return 0
-	}
-
-	repo := fmt.Sprintf("%s/%s", parts[len(parts)-2], parts[len(parts)-1])
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s", repo)
-
-	resp, err := s.httpClient.Get(apiURL)
+// GetPackageNames returns the names of the package directories, sorted,
+// without loading their metadata
+func (s *PackageService) GetPackageNames() ([]string, error) {
+	entries, err := os.ReadDir(s.packagesPath)
 	if err != nil {
-		return 0
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == 403 {
-		fmt.Printf("GitHub API returned status 403 for %s\n", githubURL)
-		return 0
-	}
-
-	if resp.StatusCode != 200 {
-		return 0
-	}
-
-	var repoData struct {
-		StargazersCount int `json:"stargazers_count"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&repoData); err != nil {
-		return 0
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
 	}
-
-	return repoData.StargazersCount
+	return names, nil
 }
 
 func (s *PackageService) GetPackage(packageID string) (*models.Package, error) {
@@ -450,7 +564,32 @@ func (s *PackageService) GetChallenge(packageID, challengeID string) *models.Pac
 	return s.loadChallenge(challengePath, challengeID)
 }
 
+// catalogChallenges returns the loaded challenges of a package; loaded is
+// false before the catalog is loaded
+func (s *PackageService) catalogChallenges(packageID string) (challenges map[string]*models.PackageChallenge, exists, loaded bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.challenges == nil {
+		return nil, false, false
+	}
+	challenges, exists = s.challenges[packageID]
+	return challenges, exists, true
+}
+
+// GetPackageChallenges returns the challenges of a package by ID, from the
+// loaded catalog or else from the filesystem
 func (s *PackageService) GetPackageChallenges(packageID string) (map[string]*models.PackageChallenge, error) {
+	if loadedChallenges, exists, loaded := s.catalogChallenges(packageID); loaded {
+		if !exists {
+			return nil, fmt.Errorf("package %s not found", packageID)
+		}
+		challenges := make(map[string]*models.PackageChallenge, len(loadedChallenges))
+		for id, challenge := range loadedChallenges {
+			challenges[id] = challenge
+		}
+		return challenges, nil
+	}
+
 	packagePath := filepath.Join(s.packagesPath, packageID)
 
 	// Check if package directory exists
@@ -458,19 +597,20 @@ func (s *PackageService) GetPackageChallenges(packageID string) (map[string]*mod
 		return nil, fmt.Errorf("package %s not found", packageID)
 	}
 
-	challengesList := s.loadChallenges(packagePath)
-	challenges := make(map[string]*models.PackageChallenge)
-
-	for _, challenge := range challengesList {
-		// Create a copy to avoid the loop variable reference issue
-		challengeCopy := challenge
-		challenges[challenge.ID] = &challengeCopy
-	}
-
-	return challenges, nil
+	return s.readChallenges(packagePath, packageID), nil
 }
 
+// GetPackageChallenge returns a challenge of a package, from the loaded
+// catalog or else from the filesystem
 func (s *PackageService) GetPackageChallenge(packageID, challengeID string) (*models.PackageChallenge, error) {
+	if challenges, _, loaded := s.catalogChallenges(packageID); loaded {
+		challenge, exists := challenges[challengeID]
+		if !exists {
+			return nil, fmt.Errorf("challenge %s not found in package %s", challengeID, packageID)
+		}
+		return challenge, nil
+	}
+
 	// Load challenge directly from filesystem
 	packagePath := filepath.Join(s.packagesPath, packageID)
 	challengePath := filepath.Join(packagePath, challengeID)
@@ -490,3 +630,16 @@ func (s *PackageService) GetPackageChallenge(packageID, challengeID string) (*mo
 
 	return challenge, nil
 }
+
+// PackageExecutionChallenge converts a package challenge to the Challenge
+// format of ExecutionService. Package challenges have no numeric ID; their
+// directory identifies the workspace.
+func PackageExecutionChallenge(challenge *models.PackageChallenge) *models.Challenge {
+	return &models.Challenge{
+		Title:     challenge.Title,
+		Template:  challenge.Template,
+		TestFile:  challenge.TestFile,
+		Execution: challenge.Execution,
+		Dir:       challenge.Dir,
+	}
+}
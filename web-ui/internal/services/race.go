@@ -0,0 +1,157 @@
+package services
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StackFrame is one call in a goroutine stack of a race report
+type StackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"` // Base name for the solution and test files, full path otherwise
+	Line     int    `json:"line"`
+	Solution bool   `json:"solution"` // The frame is in solution-template.go
+}
+
+// RaceAccess is one of the conflicting memory accesses of a data race
+type RaceAccess struct {
+	Operation string       `json:"operation"` // e.g. "write", "read", "atomic write"
+	Previous  bool         `json:"previous"`  // The earlier of the two accesses
+	Address   string       `json:"address"`
+	Goroutine int          `json:"goroutine"` // 0 for the main goroutine
+	Stack     []StackFrame `json:"stack"`
+}
+
+// RaceGoroutine tells where a goroutine involved in a data race was started
+type RaceGoroutine struct {
+	ID        int          `json:"id"`
+	State     string       `json:"state"` // "running" or "finished"
+	CreatedAt []StackFrame `json:"createdAt"`
+}
+
+// DataRace is a WARNING: DATA RACE report of the race detector
+type DataRace struct {
+	Test          string          `json:"test,omitempty"` // Test that was running when the race was reported
+	Accesses      []RaceAccess    `json:"accesses"`
+	Goroutines    []RaceGoroutine `json:"goroutines,omitempty"`
+	SolutionLines []int           `json:"solutionLines"` // Lines of solution-template.go in any of the stacks
+}
+
+var (
+	raceAccessRe    = regexp.MustCompile(`^(Previous )?(.+?) at (0x[0-9a-f]+) by (?:main goroutine|goroutine (\d+)):$`)
+	raceGoroutineRe = regexp.MustCompile(`^Goroutine (\d+) \((\w+)\) created at:$`)
+	raceFileLineRe  = regexp.MustCompile(`^\s+(\S.*):(\d+)(?: \+0x[0-9a-f]+)?$`)
+	testRunRe       = regexp.MustCompile(`^=== RUN\s+(\S+)`)
+)
+
+// parseDataRaces extracts the race detector reports from the output of a test
+// binary. A report is framed by lines of "=" and lists the two conflicting
+// accesses and the goroutines involved, each with its stack. Reports of the
+// same accesses, e.g. from repeated runs, are listed once.
+func parseDataRaces(output string) []DataRace {
+	var races []DataRace
+	seen := make(map[string]bool)
+	var race *DataRace
+	var stack *[]StackFrame
+	currentTest := ""
+
+	lines := strings.Split(output, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+
+		if race == nil {
+			if match := testRunRe.FindStringSubmatch(line); match != nil {
+				currentTest = match[1]
+			}
+			if line == "WARNING: DATA RACE" {
+				race = &DataRace{Test: currentTest}
+				stack = nil
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "=================="):
+			race.SolutionLines = solutionLines(race)
+			if key := raceKey(race); !seen[key] {
+				seen[key] = true
+				races = append(races, *race)
+			}
+			race = nil
+		case raceAccessRe.MatchString(line):
+			match := raceAccessRe.FindStringSubmatch(line)
+			goroutine, _ := strconv.Atoi(match[4])
+			race.Accesses = append(race.Accesses, RaceAccess{
+				Operation: strings.ToLower(match[2]),
+				Previous:  match[1] != "",
+				Address:   match[3],
+				Goroutine: goroutine,
+				Stack:     []StackFrame{},
+			})
+			stack = &race.Accesses[len(race.Accesses)-1].Stack
+		case raceGoroutineRe.MatchString(line):
+			match := raceGoroutineRe.FindStringSubmatch(line)
+			id, _ := strconv.Atoi(match[1])
+			race.Goroutines = append(race.Goroutines, RaceGoroutine{ID: id, State: match[2], CreatedAt: []StackFrame{}})
+			stack = &race.Goroutines[len(race.Goroutines)-1].CreatedAt
+		case stack != nil && strings.HasPrefix(line, "  ") && i+1 < len(lines):
+			// A frame is a function line followed by its file:line
+			match := raceFileLineRe.FindStringSubmatch(lines[i+1])
+			if match == nil {
+				continue
+			}
+			i++
+			lineNumber, _ := strconv.Atoi(match[2])
+			file := match[1]
+			base := filepath.Base(file)
+			if base == solutionFileName || base == solutionTestFileName {
+				file = base
+			}
+			*stack = append(*stack, StackFrame{
+				Function: strings.TrimSpace(line),
+				File:     file,
+				Line:     lineNumber,
+				Solution: base == solutionFileName,
+			})
+		}
+	}
+	return races
+}
+
+// solutionLines returns the distinct solution lines in the stacks of a race
+func solutionLines(race *DataRace) []int {
+	seen := make(map[int]bool)
+	lines := []int{}
+	add := func(stack []StackFrame) {
+		for _, frame := range stack {
+			if frame.Solution && !seen[frame.Line] {
+				seen[frame.Line] = true
+				lines = append(lines, frame.Line)
+			}
+		}
+	}
+	for _, access := range race.Accesses {
+		add(access.Stack)
+	}
+	for _, goroutine := range race.Goroutines {
+		add(goroutine.CreatedAt)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// raceKey identifies a race by the code locations of its accesses
+func raceKey(race *DataRace) string {
+	var key strings.Builder
+	for _, access := range race.Accesses {
+		key.WriteString(access.Operation)
+		for _, frame := range access.Stack {
+			key.WriteString("|" + frame.Function + ":" + strconv.Itoa(frame.Line))
+		}
+		key.WriteString("\n")
+	}
+	return key.String()
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// raceSummary writes the accesses and goroutines of a race as e.g.
+// "read by 8, previous write by 9; 8 running, 9 finished"
+func raceSummary(race DataRace) string {
+	var accesses, goroutines []string
+	for _, access := range race.Accesses {
+		operation := access.Operation
+		if access.Previous {
+			operation = "previous " + operation
+		}
+		accesses = append(accesses, fmt.Sprintf("%s by %d", operation, access.Goroutine))
+	}
+	for _, goroutine := range race.Goroutines {
+		goroutines = append(goroutines, fmt.Sprintf("%d %s", goroutine.ID, goroutine.State))
+	}
+	return strings.Join(accesses, ", ") + "; " + strings.Join(goroutines, ", ")
+}
+
+func TestParseDataRaces(t *testing.T) {
+	type race struct {
+		test    string
+		summary string
+		lines   []int
+	}
+	counter := race{
+		test:    "TestCountTo",
+		summary: "read by 8, previous write by 9; 8 running, 9 finished",
+		lines:   []int{10, 18, 20},
+	}
+	tests := []struct {
+		name   string
+		output string
+		want   []race
+	}{
+		{
+			name:   "one race",
+			output: string(readFixture(t, "race-count.txt")),
+			want:   []race{counter},
+		},
+		{
+			// The same race from two runs of the binary is listed once
+			name:   "repeated",
+			output: strings.Repeat(string(readFixture(t, "race-count.txt")), 2),
+			want:   []race{counter},
+		},
+		{
+			// The first race is in TestMain, before any test runs, and
+			// involves the main goroutine
+			name:   "main goroutine",
+			output: string(readFixture(t, "race-testmain.txt")),
+			want: []race{
+				{summary: "write by 7, previous write by 0; 7 running", lines: []int{}},
+				{test: "TestCountTo", summary: "read by 10, previous write by 9; 10 running, 9 finished", lines: []int{10, 18, 20}},
+			},
+		},
+		{
+			name:   "no races",
+			output: string(readFixture(t, "testreport-mixed.jsonl")),
+		},
+		{
+			// Output cut off in the middle of a report
+			name:   "unterminated",
+			output: strings.Split(string(readFixture(t, "race-count.txt")), "Goroutine 9")[0],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			races := parseDataRaces(tt.output)
+			if len(races) != len(tt.want) {
+				t.Fatalf("found %d races, want %d", len(races), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := races[i]
+				if got.Test != want.test || raceSummary(got) != want.summary || !reflect.DeepEqual(got.SolutionLines, want.lines) {
+					t.Errorf("race %d in %q: %s, solution lines %v\nwant in %q: %s, solution lines %v",
+						i, got.Test, raceSummary(got), got.SolutionLines, want.test, want.summary, want.lines)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDataRacesStacks(t *testing.T) {
+	races := parseDataRaces(string(readFixture(t, "race-count.txt")))
+	if len(races) != 1 {
+		t.Fatalf("found %d races, want 1", len(races))
+	}
+	race := races[0]
+	if access := race.Accesses[0]; access.Address != "0x00c0000182c8" {
+		t.Errorf("address = %s", access.Address)
+	}
+
+	want := []StackFrame{
+		{Function: "example.com/challenge9.CountTo()", File: solutionFileName, Line: 18, Solution: true},
+		{Function: "example.com/challenge9.TestCountTo()", File: solutionTestFileName, Line: 6},
+		{Function: "testing.tRunner()", File: "/usr/local/go/src/testing/testing.go", Line: 2193},
+		{Function: "testing.(*T).Run.gowrap1()", File: "/usr/local/go/src/testing/testing.go", Line: 2258},
+	}
+	if got := race.Goroutines[0].CreatedAt; !reflect.DeepEqual(got, want) {
+		t.Errorf("goroutine 8 created at\n%+v\nwant\n%+v", got, want)
+	}
+	want = []StackFrame{
+		{Function: "example.com/challenge9.(*Counter).Inc()", File: solutionFileName, Line: 10, Solution: true},
+		{Function: "example.com/challenge9.CountTo.func1()", File: solutionFileName, Line: 20, Solution: true},
+	}
+	if got := race.Accesses[1].Stack; !reflect.DeepEqual(got, want) {
+		t.Errorf("previous write at\n%+v\nwant\n%+v", got, want)
+	}
+}
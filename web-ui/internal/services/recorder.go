@@ -0,0 +1,537 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf16"
+)
+
+// TimelinesDirName is the directory in the data directory holding the
+// recorded editing timelines, one log file each
+const TimelinesDirName = "timelines"
+
+// Limits of recorded timelines
+const (
+	MaxEditBatch     = 1000      // Edits in one batch
+	MaxEditBatchText = 256 << 10 // UTF-16 code units inserted by the edits of one batch
+	MaxTimelineCode  = 256 << 10 // UTF-16 code units of the recorded code
+
+	compactWindow = time.Second // Keystrokes merged into one edit span at most this long
+	compactLines  = 200         // Records appended to a log before it is rewritten compacted
+)
+
+// Kinds of edits
+const (
+	EditInsert = "insert"
+	EditDelete = "delete"
+	EditReset  = "reset" // Replaces all of the code, e.g. when an editor is loaded
+)
+
+var (
+	ErrInvalidEdit       = errors.New("edit does not apply to the recorded code")
+	ErrEditBatchTooLarge = fmt.Errorf("at most %d edits inserting %d KB of code can be sent at once", MaxEditBatch, MaxEditBatchText>>10)
+	ErrTimelineTooLarge  = errors.New("recorded code is too large")
+)
+
+// EditOp is one change to the code in an editor. Offsets and lengths count
+// UTF-16 code units, as JavaScript strings do.
+type EditOp struct {
+	Time   int64  `json:"t"`               // Unix milliseconds
+	Since  int64  `json:"since,omitempty"` // Time of the first keystroke, when several were merged
+	Type   string `json:"type"`
+	Offset int    `json:"offset,omitempty"`
+	Length int    `json:"length,omitempty"` // Code units deleted
+	Text   string `json:"text,omitempty"`   // Code inserted, or all of the code after a reset
+}
+
+// start returns when the first keystroke of an edit was made
+func (op *EditOp) start() int64 {
+	if op.Since != 0 {
+		return op.Since
+	}
+	return op.Time
+}
+
+// EditBatch is the edits an editor sends at once. SentAt is the editor's
+// clock when it sent them: their times are moved by its difference to the
+// server's clock, so a skewed clock in the browser does not matter.
+type EditBatch struct {
+	SentAt int64    `json:"sentAt"`
+	Ops    []EditOp `json:"ops"`
+}
+
+// RunEvent is a test run in a timeline
+type RunEvent struct {
+	Time        int64           `json:"t"` // When the run was requested, Unix milliseconds
+	Kind        string          `json:"kind"`
+	Passed      bool            `json:"passed"`
+	Status      ExecutionStatus `json:"status"`
+	TestsPassed int             `json:"testsPassed"`
+	TestsTotal  int             `json:"testsTotal"`
+	ExecutionMs int64           `json:"executionMs"`
+}
+
+// RunEventOf returns the event of a run requested at a time and ending with result
+func RunEventOf(kind string, requested time.Time, result ExecutionResult) RunEvent {
+	event := RunEvent{
+		Time:        requested.UnixMilli(),
+		Kind:        kind,
+		Passed:      result.Passed,
+		Status:      result.Status,
+		ExecutionMs: result.ExecutionMs,
+	}
+	if result.Report != nil {
+		event.TestsPassed, event.TestsTotal = result.Report.Passed, result.Report.Total
+	}
+	return event
+}
+
+// TimelineEntry is an edit or a test run; exactly one of them is set
+type TimelineEntry struct {
+	Edit *EditOp   `json:"edit,omitempty"`
+	Run  *RunEvent `json:"run,omitempty"`
+}
+
+// Time returns when the entry happened, in Unix milliseconds
+func (e TimelineEntry) Time() int64 {
+	if e.Edit != nil {
+		return e.Edit.Time
+	}
+	return e.Run.Time
+}
+
+// TimelineKey names a timeline: a user's editing of a challenge in a session
+type TimelineKey struct {
+	User      string `json:"user"`
+	Challenge string `json:"challenge"`
+	Session   string `json:"session"`
+}
+
+// Timeline is the edits and test runs of a timeline, oldest first
+type Timeline struct {
+	TimelineKey
+	Entries []TimelineEntry `json:"entries"`
+}
+
+// ReplayState is the code of a timeline at a point in time
+type ReplayState struct {
+	At      int64     `json:"at"` // Unix milliseconds
+	Code    string    `json:"code"`
+	Edits   int       `json:"edits"` // Edits made up to At
+	Runs    int       `json:"runs"`  // Test runs requested up to At
+	LastRun *RunEvent `json:"lastRun,omitempty"`
+}
+
+// timelineRecord is a line of a timeline log: a batch of edits or a run
+type timelineRecord struct {
+	Edits []EditOp  `json:"edits,omitempty"`
+	Run   *RunEvent `json:"run,omitempty"`
+}
+
+// timeline is a loaded timeline with the code its edits add up to
+type timeline struct {
+	entries   []TimelineEntry
+	code      []uint16
+	lines     int // Records in the log file
+	compacted int // Records in the log file when it was last compacted
+}
+
+// RecorderService records editing timelines: the edits made in an editor,
+// keystroke by keystroke, with the test runs in between, so the writing of
+// a solution can be replayed. Each timeline is an append-only log of JSON
+// records in the data directory. Keystrokes that continue each other are
+// merged into one edit, and a log is rewritten with the merged edits once
+// it grows long.
+type RecorderService struct {
+	dir       string
+	mu        sync.Mutex
+	timelines map[TimelineKey]*timeline // Loaded timelines
+}
+
+// NewRecorderService creates a recorder keeping its timelines in dataDir
+func NewRecorderService(dataDir string) (*RecorderService, error) {
+	dir := filepath.Join(dataDir, TimelinesDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create timelines directory: %v", err)
+	}
+	return &RecorderService{dir: dir, timelines: make(map[TimelineKey]*timeline)}, nil
+}
+
+// RecordEdits adds a batch of edits to a timeline. A batch that does not
+// apply to the code recorded so far is rejected whole with ErrInvalidEdit;
+// the editor should then send a reset.
+func (rs *RecorderService) RecordEdits(key TimelineKey, batch EditBatch) error {
+	if len(batch.Ops) > MaxEditBatch {
+		return ErrEditBatchTooLarge
+	}
+	text := 0
+	for _, op := range batch.Ops {
+		if text += codeUnits(op.Text); text > MaxEditBatchText {
+			return ErrEditBatchTooLarge
+		}
+	}
+	now := time.Now().UnixMilli()
+	var shift int64
+	if batch.SentAt != 0 {
+		shift = now - batch.SentAt
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	t, err := rs.load(key)
+	if err != nil {
+		return err
+	}
+
+	// Check the whole batch against a copy of the code first
+	code := append([]uint16(nil), t.code...)
+	last := t.lastTime()
+	var ops []EditOp
+	for _, op := range batch.Ops {
+		op.Time += shift
+		if op.Time < last {
+			op.Time = last
+		}
+		if op.Time > now {
+			op.Time = now
+		}
+		op.Since = 0
+		last = op.Time
+		changed, err := applyEdit(code, &op)
+		if err != nil {
+			return err
+		}
+		if changed == nil {
+			// A reset to the code there already is
+			continue
+		}
+		if len(changed) > MaxTimelineCode {
+			return ErrTimelineTooLarge
+		}
+		code = changed
+		ops = append(ops, op)
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	if err := rs.appendRecord(key, t, timelineRecord{Edits: ops}); err != nil {
+		return err
+	}
+	for i := range ops {
+		t.addEdit(ops[i])
+	}
+	t.code = code
+	return rs.compactIfLong(key, t)
+}
+
+// RecordRun adds a test run to a timeline
+func (rs *RecorderService) RecordRun(key TimelineKey, event RunEvent) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	t, err := rs.load(key)
+	if err != nil {
+		return err
+	}
+	if err := rs.appendRecord(key, t, timelineRecord{Run: &event}); err != nil {
+		return err
+	}
+	t.addRun(event)
+	return rs.compactIfLong(key, t)
+}
+
+// Timeline returns the edits and test runs of a timeline. A timeline with
+// nothing recorded is empty.
+func (rs *RecorderService) Timeline(key TimelineKey) (*Timeline, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	t, err := rs.load(key)
+	if err != nil {
+		return nil, err
+	}
+	result := &Timeline{TimelineKey: key, Entries: make([]TimelineEntry, len(t.entries))}
+	for i, entry := range t.entries {
+		if entry.Edit != nil {
+			edit := *entry.Edit
+			result.Entries[i].Edit = &edit
+		} else {
+			run := *entry.Run
+			result.Entries[i].Run = &run
+		}
+	}
+	return result, nil
+}
+
+// Replay reconstructs the code of a timeline at a time in Unix
+// milliseconds, from the edits made up to then. Merged keystrokes count
+// as made at once, when the last of them was.
+func (rs *RecorderService) Replay(key TimelineKey, at int64) (ReplayState, error) {
+	timeline, err := rs.Timeline(key)
+	if err != nil {
+		return ReplayState{}, err
+	}
+	state := ReplayState{At: at}
+	var code []uint16
+	for _, entry := range timeline.Entries {
+		if entry.Time() > at {
+			break
+		}
+		if entry.Run != nil {
+			state.Runs++
+			state.LastRun = entry.Run
+			continue
+		}
+		// The edits were checked when they were recorded
+		if changed, err := applyEdit(code, entry.Edit); err == nil && changed != nil {
+			code = changed
+		}
+		state.Edits++
+	}
+	state.Code = string(utf16.Decode(code))
+	return state, nil
+}
+
+// applyEdit returns code with an edit made to it, or nil if the edit is a
+// reset that changes nothing. code itself may be changed.
+func applyEdit(code []uint16, op *EditOp) ([]uint16, error) {
+	switch op.Type {
+	case EditInsert:
+		if op.Offset < 0 || op.Offset > len(code) || op.Text == "" || op.Length != 0 {
+			return nil, ErrInvalidEdit
+		}
+		// Grown in place, so that typing into large code copies no more than
+		// the code after the cursor
+		text := utf16.Encode([]rune(op.Text))
+		changed := append(code, text...)
+		copy(changed[op.Offset+len(text):], changed[op.Offset:len(code)])
+		copy(changed[op.Offset:], text)
+		return changed, nil
+	case EditDelete:
+		if op.Offset < 0 || op.Length <= 0 || op.Offset+op.Length > len(code) || op.Text != "" {
+			return nil, ErrInvalidEdit
+		}
+		return append(code[:op.Offset], code[op.Offset+op.Length:]...), nil
+	case EditReset:
+		if op.Offset != 0 || op.Length != 0 {
+			return nil, ErrInvalidEdit
+		}
+		text := utf16.Encode([]rune(op.Text))
+		if equalCode(code, text) {
+			return nil, nil
+		}
+		if text == nil {
+			text = []uint16{}
+		}
+		return text, nil
+	default:
+		return nil, ErrInvalidEdit
+	}
+}
+
+// codeUnits returns the length of a string in UTF-16 code units
+func codeUnits(text string) int {
+	n := 0
+	for _, r := range text {
+		if r >= 0x10000 {
+			// A surrogate pair
+			n++
+		}
+		n++
+	}
+	return n
+}
+
+// equalCode reports whether two pieces of code are the same
+func equalCode(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lastTime returns the time of the latest entry of a timeline
+func (t *timeline) lastTime() int64 {
+	if len(t.entries) == 0 {
+		return 0
+	}
+	return t.entries[len(t.entries)-1].Time()
+}
+
+// addEdit adds an edit to a timeline, merged into the edit before it if
+// the two are keystrokes that continue each other: typing on at the end of
+// an insertion, or deleting on from where a deletion was
+func (t *timeline) addEdit(op EditOp) {
+	if n := len(t.entries); n > 0 && t.entries[n-1].Edit != nil {
+		prev := t.entries[n-1].Edit
+		if op.Type == prev.Type && op.Time-prev.start() <= compactWindow.Milliseconds() {
+			merged := true
+			switch {
+			case op.Type == EditInsert && op.Offset == prev.Offset+codeUnits(prev.Text):
+				prev.Text += op.Text
+			case op.Type == EditDelete && op.Offset+op.Length == prev.Offset:
+				// Backspace
+				prev.Offset, prev.Length = op.Offset, prev.Length+op.Length
+			case op.Type == EditDelete && op.Offset == prev.Offset:
+				// Delete
+				prev.Length += op.Length
+			default:
+				merged = false
+			}
+			if merged {
+				prev.Since, prev.Time = prev.start(), op.Time
+				return
+			}
+		}
+	}
+	t.entries = append(t.entries, TimelineEntry{Edit: &op})
+}
+
+// addRun adds a test run to a timeline. Runs are recorded when they
+// finish, so edits made while one ran may come before it.
+func (t *timeline) addRun(event RunEvent) {
+	i := len(t.entries)
+	for i > 0 && t.entries[i-1].Time() > event.Time {
+		i--
+	}
+	t.entries = append(t.entries, TimelineEntry{})
+	copy(t.entries[i+1:], t.entries[i:])
+	t.entries[i] = TimelineEntry{Run: &event}
+}
+
+// load returns a timeline, reading its log the first time. rs.mu is held.
+func (rs *RecorderService) load(key TimelineKey) (*timeline, error) {
+	if t, ok := rs.timelines[key]; ok {
+		return t, nil
+	}
+	t := &timeline{}
+	file, err := os.OpenFile(rs.path(key), os.O_RDWR, 0600)
+	if os.IsNotExist(err) {
+		rs.timelines[key] = t
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open timeline: %v", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var end int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				// A record without its newline was cut short; the next
+				// record must not be appended to it
+				log.Printf("Dropping %d bytes of an incomplete timeline record", len(line))
+				if err := file.Truncate(end); err != nil {
+					return nil, fmt.Errorf("failed to repair timeline: %v", err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read timeline: %v", err)
+		}
+		end += int64(len(line))
+		var record timelineRecord
+		if err := json.Unmarshal(bytes.TrimSpace(line), &record); err != nil {
+			log.Printf("Skipping unreadable timeline record at offset %d: %v", end-int64(len(line)), err)
+			continue
+		}
+		t.lines++
+		if record.Run != nil {
+			t.addRun(*record.Run)
+			continue
+		}
+		for i := range record.Edits {
+			if changed, err := applyEdit(t.code, &record.Edits[i]); err == nil && changed != nil {
+				t.code = changed
+			}
+			t.addEdit(record.Edits[i])
+		}
+	}
+	rs.timelines[key] = t
+	return t, nil
+}
+
+// appendRecord adds a record to the log of a timeline
+func (rs *RecorderService) appendRecord(key TimelineKey, t *timeline, record timelineRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(rs.path(key), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open timeline: %v", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write timeline: %v", err)
+	}
+	t.lines++
+	return file.Close()
+}
+
+// compactIfLong rewrites the log of a timeline with its merged edits once
+// has had more than compactLines records added since it last was
+func (rs *RecorderService) compactIfLong(key TimelineKey, t *timeline) error {
+	if t.lines-t.compacted <= compactLines {
+		return nil
+	}
+
+	// Consecutive edits share a record
+	var records []timelineRecord
+	for _, entry := range t.entries {
+		if entry.Run != nil {
+			records = append(records, timelineRecord{Run: entry.Run})
+			continue
+		}
+		if n := len(records); n == 0 || records[n-1].Run != nil {
+			records = append(records, timelineRecord{})
+		}
+		records[len(records)-1].Edits = append(records[len(records)-1].Edits, *entry.Edit)
+	}
+	var data bytes.Buffer
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		data.Write(append(line, '\n'))
+	}
+
+	path := rs.path(key)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to compact timeline: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to compact timeline: %v", err)
+	}
+	t.lines, t.compacted = len(records), len(records)
+	return nil
+}
+
+// path returns the log file of a timeline, named after a hash of its key
+func (rs *RecorderService) path(key TimelineKey) string {
+	sum := sha256.Sum256([]byte(key.User + "\x00" + key.Challenge + "\x00" + key.Session))
+	return filepath.Join(rs.dir, hex.EncodeToString(sum[:])+".jsonl")
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+func TestApplyEdit(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		op   EditOp
+		want string
+		err  error
+	}{
+		{"insert at the start", "bc", EditOp{Type: EditInsert, Offset: 0, Text: "a"}, "abc", nil},
+		{"insert in the middle", "ac", EditOp{Type: EditInsert, Offset: 1, Text: "b"}, "abc", nil},
+		{"insert at the end", "ab", EditOp{Type: EditInsert, Offset: 2, Text: "c"}, "abc", nil},
+		{"insert after a surrogate pair", "😀!", EditOp{Type: EditInsert, Offset: 2, Text: "é"}, "😀é!", nil},
+		{"insert past the end", "ab", EditOp{Type: EditInsert, Offset: 3, Text: "c"}, "", ErrInvalidEdit},
+		{"insert at a negative offset", "ab", EditOp{Type: EditInsert, Offset: -1, Text: "c"}, "", ErrInvalidEdit},
+		{"insert nothing", "ab", EditOp{Type: EditInsert, Offset: 1}, "", ErrInvalidEdit},
+		{"insert with a length", "ab", EditOp{Type: EditInsert, Offset: 1, Length: 1, Text: "c"}, "", ErrInvalidEdit},
+		{"delete", "abc", EditOp{Type: EditDelete, Offset: 1, Length: 1}, "ac", nil},
+		{"delete a surrogate pair", "a😀b", EditOp{Type: EditDelete, Offset: 1, Length: 2}, "ab", nil},
+		{"delete everything", "abc", EditOp{Type: EditDelete, Offset: 0, Length: 3}, "", nil},
+		{"delete past the end", "abc", EditOp{Type: EditDelete, Offset: 2, Length: 2}, "", ErrInvalidEdit},
+		{"delete nothing", "abc", EditOp{Type: EditDelete, Offset: 1}, "", ErrInvalidEdit},
+		{"delete with text", "abc", EditOp{Type: EditDelete, Offset: 1, Length: 1, Text: "b"}, "", ErrInvalidEdit},
+		{"reset", "abc", EditOp{Type: EditReset, Text: "xyz"}, "xyz", nil},
+		{"reset to nothing", "abc", EditOp{Type: EditReset}, "", nil},
+		{"reset at an offset", "abc", EditOp{Type: EditReset, Offset: 1, Text: "xyz"}, "", ErrInvalidEdit},
+		{"unknown type", "abc", EditOp{Type: "replace", Text: "xyz"}, "", ErrInvalidEdit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, err := applyEdit(utf16.Encode([]rune(tt.code)), &tt.op)
+			if err != tt.err {
+				t.Fatalf("applyEdit(%q, %+v) error = %v, want %v", tt.code, tt.op, err, tt.err)
+			}
+			if got := string(utf16.Decode(changed)); err == nil && (changed == nil || got != tt.want) {
+				t.Errorf("applyEdit(%q, %+v) = %q, want %q", tt.code, tt.op, got, tt.want)
+			}
+		})
+	}
+
+	if changed, err := applyEdit(utf16.Encode([]rune("abc")), &EditOp{Type: EditReset, Text: "abc"}); changed != nil || err != nil {
+		t.Errorf("reset to the same code = %v, %v, want nil for no change", changed, err)
+	}
+}
+
+func TestTimelineAddEdit(t *testing.T) {
+	const start = 1000
+	tests := []struct {
+		name string
+		ops  []EditOp
+		want []EditOp
+	}{
+		{
+			name: "typing on",
+			ops: []EditOp{
+				{Time: start, Type: EditInsert, Offset: 4, Text: "a"},
+				{Time: start + 100, Type: EditInsert, Offset: 5, Text: "é"},
+				{Time: start + 200, Type: EditInsert, Offset: 6, Text: "c"},
+			},
+			want: []EditOp{{Time: start + 200, Since: start, Type: EditInsert, Offset: 4, Text: "aéc"}},
+		},
+		{
+			name: "backspace",
+			ops: []EditOp{
+				{Time: start, Type: EditDelete, Offset: 9, Length: 1},
+				{Time: start + 100, Type: EditDelete, Offset: 8, Length: 1},
+				{Time: start + 200, Type: EditDelete, Offset: 6, Length: 2},
+			},
+			want: []EditOp{{Time: start + 200, Since: start, Type: EditDelete, Offset: 6, Length: 4}},
+		},
+		{
+			name: "forward delete",
+			ops: []EditOp{
+				{Time: start, Type: EditDelete, Offset: 3, Length: 1},
+				{Time: start + 100, Type: EditDelete, Offset: 3, Length: 2},
+			},
+			want: []EditOp{{Time: start + 100, Since: start, Type: EditDelete, Offset: 3, Length: 3}},
+		},
+		{
+			name: "typing elsewhere",
+			ops: []EditOp{
+				{Time: start, Type: EditInsert, Offset: 4, Text: "a"},
+				{Time: start + 100, Type: EditInsert, Offset: 0, Text: "b"},
+			},
+			want: []EditOp{
+				{Time: start, Type: EditInsert, Offset: 4, Text: "a"},
+				{Time: start + 100, Type: EditInsert, Offset: 0, Text: "b"},
+			},
+		},
+		{
+			name: "deleting what was typed",
+			ops: []EditOp{
+				{Time: start, Type: EditInsert, Offset: 4, Text: "a"},
+				{Time: start + 100, Type: EditDelete, Offset: 4, Length: 1},
+			},
+			want: []EditOp{
+				{Time: start, Type: EditInsert, Offset: 4, Text: "a"},
+				{Time: start + 100, Type: EditDelete, Offset: 4, Length: 1},
+			},
+		},
+		{
+			// The window counts from the first keystroke of the merged edit
+			name: "typing past the window",
+			ops: []EditOp{
+				{Time: start, Type: EditInsert, Offset: 0, Text: "a"},
+				{Time: start + 600, Type: EditInsert, Offset: 1, Text: "b"},
+				{Time: start + 1200, Type: EditInsert, Offset: 2, Text: "c"},
+			},
+			want: []EditOp{
+				{Time: start + 600, Since: start, Type: EditInsert, Offset: 0, Text: "ab"},
+				{Time: start + 1200, Type: EditInsert, Offset: 2, Text: "c"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tl := &timeline{}
+			for _, op := range tt.ops {
+				tl.addEdit(op)
+			}
+			var got []EditOp
+			for _, entry := range tl.entries {
+				got = append(got, *entry.Edit)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("edits = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// A test run in between ends a merged edit
+	tl := &timeline{}
+	tl.addEdit(EditOp{Time: start, Type: EditInsert, Offset: 0, Text: "a"})
+	tl.addRun(RunEvent{Time: start + 50, Kind: "run"})
+	tl.addEdit(EditOp{Time: start + 100, Type: EditInsert, Offset: 1, Text: "b"})
+	if len(tl.entries) != 3 || tl.entries[2].Edit.Text != "b" {
+		t.Errorf("entries = %+v, want the edits on both sides of the run", tl.entries)
+	}
+}
+
+// typeCode records typing text at offset one keystroke per batch, a
+// keystroke every step from start on
+func typeCode(t *testing.T, rs *RecorderService, key TimelineKey, offset int, text string, start int64, step time.Duration) {
+	t.Helper()
+	for i, r := range text {
+		op := EditOp{Time: start + int64(i)*step.Milliseconds(), Type: EditInsert, Offset: offset, Text: string(r)}
+		if err := rs.RecordEdits(key, EditBatch{Ops: []EditOp{op}}); err != nil {
+			t.Fatal(err)
+		}
+		offset += codeUnits(string(r))
+	}
+}
+
+func TestRecorderCompactsAndReloads(t *testing.T) {
+	dir := t.TempDir()
+	rs, err := NewRecorderService(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := TimelineKey{User: "jane", Challenge: "1", Session: "s1"}
+	start := time.Now().Add(-time.Hour).UnixMilli()
+
+	// Keystrokes half a second apart are merged, with a run half way
+	code := strings.Repeat("x", compactLines)
+	typeCode(t, rs, key, 0, code, start, 500*time.Millisecond)
+	runAt := start + int64(compactLines)*500
+	if err := rs.RecordRun(key, RunEvent{Time: runAt, Kind: "run", Passed: true}); err != nil {
+		t.Fatal(err)
+	}
+	typeCode(t, rs, key, compactLines, code, runAt, 500*time.Millisecond)
+	before, err := rs.Timeline(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(rs.path(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines >= 2*compactLines {
+		t.Errorf("log has %d lines after %d records, want it compacted", lines, 2*compactLines+1)
+	}
+
+	reloaded, err := NewRecorderService(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := reloaded.Timeline(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(after, before) {
+		t.Errorf("reloaded timeline has %d entries, want the %d recorded", len(after.Entries), len(before.Entries))
+	}
+	replay, err := reloaded.Replay(key, time.Now().UnixMilli())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.Code != code+code || replay.Runs != 1 || !replay.LastRun.Passed {
+		t.Errorf("replay = %+v, want all of the code and the run", replay)
+	}
+}
+
+func TestRecorderReplay(t *testing.T) {
+	rs, err := NewRecorderService(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := TimelineKey{User: "jane", Challenge: "1", Session: "s1"}
+	start := time.Now().Add(-time.Hour).UnixMilli()
+
+	// Keystrokes two seconds apart are not merged
+	batch := EditBatch{Ops: []EditOp{
+		{Time: start, Type: EditReset, Text: "ab"},
+		{Time: start + 2000, Type: EditInsert, Offset: 2, Text: "c"},
+		{Time: start + 4000, Type: EditDelete, Offset: 0, Length: 1},
+	}}
+	if err := rs.RecordEdits(key, batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.RecordRun(key, RunEvent{Time: start + 3000, Kind: "run"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		at    int64
+		code  string
+		edits int
+		runs  int
+	}{
+		{start - 1, "", 0, 0},
+		{start, "ab", 1, 0},
+		{start + 1999, "ab", 1, 0},
+		{start + 2000, "abc", 2, 0},
+		{start + 3000, "abc", 2, 1},
+		{start + 4000, "bc", 3, 1},
+	}
+	for _, tt := range tests {
+		state, err := rs.Replay(key, tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.Code != tt.code || state.Edits != tt.edits || state.Runs != tt.runs {
+			t.Errorf("replay at +%dms = %+v, want %q after %d edits and %d runs", tt.at-start, state, tt.code, tt.edits, tt.runs)
+		}
+	}
+}
+
+func TestRecorderTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	rs, err := NewRecorderService(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := TimelineKey{User: "jane", Challenge: "1", Session: "s1"}
+	start := time.Now().Add(-time.Hour).UnixMilli()
+	typeCode(t, rs, key, 0, "ab", start, 2*time.Second)
+
+	// A crash cut the last record short
+	file, err := os.OpenFile(rs.path(key), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"edits":[{"t":`)
+	file.Close()
+
+	// After a restart, the edits go on from the complete records
+	rs, err = NewRecorderService(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.RecordEdits(key, EditBatch{Ops: []EditOp{{Time: start + 5000, Type: EditInsert, Offset: 2, Text: "c"}}}); err != nil {
+		t.Fatal(err)
+	}
+	rs, err = NewRecorderService(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := rs.Replay(key, time.Now().UnixMilli())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Code != "abc" || state.Edits != 3 {
+		t.Errorf("replay after the restart = %+v, want abc after 3 edits", state)
+	}
+}
+
+func TestRecorderBatchLimits(t *testing.T) {
+	rs, err := NewRecorderService(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := TimelineKey{User: "jane", Challenge: "1", Session: "s1"}
+	resets := make([]EditOp, 10)
+	for i := range resets {
+		resets[i] = EditOp{Type: EditReset, Text: strings.Repeat(string(rune('a'+i)), MaxEditBatchText/8)}
+	}
+	if err := rs.RecordEdits(key, EditBatch{Ops: resets}); err != ErrEditBatchTooLarge {
+		t.Errorf("resets inserting %d code units: %v, want %v", 10*MaxEditBatchText/8, err, ErrEditBatchTooLarge)
+	}
+	if err := rs.RecordEdits(key, EditBatch{Ops: resets[:8]}); err != nil {
+		t.Errorf("resets inserting %d code units: %v", MaxEditBatchText, err)
+	}
+}
@@ -1,150 +1,175 @@
 package services
 
 import (
-	"io/ioutil"
+	"log"
+	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
-	"time"
+	"sync"
 
 	"web-ui/internal/models"
+	"web-ui/internal/scoreboard"
 )
 
-// ScoreboardService handles scoreboard-related operations
+// ScoreboardService handles scoreboard-related operations. Like the
+// challenges, the scoreboards are a snapshot that is replaced as a whole, so
+// maps and entries handed out never change.
 type ScoreboardService struct {
+	mu          sync.RWMutex
 	scoreboards models.ScoreboardMap
+	submitted   map[submittedKey]models.ScoreboardEntry // Best entries of submissions since startup; reapplied on reload
+}
+
+// submittedKey is a user's entry on the scoreboard of a challenge
+type submittedKey struct {
+	username    string
+	challengeID int
 }
 
 // NewScoreboardService creates a new scoreboard service
 func NewScoreboardService() *ScoreboardService {
 	return &ScoreboardService{
 		scoreboards: make(models.ScoreboardMap),
+		submitted:   make(map[submittedKey]models.ScoreboardEntry),
 	}
 }
 
-// LoadScoreboards loads all scoreboards from the filesystem
+// LoadScoreboards loads all scoreboards from the filesystem. The entries of
+// submissions made since startup are kept where they beat the files'.
 func (ss *ScoreboardService) LoadScoreboards(challenges models.ChallengeMap) error {
-	for id := range challenges {
-		challengeDir := filepath.Join("..", "challenge-"+strconv.Itoa(id))
-		ss.loadScoreboardForChallenge(id, challengeDir)
+	ss.mu.RLock()
+	previous := ss.scoreboards
+	ss.mu.RUnlock()
+
+	scoreboards := make(models.ScoreboardMap)
+	for id, challenge := range challenges {
+		entries, err := loadScoreboardForChallenge(id, challenge.Dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			log.Printf("Skipping scoreboard of challenge %d: %v", id, err)
+			// A scoreboard caught in the middle of an update keeps its last version
+			entries = previous[id]
+		}
+		if entries != nil {
+			scoreboards[id] = entries
+		}
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for _, entry := range ss.submitted {
+		if updated, changed := withEntry(scoreboards[entry.ChallengeID], entry); changed {
+			scoreboards[entry.ChallengeID] = updated
+		}
 	}
+	ss.scoreboards = scoreboards
 	return nil
 }
 
 // loadScoreboardForChallenge loads the scoreboard for a specific challenge
-func (ss *ScoreboardService) loadScoreboardForChallenge(id int, dir string) {
-	scoreboardPath := filepath.Join(dir, "SCOREBOARD.md")
-	scoreboardContent, err := ioutil.ReadFile(scoreboardPath)
+func loadScoreboardForChallenge(id int, dir string) ([]models.ScoreboardEntry, error) {
+	board, err := scoreboard.ReadFile(filepath.Join(dir, scoreboard.FileName))
 	if err != nil {
-		return
-	}
-
-	// Parse scoreboard markdown table
-	entries := ss.parseScoreboardMarkdown(string(scoreboardContent), id)
-	ss.scoreboards[id] = entries
-}
-
-// parseScoreboardMarkdown parses the scoreboard markdown table
-func (ss *ScoreboardService) parseScoreboardMarkdown(content string, challengeID int) []models.ScoreboardEntry {
-	lines := strings.Split(content, "\n")
-	entries := []models.ScoreboardEntry{}
-
-	// Determine format by looking at header line
-	var format int = 1 // Default to format 1
-	headerLine := ""
-	for i, line := range lines {
-		if i > 0 && strings.Contains(line, "|") {
-			headerLine = line
-			break
-		}
+		return nil, err
 	}
 
-	if strings.Contains(headerLine, "Rank") && strings.Contains(headerLine, "Username") {
-		format = 2
+	entries := make([]models.ScoreboardEntry, 0, len(board.Entries))
+	for _, entry := range board.Entries {
+		entries = append(entries, models.ScoreboardEntry{Entry: entry, ChallengeID: id})
 	}
-
-	for i, line := range lines {
-		// Skip header and separator lines
-		if i < 3 {
-			continue
-		}
-
-		// Skip empty lines
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-
-		// Skip separator lines
-		if strings.Contains(line, "---") {
-			continue
-		}
-
-		parts := strings.Split(line, "|")
-		if len(parts) < 3 {
-			continue
-		}
-
-		var username string
-
-		if format == 1 {
-			// Format is: | Username | Passed Tests | Total Tests |
-			username = strings.TrimSpace(parts[1])
-		} else {
-			// Format is: | Rank | Username | Solution | Date Submitted |
-			username = strings.TrimSpace(parts[2])
-		}
-
-		// Skip empty usernames or placeholders
-		if username == "" || username == "------" || ss.isNumeric(username) {
-			continue
-		}
-
-		// Use current time for existing entries
-		entry := models.ScoreboardEntry{
-			Username:    username,
-			ChallengeID: challengeID,
-			SubmittedAt: time.Now(),
-		}
-
-		entries = append(entries, entry)
-	}
-
-	return entries
-}
-
-// isNumeric checks if a string contains only digits
-func (ss *ScoreboardService) isNumeric(s string) bool {
-	for _, r := range s {
-		if r < '0' || r > '9' {
-			return false
-		}
-	}
-	return true
+	return entries, nil
 }
 
 // GetScoreboard returns the scoreboard for a specific challenge
 func (ss *ScoreboardService) GetScoreboard(challengeID int) ([]models.ScoreboardEntry, bool) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
 	scoreboard, exists := ss.scoreboards[challengeID]
 	return scoreboard, exists
 }
 
 // GetAllScoreboards returns all scoreboards
 func (ss *ScoreboardService) GetAllScoreboards() models.ScoreboardMap {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
 	return ss.scoreboards
 }
 
-// AddSubmission adds a submission to the scoreboard
+// AddSubmission adds a submission to the scoreboard, replacing the user's
+// previous entry unless that one is better
 func (ss *ScoreboardService) AddSubmission(submission models.Submission) {
 	entry := models.ScoreboardEntry{
-		Username:    submission.Username,
+		Entry: scoreboard.Entry{
+			Username:    submission.Username,
+			Passed:      submission.TestsPassed,
+			Total:       submission.TestsTotal,
+			SubmittedAt: submission.SubmittedAt,
+			ExecutionMs: submission.ExecutionMs,
+		},
 		ChallengeID: submission.ChallengeID,
-		SubmittedAt: submission.SubmittedAt,
 	}
 
-	// Add to the scoreboard for this challenge
-	if ss.scoreboards[submission.ChallengeID] == nil {
-		ss.scoreboards[submission.ChallengeID] = []models.ScoreboardEntry{}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	key := submittedKey{entry.Username, entry.ChallengeID}
+	if best, ok := ss.submitted[key]; ok && beats(best, entry) {
+		return
+	}
+	ss.submitted[key] = entry
+	updated, changed := withEntry(ss.scoreboards[entry.ChallengeID], entry)
+	if !changed {
+		return
+	}
+	scoreboards := make(models.ScoreboardMap, len(ss.scoreboards)+1)
+	for id, entries := range ss.scoreboards {
+		scoreboards[id] = entries
+	}
+	scoreboards[entry.ChallengeID] = updated
+	ss.scoreboards = scoreboards
+}
+
+// withEntry returns a copy of entries with the user's entry replaced by
+// entry, or entry added. changed is false, and entries is returned, when the
+// user's entry beats entry.
+func withEntry(entries []models.ScoreboardEntry, entry models.ScoreboardEntry) (updated []models.ScoreboardEntry, changed bool) {
+	updated = make([]models.ScoreboardEntry, 0, len(entries)+1)
+	replaced := false
+	for _, existing := range entries {
+		if existing.Username == entry.Username {
+			if beats(existing, entry) {
+				return entries, false
+			}
+			existing = entry
+			replaced = true
+		}
+		updated = append(updated, existing)
 	}
+	if !replaced {
+		updated = append(updated, entry)
+	}
+	return updated, true
+}
+
+// beats reports whether a user's entry a is better than their entry b, as
+// scoreboard.Entry.Beats tells. Of two as good, the later one counts.
+func beats(a, b models.ScoreboardEntry) bool {
+	return a.Entry.Beats(b.Entry)
+}
 
-	ss.scoreboards[submission.ChallengeID] = append(ss.scoreboards[submission.ChallengeID], entry)
+// Completions returns the classic challenges each user has passed every test of
+func (ss *ScoreboardService) Completions() map[string]map[int]bool {
+	completions := make(map[string]map[int]bool)
+	for challengeID, entries := range ss.GetAllScoreboards() {
+		for _, entry := range entries {
+			if !entry.Completed() {
+				continue
+			}
+			if completions[entry.Username] == nil {
+				completions[entry.Username] = make(map[int]bool)
+			}
+			completions[entry.Username][challengeID] = true
+		}
+	}
+	return completions
 }
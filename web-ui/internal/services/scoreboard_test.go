@@ -0,0 +1,88 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"web-ui/internal/models"
+	"web-ui/internal/scoreboard"
+)
+
+func TestScoreboardEntryBeats(t *testing.T) {
+	entry := func(passed, total int) models.ScoreboardEntry {
+		return models.ScoreboardEntry{Entry: scoreboard.Entry{Username: "alice", Passed: passed, Total: total}}
+	}
+	tests := []struct {
+		name string
+		a, b models.ScoreboardEntry
+		want bool
+	}{
+		{"completed beats failing", entry(3, 3), entry(9, 10), true},
+		{"failing does not beat completed", entry(9, 10), entry(3, 3), false},
+		{"larger share", entry(3, 4), entry(5, 10), true},
+		{"smaller share", entry(5, 10), entry(3, 4), false},
+		{"same share", entry(1, 2), entry(2, 4), false},
+		{"both completed", entry(10, 10), entry(3, 3), false},
+		{"something beats nothing ran", entry(1, 4), entry(0, 0), true},
+		{"nothing ran", entry(0, 0), entry(0, 4), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := beats(tt.a, tt.b); got != tt.want {
+				t.Errorf("beats(%d/%d, %d/%d) = %v, want %v", tt.a.Passed, tt.a.Total, tt.b.Passed, tt.b.Total, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreboardKeepsBestSubmission(t *testing.T) {
+	dir := t.TempDir()
+	board := "# Scoreboard for challenge-1\n| Username | Passed Tests | Total Tests |\n|---|---|---|\n| bob | 4 | 4 |\n| carol | 1 | 4 |\n"
+	if err := os.WriteFile(filepath.Join(dir, scoreboard.FileName), []byte(board), 0644); err != nil {
+		t.Fatal(err)
+	}
+	challenges := models.ChallengeMap{1: &models.Challenge{ID: 1, Dir: dir}}
+	ss := NewScoreboardService()
+	if err := ss.LoadScoreboards(challenges); err != nil {
+		t.Fatal(err)
+	}
+
+	submit := func(username string, passed, total int) {
+		ss.AddSubmission(models.Submission{Username: username, ChallengeID: 1, TestsPassed: passed, TestsTotal: total, SubmittedAt: time.Now()})
+	}
+	submit("alice", 4, 4)
+	submit("alice", 2, 4) // A later, worse run
+	submit("bob", 3, 4)   // Worse than the file's row
+	submit("carol", 2, 4) // Better than the file's row
+	for i := 0; i < 10; i++ {
+		submit("dave", i, 10)
+	}
+	if len(ss.submitted) != 4 {
+		t.Errorf("kept %d submitted entries, want one per user", len(ss.submitted))
+	}
+
+	check := func(when string) {
+		t.Helper()
+		entries, _ := ss.GetScoreboard(1)
+		passed := map[string]int{}
+		for _, entry := range entries {
+			if _, ok := passed[entry.Username]; ok {
+				t.Errorf("%s: %s has two entries", when, entry.Username)
+			}
+			passed[entry.Username] = entry.Passed
+		}
+		want := map[string]int{"alice": 4, "bob": 4, "carol": 2, "dave": 9}
+		for username, n := range want {
+			if passed[username] != n {
+				t.Errorf("%s: %s passed %d tests, want %d", when, username, passed[username], n)
+			}
+		}
+	}
+	check("after the submissions")
+	if err := ss.LoadScoreboards(challenges); err != nil {
+		t.Fatal(err)
+	}
+	check("after a reload")
+}
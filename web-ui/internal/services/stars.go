@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// githubAPI is the GitHub REST API the star counts are fetched from
+const githubAPI = "https://api.github.com"
+
+// StarsFileName is the file in the data directory holding the last known
+// star counts
+const StarsFileName = "github-stars.json"
+
+// repoStars is the last known star count of a GitHub repository
+type repoStars struct {
+	Stars     int       `json:"stars"`
+	ETag      string    `json:"etag,omitempty"` // Revalidates the count without using up the rate limit
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// starStore keeps the star counts of the packages' GitHub repositories. It
+// only goes to the network when refresh is called; the counts are kept in a
+// file, so they survive restarts and are shown offline.
+type starStore struct {
+	path       string // Empty keeps the counts in memory only
+	apiURL     string
+	token      string // Optional GITHUB_TOKEN for a higher rate limit
+	httpClient *http.Client
+
+	mu           sync.Mutex
+	repos        map[string]repoStars // By "owner/repo"
+	limitedUntil time.Time            // No requests before this time after hitting the rate limit
+}
+
+// newStarStore creates a store of the counts in path, reading the counts
+// kept there
+func newStarStore(path string) *starStore {
+	store := &starStore{
+		path:       path,
+		apiURL:     githubAPI,
+		token:      os.Getenv("GITHUB_TOKEN"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		repos:      make(map[string]repoStars),
+	}
+	if path == "" {
+		return store
+	}
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &store.repos)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Ignoring the star counts in %s: %v", path, err)
+		store.repos = make(map[string]repoStars)
+	}
+	return store
+}
+
+// get returns the last known star count of a repository
+func (ss *starStore) get(repo string) (int, bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	stars, ok := ss.repos[repo]
+	return stars.Stars, ok
+}
+
+// refresh fetches the star counts of repos, revalidating known counts with
+// their ETags, and saves them. It stops early while rate limited or when ctx
+// is canceled. It reports whether any count changed.
+func (ss *starStore) refresh(ctx context.Context, repos []string) (bool, error) {
+	changed := false
+	checked := 0
+	var err error
+	for _, repo := range repos {
+		if ctx.Err() != nil {
+			break
+		}
+		ss.mu.Lock()
+		limitedUntil := ss.limitedUntil
+		known := ss.repos[repo]
+		ss.mu.Unlock()
+		if time.Now().Before(limitedUntil) {
+			err = fmt.Errorf("rate limited by GitHub until %s", limitedUntil.Format(time.Kitchen))
+			break
+		}
+
+		var stars repoStars
+		stars, err = ss.fetch(ctx, repo, known)
+		if err != nil {
+			log.Printf("Failed to fetch the GitHub stars of %s: %v", repo, err)
+			continue
+		}
+		checked++
+		ss.mu.Lock()
+		ss.repos[repo] = stars
+		ss.mu.Unlock()
+		if stars.Stars != known.Stars {
+			changed = true
+		}
+	}
+
+	if checked > 0 {
+		if saveErr := ss.save(); saveErr != nil {
+			log.Printf("Failed to save the GitHub stars: %v", saveErr)
+		}
+	}
+	return changed, err
+}
+
+// fetch requests the star count of a repository. An unchanged repository
+// answers 304 Not Modified to the ETag of the known count.
+func (ss *starStore) fetch(ctx context.Context, repo string, known repoStars) (repoStars, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", ss.apiURL+"/repos/"+repo, nil)
+	if err != nil {
+		return known, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if known.ETag != "" {
+		req.Header.Set("If-None-Match", known.ETag)
+	}
+	if ss.token != "" {
+		req.Header.Set("Authorization", "Bearer "+ss.token)
+	}
+
+	resp, err := ss.httpClient.Do(req)
+	if err != nil {
+		return known, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		known.CheckedAt = time.Now()
+		return known, nil
+	case resp.StatusCode == http.StatusOK:
+		var repoData struct {
+			StargazersCount int `json:"stargazers_count"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&repoData); err != nil {
+			return known, err
+		}
+		return repoStars{Stars: repoData.StargazersCount, ETag: resp.Header.Get("ETag"), CheckedAt: time.Now()}, nil
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		if until, limited := rateLimitReset(resp.Header); limited {
+			ss.mu.Lock()
+			ss.limitedUntil = until
+			ss.mu.Unlock()
+			return known, fmt.Errorf("rate limited until %s", until.Format(time.Kitchen))
+		}
+	}
+	return known, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+}
+
+// rateLimitReset reads when a rate limited client may send requests again,
+// from Retry-After or the X-RateLimit headers
+func rateLimitReset(header http.Header) (time.Time, bool) {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		return time.Now().Add(time.Duration(seconds) * time.Second), true
+	}
+	if header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return time.Unix(reset, 0), true
+		}
+		return time.Now().Add(time.Hour), true
+	}
+	return time.Time{}, false
+}
+
+// save writes the counts to the store's file, replacing it at once
+func (ss *starStore) save() error {
+	if ss.path == "" {
+		return nil
+	}
+	ss.mu.Lock()
+	data, err := json.MarshalIndent(ss.repos, "", "  ")
+	ss.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ss.path), 0755); err != nil {
+		return err
+	}
+	tmp := ss.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ss.path)
+}
+
+// githubRepo returns the "owner/repo" of a GitHub URL, e.g.
+// https://github.com/gin-gonic/gin
+func githubRepo(githubURL string) (string, bool) {
+	parts := strings.Split(strings.TrimSuffix(strings.TrimSpace(githubURL), "/"), "/")
+	if len(parts) < 2 || !strings.Contains(githubURL, "github.com") {
+		return "", false
+	}
+	return parts[len(parts)-2] + "/" + parts[len(parts)-1], true
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGitHub serves the star counts of repositories with ETags, answering
+// 304 to a current ETag, and rate limits requests while limited is set
+type fakeGitHub struct {
+	mu       sync.Mutex
+	stars    map[string]int
+	limited  http.Header // Headers of the 403 answer to every request
+	requests []string    // "owner/repo If-None-Match"
+}
+
+func (gh *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	repo := strings.TrimPrefix(r.URL.Path, "/repos/")
+	gh.requests = append(gh.requests, strings.TrimSpace(repo+" "+r.Header.Get("If-None-Match")))
+	if gh.limited != nil {
+		for name, values := range gh.limited {
+			w.Header()[name] = values
+		}
+		http.Error(w, `{"message": "API rate limit exceeded"}`, http.StatusForbidden)
+		return
+	}
+	stars, ok := gh.stars[repo]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	etag := fmt.Sprintf(`"%s-%d"`, repo, stars)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	fmt.Fprintf(w, `{"full_name": %q, "stargazers_count": %d}`, repo, stars)
+}
+
+// takeRequests returns the requests made since the last call
+func (gh *fakeGitHub) takeRequests() []string {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	requests := gh.requests
+	gh.requests = nil
+	return requests
+}
+
+// newTestStarStore creates a store of the counts in path that fetches from gh
+func newTestStarStore(t *testing.T, path string, gh *fakeGitHub) *starStore {
+	t.Helper()
+	server := httptest.NewServer(gh)
+	t.Cleanup(server.Close)
+	store := newStarStore(path)
+	store.apiURL = server.URL
+	store.token = ""
+	return store
+}
+
+func TestStarStoreRevalidates(t *testing.T) {
+	gh := &fakeGitHub{stars: map[string]int{"gin-gonic/gin": 80000, "redis/go-redis": 20000}}
+	store := newTestStarStore(t, "", gh)
+	repos := []string{"gin-gonic/gin", "redis/go-redis"}
+
+	changed, err := store.refresh(context.Background(), repos)
+	if err != nil || !changed {
+		t.Fatalf("first refresh = %v, %v, want a change", changed, err)
+	}
+	if got := strings.Join(gh.takeRequests(), ", "); got != "gin-gonic/gin, redis/go-redis" {
+		t.Errorf("first requests = %s, want no ETags", got)
+	}
+
+	// Unchanged repositories answer 304 and keep their counts
+	gh.stars["redis/go-redis"] = 20001
+	changed, err = store.refresh(context.Background(), repos)
+	if err != nil || !changed {
+		t.Fatalf("second refresh = %v, %v, want a change", changed, err)
+	}
+	if got, want := strings.Join(gh.takeRequests(), ", "), `gin-gonic/gin "gin-gonic/gin-80000", redis/go-redis "redis/go-redis-20000"`; got != want {
+		t.Errorf("second requests = %s, want %s", got, want)
+	}
+	for repo, want := range map[string]int{"gin-gonic/gin": 80000, "redis/go-redis": 20001} {
+		if stars, ok := store.get(repo); !ok || stars != want {
+			t.Errorf("stars of %s = %d, %v, want %d", repo, stars, ok, want)
+		}
+	}
+
+	changed, err = store.refresh(context.Background(), repos)
+	if err != nil || changed {
+		t.Errorf("refresh of unchanged repositories = %v, %v, want no change", changed, err)
+	}
+	if got, want := strings.Join(gh.takeRequests(), ", "), `gin-gonic/gin "gin-gonic/gin-80000", redis/go-redis "redis/go-redis-20001"`; got != want {
+		t.Errorf("third requests = %s, want %s", got, want)
+	}
+
+	// A failed repository keeps its count and does not stop the others
+	delete(gh.stars, "gin-gonic/gin")
+	if _, err := store.refresh(context.Background(), repos); err != nil {
+		t.Errorf("refresh with a missing repository: %v", err)
+	}
+	if got := len(gh.takeRequests()); got != 2 {
+		t.Errorf("%d requests with a missing repository, want 2", got)
+	}
+	if stars, ok := store.get("gin-gonic/gin"); !ok || stars != 80000 {
+		t.Errorf("stars of a failed repository = %d, %v, want 80000", stars, ok)
+	}
+}
+
+func TestStarStoreBacksOff(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	gh := &fakeGitHub{
+		stars: map[string]int{"gin-gonic/gin": 80000, "redis/go-redis": 20000},
+		limited: http.Header{
+			"X-Ratelimit-Remaining": {"0"},
+			"X-Ratelimit-Reset":     {strconv.FormatInt(reset.Unix(), 10)},
+		},
+	}
+	store := newTestStarStore(t, "", gh)
+	repos := []string{"gin-gonic/gin", "redis/go-redis"}
+
+	// The first 403 stops the refresh until the reset
+	changed, err := store.refresh(context.Background(), repos)
+	if err == nil || !strings.Contains(err.Error(), "rate limited") || changed {
+		t.Errorf("rate limited refresh = %v, %v, want a rate limit error", changed, err)
+	}
+	if got := gh.takeRequests(); len(got) != 1 {
+		t.Errorf("requests while rate limited = %q, want one", got)
+	}
+	if !store.limitedUntil.Equal(reset) {
+		t.Errorf("limited until %s, want %s", store.limitedUntil, reset)
+	}
+
+	gh.limited = nil
+	if _, err := store.refresh(context.Background(), repos); err == nil {
+		t.Errorf("refresh before the reset succeeded")
+	}
+	if got := gh.takeRequests(); len(got) != 0 {
+		t.Errorf("requests before the reset = %q, want none", got)
+	}
+
+	// After the reset the counts are fetched again
+	store.limitedUntil = time.Now().Add(-time.Second)
+	changed, err = store.refresh(context.Background(), repos)
+	if err != nil || !changed {
+		t.Errorf("refresh after the reset = %v, %v, want a change", changed, err)
+	}
+	if got := gh.takeRequests(); len(got) != 2 {
+		t.Errorf("requests after the reset = %q, want two", got)
+	}
+}
+
+func TestRateLimitReset(t *testing.T) {
+	now := time.Now()
+	reset := now.Add(10 * time.Minute).Truncate(time.Second)
+	tests := []struct {
+		name    string
+		header  http.Header
+		limited bool
+		after   time.Duration // Minimum wait from now
+		until   time.Time     // Exact time, if not zero
+	}{
+		{name: "not limited", header: http.Header{"X-Ratelimit-Remaining": {"59"}}},
+		{name: "forbidden without headers", header: http.Header{}},
+		{name: "retry after", header: http.Header{"Retry-After": {"120"}}, limited: true, after: 2 * time.Minute},
+		{name: "reset", header: http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {strconv.FormatInt(reset.Unix(), 10)}}, limited: true, until: reset},
+		{name: "reset unknown", header: http.Header{"X-Ratelimit-Remaining": {"0"}}, limited: true, after: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, limited := rateLimitReset(tt.header)
+			if limited != tt.limited {
+				t.Fatalf("limited = %v, want %v", limited, tt.limited)
+			}
+			if !tt.until.IsZero() && !until.Equal(tt.until) {
+				t.Errorf("until = %s, want %s", until, tt.until)
+			}
+			if tt.after > 0 && until.Before(now.Add(tt.after)) {
+				t.Errorf("until = %s, want at least %s from now", until, tt.after)
+			}
+		})
+	}
+}
+
+func TestStarStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", StarsFileName)
+	gh := &fakeGitHub{stars: map[string]int{"gin-gonic/gin": 80000}}
+	store := newTestStarStore(t, path, gh)
+	if _, err := store.refresh(context.Background(), []string{"gin-gonic/gin"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	// A restarted store shows the saved count offline and revalidates it
+	// with the saved ETag
+	reloaded := newTestStarStore(t, path, gh)
+	if stars, ok := reloaded.get("gin-gonic/gin"); !ok || stars != 80000 {
+		t.Errorf("reloaded stars = %d, %v, want 80000", stars, ok)
+	}
+	gh.takeRequests()
+	if changed, err := reloaded.refresh(context.Background(), []string{"gin-gonic/gin"}); err != nil || changed {
+		t.Errorf("refresh of the reloaded store = %v, %v, want no change", changed, err)
+	}
+	if got, want := strings.Join(gh.takeRequests(), ", "), `gin-gonic/gin "gin-gonic/gin-80000"`; got != want {
+		t.Errorf("requests = %s, want %s", got, want)
+	}
+
+	// Nothing is saved while every request fails
+	modified := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	gh.limited = http.Header{"Retry-After": {"60"}}
+	reloaded.refresh(context.Background(), []string{"gin-gonic/gin"})
+	if info, err := os.Stat(path); err != nil || !info.ModTime().Equal(modified) {
+		t.Errorf("stars file rewritten after failed requests: %v", err)
+	}
+
+	// An unreadable file is ignored
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := newStarStore(path).get("gin-gonic/gin"); ok {
+		t.Errorf("counts read from an unreadable file")
+	}
+}
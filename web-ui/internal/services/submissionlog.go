@@ -0,0 +1,274 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Files of the file-backed submission store
+const (
+	submissionLogName   = "submissions.log" // One JSON record per line, only ever appended to
+	submissionIndexName = "submissions.idx" // One index entry per line, rebuilt from the log if behind
+)
+
+// FileSubmissionStore keeps submissions in an append-only log in a data
+// directory. Each record is a line of JSON; an index file holds the fields
+// queries filter on and the position of every record, and is loaded into
+// memory on open. Only the log is synced: an index that is behind after a
+// crash is completed from the log, and a record cut short is dropped. Both
+// files are opened for appending, so a write always lands after the last
+// complete record once a failed one is cut off.
+type FileSubmissionStore struct {
+	mu      sync.RWMutex
+	logFile *os.File
+	idxFile *os.File
+	logSize int64
+	entries []submissionIndexEntry
+	byID    map[int64]int // Position in entries
+	nextID  int64
+}
+
+// NewFileSubmissionStore opens the submission store in dir, creating it if needed
+func NewFileSubmissionStore(dir string) (*FileSubmissionStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %v", err)
+	}
+	logFile, err := os.OpenFile(filepath.Join(dir, submissionLogName), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open submission log: %v", err)
+	}
+	idxFile, err := os.OpenFile(filepath.Join(dir, submissionIndexName), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		logFile.Close()
+		return nil, fmt.Errorf("failed to open submission index: %v", err)
+	}
+
+	s := &FileSubmissionStore{
+		logFile: logFile,
+		idxFile: idxFile,
+		byID:    make(map[int64]int),
+		nextID:  1,
+	}
+	if err := s.load(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the index and brings it up to date with the log
+func (s *FileSubmissionStore) load() error {
+	info, err := s.logFile.Stat()
+	if err != nil {
+		return err
+	}
+	s.logSize = info.Size()
+
+	// Index entries past the end of the log were written for records that
+	// did not make it to disk
+	indexed, _, err := readLines(s.idxFile, 0)
+	if err != nil {
+		return fmt.Errorf("failed to read submission index: %v", err)
+	}
+	var logEnd, idxSize int64
+	for _, line := range indexed {
+		var entry submissionIndexEntry
+		if err := json.Unmarshal(line.data, &entry); err != nil || entry.Offset < logEnd || entry.Offset+entry.Length > s.logSize {
+			break
+		}
+		s.addEntry(entry)
+		logEnd = entry.Offset + entry.Length
+		idxSize = line.end
+	}
+
+	// Records after the last indexed one are added to the index
+	records, end, err := readLines(s.logFile, logEnd)
+	if err != nil {
+		return fmt.Errorf("failed to read submission log: %v", err)
+	}
+	var missing []submissionIndexEntry
+	for _, line := range records {
+		var record SubmissionRecord
+		if err := json.Unmarshal(line.data, &record); err != nil {
+			log.Printf("Skipping unreadable submission record at offset %d: %v", line.start, err)
+			continue
+		}
+		entry := newSubmissionIndexEntry(&record)
+		entry.Offset = line.start
+		entry.Length = line.end - line.start
+		missing = append(missing, entry)
+	}
+	if end < s.logSize {
+		log.Printf("Dropping %d bytes of an incomplete submission record", s.logSize-end)
+		if err := s.logFile.Truncate(end); err != nil {
+			return err
+		}
+		s.logSize = end
+	}
+
+	// Entries after the last good one are rewritten from the log
+	if err := s.idxFile.Truncate(idxSize); err != nil {
+		return err
+	}
+	for _, entry := range missing {
+		if err := s.writeEntry(entry); err != nil {
+			return err
+		}
+		s.addEntry(entry)
+	}
+	sortEntries(s.entries)
+	for i, entry := range s.entries {
+		s.byID[entry.ID] = i
+	}
+	return nil
+}
+
+// addEntry adds an entry to the in-memory index
+func (s *FileSubmissionStore) addEntry(entry submissionIndexEntry) {
+	s.byID[entry.ID] = len(s.entries)
+	s.entries = append(s.entries, entry)
+	if entry.ID >= s.nextID {
+		s.nextID = entry.ID + 1
+	}
+}
+
+// writeEntry appends an entry to the index file
+func (s *FileSubmissionStore) writeEntry(entry submissionIndexEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = s.idxFile.Write(append(data, '\n'))
+	return err
+}
+
+// Add appends a submission to the log and assigns its ID
+func (s *FileSubmissionStore) Add(record *SubmissionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record.ID = s.nextID
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode submission: %v", err)
+	}
+	data = append(data, '\n')
+	if _, err := s.logFile.Write(data); err != nil {
+		s.dropUnsynced()
+		return fmt.Errorf("failed to write submission: %v", err)
+	}
+	if err := s.logFile.Sync(); err != nil {
+		// The record may not have reached the disk; as it gets no ID, it
+		// must not stay in the log for the next record to be written after
+		s.dropUnsynced()
+		return fmt.Errorf("failed to sync submission log: %v", err)
+	}
+
+	entry := newSubmissionIndexEntry(record)
+	entry.Offset = s.logSize
+	entry.Length = int64(len(data))
+	s.logSize += entry.Length
+	s.addEntry(entry)
+	if err := s.writeEntry(entry); err != nil {
+		// The record is safe in the log; the next open re-indexes it
+		log.Printf("Failed to write submission index entry: %v", err)
+	}
+	return nil
+}
+
+// dropUnsynced cuts whatever part of a failed record was written off the log
+func (s *FileSubmissionStore) dropUnsynced() {
+	if err := s.logFile.Truncate(s.logSize); err != nil {
+		log.Printf("Failed to drop a failed submission record: %v", err)
+	}
+}
+
+// Get reads a submission from the log
+func (s *FileSubmissionStore) Get(id int64) (*SubmissionRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.byID[id]
+	if !ok {
+		return nil, ErrSubmissionNotFound
+	}
+	return s.readRecord(s.entries[i])
+}
+
+// Query returns a page of the submissions matching q, newest first
+func (s *FileSubmissionStore) Query(q SubmissionQuery) (SubmissionPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, total := selectEntries(s.entries, q)
+	page := SubmissionPage{Submissions: []SubmissionRecord{}, Total: total, Offset: q.Offset, Limit: q.Limit}
+	for _, entry := range entries {
+		record, err := s.readRecord(entry)
+		if err != nil {
+			return page, err
+		}
+		page.Submissions = append(page.Submissions, *record)
+	}
+	return page, nil
+}
+
+// readRecord reads the record an index entry points to
+func (s *FileSubmissionStore) readRecord(entry submissionIndexEntry) (*SubmissionRecord, error) {
+	data := make([]byte, entry.Length)
+	if _, err := s.logFile.ReadAt(data, entry.Offset); err != nil {
+		return nil, fmt.Errorf("failed to read submission %d: %v", entry.ID, err)
+	}
+	var record SubmissionRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode submission %d: %v", entry.ID, err)
+	}
+	return &record, nil
+}
+
+// Close closes the log and index files
+func (s *FileSubmissionStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idxErr := s.idxFile.Close()
+	if err := s.logFile.Close(); err != nil {
+		return err
+	}
+	return idxErr
+}
+
+// logLine is a complete line of a log file and its byte range
+type logLine struct {
+	data       []byte
+	start, end int64 // end includes the newline
+}
+
+// readLines reads the newline-terminated lines of f from offset on. It
+// returns the end of the last complete line; anything after it is a write
+// that was cut short.
+func readLines(f *os.File, offset int64) ([]logLine, int64, error) {
+	reader := bufio.NewReader(io.NewSectionReader(f, offset, 1<<62))
+	var lines []logLine
+	end := offset
+	for {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return lines, end, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		start := end
+		end += int64(len(data))
+		if line := bytes.TrimSpace(data); len(line) > 0 {
+			lines = append(lines, logLine{data: line, start: start, end: end})
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"web-ui/internal/models"
+)
+
+// addSubmissions adds a submission of challenge 1 per user to a store
+func addSubmissions(t *testing.T, store *FileSubmissionStore, users ...string) {
+	t.Helper()
+	for i, user := range users {
+		record := &SubmissionRecord{Submission: models.Submission{
+			Username:    user,
+			ChallengeID: 1,
+			Code:        "package main\n",
+			SubmittedAt: time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC),
+			Passed:      i%2 == 0,
+		}}
+		if err := store.Add(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// openStore opens the store in dir and closes it when the test ends
+func openStore(t *testing.T, dir string) *FileSubmissionStore {
+	t.Helper()
+	store, err := NewFileSubmissionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// checkSubmissions checks that a store holds the submissions of users with
+// IDs from 1 on
+func checkSubmissions(t *testing.T, store *FileSubmissionStore, users ...string) {
+	t.Helper()
+	page, err := store.Query(SubmissionQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != len(users) {
+		t.Fatalf("store holds %d submissions, want %d", page.Total, len(users))
+	}
+	for i, user := range users {
+		record, err := store.Get(int64(i + 1))
+		if err != nil {
+			t.Fatalf("submission %d: %v", i+1, err)
+		}
+		if record.Username != user || record.Code != "package main\n" {
+			t.Errorf("submission %d = %+v, want one by %s", i+1, record, user)
+		}
+	}
+}
+
+func TestFileSubmissionStoreReopen(t *testing.T) {
+	dir := t.TempDir()
+	store := openStore(t, dir)
+	addSubmissions(t, store, "alice", "bob")
+	store.Close()
+
+	store = openStore(t, dir)
+	checkSubmissions(t, store, "alice", "bob")
+	addSubmissions(t, store, "carol")
+	checkSubmissions(t, store, "alice", "bob", "carol")
+
+	passed := true
+	page, err := store.Query(SubmissionQuery{Passed: &passed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || page.Submissions[0].Username != "carol" {
+		t.Errorf("passed submissions = %+v, want carol's and alice's, newest first", page.Submissions)
+	}
+}
+
+func TestFileSubmissionStoreRebuildsIndex(t *testing.T) {
+	tests := []struct {
+		name  string
+		index func(data []byte) []byte
+	}{
+		{"missing", func([]byte) []byte { return nil }},
+		{"behind the log", func(data []byte) []byte { return data[:len(data)/2] }},
+		{"torn entry", func(data []byte) []byte { return data[:len(data)-3] }},
+		{"garbage", func([]byte) []byte { return []byte("not json\n") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store := openStore(t, dir)
+			addSubmissions(t, store, "alice", "bob", "carol")
+			store.Close()
+
+			path := filepath.Join(dir, submissionIndexName)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, tt.index(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			store = openStore(t, dir)
+			checkSubmissions(t, store, "alice", "bob", "carol")
+			store.Close()
+			if rebuilt, err := os.ReadFile(path); err != nil || string(rebuilt) != string(data) {
+				t.Errorf("rebuilt index =\n%s\nwant\n%s", rebuilt, data)
+			}
+		})
+	}
+}
+
+func TestFileSubmissionStoreTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	store := openStore(t, dir)
+	addSubmissions(t, store, "alice", "bob")
+	store.Close()
+
+	// A crash cut the last record short
+	path := filepath.Join(dir, submissionLogName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	torn := append(append([]byte{}, data...), `{"id":3,"username":"mal`...)
+	if err := os.WriteFile(path, torn, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store = openStore(t, dir)
+	checkSubmissions(t, store, "alice", "bob")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(data)) {
+		t.Fatalf("log is %d bytes after opening, want %d with the torn record dropped", info.Size(), len(data))
+	}
+
+	// The next record starts on a line of its own and survives reopening
+	addSubmissions(t, store, "carol")
+	store.Close()
+	store = openStore(t, dir)
+	checkSubmissions(t, store, "alice", "bob", "carol")
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"web-ui/internal/models"
+)
+
+// ErrSubmissionNotFound is returned for unknown submission IDs
+var ErrSubmissionNotFound = errors.New("submission not found")
+
+// MaxCodeBytes is the size of the largest solution that is run and stored
+const MaxCodeBytes = 64 << 10
+
+// SubmissionRecord is a stored submission with the full result of its run
+type SubmissionRecord struct {
+	ID int64 `json:"id"`
+	models.Submission
+	PackageName        string           `json:"packageName,omitempty"`        // Set for package challenges, e.g. "gin"
+	PackageChallengeID string           `json:"packageChallengeId,omitempty"` // e.g. "challenge-1"
+	Result             *ExecutionResult `json:"result,omitempty"`
+}
+
+// SubmissionQuery selects stored submissions. Zero fields match everything.
+type SubmissionQuery struct {
+	Username           string
+	ChallengeID        int    // Classic challenge
+	PackageName        string // Package of a package challenge
+	PackageChallengeID string // Package challenge, e.g. "challenge-1"
+	Passed             *bool
+	Since              time.Time // Submitted at or after
+	Until              time.Time // Submitted before
+	Offset             int
+	Limit              int // 0 returns every match after Offset
+}
+
+// SubmissionPage is one page of the submissions matching a query, newest first
+type SubmissionPage struct {
+	Submissions []SubmissionRecord `json:"submissions"`
+	Total       int                `json:"total"` // Matches on all pages
+	Offset      int                `json:"offset"`
+	Limit       int                `json:"limit"`
+}
+
+// SubmissionStore keeps every submission and the result of its run
+type SubmissionStore interface {
+	// Add stores a submission and assigns its ID
+	Add(record *SubmissionRecord) error
+	// Get returns a submission by ID, or ErrSubmissionNotFound
+	Get(id int64) (*SubmissionRecord, error)
+	// Query returns a page of the submissions matching q, newest first
+	Query(q SubmissionQuery) (SubmissionPage, error)
+	// Close releases the store's files
+	Close() error
+}
+
+// submissionIndexEntry holds the fields submissions are queried by and, for
+// the file store, where the full record is in the log
+type submissionIndexEntry struct {
+	ID                 int64     `json:"id"`
+	Offset             int64     `json:"offset"`
+	Length             int64     `json:"length"`
+	Username           string    `json:"username"`
+	ChallengeID        int       `json:"challengeId,omitempty"`
+	PackageName        string    `json:"packageName,omitempty"`
+	PackageChallengeID string    `json:"packageChallengeId,omitempty"`
+	Passed             bool      `json:"passed"`
+	SubmittedAt        time.Time `json:"submittedAt"`
+}
+
+// newSubmissionIndexEntry returns the index entry of a record
+func newSubmissionIndexEntry(record *SubmissionRecord) submissionIndexEntry {
+	return submissionIndexEntry{
+		ID:                 record.ID,
+		Username:           record.Username,
+		ChallengeID:        record.ChallengeID,
+		PackageName:        record.PackageName,
+		PackageChallengeID: record.PackageChallengeID,
+		Passed:             record.Passed,
+		SubmittedAt:        record.SubmittedAt,
+	}
+}
+
+// matches reports whether the entry satisfies the query's filters
+func (e *submissionIndexEntry) matches(q SubmissionQuery) bool {
+	switch {
+	case q.Username != "" && e.Username != q.Username:
+		return false
+	case q.ChallengeID != 0 && e.ChallengeID != q.ChallengeID:
+		return false
+	case q.PackageName != "" && e.PackageName != q.PackageName:
+		return false
+	case q.PackageChallengeID != "" && e.PackageChallengeID != q.PackageChallengeID:
+		return false
+	case q.Passed != nil && e.Passed != *q.Passed:
+		return false
+	case !q.Since.IsZero() && e.SubmittedAt.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !e.SubmittedAt.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+// selectEntries returns the page of entries matching q, newest first, and the
+// number of matches. entries must be in ID order.
+func selectEntries(entries []submissionIndexEntry, q SubmissionQuery) ([]submissionIndexEntry, int) {
+	var page []submissionIndexEntry
+	total := 0
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !entries[i].matches(q) {
+			continue
+		}
+		if total >= q.Offset && (q.Limit <= 0 || len(page) < q.Limit) {
+			page = append(page, entries[i])
+		}
+		total++
+	}
+	return page, total
+}
+
+// MemorySubmissionStore keeps submissions in memory, e.g. for tests
+type MemorySubmissionStore struct {
+	mu      sync.RWMutex
+	entries []submissionIndexEntry
+	records map[int64]SubmissionRecord
+	nextID  int64
+}
+
+// NewMemorySubmissionStore creates an empty in-memory submission store
+func NewMemorySubmissionStore() *MemorySubmissionStore {
+	return &MemorySubmissionStore{
+		records: make(map[int64]SubmissionRecord),
+		nextID:  1,
+	}
+}
+
+// Add stores a submission and assigns its ID
+func (s *MemorySubmissionStore) Add(record *SubmissionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record.ID = s.nextID
+	s.nextID++
+	s.records[record.ID] = *record
+	s.entries = append(s.entries, newSubmissionIndexEntry(record))
+	return nil
+}
+
+// Get returns a submission by ID
+func (s *MemorySubmissionStore) Get(id int64) (*SubmissionRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.records[id]
+	if !ok {
+		return nil, ErrSubmissionNotFound
+	}
+	return &record, nil
+}
+
+// Query returns a page of the submissions matching q, newest first
+func (s *MemorySubmissionStore) Query(q SubmissionQuery) (SubmissionPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, total := selectEntries(s.entries, q)
+	page := SubmissionPage{Submissions: []SubmissionRecord{}, Total: total, Offset: q.Offset, Limit: q.Limit}
+	for _, entry := range entries {
+		page.Submissions = append(page.Submissions, s.records[entry.ID])
+	}
+	return page, nil
+}
+
+// Close does nothing for the in-memory store
+func (s *MemorySubmissionStore) Close() error {
+	return nil
+}
+
+// sortEntries orders index entries by ID
+func sortEntries(entries []submissionIndexEntry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"web-ui/internal/models"
+)
+
+// TeamsFileName is the file in the data directory holding the teams
+const TeamsFileName = "teams.json"
+
+// maxTeamName is the longest team name, in characters
+const maxTeamName = 64
+
+// Errors of managing teams
+var (
+	ErrTeamNotFound       = errors.New("team not found")
+	ErrInvalidTeamName    = fmt.Errorf("team names have 1 to %d characters with at least one letter or digit", maxTeamName)
+	ErrInvalidInviteCode  = errors.New("invalid invite code")
+	ErrInvalidTeamRole    = errors.New("roles are owner, mentor or member")
+	ErrAlreadyTeamMember  = errors.New("already a member of the team")
+	ErrNotTeamMember      = errors.New("not a member of the team")
+	ErrTeamPermission     = errors.New("your role in the team does not allow this")
+	ErrLastTeamOwner      = errors.New("a team needs an owner; make someone else owner first")
+	ErrRemoveSelf         = errors.New("leave the team to remove yourself")
+	ErrTeamMemberRequired = errors.New("sign in to manage teams")
+)
+
+// TeamService keeps the teams. Teams handed out are snapshots that never
+// change; every change replaces the team, so callers must not modify them.
+type TeamService struct {
+	path string // Empty keeps the teams in memory only
+
+	mu    sync.RWMutex
+	teams map[string]*models.Team // By ID
+}
+
+// NewTeamService creates a team service keeping its teams in dir
+func NewTeamService(dir string) (*TeamService, error) {
+	ts := &TeamService{teams: make(map[string]*models.Team)}
+	if dir == "" {
+		return ts, nil
+	}
+	ts.path = filepath.Join(dir, TeamsFileName)
+	data, err := ioutil.ReadFile(ts.path)
+	if os.IsNotExist(err) {
+		return ts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &ts.teams); err != nil {
+		return nil, fmt.Errorf("%s: %v", ts.path, err)
+	}
+	return ts, nil
+}
+
+// Team returns a team by ID
+func (ts *TeamService) Team(id string) (*models.Team, bool) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	team, ok := ts.teams[id]
+	return team, ok
+}
+
+// UserTeams returns the teams a user is a member of, by name
+func (ts *TeamService) UserTeams(username string) []*models.Team {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	var teams []*models.Team
+	for _, team := range ts.teams {
+		if _, ok := team.Member(username); ok {
+			teams = append(teams, team)
+		}
+	}
+	sort.Slice(teams, func(i, j int) bool { return teams[i].Name < teams[j].Name })
+	return teams
+}
+
+// CreateTeam creates a team owned by owner, with a new invite code
+func (ts *TeamService) CreateTeam(name, owner string) (*models.Team, error) {
+	name = strings.TrimSpace(name)
+	slug := teamSlug(name)
+	if slug == "" || len([]rune(name)) > maxTeamName {
+		return nil, ErrInvalidTeamName
+	}
+	if owner == "" {
+		return nil, ErrTeamMemberRequired
+	}
+	code, err := newInviteCode()
+	if err != nil {
+		return nil, err
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	id := slug
+	for n := 2; ts.teams[id] != nil; n++ {
+		id = fmt.Sprintf("%s-%d", slug, n)
+	}
+	now := time.Now()
+	team := &models.Team{
+		ID:         id,
+		Name:       name,
+		InviteCode: code,
+		CreatedAt:  now,
+		Members:    []models.TeamMember{{Username: owner, Role: models.RoleOwner, JoinedAt: now}},
+	}
+	if err := ts.put(team); err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+// JoinTeam adds a user to the team with an invite code, as a member
+func (ts *TeamService) JoinTeam(inviteCode, username string) (*models.Team, error) {
+	if username == "" {
+		return nil, ErrTeamMemberRequired
+	}
+	inviteCode = strings.ToUpper(strings.TrimSpace(inviteCode))
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for _, team := range ts.teams {
+		if inviteCode == "" || team.InviteCode != inviteCode {
+			continue
+		}
+		if _, ok := team.Member(username); ok {
+			return nil, ErrAlreadyTeamMember
+		}
+		updated := copyTeam(team)
+		updated.Members = append(updated.Members, models.TeamMember{Username: username, Role: models.RoleMember, JoinedAt: time.Now()})
+		if err := ts.put(updated); err != nil {
+			return nil, err
+		}
+		return updated, nil
+	}
+	return nil, ErrInvalidInviteCode
+}
+
+// LeaveTeam removes a user from a team. The last owner cannot leave while
+// others remain; a team whose last member leaves is deleted.
+func (ts *TeamService) LeaveTeam(id, username string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	team, ok := ts.teams[id]
+	if !ok {
+		return ErrTeamNotFound
+	}
+	member, ok := team.Member(username)
+	if !ok {
+		return ErrNotTeamMember
+	}
+	if len(team.Members) == 1 {
+		delete(ts.teams, id)
+		if err := ts.save(); err != nil {
+			ts.teams[id] = team
+			return err
+		}
+		return nil
+	}
+	if member.Role == models.RoleOwner && countRole(team, models.RoleOwner) == 1 {
+		return ErrLastTeamOwner
+	}
+	return ts.put(withoutMember(team, member.Username))
+}
+
+// SetRole changes the role of a member. Only owners change roles.
+func (ts *TeamService) SetRole(id, actor, username string, role models.TeamRole) (*models.Team, error) {
+	if !role.Valid() {
+		return nil, ErrInvalidTeamRole
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	team, err := ts.authorize(id, actor, models.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	member, ok := team.Member(username)
+	if !ok {
+		return nil, ErrNotTeamMember
+	}
+	if member.Role == models.RoleOwner && role != models.RoleOwner && countRole(team, models.RoleOwner) == 1 {
+		return nil, ErrLastTeamOwner
+	}
+
+	updated := copyTeam(team)
+	for i := range updated.Members {
+		if updated.Members[i].Username == member.Username {
+			updated.Members[i].Role = role
+		}
+	}
+	if err := ts.put(updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// RemoveMember removes a member from a team. Owners remove anyone but
+// themselves, who leave instead; mentors remove members.
+func (ts *TeamService) RemoveMember(id, actor, username string) (*models.Team, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	team, err := ts.authorize(id, actor, models.RoleMentor)
+	if err != nil {
+		return nil, err
+	}
+	member, ok := team.Member(username)
+	if !ok {
+		return nil, ErrNotTeamMember
+	}
+	acting, _ := team.Member(actor)
+	if member.Username == acting.Username {
+		return nil, ErrRemoveSelf
+	}
+	if acting.Role == models.RoleMentor && member.Role != models.RoleMember {
+		return nil, ErrTeamPermission
+	}
+
+	updated := withoutMember(team, member.Username)
+	if err := ts.put(updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// NewInviteCode replaces the invite code of a team, so the old one no longer
+// joins it. Owners and mentors can do this.
+func (ts *TeamService) NewInviteCode(id, actor string) (*models.Team, error) {
+	code, err := newInviteCode()
+	if err != nil {
+		return nil, err
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	team, err := ts.authorize(id, actor, models.RoleMentor)
+	if err != nil {
+		return nil, err
+	}
+	updated := copyTeam(team)
+	updated.InviteCode = code
+	if err := ts.put(updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// authorize returns a team if actor has at least the given role in it.
+// ts.mu must be held.
+func (ts *TeamService) authorize(id, actor string, least models.TeamRole) (*models.Team, error) {
+	team, ok := ts.teams[id]
+	if !ok {
+		return nil, ErrTeamNotFound
+	}
+	member, ok := team.Member(actor)
+	if !ok {
+		return nil, ErrNotTeamMember
+	}
+	if least == models.RoleOwner && member.Role != models.RoleOwner {
+		return nil, ErrTeamPermission
+	}
+	if least == models.RoleMentor && member.Role == models.RoleMember {
+		return nil, ErrTeamPermission
+	}
+	return team, nil
+}
+
+// put stores a team, replacing its previous version, and saves the teams.
+// ts.mu must be held.
+func (ts *TeamService) put(team *models.Team) error {
+	previous, existed := ts.teams[team.ID]
+	ts.teams[team.ID] = team
+	if err := ts.save(); err != nil {
+		if existed {
+			ts.teams[team.ID] = previous
+		} else {
+			delete(ts.teams, team.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// save writes the teams to the service's file, replacing it at once. ts.mu
+// must be held.
+func (ts *TeamService) save() error {
+	if ts.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(ts.teams, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ts.path), 0755); err != nil {
+		return err
+	}
+	tmp := ts.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ts.path)
+}
+
+// copyTeam returns a copy of a team that can be changed
+func copyTeam(team *models.Team) *models.Team {
+	copied := *team
+	copied.Members = append([]models.TeamMember(nil), team.Members...)
+	return &copied
+}
+
+// withoutMember returns a copy of a team without a member
+func withoutMember(team *models.Team, username string) *models.Team {
+	updated := copyTeam(team)
+	updated.Members = updated.Members[:0]
+	for _, member := range team.Members {
+		if member.Username != username {
+			updated.Members = append(updated.Members, member)
+		}
+	}
+	return updated
+}
+
+// countRole counts the members of a team with a role
+func countRole(team *models.Team, role models.TeamRole) int {
+	count := 0
+	for _, member := range team.Members {
+		if member.Role == role {
+			count++
+		}
+	}
+	return count
+}
+
+// teamSlug derives a team ID from its name: lower-case letters and digits
+// separated by single hyphens
+func teamSlug(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	return b.String()
+}
+
+// newInviteCode returns a random invite code of 10 letters and digits that
+// is easy to read out
+func newInviteCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(buf)[:10], nil
+}
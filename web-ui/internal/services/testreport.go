@@ -0,0 +1,188 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// TestStatus is the outcome of a single test or of the whole package
+type TestStatus string
+
+const (
+	TestPassed     TestStatus = "pass"
+	TestFailed     TestStatus = "fail"
+	TestSkipped    TestStatus = "skip"
+	TestIncomplete TestStatus = "incomplete" // Started but never finished, e.g. the run was killed
+)
+
+// TestResult is one test or subtest from a `go test -json` run
+type TestResult struct {
+	Name      string        `json:"name"` // Full name, e.g. "TestSum/negative"
+	Status    TestStatus    `json:"status"`
+	ElapsedMs int64         `json:"elapsedMs"`
+	Output    string        `json:"output,omitempty"` // Everything the test printed, including its --- lines
+	Subtests  []*TestResult `json:"subtests,omitempty"`
+}
+
+// TestReport is the structured result of running a challenge's tests
+type TestReport struct {
+	Package     string        `json:"package"`
+	Status      TestStatus    `json:"status"`
+	ElapsedMs   int64         `json:"elapsedMs"`
+	BuildFailed bool          `json:"buildFailed"`
+	BuildOutput string        `json:"buildOutput,omitempty"` // Compiler output when the build failed
+	Output      string        `json:"output,omitempty"`      // Package output not attributed to a test
+	Tests       []*TestResult `json:"tests"`
+
+	// Counts include subtests, like the "--- PASS:" lines the scoreboards count
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+	Total   int `json:"total"` // Passed + Failed
+}
+
+// Score returns the percentage (0-100) of tests that passed
+func (r *TestReport) Score() int {
+	if r == nil || r.Total == 0 {
+		return 0
+	}
+	return r.Passed * 100 / r.Total
+}
+
+// statusRank orders the outcomes of a test's runs from best to worst
+var statusRank = map[TestStatus]int{
+	TestSkipped:    0,
+	TestPassed:     1,
+	TestIncomplete: 2,
+	TestFailed:     3,
+}
+
+// testEvent is a line of `go test -json` output, as written by test2json
+type testEvent struct {
+	Action  string  `json:"Action"`
+	Package string  `json:"Package"`
+	Test    string  `json:"Test"`
+	Elapsed float64 `json:"Elapsed"` // Seconds
+	Output  string  `json:"Output"`
+}
+
+// buildFailedReport describes a run whose test binary did not compile
+func buildFailedReport(pkg, output string) *TestReport {
+	return &TestReport{
+		Package:     pkg,
+		Status:      TestFailed,
+		BuildFailed: true,
+		BuildOutput: output,
+		Tests:       []*TestResult{},
+	}
+}
+
+// parseTestEvents builds a TestReport from `go test -json` output and returns
+// it together with the plain-text output the events carried. Lines that are
+// not JSON events (e.g. a runtime crash after the stream ended) are kept as
+// package output.
+func parseTestEvents(data []byte) (*TestReport, string) {
+	report := &TestReport{Status: TestIncomplete, Tests: []*TestResult{}}
+	tests := make(map[string]*TestResult)
+	finished := make(map[string]bool) // Tests that finished at least one run
+	running := make(map[string]bool)  // Tests whose latest run did not finish
+	var text, packageOutput strings.Builder
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		var event testEvent
+		if len(line) == 0 || line[0] != '{' || json.Unmarshal(line, &event) != nil {
+			text.Write(line)
+			text.WriteByte('\n')
+			packageOutput.Write(line)
+			packageOutput.WriteByte('\n')
+			continue
+		}
+		if report.Package == "" {
+			report.Package = event.Package
+		}
+
+		if event.Test == "" {
+			switch event.Action {
+			case "output":
+				text.WriteString(event.Output)
+				packageOutput.WriteString(event.Output)
+			case "pass", "fail", "skip":
+				report.Status = TestStatus(event.Action)
+				report.ElapsedMs = secondsToMs(event.Elapsed)
+			}
+			continue
+		}
+
+		test := tests[event.Test]
+		if test == nil {
+			test = &TestResult{Name: event.Test, Status: TestIncomplete}
+			tests[event.Test] = test
+			running[event.Test] = true
+			if parent := parentTest(tests, event.Test); parent != nil {
+				parent.Subtests = append(parent.Subtests, test)
+			} else {
+				report.Tests = append(report.Tests, test)
+			}
+		}
+
+		// With repeated runs (-test.count) the test keeps its worst outcome,
+		// so a test that failed once failed
+		switch event.Action {
+		case "run":
+			running[event.Test] = true
+		case "output":
+			text.WriteString(event.Output)
+			test.Output += event.Output
+		case "pass", "fail", "skip":
+			status := TestStatus(event.Action)
+			if !finished[event.Test] || statusRank[status] > statusRank[test.Status] {
+				test.Status = status
+			}
+			test.ElapsedMs += secondsToMs(event.Elapsed)
+			finished[event.Test] = true
+			running[event.Test] = false
+		}
+	}
+	for name, test := range tests {
+		if running[name] && test.Status != TestFailed {
+			test.Status = TestIncomplete
+		}
+	}
+
+	report.Output = packageOutput.String()
+	for _, test := range tests {
+		switch test.Status {
+		case TestPassed:
+			report.Passed++
+		case TestSkipped:
+			report.Skipped++
+		default:
+			// Tests that never finished did not pass either
+			report.Failed++
+		}
+	}
+	report.Total = report.Passed + report.Failed
+
+	return report, text.String()
+}
+
+// parentTest finds the closest enclosing test of a subtest name
+func parentTest(tests map[string]*TestResult, name string) *TestResult {
+	for i := strings.LastIndex(name, "/"); i > 0; i = strings.LastIndex(name[:i], "/") {
+		if parent, ok := tests[name[:i]]; ok {
+			return parent
+		}
+	}
+	return nil
+}
+
+// secondsToMs converts test2json's elapsed seconds to milliseconds
+func secondsToMs(seconds float64) int64 {
+	return int64(seconds*1000 + 0.5)
+}
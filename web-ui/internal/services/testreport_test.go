@@ -0,0 +1,145 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readFixture reads a file of captured tool output from testdata
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// testTree writes tests and their subtests as "name=status(subtests...)"
+func testTree(tests []*TestResult) string {
+	var parts []string
+	for _, test := range tests {
+		part := test.Name + "=" + string(test.Status)
+		if len(test.Subtests) > 0 {
+			part += "(" + testTree(test.Subtests) + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseTestEvents(t *testing.T) {
+	tests := []struct {
+		fixture                        string
+		status                         TestStatus
+		tree                           string
+		passed, failed, skipped, total int
+		score                          int
+		output                         string // In the package output
+	}{
+		{
+			fixture: "testreport-mixed.jsonl",
+			status:  TestFailed,
+			tree:    "TestSum=fail(TestSum/positive=pass TestSum/negative=pass TestSum/wrong=fail) TestSkipped=skip TestPasses=pass",
+			passed:  3, failed: 2, skipped: 1, total: 5,
+			score:  60,
+			output: "FAIL\texample.com/report",
+		},
+		{
+			// The panicking subtest fails, and its parent with it
+			fixture: "testreport-panic.jsonl",
+			status:  TestFailed,
+			tree:    "TestSum=pass TestPanics=fail(TestPanics/nil_map=fail)",
+			passed:  1, failed: 2, total: 3,
+			score: 33,
+		},
+		{
+			// The run was killed in the middle of a subtest
+			fixture: "testreport-killed.jsonl",
+			status:  TestIncomplete,
+			tree:    "TestSum=incomplete(TestSum/positive=pass TestSum/negative=pass TestSum/wrong=incomplete)",
+			passed:  2, failed: 2, total: 4,
+			score:  50,
+			output: "signal: killed\n",
+		},
+		{
+			// Run three times with -count=3: TestSum failed the first run,
+			// TestStable/racy the last
+			fixture: "testreport-count.jsonl",
+			status:  TestFailed,
+			tree:    "TestSum=fail TestStable=fail(TestStable/racy=fail) TestPasses=pass",
+			passed:  1, failed: 3, total: 4,
+			score:  25,
+			output: "FAIL\texample.com/report",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data := readFixture(t, tt.fixture)
+			report, text := parseTestEvents(data)
+			if report.Package != "example.com/report" || report.Status != tt.status {
+				t.Errorf("package %q with status %s, want example.com/report with %s", report.Package, report.Status, tt.status)
+			}
+			if got := testTree(report.Tests); got != tt.tree {
+				t.Errorf("tests = %s\nwant %s", got, tt.tree)
+			}
+			if report.Passed != tt.passed || report.Failed != tt.failed || report.Skipped != tt.skipped || report.Total != tt.total {
+				t.Errorf("counts = %d passed, %d failed, %d skipped of %d, want %d, %d, %d of %d",
+					report.Passed, report.Failed, report.Skipped, report.Total, tt.passed, tt.failed, tt.skipped, tt.total)
+			}
+			if report.Score() != tt.score {
+				t.Errorf("score = %d, want %d", report.Score(), tt.score)
+			}
+			if !strings.Contains(report.Output, tt.output) {
+				t.Errorf("package output %q does not contain %q", report.Output, tt.output)
+			}
+			if !strings.Contains(text, "=== RUN   TestSum\n") || !strings.Contains(text, report.Output) {
+				t.Errorf("plain-text output is missing lines:\n%s", text)
+			}
+		})
+	}
+}
+
+func TestParseTestEventsOutput(t *testing.T) {
+	report, _ := parseTestEvents(readFixture(t, "testreport-mixed.jsonl"))
+	byName := map[string]*TestResult{}
+	var index func([]*TestResult)
+	index = func(tests []*TestResult) {
+		for _, test := range tests {
+			byName[test.Name] = test
+			index(test.Subtests)
+		}
+	}
+	index(report.Tests)
+
+	for name, want := range map[string]string{
+		"TestSum/wrong": "Sum(1, 1) = 2, want 3",
+		"TestSkipped":   "not yet",
+		"TestPasses":    "fine",
+	} {
+		if test := byName[name]; test == nil || !strings.Contains(test.Output, want) {
+			t.Errorf("output of %s does not contain %q", name, want)
+		}
+	}
+	if strings.Contains(byName["TestSum/positive"].Output, "want 3") {
+		t.Errorf("output of one subtest was attributed to another")
+	}
+
+	panicked, _ := parseTestEvents(readFixture(t, "testreport-panic.jsonl"))
+	if out := panicked.Tests[1].Output; !strings.Contains(out, "panic: assignment to entry in nil map") {
+		t.Errorf("output of TestPanics = %q, want the panic", out)
+	}
+}
+
+func TestParseTestEventsEmpty(t *testing.T) {
+	report, text := parseTestEvents(nil)
+	if report.Status != TestIncomplete || len(report.Tests) != 0 || report.Total != 0 || report.Score() != 0 || text != "" {
+		t.Errorf("report of no output = %+v, %q", report, text)
+	}
+	var missing *TestReport
+	if missing.Score() != 0 {
+		t.Errorf("score of no report = %d", missing.Score())
+	}
+}
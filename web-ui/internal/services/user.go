@@ -1,18 +1,20 @@
 package services
 
 import (
-	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
+	"sync"
 
 	"web-ui/internal/models"
+	"web-ui/internal/scoreboard"
 )
 
-// UserService handles user-related operations
+// UserService handles user-related operations. The cached attempts are
+// never modified once cached; RecordScore replaces a user's attempts with an
+// updated copy, and callers get copies of their own.
 type UserService struct {
+	mu           sync.Mutex
 	userAttempts models.UserAttemptsMap
 }
 
@@ -26,10 +28,24 @@ func NewUserService() *UserService {
 // LoadUserAttempts checks the filesystem for submission directories
 func (us *UserService) LoadUserAttempts(username string, challenges models.ChallengeMap) *models.UserAttemptedChallenges {
 	// If we already loaded this user's attempts, return from cache
-	if attempts, ok := us.userAttempts[username]; ok {
+	if attempts, ok := us.cached(username); ok {
 		return attempts
 	}
 
+	userAttempt := us.scanAttempts(username, challenges)
+
+	// Cache the results, unless attempts recorded while scanning got there first
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if attempts, ok := us.userAttempts[username]; ok {
+		return copyAttempts(attempts)
+	}
+	us.userAttempts[username] = userAttempt
+	return copyAttempts(userAttempt)
+}
+
+// scanAttempts reads a user's attempts from the submission directories
+func (us *UserService) scanAttempts(username string, challenges models.ChallengeMap) *models.UserAttemptedChallenges {
 	// Create new tracking structure
 	userAttempt := &models.UserAttemptedChallenges{
 		Username:     username,
@@ -38,122 +54,112 @@ func (us *UserService) LoadUserAttempts(username string, challenges models.Chall
 	}
 
 	// Scan all challenge directories for this user's submissions
-	for id := range challenges {
-		if us.hasUserSubmission(username, id) {
+	for id, challenge := range challenges {
+		if us.hasUserSubmission(username, challenge) {
 			userAttempt.AttemptedIDs[id] = true
 			// Calculate score based on test results
-			score := us.calculateScore(username, id)
+			score := us.calculateScore(username, challenge)
 			userAttempt.Scores[id] = score
 		}
 	}
-
-	// Cache the results
-	us.userAttempts[username] = userAttempt
 	return userAttempt
 }
 
-// hasUserSubmission checks if a user has a submission for a challenge
-func (us *UserService) hasUserSubmission(username string, challengeID int) bool {
-	// Try different path formats to handle potential path issues
-	// Absolute path
-	submissionDir := filepath.Join("..", fmt.Sprintf("challenge-%d", challengeID), "submissions", username)
-	submissionFile := filepath.Join(submissionDir, "solution-template.go")
-
-	// Check if the file exists
-	if _, err := os.Stat(submissionFile); err == nil {
-		return true
+// cached returns a copy of the cached attempts of a user
+func (us *UserService) cached(username string) (*models.UserAttemptedChallenges, bool) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	attempts, ok := us.userAttempts[username]
+	if !ok {
+		return nil, false
 	}
+	return copyAttempts(attempts), true
+}
 
-	// Alternative path (direct from workspace root)
-	altSubmissionFile := filepath.Join(fmt.Sprintf("challenge-%d", challengeID), "submissions", username, "solution-template.go")
-
-	if _, err := os.Stat(altSubmissionFile); err == nil {
-		return true
+// copyAttempts returns a copy of attempts the caller may modify
+func copyAttempts(attempts *models.UserAttemptedChallenges) *models.UserAttemptedChallenges {
+	copied := &models.UserAttemptedChallenges{
+		Username:     attempts.Username,
+		AttemptedIDs: make(map[int]bool, len(attempts.AttemptedIDs)),
+		Scores:       make(map[int]int, len(attempts.Scores)),
 	}
+	for id, attempted := range attempts.AttemptedIDs {
+		copied.AttemptedIDs[id] = attempted
+	}
+	for id, score := range attempts.Scores {
+		copied.Scores[id] = score
+	}
+	return copied
+}
 
-	return false
+// hasUserSubmission checks if a user has a submission for a challenge
+func (us *UserService) hasUserSubmission(username string, challenge *models.Challenge) bool {
+	_, err := os.Stat(classicSubmission(challenge, username).Path)
+	return err == nil
 }
 
 // GetExistingSolution returns the content of an existing solution file if it exists
-func (us *UserService) GetExistingSolution(username string, challengeID int) string {
+func (us *UserService) GetExistingSolution(username string, challenge *models.Challenge) string {
 	if username == "" {
 		return ""
 	}
 
-	// Try different path formats
-	// First try the relative path from web-ui
-	submissionFile := filepath.Join("..", fmt.Sprintf("challenge-%d", challengeID), "submissions", username, "solution-template.go")
-	content, err := ioutil.ReadFile(submissionFile)
-	if err == nil {
-		return string(content)
-	}
-
-	// Try alternative path from root directory
-	altSubmissionFile := filepath.Join(fmt.Sprintf("challenge-%d", challengeID), "submissions", username, "solution-template.go")
-	content, err = ioutil.ReadFile(altSubmissionFile)
-	if err == nil {
-		return string(content)
+	content, err := ioutil.ReadFile(classicSubmission(challenge, username).Path)
+	if err != nil {
+		return ""
 	}
+	return string(content)
+}
 
-	return ""
+// classicSubmission returns the submission of a user to a classic challenge
+func classicSubmission(challenge *models.Challenge, username string) GradeTarget {
+	return SubmissionTarget(ChallengeRef{ChallengeID: challenge.ID, Dir: challenge.Dir}, username)
 }
 
-// RefreshUserAttempts clears the cache for a user and reloads their attempts
+// RefreshUserAttempts rereads a user's attempts from the filesystem and
+// replaces the cached ones
 func (us *UserService) RefreshUserAttempts(username string, challenges models.ChallengeMap) *models.UserAttemptedChallenges {
-	// Clear cache
-	delete(us.userAttempts, username)
-	// Reload and return
-	return us.LoadUserAttempts(username, challenges)
+	userAttempt := us.scanAttempts(username, challenges)
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	us.userAttempts[username] = userAttempt
+	return copyAttempts(userAttempt)
 }
 
 // GetUserAttempts returns the cached user attempts or loads them if not cached
 func (us *UserService) GetUserAttempts(username string, challenges models.ChallengeMap) *models.UserAttemptedChallenges {
-	if attempts, ok := us.userAttempts[username]; ok {
+	if attempts, ok := us.cached(username); ok {
 		return attempts
 	}
 	return us.LoadUserAttempts(username, challenges)
 }
 
+// RecordScore stores the score of a test run for a user whose attempts are
+// cached, so it is shown before the scoreboard is updated
+func (us *UserService) RecordScore(username string, challengeID int, score int) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	attempts, ok := us.userAttempts[username]
+	if !ok || username == "" {
+		return
+	}
+	updated := copyAttempts(attempts)
+	updated.AttemptedIDs[challengeID] = true
+	updated.Scores[challengeID] = score
+	us.userAttempts[username] = updated
+}
+
 // calculateScore calculates the score for a user's submission for a challenge
-func (us *UserService) calculateScore(username string, challengeID int) int {
+func (us *UserService) calculateScore(username string, challenge *models.Challenge) int {
 	// Read the scoreboard file for this challenge
-	scoreboardPath := filepath.Join("..", fmt.Sprintf("challenge-%d", challengeID), "SCOREBOARD.md")
-	content, err := ioutil.ReadFile(scoreboardPath)
+	board, err := scoreboard.ReadFile(filepath.Join(challenge.Dir, scoreboard.FileName))
 	if err != nil {
-		// Try alternative path
-		scoreboardPath = filepath.Join(fmt.Sprintf("challenge-%d", challengeID), "SCOREBOARD.md")
-		content, err = ioutil.ReadFile(scoreboardPath)
-		if err != nil {
-			// No scoreboard file, return default score
-			return 50
-		}
-	}
-
-	scoreboardContent := string(content)
-
-	// Parse the scoreboard to find this user's results
-	lines := strings.Split(scoreboardContent, "\n")
-	for _, line := range lines {
-		// Look for lines that contain the username
-		if strings.Contains(line, username) && strings.Contains(line, "|") {
-			// Parse the table row: | Username | Passed Tests | Total Tests |
-			parts := strings.Split(line, "|")
-			if len(parts) >= 4 {
-				passedStr := strings.TrimSpace(parts[2])
-				totalStr := strings.TrimSpace(parts[3])
-
-				passed, err1 := strconv.Atoi(passedStr)
-				total, err2 := strconv.Atoi(totalStr)
-
-				if err1 == nil && err2 == nil && total > 0 {
-					// Calculate percentage score
-					score := (passed * 100) / total
-					return score
-				}
-			}
-		}
+		// No scoreboard file, return default score
+		return 50
 	}
 
-	// User not found in scoreboard, return 0
-	return 0
+	// User not found in scoreboard scores 0
+	entry, _ := board.Find(username)
+	return entry.Score()
 }
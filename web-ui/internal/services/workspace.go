@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"web-ui/internal/models"
+)
+
+// workspaceReadyFile marks a workspace directory as fully prepared
+const workspaceReadyFile = ".ready"
+
+// baselinesDirName is the directory of the workspace root that keeps the
+// measured benchmark baselines, outside the workspaces runs build in
+const baselinesDirName = ".baselines"
+
+// workspace is a prepared build directory for one challenge: its go.mod and
+// go.sum with all modules downloaded, the challenge tests and the solution
+// template, compiled once so the build cache is warm. Runs compile in it with
+// the submitted solution overlaid and never modify it.
+type workspace struct {
+	mu          sync.RWMutex // Held for reading while a run builds in dir
+	dir         string
+	module      string   // Module path from go.mod
+	requires    []string // Module paths required by go.mod
+	fingerprint string   // Hash of the inputs the workspace was prepared from
+
+	baselineMu sync.Mutex // Serializes measuring the benchmark baseline
+}
+
+// baselineFile returns the file the benchmark baseline of a config, given by
+// its hash, is kept in for the workspace directory dir
+func baselineFile(dir, configHash string) string {
+	return filepath.Join(filepath.Dir(dir), baselinesDirName, filepath.Base(dir)+"-"+configHash+".json")
+}
+
+// provides reports whether every package is in a module the workspace requires
+func (ws *workspace) provides(packages []string) bool {
+	return len(ws.missing(packages)) == 0
+}
+
+// missing returns the packages that are in none of the required modules
+func (ws *workspace) missing(packages []string) []string {
+	var missing []string
+	for _, pkg := range packages {
+		found := false
+		for _, module := range ws.requires {
+			if pkg == module || strings.HasPrefix(pkg, module+"/") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, pkg)
+		}
+	}
+	return missing
+}
+
+// SetWorkspaceRoot sets the directory prepared challenge workspaces are kept in
+func (es *ExecutionService) SetWorkspaceRoot(dir string) {
+	es.workspacesMu.Lock()
+	defer es.workspacesMu.Unlock()
+	es.workspaceRoot = dir
+}
+
+// workspaceFor returns the challenge's workspace, preparing it first if the
+// challenge's go.mod, go.sum, tests or template changed. The workspace is returned
+// read-locked; the caller must call ws.mu.RUnlock when its build is done.
+func (es *ExecutionService) workspaceFor(ctx context.Context, challenge *models.Challenge, output *lineWriter) (*workspace, error) {
+	key := workspaceKey(challenge)
+	goMod, goSum := readModuleFiles(challenge.Dir)
+	fingerprint := workspaceFingerprint(goMod, goSum, challenge.TestFile, challenge.Template)
+
+	es.workspacesMu.Lock()
+	ws, ok := es.workspaces[key]
+	if !ok {
+		ws = &workspace{}
+		es.workspaces[key] = ws
+	}
+	root := es.workspaceRoot
+	es.workspacesMu.Unlock()
+
+	for {
+		ws.mu.RLock()
+		if ws.fingerprint == fingerprint {
+			return ws, nil
+		}
+		ws.mu.RUnlock()
+
+		ws.mu.Lock()
+		if ws.fingerprint != fingerprint {
+			if err := es.prepareWorkspace(ctx, ws, root, challenge, goMod, goSum, fingerprint, output); err != nil {
+				ws.mu.Unlock()
+				return nil, err
+			}
+		}
+		ws.mu.Unlock()
+	}
+}
+
+// prepareWorkspace builds a fresh workspace directory for the challenge and
+// swaps it in; ws.mu must be held for writing. A directory prepared earlier
+// from the same inputs, e.g. before a restart, is reused as is.
+func (es *ExecutionService) prepareWorkspace(ctx context.Context, ws *workspace, root string, challenge *models.Challenge, goMod, goSum []byte, fingerprint string, output *lineWriter) error {
+	module := modulePath(goMod)
+	if module == "" {
+		module = fmt.Sprintf("challenge-%d", challenge.ID)
+	}
+	dir := filepath.Join(root, strings.NewReplacer("/", "_", "\\", "_").Replace(module)+"-"+fingerprint[:12])
+
+	if _, err := os.Stat(filepath.Join(dir, workspaceReadyFile)); err != nil {
+		output.Line("Preparing workspace for " + module)
+		if err := os.MkdirAll(root, 0755); err != nil {
+			return fmt.Errorf("failed to create workspace root: %v", err)
+		}
+		tempDir, err := ioutil.TempDir(root, ".prepare-")
+		if err != nil {
+			return fmt.Errorf("failed to create workspace: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		if err := es.populateWorkspace(ctx, tempDir, challenge, goMod, goSum, output); err != nil {
+			return err
+		}
+		os.RemoveAll(dir)
+		if err := os.Rename(tempDir, dir); err != nil {
+			return fmt.Errorf("failed to install workspace: %v", err)
+		}
+	}
+
+	preparedMod, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return fmt.Errorf("failed to read workspace go.mod: %v", err)
+	}
+	if ws.dir != "" && ws.dir != dir {
+		// Nobody builds in the old directory: that needs ws.mu for reading
+		os.RemoveAll(ws.dir)
+		if stale, err := filepath.Glob(baselineFile(ws.dir, "*")); err == nil {
+			for _, file := range stale {
+				os.Remove(file)
+			}
+		}
+	}
+	ws.dir = dir
+	ws.module = module
+	ws.requires = requiredModules(preparedMod)
+	ws.fingerprint = fingerprint
+	return nil
+}
+
+// populateWorkspace writes the module, tests and template into dir, downloads
+// the pinned modules and compiles everything once
+func (es *ExecutionService) populateWorkspace(ctx context.Context, dir string, challenge *models.Challenge, goMod, goSum []byte, output *lineWriter) error {
+	if goMod != nil {
+		if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), goMod, 0644); err != nil {
+			return err
+		}
+	} else if err := es.initGoModule(ctx, dir, challenge.ID, output); err != nil {
+		return fmt.Errorf("failed to initialize Go module: %v", err)
+	}
+	if goSum != nil {
+		if err := ioutil.WriteFile(filepath.Join(dir, "go.sum"), goSum, 0644); err != nil {
+			return err
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, solutionTestFileName), []byte(challenge.TestFile), 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, solutionFileName), []byte(challenge.Template), 0644); err != nil {
+		return err
+	}
+
+	if err := es.runGoErr(ctx, dir, output.Writer(), "mod", "download"); err != nil {
+		return fmt.Errorf("failed to download modules: %v", err)
+	}
+
+	// Tests and templates of challenges without a go.mod can still import
+	// modules; add only what go.mod does not pin already. A plain go get
+	// would upgrade pinned modules to their latest version.
+	pinned := &workspace{requires: requiredModules(goMod)}
+	required := es.detectRequiredPackages(challenge.Template+"\n"+challenge.TestFile, challenge.ID)
+	if err := es.installDependencies(ctx, dir, pinned.missing(required), output); err != nil {
+		return err
+	}
+
+	// Warm the build cache. The template usually does not pass the tests and
+	// may not even compile; its dependencies are compiled either way.
+	warm := es.runGo(ctx, dir, nil, "test", "-c", "-o", filepath.Join(dir, ".warm.test"))
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if !warm.Success() {
+		output.Line("Solution template does not compile; dependencies were still built")
+	}
+	os.Remove(filepath.Join(dir, ".warm.test"))
+	if challenge.Execution != nil && challenge.Execution.Race {
+		// The race-instrumented standard library is a separate build
+		es.runGo(ctx, dir, nil, "test", "-c", "-race", "-o", filepath.Join(dir, ".warm.test"))
+		os.Remove(filepath.Join(dir, ".warm.test"))
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, workspaceReadyFile), nil, 0644)
+}
+
+// writeOverlay writes a go build overlay that replaces the workspace's
+// solution file with the one in runDir and returns its path
+func writeOverlay(runDir, workspaceDir string) (string, error) {
+	overlay := struct {
+		Replace map[string]string
+	}{
+		Replace: map[string]string{
+			filepath.Join(workspaceDir, solutionFileName): filepath.Join(runDir, solutionFileName),
+		},
+	}
+	data, err := json.Marshal(overlay)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(runDir, "overlay.json")
+	return path, ioutil.WriteFile(path, data, 0644)
+}
+
+// copyModuleFiles copies go.mod and go.sum from one directory to another
+func copyModuleFiles(from, to string) error {
+	for _, name := range []string{"go.mod", "go.sum"} {
+		data, err := ioutil.ReadFile(filepath.Join(from, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(to, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workspaceKey identifies a challenge's workspace: its directory, or its ID
+// for challenges that were not loaded from disk
+func workspaceKey(challenge *models.Challenge) string {
+	if challenge.Dir != "" {
+		if abs, err := filepath.Abs(challenge.Dir); err == nil {
+			return abs
+		}
+		return challenge.Dir
+	}
+	return fmt.Sprintf("challenge-%d", challenge.ID)
+}
+
+// readModuleFiles reads go.mod and go.sum from a challenge directory; missing
+// files are returned as nil
+func readModuleFiles(dir string) (goMod, goSum []byte) {
+	if dir == "" {
+		return nil, nil
+	}
+	goMod, _ = ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	goSum, _ = ioutil.ReadFile(filepath.Join(dir, "go.sum"))
+	return goMod, goSum
+}
+
+// workspaceFingerprint hashes everything a workspace is prepared from
+func workspaceFingerprint(goMod, goSum []byte, testFile, template string) string {
+	h := sha256.New()
+	for _, part := range [][]byte{goMod, goSum, []byte(testFile), []byte(template)} {
+		fmt.Fprintf(h, "%d:", len(part))
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+var (
+	moduleLineRe  = regexp.MustCompile(`(?m)^module\s+"?([^\s"]+)"?`)
+	requireLineRe = regexp.MustCompile(`^(?:require\s+)?"?([^\s"()]+)"?\s+v\S+`)
+)
+
+// modulePath returns the module path declared in go.mod
+func modulePath(goMod []byte) string {
+	if match := moduleLineRe.FindSubmatch(goMod); match != nil {
+		return string(match[1])
+	}
+	return ""
+}
+
+// requiredModules returns the module paths in go.mod's require directives
+func requiredModules(goMod []byte) []string {
+	var modules []string
+	inBlock := false
+	for _, line := range strings.Split(string(goMod), "\n") {
+		line = strings.TrimSpace(line)
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		switch {
+		case line == "require (":
+			inBlock = true
+		case inBlock && line == ")":
+			inBlock = false
+		case inBlock || strings.HasPrefix(line, "require "):
+			if match := requireLineRe.FindStringSubmatch(line); match != nil {
+				modules = append(modules, match[1])
+			}
+		}
+	}
+	return modules
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"web-ui/internal/models"
+)
+
+func TestRequiredModules(t *testing.T) {
+	tests := []struct {
+		name     string
+		goMod    string
+		module   string
+		requires []string
+	}{
+		{
+			name:   "single and block requires",
+			goMod:  string(readFixture(t, "workspace-grpc.mod")),
+			module: "challenge14",
+			requires: []string{
+				"google.golang.org/grpc",
+				"golang.org/x/net",
+				"golang.org/x/sys",
+				"golang.org/x/text",
+				"google.golang.org/genproto/googleapis/rpc",
+				"google.golang.org/protobuf",
+			},
+		},
+		{
+			name:   "direct and indirect blocks",
+			goMod:  string(readFixture(t, "workspace-cobra.mod")),
+			module: "cobra-challenge-4",
+			requires: []string{
+				"github.com/spf13/cobra", "github.com/spf13/viper", "gopkg.in/yaml.v3",
+				"github.com/fsnotify/fsnotify", "github.com/hashicorp/hcl", "github.com/inconshreveable/mousetrap",
+				"github.com/magiconair/properties", "github.com/mitchellh/mapstructure", "github.com/pelletier/go-toml/v2",
+				"github.com/spf13/afero", "github.com/spf13/cast", "github.com/spf13/jwalterweatherman",
+				"github.com/spf13/pflag", "github.com/subosito/gotenv", "golang.org/x/sys",
+				"golang.org/x/text", "gopkg.in/ini.v1",
+			},
+		},
+		{
+			name:     "quoted",
+			goMod:    "module \"example.com/quoted\"\n\nrequire \"example.com/dep\" v1.0.0 // pinned\n",
+			module:   "example.com/quoted",
+			requires: []string{"example.com/dep"},
+		},
+		{
+			name:  "no go.mod",
+			goMod: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := modulePath([]byte(tt.goMod)); got != tt.module {
+				t.Errorf("module = %q, want %q", got, tt.module)
+			}
+			if got := requiredModules([]byte(tt.goMod)); !reflect.DeepEqual(got, tt.requires) {
+				t.Errorf("requires = %q\nwant %q", got, tt.requires)
+			}
+		})
+	}
+}
+
+func TestWorkspaceMissing(t *testing.T) {
+	ws := &workspace{requires: requiredModules(readFixture(t, "workspace-cobra.mod"))}
+	packages := []string{"github.com/spf13/cobra", "github.com/spf13/cobra/doc", "golang.org/x/sys/unix", "github.com/spf13/cobrax", "github.com/gin-gonic/gin"}
+	if got, want := ws.missing(packages), []string{"github.com/spf13/cobrax", "github.com/gin-gonic/gin"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("missing = %q, want %q", got, want)
+	}
+	if !ws.provides(packages[:3]) || ws.provides(packages) {
+		t.Errorf("provides does not match missing")
+	}
+}
+
+func TestWorkspaceFor(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	challengeDir := t.TempDir()
+	goMod := "module example.com/sum\n\ngo 1.21\n"
+	if err := os.WriteFile(filepath.Join(challengeDir, "go.mod"), []byte(goMod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	challenge := &models.Challenge{
+		ID:       7,
+		Dir:      challengeDir,
+		Template: "package sum\n\nfunc Sum(a, b int) int { return 0 }\n",
+		TestFile: "package sum\n\nimport \"testing\"\n\nfunc TestSum(t *testing.T) {\n\tif Sum(1, 2) != 3 {\n\t\tt.Fail()\n\t}\n}\n",
+	}
+	root := t.TempDir()
+
+	// prepare gets the challenge's workspace and reports whether it was
+	// prepared rather than reused
+	prepare := func(es *ExecutionService) (string, bool) {
+		t.Helper()
+		prepared := false
+		output := newLineWriter(PhaseBuild, func(phase, line string) {
+			if strings.HasPrefix(line, "Preparing workspace") {
+				prepared = true
+			}
+		})
+		ws, err := es.workspaceFor(context.Background(), challenge, output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ws.mu.RUnlock()
+		if ws.module != "example.com/sum" {
+			t.Errorf("module = %q", ws.module)
+		}
+		return ws.dir, prepared
+	}
+
+	es := NewExecutionService()
+	es.SetWorkspaceRoot(root)
+	dir, prepared := prepare(es)
+	if !prepared {
+		t.Errorf("the first run did not prepare a workspace")
+	}
+	for name, want := range map[string]string{"go.mod": goMod, solutionFileName: challenge.Template, solutionTestFileName: challenge.TestFile} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != want {
+			t.Errorf("%s of the workspace = %q, %v, want the challenge's", name, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, workspaceReadyFile)); err != nil {
+		t.Errorf("workspace is not marked ready: %v", err)
+	}
+
+	if again, prepared := prepare(es); again != dir || prepared {
+		t.Errorf("the second run prepared %s again", again)
+	}
+
+	// A restarted server reuses the directory
+	restarted := NewExecutionService()
+	restarted.SetWorkspaceRoot(root)
+	if again, prepared := prepare(restarted); again != dir || prepared {
+		t.Errorf("after a restart the workspace was prepared again in %s", again)
+	}
+
+	// Baselines are kept beside the workspace, not in it
+	baseline := baselineFile(dir, "0123456789ab")
+	if filepath.Dir(filepath.Dir(baseline)) != root {
+		t.Errorf("baseline file %s is not in the workspace root", baseline)
+	}
+	if err := os.MkdirAll(filepath.Dir(baseline), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(baseline, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A changed template needs a new workspace, which replaces the old one
+	// and its baselines
+	challenge.Template = "package sum\n\nfunc Sum(a, b int) int { return a + b }\n"
+	changed, prepared := prepare(restarted)
+	if changed == dir || !prepared {
+		t.Errorf("a changed template reused the workspace")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("the old workspace was kept: %v", err)
+	}
+	if _, err := os.Stat(baseline); !os.IsNotExist(err) {
+		t.Errorf("the old workspace's baseline was kept: %v", err)
+	}
+}
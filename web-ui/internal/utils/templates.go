@@ -420,6 +420,11 @@ func GetTemplateFuncs() template.FuncMap {
 			}
 			return fmt.Sprintf("%d", stars)
 		},
+		"clock": func(ms int64) string {
+			// Minutes and seconds, e.g. 12:05, for times into an interview
+			seconds := ms / 1000
+			return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+		},
 		"truncate": func(length int, s string) string {
 			if len(s) <= length {
 				return s
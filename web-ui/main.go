@@ -1,11 +1,23 @@
 package main
 
 import (
+	"context"
 	"embed"
-	"fmt"
+	"flag"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
+	"web-ui/internal/auth"
+	"web-ui/internal/config"
+	"web-ui/internal/modproxy"
+	"web-ui/internal/sandbox"
 	"web-ui/internal/server"
 	"web-ui/internal/services"
 )
@@ -13,13 +25,70 @@ import (
 //go:embed templates static
 var content embed.FS
 
+// shutdownTimeout is how long requests in flight, such as code runs, may take
+// to finish once the server is asked to stop
+const shutdownTimeout = 30 * time.Second
+
 func main() {
+	// Act as the sandbox helper when re-executed by the execution service
+	sandbox.RunHelperIfRequested()
+
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
+	cfg := config.Default()
+	cfg.RegisterFlags(flag.CommandLine)
+	if err := cfg.Parse(flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	log.Printf("Serving challenges from %s", cfg.RepoRoot)
+
 	// Initialize services
-	challengeService := services.NewChallengeService()
+	challengeService := services.NewChallengeService(cfg.RepoRoot)
 	scoreboardService := services.NewScoreboardService()
 	userService := services.NewUserService()
-	executionService := services.NewExecutionService()
-	packageService := services.NewPackageService()
+	executionService, err := newExecutionService(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	packageService := services.NewPackageService(cfg.PackagesDir)
+	packageService.SetGitHubStars(cfg.Features.GitHubStars)
+	packageService.SetStarsFile(filepath.Join(cfg.DataDir, services.StarsFileName))
+	jobService := services.NewJobService(cfg.Workers)
+	submissionStore, err := services.NewFileSubmissionStore(cfg.DataDir)
+	if err != nil {
+		log.Fatalf("Failed to open submission store: %v", err)
+	}
+	authService, err := auth.NewService(cfg.DataDir)
+	if err != nil {
+		log.Fatalf("Failed to open accounts: %v", err)
+	}
+	authService.SetRequired(cfg.Auth.Required)
+	authService.SetSignup(cfg.Auth.Signup)
+	authService.SetSessionTTL(time.Duration(cfg.Auth.SessionTTL))
+	authService.SetPublicURL(cfg.Auth.PublicURL)
+	authService.SetAdminToken(cfg.Auth.AdminToken)
+	if cfg.Auth.GitHub.ClientID != "" {
+		authService.AddProvider(auth.NewGitHubProvider(cfg.Auth.GitHub.ClientID, cfg.Auth.GitHub.ClientSecret))
+	}
+	teamService, err := services.NewTeamService(cfg.DataDir)
+	if err != nil {
+		log.Fatalf("Failed to open teams: %v", err)
+	}
+	interviewService, err := services.NewInterviewService(cfg.DataDir)
+	if err != nil {
+		log.Fatalf("Failed to open interview sessions: %v", err)
+	}
+	recorderService, err := services.NewRecorderService(cfg.DataDir)
+	if err != nil {
+		log.Fatalf("Failed to open editing timelines: %v", err)
+	}
+	if cfg.Auth.Required {
+		log.Println("Submitting and saving solutions requires signing in")
+	} else {
+		log.Println("Signing in is off: requests act as the username they name")
+	}
 
 	// Load data
 	log.Println("Loading challenges...")
@@ -36,6 +105,45 @@ func main() {
 	if err := packageService.LoadPackages(); err != nil {
 		log.Fatalf("Failed to load packages: %v", err)
 	}
+	if cfg.Features.GitHubStars {
+		// Pages show the last known star counts until the refresh is done
+		go packageService.WatchStars(context.Background(), time.Duration(cfg.StarsInterval))
+	}
+
+	if isolation, _ := sandbox.ParseIsolation(cfg.Isolation); isolation != sandbox.IsolationNone {
+		if effective, err := executionService.Isolation(); err == nil {
+			log.Println("Submitted code runs in isolated namespaces")
+		} else if effective == sandbox.IsolationNone {
+			log.Printf("Warning: namespace isolation unavailable, running submitted code without it: %v", err)
+		} else {
+			log.Printf("Warning: namespace isolation unavailable, code runs will fail: %v", err)
+		}
+	}
+
+	// Reload challenges, scoreboards and packages when their files change
+	contentWatcher := services.NewContentWatcher(challengeService, scoreboardService, packageService)
+	if cfg.WatchInterval > 0 {
+		go contentWatcher.Watch(context.Background(), time.Duration(cfg.WatchInterval))
+	}
+
+	// Listen first, so the module proxy URL has the actual port
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", cfg.Listen, err)
+	}
+
+	// Serve dependencies of submissions from the local module store
+	moduleProxy := modproxy.NewProxy(cfg.ModulesDir)
+	if modules := moduleProxy.Modules(); !cfg.Features.ModuleProxy {
+		log.Printf("Module proxy is turned off, dependencies are downloaded from the internet")
+		moduleProxy = nil
+	} else if len(modules) > 0 {
+		executionService.SetModuleProxy(localURL(listener.Addr())+"/goproxy", modules)
+		log.Printf("Serving %d modules from %s as the Go module proxy", len(modules), cfg.ModulesDir)
+	} else {
+		log.Printf("Module store %s is empty, dependencies are downloaded from the internet (run `go run . import-modules` to work offline)", cfg.ModulesDir)
+		moduleProxy = nil
+	}
 
 	// Initialize server
 	srv := server.NewServer(
@@ -45,13 +153,60 @@ func main() {
 		userService,
 		executionService,
 		packageService,
+		jobService,
+		submissionStore,
+		contentWatcher,
+		authService,
+		teamService,
+		interviewService,
+		recorderService,
+		cfg.Features,
 	)
 
 	// Setup routes
 	mux := srv.SetupRoutes()
+	if moduleProxy != nil {
+		mux.Handle("/goproxy/", http.StripPrefix("/goproxy", moduleProxy))
+	}
 
 	// Start server
-	port := 8080
-	log.Printf("Server starting on http://localhost:%d", port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), mux))
+	httpServer := &http.Server{Handler: mux}
+	stopped := make(chan struct{})
+	go func() {
+		// On SIGINT or SIGTERM, finish the requests in flight and stop
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		log.Printf("Received %v, shutting down", <-signals)
+		signal.Stop(signals)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.Printf("Warning: requests still running at shutdown: %v", err)
+		}
+		close(stopped)
+	}()
+
+	log.Printf("Server starting on %s", localURL(listener.Addr()))
+	if err := httpServer.Serve(listener); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-stopped
+	if err := submissionStore.Close(); err != nil {
+		log.Fatalf("Failed to close submission store: %v", err)
+	}
+	log.Println("Server stopped")
+}
+
+// localURL returns the URL the server is reached at from this machine;
+// servers listening on every address are reached through the loopback one
+func localURL(addr net.Addr) string {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return "http://" + addr.String()
+	}
+	host := tcpAddr.IP.String()
+	if tcpAddr.IP.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(tcpAddr.Port))
 }
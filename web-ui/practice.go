@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"web-ui/internal/auth"
+	"web-ui/internal/config"
+	"web-ui/internal/scoreboard"
+	"web-ui/internal/services"
+	"web-ui/internal/utils"
+)
+
+// practice is what the local practice commands work with: the challenges,
+// the grader that runs submissions exactly as the web UI and the scoreboard
+// workflows do, and the user practicing
+type practice struct {
+	grader     *services.GradingService
+	challenges []services.ChallengeRef
+	workDir    string // The directory the command was started in
+	username   string
+}
+
+// newPractice sets up a practice command and works out the user's GitHub
+// username from the flag or else from git
+func newPractice(cfg *config.Config, username string) (*practice, error) {
+	workDir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	if username == "" {
+		info := utils.GetGitUsername()
+		if info.Username == "" {
+			return nil, fmt.Errorf("no GitHub username found in the git remote origin or git config; pass -user")
+		}
+		username = info.Username
+		fmt.Fprintf(os.Stderr, "Practicing as %s (from %s; pass -user to change)\n", username, info.Source)
+	}
+	if !auth.ValidUsername(username) {
+		return nil, fmt.Errorf("%q is not a GitHub username; pass yours with -user", username)
+	}
+
+	grader, err := newGrader(cfg)
+	if err != nil {
+		return nil, err
+	}
+	challenges, err := grader.Challenges()
+	if err != nil {
+		return nil, err
+	}
+	return &practice{grader: grader, challenges: challenges, workDir: workDir, username: username}, nil
+}
+
+// challenge resolves a challenge given on the command line: a number or
+// challenge-N for a classic challenge, or package/challenge-id for a package
+// challenge, where the ID may be shortened to its number, e.g. gin/1. Without
+// a name, the challenge whose directory the command was started in is used.
+func (p *practice) challenge(name string) (services.ChallengeRef, error) {
+	if name == "" {
+		for _, challenge := range p.challenges {
+			dir := filepath.Clean(challenge.Dir)
+			if p.workDir == dir || strings.HasPrefix(p.workDir, dir+string(filepath.Separator)) {
+				return challenge, nil
+			}
+		}
+		return services.ChallengeRef{}, fmt.Errorf("not in a challenge directory; name the challenge, e.g. 1 or gin/challenge-1-basic-routing")
+	}
+
+	name = strings.TrimPrefix(strings.Trim(filepath.ToSlash(name), "/"), "packages/")
+	if _, err := strconv.Atoi(name); err == nil {
+		name = "challenge-" + name
+	}
+	packageName, id, isPackage := strings.Cut(name, "/")
+	if _, err := strconv.Atoi(id); err == nil {
+		id = "challenge-" + id
+	}
+	for _, challenge := range p.challenges {
+		if challenge.String() == name {
+			return challenge, nil
+		}
+		if isPackage && challenge.PackageName == packageName && strings.HasPrefix(challenge.PackageChallengeID, id+"-") {
+			return challenge, nil
+		}
+	}
+	return services.ChallengeRef{}, fmt.Errorf("unknown challenge %q", name)
+}
+
+// practiceFlags adds the flags every practice command has: the
+// configuration and the user
+func practiceFlags(fs *flag.FlagSet) (cfg *config.Config, username *string) {
+	cfg = config.Default()
+	cfg.RegisterFlags(fs)
+	username = fs.String("user", "", "your GitHub username (default: from the git remote origin or git config)")
+	return cfg, username
+}
+
+// initCommand creates the user's submission to a challenge from its template
+func initCommand(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	cfg, username := practiceFlags(fs)
+	force := fs.Bool("force", false, "replace an existing submission with the template")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: web-ui init [flags] challenge\n\n")
+		fmt.Fprintf(fs.Output(), "Copies the solution template of a challenge, e.g. 1 or gin/challenge-1-basic-routing,\n")
+		fmt.Fprintf(fs.Output(), "into your submissions directory. An existing submission is kept.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := cfg.Parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one challenge")
+	}
+
+	p, err := newPractice(cfg, *username)
+	if err != nil {
+		return err
+	}
+	ref, err := p.challenge(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	challenge, err := p.grader.Challenge(ref)
+	if err != nil {
+		return err
+	}
+
+	target := services.SubmissionTarget(ref, p.username)
+	path := p.displayPath(target.Path)
+	if _, err := os.Stat(target.Path); err == nil && !*force {
+		fmt.Printf("Your submission to %s already exists at %s\n", ref, path)
+	} else {
+		if err := os.MkdirAll(filepath.Dir(target.Path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(target.Path, []byte(challenge.Template), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Copied the solution template of %s to %s\n", ref, path)
+	}
+	if _, err := os.Stat(filepath.Join(ref.Dir, "learning.md")); err == nil {
+		fmt.Printf("Learning materials are in %s and on the challenge page of the web UI\n", p.displayPath(filepath.Join(ref.Dir, "learning.md")))
+	}
+	fmt.Printf("Run the tests with ./run_tests.sh in %s\n", p.displayPath(ref.Dir))
+	return nil
+}
+
+// testCommand runs the user's submission to a challenge with the execution
+// service, streaming the test output
+func testCommand(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	cfg, username := practiceFlags(fs)
+	race := fs.Bool("race", false, "run the tests with the race detector")
+	run := fs.String("run", "", "only run the tests matching this regular expression, as go test -run")
+	bench := fs.Bool("bench", false, "run the benchmarks once the tests pass and compare them to the challenge's thresholds")
+	cover := fs.Bool("cover", false, "report the coverage of your solution")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: web-ui test [flags] [challenge]\n\n")
+		fmt.Fprintf(fs.Output(), "Runs the tests of a challenge against your submission, as the web UI does.\n")
+		fmt.Fprintf(fs.Output(), "The challenge defaults to the one of the current directory.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := cfg.Parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one challenge")
+	}
+
+	p, err := newPractice(cfg, *username)
+	if err != nil {
+		return err
+	}
+	ref, err := p.challenge(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	target, err := p.submission(ref)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Testing %s\n", p.displayPath(target.Path))
+	opts := services.RunOptions{Race: *race, Run: *run, Benchmark: *bench, Coverage: *cover}
+	result := p.grader.Run(context.Background(), target, opts, func(phase, line string) {
+		// test2json markers of output outside any test carry no name
+		if strings.TrimSpace(line) != "=== NAME" {
+			fmt.Println(line)
+		}
+	})
+	if result.Status == services.StatusError || result.Status == services.StatusIsolationError {
+		// Nothing was streamed for runs that could not be carried out
+		fmt.Println(strings.TrimSpace(result.Output))
+	}
+	fmt.Println()
+	fmt.Println(runSummary(result))
+	if !result.Passed {
+		return fmt.Errorf("%s", result.Status)
+	}
+	return nil
+}
+
+// statusCommand lists the user's submissions with their git state and
+// scoreboard rows
+func statusCommand(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	cfg, username := practiceFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: web-ui status [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Lists your submissions, whether they are committed, and their scoreboard rows.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := cfg.Parse(fs, args); err != nil {
+		return err
+	}
+
+	p, err := newPractice(cfg, *username)
+	if err != nil {
+		return err
+	}
+
+	var targets []services.GradeTarget
+	var paths []string
+	for _, challenge := range p.challenges {
+		target := services.SubmissionTarget(challenge, p.username)
+		if _, err := os.Stat(target.Path); err == nil {
+			targets = append(targets, target)
+			paths = append(paths, target.Path)
+		}
+	}
+	if len(targets) == 0 {
+		fmt.Printf("%s has no submissions yet; start one with `init <challenge>`\n", p.username)
+		return nil
+	}
+	changes, err := gitChanges(paths)
+	if err != nil {
+		return err
+	}
+
+	completed := make(map[bool]int) // Package challenge -> completed
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHALLENGE\tGIT\tSCOREBOARD")
+	for _, target := range targets {
+		listed := "not listed"
+		board, err := scoreboard.ReadFile(target.ScoreboardPath())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil {
+			if entry, ok := board.Find(p.username); ok {
+				listed = fmt.Sprintf("%d/%d", entry.Passed, entry.Total)
+				if entry.Completed() {
+					listed += " completed"
+					completed[target.PackageName != ""]++
+				}
+			}
+		}
+		state, ok := changes[target.Path]
+		if !ok {
+			state = "committed"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", target.ChallengeRef, state, listed)
+	}
+	w.Flush()
+
+	classic, packages := 0, 0
+	for _, challenge := range p.challenges {
+		if challenge.PackageName == "" {
+			classic++
+		} else {
+			packages++
+		}
+	}
+	fmt.Printf("\n%s completed %d of %d classic and %d of %d package challenges\n",
+		p.username, completed[false], classic, completed[true], packages)
+	return nil
+}
+
+// submitCommand grades the user's submission to a challenge as the
+// scoreboard workflows will, and commits it if it passes
+func submitCommand(args []string) error {
+	fs := flag.NewFlagSet("submit", flag.ExitOnError)
+	cfg, username := practiceFlags(fs)
+	commit := fs.Bool("commit", false, "commit the submission if it passes")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: web-ui submit [flags] [challenge]\n\n")
+		fmt.Fprintf(fs.Output(), "Grades your submission to a challenge exactly as the scoreboard workflows do\n")
+		fmt.Fprintf(fs.Output(), "and shows how to send it in. Pushing is left to you.\n")
+		fmt.Fprintf(fs.Output(), "The challenge defaults to the one of the current directory.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := cfg.Parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one challenge")
+	}
+
+	p, err := newPractice(cfg, *username)
+	if err != nil {
+		return err
+	}
+	ref, err := p.challenge(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	target, err := p.submission(ref)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Grading %s\n", p.displayPath(target.Path))
+	graded := p.grader.Grade(context.Background(), []services.GradeTarget{target}, 1, nil)[0]
+	if !graded.Entry.Completed() {
+		fmt.Println(strings.TrimSpace(graded.Result.Output))
+		fmt.Println()
+		fmt.Println(runSummary(graded.Result))
+		return fmt.Errorf("the scoreboard would list %d/%d; fix the failing tests before submitting", graded.Entry.Passed, graded.Entry.Total)
+	}
+	fmt.Printf("%s\nThe scoreboard will list %s with %d/%d\n\n", runSummary(graded.Result), p.username, graded.Entry.Passed, graded.Entry.Total)
+
+	message := fmt.Sprintf("Add solution for Challenge %d", ref.ChallengeID)
+	if ref.PackageName != "" {
+		message = fmt.Sprintf("Add solution for %s %s", ref.PackageName, ref.PackageChallengeID)
+	}
+	path := p.displayPath(target.Path)
+	if *commit {
+		if _, err := gitOutput(".", "add", "--", target.Path); err != nil {
+			return err
+		}
+		if _, err := gitOutput(".", "commit", "-m", message, "--", target.Path); err != nil {
+			return err
+		}
+		fmt.Printf("Committed %s\n", path)
+		fmt.Println("Push it to your fork and open a pull request:")
+	} else {
+		fmt.Println("Commit it, push it to your fork and open a pull request:")
+		fmt.Printf("  git add %s\n", path)
+		fmt.Printf("  git commit -m %q\n", message)
+	}
+	fmt.Println("  git push origin HEAD")
+	return nil
+}
+
+// submission returns the user's existing submission to a challenge
+func (p *practice) submission(challenge services.ChallengeRef) (services.GradeTarget, error) {
+	target := services.SubmissionTarget(challenge, p.username)
+	if _, err := os.Stat(target.Path); err != nil {
+		return target, fmt.Errorf("%s has no submission to %s; start one with `init %s`", p.username, challenge, challenge)
+	}
+	return target, nil
+}
+
+// displayPath returns a path as seen from the directory the command was
+// started in
+func (p *practice) displayPath(path string) string {
+	if rel, err := filepath.Rel(p.workDir, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// runSummary sums up a run in one line
+func runSummary(result services.ExecutionResult) string {
+	label := "PASS"
+	if !result.Passed {
+		label = "FAIL"
+	}
+	summary := fmt.Sprintf("%s (%s) in %dms", label, result.Status, result.ExecutionMs)
+	if result.Report != nil {
+		summary = fmt.Sprintf("%s: %d/%d tests passed", summary, result.Report.Passed, result.Report.Total)
+	}
+	if result.Coverage != nil {
+		summary = fmt.Sprintf("%s, %.1f%% of statements covered", summary, result.Coverage.Percent)
+	}
+	if result.Message != "" {
+		summary += " - " + result.Message
+	}
+	return summary
+}
+
+// gitChanges returns the uncommitted state of those of the paths that have
+// any, e.g. "modified" or "untracked"
+func gitChanges(paths []string) (map[string]string, error) {
+	output, err := gitOutput(".", append([]string{"status", "--porcelain", "-z", "--untracked-files=all", "--"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	root, err := gitRoot(".")
+	if err != nil {
+		return nil, err
+	}
+	states := map[byte]string{'M': "modified", 'A': "added", 'D': "deleted", '?': "untracked"}
+	changes := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimRight(output, "\x00"), "\x00") {
+		if len(line) < 4 {
+			continue
+		}
+		// XY path: X is the staged state, Y the unstaged one
+		state := states[line[1]]
+		if state == "" {
+			state = states[line[0]]
+		}
+		if state == "" {
+			state = "changed"
+		}
+		changes[filepath.Join(root, filepath.FromSlash(line[3:]))] = state
+	}
+	return changes, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"web-ui/internal/services"
+)
+
+func TestPracticeChallenge(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	p := &practice{challenges: []services.ChallengeRef{
+		{ChallengeID: 1, Dir: filepath.Join(root, "challenge-1")},
+		{ChallengeID: 10, Dir: filepath.Join(root, "challenge-10")},
+		{PackageName: "gin", PackageChallengeID: "challenge-1-basic-routing", Dir: filepath.Join(root, "packages", "gin", "challenge-1-basic-routing")},
+		{PackageName: "gin", PackageChallengeID: "challenge-10-graceful-shutdown", Dir: filepath.Join(root, "packages", "gin", "challenge-10-graceful-shutdown")},
+	}}
+	tests := []struct {
+		name    string
+		arg     string
+		workDir string // Relative to root
+		want    string // The challenge, or a part of the error
+	}{
+		{name: "number", arg: "1", want: "challenge-1"},
+		{name: "two-digit number", arg: "10", want: "challenge-10"},
+		{name: "directory name", arg: "challenge-10", want: "challenge-10"},
+		{name: "directory with slash", arg: "challenge-1/", want: "challenge-1"},
+		{name: "package challenge", arg: "gin/challenge-1-basic-routing", want: "gin/challenge-1-basic-routing"},
+		{name: "package directory", arg: "packages/gin/challenge-10-graceful-shutdown/", want: "gin/challenge-10-graceful-shutdown"},
+		{name: "package challenge number", arg: "gin/1", want: "gin/challenge-1-basic-routing"},
+		{name: "package challenge prefix", arg: "gin/challenge-10", want: "gin/challenge-10-graceful-shutdown"},
+		{name: "challenge directory", workDir: "challenge-10", want: "challenge-10"},
+		{name: "submission directory", workDir: "challenge-1/submissions/alice", want: "challenge-1"},
+		{name: "package submission directory", workDir: "packages/gin/challenge-1-basic-routing/submissions/alice", want: "gin/challenge-1-basic-routing"},
+		{name: "argument over directory", arg: "10", workDir: "challenge-1", want: "challenge-10"},
+		{name: "unknown number", arg: "99", want: `unknown challenge "challenge-99"`},
+		{name: "unknown package", arg: "echo/1", want: `unknown challenge "echo/1"`},
+		{name: "unknown package challenge", arg: "gin/2", want: `unknown challenge "gin/2"`},
+		{name: "outside challenges", workDir: "web-ui", want: "not in a challenge directory"},
+		{name: "repository root", want: "not in a challenge directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p.workDir = filepath.Join(root, filepath.FromSlash(tt.workDir))
+			challenge, err := p.challenge(tt.arg)
+			if err != nil {
+				if !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("challenge(%q) in %s: %v, want %s", tt.arg, tt.workDir, err, tt.want)
+				}
+				return
+			}
+			if challenge.String() != tt.want {
+				t.Errorf("challenge(%q) in %s = %s, want %s", tt.arg, tt.workDir, challenge, tt.want)
+			}
+		})
+	}
+}
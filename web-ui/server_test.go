@@ -0,0 +1,699 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"web-ui/internal/auth"
+	"web-ui/internal/config"
+	"web-ui/internal/handlers"
+	"web-ui/internal/models"
+	"web-ui/internal/sandbox"
+	"web-ui/internal/server"
+	"web-ui/internal/services"
+)
+
+func TestMain(m *testing.M) {
+	// Code runs re-execute the test binary as the sandbox helper
+	sandbox.RunHelperIfRequested()
+	os.Exit(m.Run())
+}
+
+const (
+	testSolution = `package main
+
+// Sum returns the sum of a and b.
+func Sum(a int, b int) int {
+	return a + b
+}
+`
+	testFailingSolution = `package main
+
+// Sum returns the sum of a and b.
+func Sum(a int, b int) int {
+	return 0
+}
+`
+	testTests = `package main
+
+import "testing"
+
+func TestSum(t *testing.T) {
+	if got := Sum(2, 3); got != 5 {
+		t.Errorf("Sum(2, 3) = %d, want 5", got)
+	}
+}
+
+func TestSumNegative(t *testing.T) {
+	if got := Sum(-2, -3); got != -5 {
+		t.Errorf("Sum(-2, -3) = %d, want -5", got)
+	}
+}
+`
+)
+
+// newTestRepo writes a repository with one small classic challenge, a
+// committed submission by alice and no packages
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"challenge-1/README.md":                              "# Challenge 1: Sum of Two Numbers\n\nWrite a function `Sum`.\n",
+		"challenge-1/go.mod":                                 "module challenge1\n\ngo 1.21\n",
+		"challenge-1/solution-template.go":                   testFailingSolution,
+		"challenge-1/solution-template_test.go":              testTests,
+		"challenge-1/SCOREBOARD.md":                          "# Scoreboard for challenge-1\n| Username   | Passed Tests | Total Tests |\n|------------|--------------|-------------|\n| alice | 2 | 2 |\n",
+		"challenge-1/submissions/alice/solution-template.go": testSolution,
+		"packages/.keep":                                     "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// newTestServer serves the web UI for the repository at root the way main
+// does, with its content reloaded every few milliseconds. With authRequired
+// users have to sign in to submit and save.
+func newTestServer(t *testing.T, root string, authRequired bool) *httptest.Server {
+	t.Helper()
+	cfg := config.Default()
+	cfg.RepoRoot = root
+	cfg.PackagesDir = filepath.Join(root, "packages")
+	cfg.DataDir = t.TempDir()
+	cfg.Workers = 2
+
+	challengeService := services.NewChallengeService(cfg.RepoRoot)
+	if err := challengeService.LoadChallenges(); err != nil {
+		t.Fatal(err)
+	}
+	scoreboardService := services.NewScoreboardService()
+	if err := scoreboardService.LoadScoreboards(challengeService.GetChallenges()); err != nil {
+		t.Fatal(err)
+	}
+	packageService := services.NewPackageService(cfg.PackagesDir)
+	packageService.SetGitHubStars(false)
+	if err := packageService.LoadPackages(); err != nil {
+		t.Fatal(err)
+	}
+	executionService, err := newExecutionService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executionService.SetWorkspaceRoot(t.TempDir())
+	submissionStore, err := services.NewFileSubmissionStore(cfg.DataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { submissionStore.Close() })
+	authService, err := auth.NewService(cfg.DataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authService.SetRequired(authRequired)
+	authService.SetAdminToken(testAdminToken)
+	teamService, err := services.NewTeamService(cfg.DataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interviewService, err := services.NewInterviewService(cfg.DataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorderService, err := services.NewRecorderService(cfg.DataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contentWatcher := services.NewContentWatcher(challengeService, scoreboardService, packageService)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go contentWatcher.Watch(ctx, 5*time.Millisecond)
+
+	srv := server.NewServer(
+		content,
+		challengeService,
+		scoreboardService,
+		services.NewUserService(),
+		executionService,
+		packageService,
+		services.NewJobService(cfg.Workers),
+		submissionStore,
+		contentWatcher,
+		authService,
+		teamService,
+		interviewService,
+		recorderService,
+		cfg.Features,
+	)
+	ts := httptest.NewServer(srv.SetupRoutes())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// request sends a request as a user and decodes a JSON response into out,
+// if not nil. Any status but 200 OK is an error.
+func request(ts *httptest.Server, username, method, path string, body interface{}, out interface{}) error {
+	return send(ts.Client(), ts, username, method, path, body, out)
+}
+
+// signIn registers a local account on the test server and returns a client
+// carrying its session
+func signIn(t *testing.T, ts *httptest.Server, username string) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Jar: jar}
+	credentials := map[string]string{"username": username, "password": "correct horse"}
+	if err := send(client, ts, "", "POST", "/api/auth/register", credentials, nil); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// testAdminToken is the admin token of the test servers
+const testAdminToken = "test-admin-token"
+
+// bearerToken is a transport sending a bearer token with every request
+type bearerToken string
+
+func (token bearerToken) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+string(token))
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// requestAs is request with the client of a signed-in user
+func requestAs(client *http.Client, ts *httptest.Server, method, path string, body interface{}, out interface{}) error {
+	return send(client, ts, "", method, path, body, out)
+}
+
+// send sends a request with a client, naming username in the username
+// cookie unless it is empty
+func send(client *http.Client, ts *httptest.Server, username, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, ts.URL+path, reader)
+	if err != nil {
+		return err
+	}
+	if username != "" {
+		req.AddCookie(&http.Cookie{Name: "username", Value: username})
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(data))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("%s %s: %v", method, path, err)
+		}
+	}
+	return nil
+}
+
+// TestConcurrentRequests hammers the handlers from many users at once while
+// the content is reloaded, as in a classroom. Run it with -race.
+func TestConcurrentRequests(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+	root := newTestRepo(t)
+	ts := newTestServer(t, root, false)
+
+	users := []string{"alice", "bob", "carol", "dave", "erin", "frank"}
+	reads := []string{
+		"/",
+		"/challenge/1",
+		"/scoreboard",
+		"/scoreboard/1",
+		"/api/challenges",
+		"/api/challenges/1",
+		"/api/scoreboard/1",
+		"/api/main-leaderboard",
+		"/api/main-scoreboard-rank?username=alice",
+		"/api/submissions?challengeId=1",
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 1000)
+	for i, username := range users {
+		wg.Add(1)
+		go func(i int, username string) {
+			defer wg.Done()
+
+			// Half of the users pass
+			code := testSolution
+			if i%2 == 1 {
+				code = testFailingSolution
+			}
+			submission := models.Submission{Username: username, ChallengeID: 1, Code: code}
+			if err := request(ts, username, "POST", "/api/submissions", submission, nil); err != nil {
+				errs <- err
+			}
+			save := services.SaveSubmissionRequest{Username: username, ChallengeID: 1, Code: code}
+			if err := request(ts, username, "POST", "/api/save-to-filesystem", save, nil); err != nil {
+				errs <- err
+			}
+			if err := request(ts, username, "POST", "/api/refresh-attempts", map[string]string{"username": username}, nil); err != nil {
+				errs <- err
+			}
+		}(i, username)
+
+		for worker := 0; worker < 3; worker++ {
+			wg.Add(1)
+			go func(username string) {
+				defer wg.Done()
+				for round := 0; round < 5; round++ {
+					for _, path := range reads {
+						if err := request(ts, username, "GET", path, nil, nil); err != nil {
+							errs <- err
+						}
+					}
+				}
+			}(username)
+		}
+	}
+
+	// Reloading needs the admin token, even from localhost
+	admin := &http.Client{Transport: bearerToken(testAdminToken)}
+	if err := request(ts, "", "POST", "/api/admin/reload", nil, nil); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("reloading without the admin token: %v, want 403 Forbidden", err)
+	}
+	if err := requestAs(&http.Client{Transport: bearerToken("wrong")}, ts, "POST", "/api/admin/reload", nil, nil); err == nil {
+		t.Errorf("reloading with a wrong admin token succeeded")
+	}
+
+	// Edits to the challenge and manual reloads race the requests
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		readme := filepath.Join(root, "challenge-1", "README.md")
+		for i := 0; i < 10; i++ {
+			content := fmt.Sprintf("# Challenge 1: Sum of Two Numbers\n\nWrite a function `Sum`. Edit %d.\n", i)
+			if err := os.WriteFile(readme, []byte(content), 0644); err != nil {
+				errs <- err
+			}
+			if err := requestAs(admin, ts, "POST", "/api/admin/reload", nil, nil); err != nil {
+				errs <- err
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if t.Failed() {
+		return
+	}
+
+	// Every user's submission was stored and the passing ones made the scoreboard
+	var scoreboard []models.ScoreboardEntry
+	if err := request(ts, "", "GET", "/api/scoreboard/1", nil, &scoreboard); err != nil {
+		t.Fatal(err)
+	}
+	listed := make(map[string]bool)
+	for _, entry := range scoreboard {
+		listed[entry.Username] = true
+	}
+	for i, username := range users {
+		if want := i%2 == 0; listed[username] != want {
+			t.Errorf("%s listed on the scoreboard: %v, want %v", username, listed[username], want)
+		}
+
+		var page services.SubmissionPage
+		if err := request(ts, "", "GET", "/api/submissions?username="+username, nil, &page); err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 1 {
+			t.Errorf("%s has %d stored submissions, want 1", username, page.Total)
+		}
+
+		var attempts struct {
+			AttemptedIDs map[int]bool `json:"attemptedIds"`
+		}
+		if err := request(ts, username, "POST", "/api/refresh-attempts", map[string]string{"username": username}, &attempts); err != nil {
+			t.Fatal(err)
+		}
+		if !attempts.AttemptedIDs[1] {
+			t.Errorf("%s has not attempted challenge 1 after saving a submission", username)
+		}
+	}
+}
+
+// TestWritesBindToSignedInUser checks that with signing in required, writes
+// need a session and count for the signed-in user whatever username they post
+func TestWritesBindToSignedInUser(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+	root := newTestRepo(t)
+	ts := newTestServer(t, root, true)
+
+	save := services.SaveSubmissionRequest{Username: "mallory", ChallengeID: 1, Code: testSolution}
+	submission := models.Submission{Username: "mallory", ChallengeID: 1, Code: testSolution}
+
+	// The username cookie alone is not trusted
+	for path, body := range map[string]interface{}{"/api/save-to-filesystem": save, "/api/submissions": submission} {
+		err := request(ts, "mallory", "POST", path, body, nil)
+		if err == nil || !bytes.Contains([]byte(err.Error()), []byte("401")) {
+			t.Errorf("POST %s without signing in: %v, want 401 Unauthorized", path, err)
+		}
+	}
+
+	// Requests of the signed-in client carry its session cookie
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+	credentials := map[string]string{"username": "bob", "password": "correct horse"}
+	if err := request(ts, "", "POST", "/api/auth/register", credentials, nil); err != nil {
+		t.Fatal(err)
+	}
+	var me struct {
+		Authenticated bool   `json:"authenticated"`
+		Username      string `json:"username"`
+	}
+	if err := request(ts, "", "GET", "/api/auth/me", nil, &me); err != nil {
+		t.Fatal(err)
+	}
+	if !me.Authenticated || me.Username != "bob" {
+		t.Fatalf("/api/auth/me after registering = %+v, want bob signed in", me)
+	}
+
+	if err := request(ts, "mallory", "POST", "/api/save-to-filesystem", save, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "challenge-1", "submissions", "bob", "solution-template.go")); err != nil {
+		t.Errorf("solution of the signed-in user was not saved: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "challenge-1", "submissions", "mallory")); !os.IsNotExist(err) {
+		t.Errorf("solution was saved for the posted username")
+	}
+
+	var record services.SubmissionRecord
+	if err := request(ts, "mallory", "POST", "/api/submissions", submission, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Username != "bob" {
+		t.Errorf("submission stored for %q, want bob", record.Username)
+	}
+
+	// Signing out ends the session
+	if err := request(ts, "", "POST", "/api/auth/logout", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := request(ts, "bob", "POST", "/api/save-to-filesystem", save, nil); err == nil {
+		t.Errorf("saving after signing out succeeded")
+	}
+}
+
+// TestSubmissionAccess checks that the code and results of a submission are
+// only shown to its owner and to the mentors of their teams
+func TestSubmissionAccess(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+	root := newTestRepo(t)
+	ts := newTestServer(t, root, false)
+	alice, bob, mallory := signIn(t, ts, "alice"), signIn(t, ts, "bob"), signIn(t, ts, "mallory")
+
+	var record services.SubmissionRecord
+	submission := models.Submission{ChallengeID: 1, Code: testSolution}
+	if err := requestAs(alice, ts, "POST", "/api/submissions", submission, &record); err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/api/submissions/%d", record.ID)
+	if err := requestAs(alice, ts, "GET", path, nil, nil); err != nil {
+		t.Errorf("owner reading their submission: %v", err)
+	}
+	if err := requestAs(mallory, ts, "GET", path, nil, nil); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("another user reading the submission: %v, want 404 Not Found", err)
+	}
+
+	// Mentors see the submissions of their team's members
+	var team models.Team
+	if err := requestAs(bob, ts, "POST", "/api/teams", map[string]string{"name": "Cohort"}, &team); err != nil {
+		t.Fatal(err)
+	}
+	if err := requestAs(alice, ts, "POST", "/api/teams/join", map[string]string{"inviteCode": team.InviteCode}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := requestAs(bob, ts, "GET", path, nil, nil); err != nil {
+		t.Errorf("team owner reading a member's submission: %v", err)
+	}
+
+	// Attempt lists and diffs follow the same rule
+	var second services.SubmissionRecord
+	if err := requestAs(alice, ts, "POST", "/api/submissions", models.Submission{ChallengeID: 1, Code: testFailingSolution}, &second); err != nil {
+		t.Fatal(err)
+	}
+	diff := fmt.Sprintf("/api/attempts/%d/diff/%d", record.ID, second.ID)
+	for _, path := range []string{"/api/users/alice/challenges/1/attempts", diff} {
+		for name, client := range map[string]*http.Client{"alice": alice, "bob": bob} {
+			if err := requestAs(client, ts, "GET", path, nil, nil); err != nil {
+				t.Errorf("GET %s as %s: %v", path, name, err)
+			}
+		}
+		if err := requestAs(mallory, ts, "GET", path, nil, nil); err == nil || !strings.Contains(err.Error(), "404") {
+			t.Errorf("GET %s as another user: %v, want 404 Not Found", path, err)
+		}
+	}
+}
+
+// TestTeamLeaderboards checks that teams are joined with their invite code
+// and that their leaderboards rank the members only, for members only
+func TestTeamLeaderboards(t *testing.T) {
+	root := newTestRepo(t)
+	ts := newTestServer(t, root, false)
+	alice, bob, carol := signIn(t, ts, "alice"), signIn(t, ts, "bob"), signIn(t, ts, "carol")
+
+	// The username cookie alone does not manage teams
+	if err := request(ts, "bob", "POST", "/api/teams", map[string]string{"name": "Spring Cohort"}, nil); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("creating a team without signing in: %v, want 401 Unauthorized", err)
+	}
+
+	var team models.Team
+	if err := requestAs(bob, ts, "POST", "/api/teams", map[string]string{"name": "Spring Cohort"}, &team); err != nil {
+		t.Fatal(err)
+	}
+	if team.ID != "spring-cohort" || team.InviteCode == "" || team.RoleOf("bob") != models.RoleOwner {
+		t.Fatalf("created team = %+v, want spring-cohort owned by bob with an invite code", team)
+	}
+	var joined models.Team
+	if err := requestAs(alice, ts, "POST", "/api/teams/join", map[string]string{"inviteCode": strings.ToLower(team.InviteCode)}, &joined); err != nil {
+		t.Fatal(err)
+	}
+	if joined.RoleOf("alice") != models.RoleMember || joined.InviteCode != "" {
+		t.Errorf("joined team = %+v, want alice as a member without the invite code", joined)
+	}
+	if err := requestAs(carol, ts, "POST", "/api/teams/join", map[string]string{"inviteCode": "WRONG"}, nil); err == nil {
+		t.Errorf("joining with a wrong invite code succeeded")
+	}
+
+	// Only alice has completed a challenge; carol is not in the team
+	var everyone, scoped struct {
+		Leaderboard []handlers.LeaderboardUser `json:"leaderboard"`
+	}
+	if err := requestAs(carol, ts, "GET", "/api/main-leaderboard", nil, &everyone); err != nil {
+		t.Fatal(err)
+	}
+	if len(everyone.Leaderboard) == 0 {
+		t.Fatalf("main leaderboard is empty")
+	}
+	if err := requestAs(bob, ts, "GET", "/api/main-leaderboard?team=spring-cohort", nil, &scoped); err != nil {
+		t.Fatal(err)
+	}
+	if len(scoped.Leaderboard) != 1 || scoped.Leaderboard[0].Username != "alice" || scoped.Leaderboard[0].Rank != 1 {
+		t.Errorf("team leaderboard = %+v, want alice ranked first", scoped.Leaderboard)
+	}
+	var scoreboard []models.ScoreboardEntry
+	if err := requestAs(alice, ts, "GET", "/api/scoreboard/1?team=spring-cohort", nil, &scoreboard); err != nil {
+		t.Fatal(err)
+	}
+	if len(scoreboard) != 1 || scoreboard[0].Username != "alice" {
+		t.Errorf("team scoreboard of challenge 1 = %+v, want alice", scoreboard)
+	}
+
+	for _, path := range []string{"/api/main-leaderboard?team=spring-cohort", "/api/scoreboard/1?team=spring-cohort", "/api/teams/spring-cohort", "/teams/spring-cohort"} {
+		err := requestAs(carol, ts, "GET", path, nil, nil)
+		if err == nil || !strings.Contains(err.Error(), "403") {
+			t.Errorf("GET %s as a non-member: %v, want 403 Forbidden", path, err)
+		}
+	}
+	if err := requestAs(alice, ts, "GET", "/teams/spring-cohort", nil, nil); err != nil {
+		t.Errorf("team dashboard for a member: %v", err)
+	}
+
+	// Members cannot manage the team, and its last owner cannot leave
+	if err := requestAs(alice, ts, "DELETE", "/api/teams/spring-cohort/members/bob", nil, nil); err == nil {
+		t.Errorf("a member removed the owner")
+	}
+	if err := requestAs(bob, ts, "POST", "/api/teams/spring-cohort/leave", nil, nil); err == nil {
+		t.Errorf("the last owner left the team")
+	}
+	if err := requestAs(bob, ts, "PUT", "/api/teams/spring-cohort/members/alice", map[string]string{"role": "owner"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := requestAs(bob, ts, "POST", "/api/teams/spring-cohort/leave", nil, nil); err != nil {
+		t.Errorf("leaving after handing over ownership: %v", err)
+	}
+}
+
+// TestInterviewSessions checks that a candidate link starts its session only
+// once, that attempts are rejected once the session ended and that the
+// report holds what the candidate did
+func TestInterviewSessions(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+	root := newTestRepo(t)
+	ts := newTestServer(t, root, false)
+	bob, mallory := signIn(t, ts, "bob"), signIn(t, ts, "mallory")
+
+	var created struct {
+		Session services.InterviewSession `json:"session"`
+		Link    string                    `json:"link"`
+	}
+	create := map[string]interface{}{"candidate": "Jane", "timeLimitMinutes": 30, "challenges": []map[string]int{{"challengeId": 1}}}
+	if err := request(ts, "bob", "POST", "/api/interviews", create, nil); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("creating an interview without signing in: %v, want 401 Unauthorized", err)
+	}
+	if err := requestAs(bob, ts, "POST", "/api/interviews", create, &created); err != nil {
+		t.Fatal(err)
+	}
+	id := created.Session.ID
+	link := strings.TrimPrefix(created.Link, ts.URL)
+	if !strings.HasPrefix(link, "/interview/") {
+		t.Fatalf("candidate link = %q, want one on the test server", created.Link)
+	}
+
+	// The candidate's browser keeps the key the link gives it
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+	if err := request(ts, "", "GET", link, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := request(ts, "", "POST", link+"/start", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := request(ts, "", "POST", link+"/start", nil, nil); err == nil || !strings.Contains(err.Error(), "410") {
+		t.Errorf("starting a session twice: %v, want 410 Gone", err)
+	}
+
+	// Edits in the editor are recorded: "é" is one UTF-16 code unit
+	start := time.Now().UnixMilli()
+	edits := map[string]interface{}{"challenge": 0, "sentAt": start, "ops": []services.EditOp{
+		{Time: start, Type: services.EditReset, Text: "package main\n"},
+		{Time: start, Type: services.EditInsert, Offset: 13, Text: "// é\n"},
+		{Time: start, Type: services.EditDelete, Offset: 16, Length: 1},
+	}}
+	if err := request(ts, "", "POST", "/api/interviews/"+id+"/edits", edits, nil); err != nil {
+		t.Fatal(err)
+	}
+	outOfRange := map[string]interface{}{"challenge": 0, "ops": []services.EditOp{{Time: start, Type: services.EditDelete, Offset: 10, Length: 10}}}
+	if err := request(ts, "", "POST", "/api/interviews/"+id+"/edits", outOfRange, nil); err == nil || !strings.Contains(err.Error(), "409") {
+		t.Errorf("edit past the end of the code: %v, want 409 Conflict", err)
+	}
+
+	var attempt services.InterviewAttempt
+	if err := request(ts, "", "POST", "/api/interviews/"+id+"/submit", map[string]interface{}{"challenge": 0, "code": testSolution}, &attempt); err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Result == nil || !attempt.Result.Passed {
+		t.Errorf("submitted attempt = %+v, want passed", attempt)
+	}
+	if err := request(ts, "", "GET", "/api/interviews/"+id+"/report", nil, nil); err == nil {
+		t.Errorf("the candidate read the report")
+	}
+
+	// The timeline has the test run after the edits, and replays the code
+	var timeline services.Timeline
+	if err := requestAs(bob, ts, "GET", "/api/interviews/"+id+"/timeline?challenge=0", nil, &timeline); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(timeline.Entries); n != 4 || timeline.Entries[n-1].Run == nil || !timeline.Entries[n-1].Run.Passed {
+		t.Errorf("timeline = %+v, want 3 edits and the passing submission", timeline.Entries)
+	}
+	var replay services.ReplayState
+	if err := requestAs(bob, ts, "GET", "/api/interviews/"+id+"/replay?challenge=0", nil, &replay); err != nil {
+		t.Fatal(err)
+	}
+	if replay.Code != "package main\n// \n" || replay.Edits != 3 || replay.Runs != 1 {
+		t.Errorf("replay now = %+v, want the edited code and 1 run", replay)
+	}
+	if err := requestAs(bob, ts, "GET", fmt.Sprintf("/api/interviews/%s/replay?challenge=0&at=%d", id, start-1), nil, &replay); err != nil {
+		t.Fatal(err)
+	}
+	if replay.Code != "" || replay.Edits != 0 {
+		t.Errorf("replay before the edits = %+v, want no code", replay)
+	}
+
+	// Once the interviewer ends the session, nothing more is accepted
+	if err := requestAs(bob, ts, "POST", "/api/interviews/"+id+"/end", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	for path, method := range map[string]string{"/submit": "POST", "/run": "POST", "/draft": "PUT"} {
+		err := request(ts, "", method, "/api/interviews/"+id+path, map[string]interface{}{"challenge": 0, "code": testFailingSolution}, nil)
+		if err == nil || !strings.Contains(err.Error(), "410") {
+			t.Errorf("%s %s after the end: %v, want 410 Gone", method, path, err)
+		}
+	}
+
+	var report services.InterviewReport
+	if err := requestAs(bob, ts, "GET", "/api/interviews/"+id+"/report", nil, &report); err != nil {
+		t.Fatal(err)
+	}
+	if !report.Final || report.EndReason != services.InterviewCanceled || report.Solved != 1 {
+		t.Errorf("report = %+v, want a final report of a canceled session with 1 solved", report)
+	}
+	solved := report.Challenges[0]
+	if len(solved.Attempts) != 1 || solved.SolvedAfterMs == nil || solved.FinalCode != testSolution || solved.TestsPassed != 2 {
+		t.Errorf("report of challenge 1 = %+v, want the passing submission", solved)
+	}
+	if err := requestAs(mallory, ts, "GET", "/api/interviews/"+id+"/report", nil, nil); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("report for someone else: %v, want 403 Forbidden", err)
+	}
+}
@@ -484,6 +484,254 @@ function formatTestOutput(output) {
         .replace(/--- PASS/g, '<span class="text-success">--- PASS</span>');
 }
 
+// Run code as a background job and follow its output. The request body is
+// sent with async set; onLine receives each output line as it is produced and
+// the returned promise resolves with the job's result.
+function runJob(url, body, onLine) {
+    return fetch(url, {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify(Object.assign({}, body, { async: true }))
+    })
+    .then(response => {
+        if (!response.ok) {
+            return response.text().then(text => { throw new Error(text || response.statusText); });
+        }
+        return response.json();
+    })
+    .then(accepted => new Promise((resolve, reject) => {
+        const source = new EventSource(accepted.streamUrl);
+        source.addEventListener('line', event => {
+            if (onLine) onLine(JSON.parse(event.data));
+        });
+        source.addEventListener('done', event => {
+            source.close();
+            const job = JSON.parse(event.data);
+            if (job.status === 'canceled') {
+                reject(new Error('The run was canceled'));
+            } else {
+                resolve(job.result);
+            }
+        });
+        source.onerror = () => {
+            // EventSource reconnects by itself while the job is still running;
+            // give up only if the job is gone
+            if (source.readyState === EventSource.CLOSED) {
+                reject(new Error('Lost connection to the job stream'));
+            }
+        };
+    }));
+}
+
+// Append a streamed output line to a <pre> element and keep it scrolled down
+function appendOutputLine(element, line) {
+    if (!element) return;
+    element.textContent += line.text + '\n';
+    element.scrollTop = element.scrollHeight;
+}
+
+// Render the per-test results of a run as a checklist
+function renderTestChecklist(report) {
+    if (!report) return '';
+
+    if (report.buildFailed) {
+        return `<div class="alert alert-warning mb-3">
+            <strong>Build failed</strong> - no tests were run. See the compiler output below.
+        </div>`;
+    }
+
+    const icons = {
+        pass: '<span class="text-success">✔</span>',
+        fail: '<span class="text-danger">✘</span>',
+        skip: '<span class="text-muted">⊘</span>',
+        incomplete: '<span class="text-warning">…</span>'
+    };
+
+    function renderTests(tests, depth) {
+        return tests.map(test => {
+            const name = depth > 0 ? test.name.split('/').slice(depth).join('/') : test.name;
+            const details = test.status === 'fail' && test.output && !(test.subtests && test.subtests.length)
+                ? `<pre class="small bg-light p-2 mt-1 mb-1 rounded"><code>${escapeHtml(test.output)}</code></pre>`
+                : '';
+            return `<li class="list-group-item py-1" style="padding-left: ${1 + depth * 1.5}rem">
+                    ${icons[test.status] || ''} <code>${escapeHtml(name)}</code>
+                    <small class="text-muted">${formatExecutionTime(test.elapsedMs)}</small>
+                    ${details}
+                </li>` + renderTests(test.subtests || [], depth + 1);
+        }).join('');
+    }
+
+    return `<div class="card mb-3">
+        <div class="card-header d-flex justify-content-between">
+            <span>Tests</span>
+            <span>${report.passed}/${report.total} passed${report.skipped ? `, ${report.skipped} skipped` : ''}</span>
+        </div>
+        <ul class="list-group list-group-flush">${renderTests(report.tests || [], 0)}</ul>
+    </div>`;
+}
+
+// Render the data races found by the race detector, pointing at the lines of
+// the solution involved
+function renderRaceReports(races) {
+    if (!races || !races.length) return '';
+
+    function renderStack(stack) {
+        return stack.map(frame => `<div class="${frame.solution ? 'fw-bold' : 'text-muted'}">
+                <code>${escapeHtml(frame.function)}</code> ${escapeHtml(frame.file)}:${frame.line}
+            </div>`).join('');
+    }
+
+    const items = races.map((race, i) => {
+        const accesses = race.accesses.map(access => `<div class="mb-2">
+                <div>${access.previous ? 'Previous ' : ''}${escapeHtml(access.operation)} by ${access.goroutine ? `goroutine ${access.goroutine}` : 'main goroutine'}</div>
+                <div class="ps-3 small">${renderStack(access.stack)}</div>
+            </div>`).join('');
+        const lines = race.solutionLines.length
+            ? `solution lines ${race.solutionLines.join(', ')}`
+            : 'outside the solution';
+        return `<li class="list-group-item">
+                <div class="mb-2"><strong>Race ${i + 1}</strong>${race.test ? ` in <code>${escapeHtml(race.test)}</code>` : ''}, ${lines}</div>
+                ${accesses}
+            </li>`;
+    }).join('');
+
+    return `<div class="card mb-3 border-danger">
+        <div class="card-header text-danger">Data races (${races.length})</div>
+        <ul class="list-group list-group-flush">${items}</ul>
+    </div>`;
+}
+
+// Render the benchmark results of a run and their comparison to the baseline
+function renderBenchmarkReport(benchmark) {
+    if (!benchmark) return '';
+
+    const formatNs = ns => ns >= 1e6 ? `${(ns / 1e6).toFixed(2)} ms` : ns >= 1e3 ? `${(ns / 1e3).toFixed(2)} µs` : `${ns.toFixed(1)} ns`;
+    let html = '';
+
+    if (benchmark.message) {
+        html += `<div class="alert alert-warning mb-3">${escapeHtml(benchmark.message)}</div>`;
+    }
+
+    (benchmark.comparisons || []).forEach(comparison => {
+        const rows = (comparison.cases || []).map(c => `<tr>
+                <td><code>${escapeHtml(c.name || comparison.optimized)}</code></td>
+                <td>${formatNs(c.baselineNsPerOp)}</td>
+                <td>${formatNs(c.nsPerOp)}</td>
+                <td>${c.bytesPerOp} B / ${c.allocsPerOp} allocs</td>
+                <td>${c.speedup.toFixed(2)}x</td>
+            </tr>`).join('');
+        const failures = (comparison.failures || []).map(f => `<li>${escapeHtml(f)}</li>`).join('');
+        html += `<div class="card mb-3">
+            <div class="card-header d-flex justify-content-between">
+                <span>${comparison.passed ? '<span class="text-success">✔</span>' : '<span class="text-danger">✘</span>'}
+                    <code>${escapeHtml(comparison.optimized)}</code> vs <code>${escapeHtml(comparison.baseline)}</code></span>
+                <span>${comparison.speedup.toFixed(2)}x${comparison.minSpeedup ? ` (at least ${comparison.minSpeedup}x)` : ''}</span>
+            </div>
+            <table class="table table-sm mb-0">
+                <thead><tr><th>Case</th><th>Baseline</th><th>Solution</th><th>Memory per op</th><th>Speedup</th></tr></thead>
+                <tbody>${rows}</tbody>
+            </table>
+            ${failures ? `<ul class="text-danger small mb-2 mt-2">${failures}</ul>` : ''}
+        </div>`;
+    });
+
+    if (!(benchmark.comparisons || []).length && (benchmark.results || []).length) {
+        const rows = benchmark.results.map(r => `<tr>
+                <td><code>${escapeHtml(r.name)}</code></td>
+                <td>${formatNs(r.nsPerOp)}</td>
+                <td>${r.bytesPerOp} B / ${r.allocsPerOp} allocs</td>
+            </tr>`).join('');
+        html += `<div class="card mb-3">
+            <div class="card-header">Benchmarks</div>
+            <table class="table table-sm mb-0">
+                <thead><tr><th>Benchmark</th><th>Time per op</th><th>Memory per op</th></tr></thead>
+                <tbody>${rows}</tbody>
+            </table>
+        </div>`;
+    }
+
+    return html;
+}
+
+// Shade the lines of an Ace editor by their coverage status. The shading is
+// removed on the next edit.
+function showCoverage(editor, coverage) {
+    clearCoverage(editor);
+    const Range = ace.require('ace/range').Range;
+    const session = editor.session;
+    session.coverageMarkers = (coverage.lines || []).map(line =>
+        session.addMarker(new Range(line.line - 1, 0, line.line - 1, 1), `coverage-${line.status}`, 'fullLine'));
+    session.once('change', () => clearCoverage(editor));
+}
+
+// Remove the coverage shading of an Ace editor
+function clearCoverage(editor) {
+    const session = editor.session;
+    (session.coverageMarkers || []).forEach(id => session.removeMarker(id));
+    session.coverageMarkers = [];
+}
+
+// Underline compiler errors and vet findings of solution-template.go in an Ace
+// editor and mark their lines in the gutter. Both are removed on the next edit.
+function showDiagnostics(editor, diagnostics) {
+    clearDiagnostics(editor);
+    const Range = ace.require('ace/range').Range;
+    const session = editor.session;
+    const own = (diagnostics || []).filter(d => d.file === 'solution-template.go' && d.line > 0);
+
+    session.setAnnotations(own.map(d => ({
+        row: d.line - 1,
+        column: Math.max(d.column - 1, 0),
+        text: d.analyzer ? `${d.message} (${d.analyzer})` : d.message,
+        type: d.severity === 'error' ? 'error' : 'warning'
+    })));
+    session.diagnosticMarkers = own.map(d => {
+        const row = d.line - 1;
+        const column = Math.max(d.column - 1, 0);
+        let range = new Range(row, column, row, column + 1);
+        if (d.endLine && (d.endLine > d.line || d.endColumn > d.column)) {
+            range = new Range(row, column, d.endLine - 1, d.endColumn - 1);
+        } else {
+            // Without an end position, underline the word at the position
+            const word = session.getWordRange(row, column);
+            if (!word.isEmpty()) range = word;
+        }
+        return session.addMarker(range, `diagnostic-${d.severity}`, 'text');
+    });
+    session.once('change', () => clearDiagnostics(editor));
+}
+
+// Remove the diagnostics shown in an Ace editor
+function clearDiagnostics(editor) {
+    const session = editor.session;
+    (session.diagnosticMarkers || []).forEach(id => session.removeMarker(id));
+    session.diagnosticMarkers = [];
+    session.clearAnnotations();
+}
+
+// Render compiler errors and vet findings as a list linking to their lines
+function renderDiagnostics(diagnostics) {
+    if (!diagnostics || !diagnostics.length) return '';
+
+    const items = diagnostics.map(d => {
+        const position = d.file ? `${escapeHtml(d.file)}${d.line ? `:${d.line}${d.column ? `:${d.column}` : ''}` : ''}` : '';
+        const link = d.file === 'solution-template.go' && d.line
+            ? `<a href="#" class="diagnostic-link" data-line="${d.line}" data-column="${d.column || 1}">${position}</a>`
+            : position;
+        return `<li class="list-group-item small">
+                <span class="badge ${d.severity === 'error' ? 'bg-danger' : 'bg-warning text-dark'} me-2">${d.severity}</span>
+                ${link ? `<code class="me-2">${link}</code>` : ''}${escapeHtml(d.message)}${d.analyzer ? ` <span class="text-muted">(${escapeHtml(d.analyzer)})</span>` : ''}
+            </li>`;
+    }).join('');
+
+    return `<div class="card mb-3">
+        <div class="card-header">Problems (${diagnostics.length})</div>
+        <ul class="list-group list-group-flush">${items}</ul>
+    </div>`;
+}
+
 // Handle form submissions with AJAX
 function handleFormSubmit(formElement, successCallback, errorCallback) {
     formElement.addEventListener('submit', function(e) {